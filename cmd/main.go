@@ -4,14 +4,39 @@ import (
 	"attendance/backend/foundation/web"
 	"attendance/backend/internal/auth"
 	"attendance/backend/internal/commands"
+	"attendance/backend/internal/controller/http/v1/health"
+	"attendance/backend/internal/jobs"
+	"attendance/backend/internal/mailer"
+	"attendance/backend/internal/metrics"
+	"attendance/backend/internal/middleware"
+	"attendance/backend/internal/notification"
+	"attendance/backend/internal/notifier"
 	"attendance/backend/internal/pkg/config"
 	"attendance/backend/internal/pkg/repository/postgresql"
+	"attendance/backend/internal/realtime"
+	"attendance/backend/internal/repository/postgres/attendance"
+	job_postgres "attendance/backend/internal/repository/postgres/job"
+	notification_postgres "attendance/backend/internal/repository/postgres/notification"
+	"attendance/backend/internal/repository/postgres/user"
+	webhook_postgres "attendance/backend/internal/repository/postgres/webhook"
 	"attendance/backend/internal/router"
+	"attendance/backend/internal/scheduler"
+	"attendance/backend/internal/storage"
+	"attendance/backend/internal/webhook"
+	"context"
 	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
 	"expvar"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
+	_ "net/http/pprof"
+	"net/url"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
 	"time"
 
 	"github.com/ardanlabs/conf"
@@ -41,30 +66,74 @@ var build = "develop"
 // @in header
 // @name Authorization
 func main() {
-	logger := log.New(os.Stdout, "SALES : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	// The level is raised or lowered once the configuration is parsed.
+	var level slog.LevelVar
+	log := web.NewLogger(os.Stdout, &level, "attendance-api")
+	slog.SetDefault(log)
 
-	if err := run(logger); err != nil {
-		log.Println("main: error:", err)
+	if err := run(log, &level); err != nil {
+		log.Error("main: error", "error", err)
 		os.Exit(1)
 	}
 
 }
 
-func run(log *log.Logger) error {
+func run(log *slog.Logger, level *slog.LevelVar) error {
 
 	// =========================================================================
 	// Configuration
 
 	var cfg struct {
 		conf.Version
-		DefaultLang   string `conf:"default:uz"`
-		ServerPort    string `conf:"default:8080"`
-		Web           struct {
+		DefaultLang string `conf:"default:uz"`
+		ServerPort  string `conf:"default:8080"`
+		Log         struct {
+			Level string `conf:"default:info"`
+		}
+		Web struct {
 			APIHost         string        `conf:"default:0.0.0.0:3000"`
 			DebugHost       string        `conf:"default:0.0.0.0:4000"`
 			ReadTimeout     time.Duration `conf:"default:50s"`
 			WriteTimeout    time.Duration `conf:"default:50s"`
+			IdleTimeout     time.Duration `conf:"default:120s"`
 			ShutdownTimeout time.Duration `conf:"default:50s"`
+			WSOrigins       []string
+		}
+		Scheduler struct {
+			Interval time.Duration `conf:"default:1m"`
+		}
+		Jobs struct {
+			Workers      int           `conf:"default:2"`
+			PerKind      int           `conf:"default:1"`
+			PollInterval time.Duration `conf:"default:2s"`
+			Heartbeat    time.Duration `conf:"default:5s"`
+			Lease        time.Duration `conf:"default:1m"`
+			MaxAttempts  int           `conf:"default:3"`
+		}
+		Checkout struct {
+			Enabled bool          `conf:"default:true"`
+			Policy  string        `conf:"default:end_time"`
+			Delay   time.Duration `conf:"default:30m"`
+		}
+		Notification struct {
+			Channels  []string `conf:"default:inbox;email"`
+			Reminders bool     `conf:"default:true"`
+			Days      []string `conf:"default:Mon;Tue;Wed;Thu;Fri"`
+		}
+		Mail struct {
+			Host     string
+			Port     string `conf:"default:587"`
+			User     string
+			Password string `conf:"noprint"`
+			From     string
+		}
+		Webhook struct {
+			Workers      int           `conf:"default:4"`
+			PollInterval time.Duration `conf:"default:2s"`
+			Timeout      time.Duration `conf:"default:10s"`
+			MaxAttempts  int           `conf:"default:8"`
+			BaseBackoff  time.Duration `conf:"default:30s"`
+			MaxBackoff   time.Duration `conf:"default:6h"`
 		}
 		Auth struct {
 			KeyID          string `conf:"default:54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"`
@@ -83,11 +152,44 @@ func run(log *log.Logger) error {
 			ServiceName string  `conf:"default:sale-api"`
 			Probability float64 `conf:"default:0.05"`
 		}
+		Storage struct {
+			Driver      string `conf:"default:local"`
+			Dir         string `conf:"default:./media"`
+			S3Endpoint  string
+			S3Region    string `conf:"default:us-east-1"`
+			S3Bucket    string
+			S3AccessKey string
+			S3SecretKey string `conf:"noprint"`
+			S3PathStyle bool   `conf:"default:true"`
+		}
+		Media struct {
+			SigningKey string        `conf:"noprint"`
+			LinkTTL    time.Duration `conf:"default:15m"`
+			Public     []string      `conf:"default:company_info"`
+		}
 		Redis struct {
 			Host string `conf:"default:localhost"`
 			Port string `conf:"default:6379"`
 			DB   int    `conf:"default:0"`
 		}
+		Notify struct {
+			MinStatus     int           `conf:"default:500"`
+			BatchSize     int           `conf:"default:10"`
+			FlushInterval time.Duration `conf:"default:5s"`
+			RateLimit     int           `conf:"default:20"`
+			RatePeriod    time.Duration `conf:"default:1m"`
+			TelegramURL   string        `conf:"default:https://api.telegram.org"`
+			TelegramToken string        `conf:"noprint"`
+			TelegramChats []string
+			WebhookURL    string `conf:"noprint"`
+			SMTPHost      string
+			SMTPPort      string `conf:"default:587"`
+			SMTPUser      string
+			SMTPPassword  string `conf:"noprint"`
+			MailFrom      string
+			MailTo        []string
+			File          string
+		}
 	}
 	cfg.Version.SVN = build
 	cfg.Version.Desc = "copyright information here"
@@ -112,24 +214,26 @@ func run(log *log.Logger) error {
 		return errors.Wrap(err, "parsing config")
 	}
 
+	level.Set(web.ParseLevel(cfg.Log.Level))
+
 	// =========================================================================
 	// App Starting
 
 	// Print the build version for our logs. Also expose it under /debug/vars.
 	expvar.NewString("build").Set(build)
-	log.Printf("main : Started : Application initializing : version %q", build)
-	defer log.Println("main: Completed")
+	log.Info("main: Started: Application initializing", "version", build)
+	defer log.Info("main: Completed")
 
 	out, err := conf.String(&cfg)
 	if err != nil {
 		return errors.Wrap(err, "generating config for output")
 	}
-	log.Printf("main: Config :\n%v\n", out)
+	log.Info("main: Config", "config", out)
 
 	// =========================================================================
 	// Initialize authentication support
 
-	log.Println("main : Started : Initializing authentication support")
+	log.Info("main: Started: Initializing authentication support")
 
 	privatePEM, err := os.ReadFile(cfg.Auth.PrivateKeyFile)
 	if err != nil {
@@ -157,11 +261,11 @@ func run(log *log.Logger) error {
 	// =========================================================================
 	// Start Database: postgresql
 
-	log.Println("main: Initializing database support")
+	log.Info("main: Initializing database support")
 
 	yamlConfig, err := config.NewConfig() // Call the exported function
 	if err != nil {
-		fmt.Printf("Error loading configuration: %v", err)
+		return errors.Wrap(err, "loading configuration")
 	}
 
 	postgresDB := postgresql.NewDB(postgresql.Config{
@@ -177,35 +281,344 @@ func run(log *log.Logger) error {
 		return errors.Wrap(err, "connecting to db")
 	}
 	defer func() {
-		log.Printf("main: Database Stopping : %s", cfg.Postgres.Host)
+		log.Info("main: Database Stopping", "host", yamlConfig.DBHost)
 		postgresDB.Close()
 	}()
 
 	// =====================
 
+	// =========================================================================
+	// Start Storage
+	//
+	// Uploads and generated files are kept in a directory or an S3 bucket, so
+	// every instance sees the same files.
+
+	log.Info("main: Initializing storage", "driver", cfg.Storage.Driver)
+
+	files, err := storage.New(storage.Config{
+		Driver: cfg.Storage.Driver,
+		Dir:    cfg.Storage.Dir,
+		S3: storage.S3Config{
+			Endpoint:  cfg.Storage.S3Endpoint,
+			Region:    cfg.Storage.S3Region,
+			Bucket:    cfg.Storage.S3Bucket,
+			AccessKey: cfg.Storage.S3AccessKey,
+			SecretKey: cfg.Storage.S3SecretKey,
+			PathStyle: cfg.Storage.S3PathStyle,
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "opening storage")
+	}
+
+	// Without a key of their own, media links are signed with one derived
+	// from the auth key, which every instance already shares.
+	signingKey := []byte(cfg.Media.SigningKey)
+	if len(signingKey) == 0 {
+		sum := sha256.Sum256(append([]byte("media links\n"), x509.MarshalPKCS1PrivateKey(privateKey)...))
+		signingKey = sum[:]
+	}
+	signer, err := storage.NewSigner(storage.SignerConfig{
+		Secret: signingKey,
+		TTL:    cfg.Media.LinkTTL,
+		Public: cfg.Media.Public,
+	})
+	if err != nil {
+		return errors.Wrap(err, "constructing media link signer")
+	}
+
 	// =========================================================================
 	// Start Cache: redis
 
-	log.Println("main: Initializing cache support")
+	log.Info("main: Initializing cache support")
 
 	redisDB := redis.NewClient(&redis.Options{
 		Addr:     fmt.Sprintf("%s:%s", cfg.Redis.Host, cfg.Redis.Port),
 		Password: "",
 		DB:       cfg.Redis.DB,
 	})
+	defer func() {
+		log.Info("main: Cache Stopping", "host", cfg.Redis.Host)
+		redisDB.Close()
+	}()
+
+	// =========================================================================
+	// Start Error Notifier
 
-	// ======================
+	log.Info("main: Initializing error notifier")
 
+	// The bot credentials in config.yaml are still honoured when they are not
+	// given through flags or the environment.
+	if cfg.Notify.TelegramToken == "" {
+		cfg.Notify.TelegramToken = yamlConfig.ErrorBotToken
+		cfg.Notify.TelegramChats = yamlConfig.ErrorChatID
+	}
+
+	var sinks []notifier.Sink
+	if cfg.Notify.TelegramToken != "" && len(cfg.Notify.TelegramChats) > 0 {
+		sinks = append(sinks, notifier.NewTelegram(cfg.Notify.TelegramURL, cfg.Notify.TelegramToken, cfg.Notify.TelegramChats))
+	}
+	if cfg.Notify.WebhookURL != "" {
+		sinks = append(sinks, notifier.NewWebhook(cfg.Notify.WebhookURL))
+	}
+	if cfg.Notify.SMTPHost != "" && len(cfg.Notify.MailTo) > 0 {
+		sinks = append(sinks, &notifier.Email{
+			Host:     cfg.Notify.SMTPHost,
+			Port:     cfg.Notify.SMTPPort,
+			Username: cfg.Notify.SMTPUser,
+			Password: cfg.Notify.SMTPPassword,
+			From:     cfg.Notify.MailFrom,
+			To:       cfg.Notify.MailTo,
+		})
+	}
+	if cfg.Notify.File != "" {
+		sinks = append(sinks, notifier.NewFile(cfg.Notify.File))
+	}
+	if len(sinks) == 0 {
+		log.Info("main: no error notifier sink configured")
+	}
+
+	alerts := notifier.New(notifier.Config{
+		MinStatus:     cfg.Notify.MinStatus,
+		BatchSize:     cfg.Notify.BatchSize,
+		FlushInterval: cfg.Notify.FlushInterval,
+		RateLimit:     cfg.Notify.RateLimit,
+		RatePeriod:    cfg.Notify.RatePeriod,
+	}, sinks...)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := alerts.Close(ctx); err != nil {
+			log.Error("main: flushing error notifier", "error", err)
+		}
+	}()
+
+	// =========================================================================
+	// Start Debug Service
+	//
+	// /debug/pprof - Added to the default mux by importing the net/http/pprof package.
+	// /debug/vars - Added to the default mux by importing the expvar package.
+	// /metrics - Prometheus metrics, kept off the public API.
+
+	log.Info("main: Initializing debugging support")
+
+	appMetrics := metrics.New(postgresDB.DB.DB)
+	http.Handle("/metrics", appMetrics.Handler())
+
+	go func() {
+		log.Info("main: Debug Listening", "host", cfg.Web.DebugHost)
+		if err := http.ListenAndServe(cfg.Web.DebugHost, http.DefaultServeMux); err != nil {
+			log.Error("main: Debug Listener closed", "error", err)
+		}
+	}()
+
+	// =========================================================================
+	// Start API Service
+
+	log.Info("main: Initializing API support")
+
+	// Make a channel to listen for an interrupt or terminate signal from the OS.
+	// Use a buffered channel because the signal package requires it.
 	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
 	// gin engine
-	webApp := web.NewApp(shutdown, cfg.DefaultLang)
+	webApp := web.NewApp(shutdown, cfg.DefaultLang, middleware.RequestID(), middleware.Logger(log), middleware.Metrics(appMetrics), middleware.Alert(alerts, health.LivenessPath, health.ReadinessPath), middleware.Errors(), middleware.Panics())
 
 	// migrations
 	commands.MigrateUP(postgresDB)
 	//commands.Migrate(postgresDB)
 
-	r := router.NewRouter(webApp, postgresDB, redisDB, fmt.Sprintf(":%s", cfg.ServerPort), auth, yamlConfig.BaseUrl)
+	// =========================================================================
+	// Start Realtime Hub
+	//
+	// One LISTEN connection feeds every dashboard stream, another every
+	// notification stream.
+
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	defer stopHub()
+
+	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+		url.QueryEscape(yamlConfig.DBUsername), url.QueryEscape(yamlConfig.DBPassword), yamlConfig.DBHost, yamlConfig.DBPort, yamlConfig.DBName)
+
+	hub := realtime.NewHub(realtime.Config{DSN: dsn}, user.NewRepository(postgresDB, files, signer))
+	go hub.Run(hubCtx)
+
+	inbox := realtime.NewInbox(dsn)
+	go inbox.Run(hubCtx)
+
+	// =========================================================================
+	// Start Webhook Dispatcher
+
+	webhooks := webhook.NewDispatcher(webhook.Config{
+		Workers:      cfg.Webhook.Workers,
+		PollInterval: cfg.Webhook.PollInterval,
+		Timeout:      cfg.Webhook.Timeout,
+		MaxAttempts:  cfg.Webhook.MaxAttempts,
+		BaseBackoff:  cfg.Webhook.BaseBackoff,
+		MaxBackoff:   cfg.Webhook.MaxBackoff,
+	}, webhook_postgres.NewRepository(postgresDB))
+
+	webhookCtx, stopWebhooks := context.WithCancel(context.Background())
+	webhooksDone := make(chan struct{})
+	go func() {
+		webhooks.Run(webhookCtx)
+		close(webhooksDone)
+	}()
+	defer func() {
+		stopWebhooks()
+		<-webhooksDone
+	}()
+
+	// =========================================================================
+	// Start Job Runner
+	//
+	// Imports, exports and the QR code list are run here, off the request.
+
+	users := user.NewRepository(postgresDB, files, signer)
+	runner := jobs.NewRunner(jobs.Config{
+		Workers:      cfg.Jobs.Workers,
+		PerKind:      cfg.Jobs.PerKind,
+		PollInterval: cfg.Jobs.PollInterval,
+		Heartbeat:    cfg.Jobs.Heartbeat,
+		Lease:        cfg.Jobs.Lease,
+		MaxAttempts:  cfg.Jobs.MaxAttempts,
+	}, job_postgres.NewRepository(postgresDB),
+		jobs.UserImport{Users: users},
+		jobs.UserExport{Users: users},
+		jobs.QrCodeList{Users: users, Files: files},
+	)
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	jobsDone := make(chan struct{})
+	go func() {
+		runner.Run(jobsCtx)
+		close(jobsDone)
+	}()
+	defer func() {
+		stopJobs()
+		<-jobsDone
+	}()
+
+	// =========================================================================
+	// Start Scheduler
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		return errors.Wrap(err, "loading time zone")
+	}
+
+	mail := &mailer.Mailer{
+		Host:     cfg.Mail.Host,
+		Port:     cfg.Mail.Port,
+		Username: cfg.Mail.User,
+		Password: cfg.Mail.Password,
+		From:     cfg.Mail.From,
+	}
+
+	switch cfg.Checkout.Policy {
+	case attendance.CheckoutPolicyEndTime, attendance.CheckoutPolicyLastSeen, attendance.CheckoutPolicyFlagOnly:
+	default:
+		return fmt.Errorf("unknown checkout policy %q", cfg.Checkout.Policy)
+	}
+
+	for _, c := range cfg.Notification.Channels {
+		if !slices.Contains(notification_postgres.Channels, c) {
+			return fmt.Errorf("unknown notification channel %q", c)
+		}
+	}
+	reminderDays, err := scheduler.ParseWeekdays(cfg.Notification.Days)
+	if err != nil {
+		return errors.Wrap(err, "parsing reminder days")
+	}
+
+	notificationPostgres := notification_postgres.NewRepository(postgresDB)
+	notifications := notification.NewService(notification.Config{
+		Lang:     cfg.DefaultLang,
+		Channels: cfg.Notification.Channels,
+	}, notificationPostgres,
+		notification.Email{Mailer: mail},
+		notification.Webhook{DB: postgresDB.DB},
+		notification.Inbox{Store: notificationPostgres},
+	)
+
+	scheduled := []scheduler.Job{scheduler.NewImportCleanupJob(users)}
+	if cfg.Checkout.Enabled {
+		scheduled = append(scheduled, scheduler.NewCheckoutJob(scheduler.CheckoutConfig{
+			Policy:   cfg.Checkout.Policy,
+			Delay:    cfg.Checkout.Delay,
+			Location: tokyo,
+		}, attendance.NewRepository(postgresDB), notifications))
+	}
+	if cfg.Notification.Reminders {
+		scheduled = append(scheduled, scheduler.NewReminderJob(scheduler.ReminderConfig{
+			Days:     reminderDays,
+			Location: tokyo,
+		}, attendance.NewRepository(postgresDB), notifications))
+	}
+
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	schedulerDone := make(chan struct{})
+	go func() {
+		scheduler.New(cfg.Scheduler.Interval, scheduled...).Run(schedulerCtx)
+		close(schedulerDone)
+	}()
+	defer func() {
+		stopScheduler()
+		<-schedulerDone
+	}()
+
+	r := router.NewRouter(webApp, postgresDB, redisDB, auth, yamlConfig.BaseUrl, appMetrics, hub, inbox, cfg.Web.WSOrigins, cfg.Notification.Channels, files, signer, mail)
+	if err := r.Init(); err != nil {
+		return errors.Wrap(err, "registering routes")
+	}
+
+	api := http.Server{
+		Addr:         fmt.Sprintf(":%s", cfg.ServerPort),
+		Handler:      webApp,
+		ReadTimeout:  cfg.Web.ReadTimeout,
+		WriteTimeout: cfg.Web.WriteTimeout,
+		IdleTimeout:  cfg.Web.IdleTimeout,
+		ErrorLog:     slog.NewLogLogger(log.Handler(), slog.LevelError),
+	}
+
+	// SSE streams never finish on their own; end them as soon as the
+	// server stops accepting connections so Shutdown can drain them.
+	api.RegisterOnShutdown(webApp.StopStreams)
+
+	// Make a channel to listen for errors coming from the listener. Use a
+	// buffered channel so the goroutine can exit if we don't collect this error.
+	serverErrors := make(chan error, 1)
+
+	// Start the service listening for requests.
+	go func() {
+		log.Info("main: API listening", "addr", api.Addr)
+		serverErrors <- api.ListenAndServe()
+	}()
+
+	// =========================================================================
+	// Shutdown
+
+	// Blocking main and waiting for shutdown.
+	select {
+	case err := <-serverErrors:
+		return errors.Wrap(err, "server error")
+
+	case sig := <-shutdown:
+		log.Info("main: Start shutdown", "signal", sig.String())
+
+		// Give outstanding requests a deadline for completion.
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
+		defer cancel()
+
+		// Asking listener to shutdown and shed load.
+		if err := api.Shutdown(ctx); err != nil {
+			api.Close()
+			return errors.Wrap(err, "could not stop server gracefully")
+		}
+
+		log.Info("main: Shutdown complete", "signal", sig.String())
+	}
 
-	return r.Init()
+	return nil
 }
@@ -89,6 +89,9 @@ const docTemplate = `{
         "web.ErrorResponse": {
             "type": "object",
             "properties": {
+                "code": {
+                    "type": "string"
+                },
                 "data": {},
                 "error": {
                     "type": "string"
@@ -99,6 +102,15 @@ const docTemplate = `{
                         "$ref": "#/definitions/web.FieldError"
                     }
                 },
+                "localized_message": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
                 "status": {
                     "type": "boolean"
                 }
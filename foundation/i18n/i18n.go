@@ -0,0 +1,95 @@
+// Package i18n holds the message catalog of the API and negotiates the
+// language of a request. Messages are looked up by stable codes, so clients
+// can rely on the code while showing the text in the user's language.
+package i18n
+
+import (
+	"fmt"
+
+	"golang.org/x/text/language"
+)
+
+// Supported languages.
+const (
+	English  = "en"
+	Japanese = "ja"
+	Uzbek    = "uz"
+)
+
+// Fallback is used when a message has no translation in the requested
+// language.
+const Fallback = English
+
+var (
+	tags    = []language.Tag{language.English, language.Japanese, language.Uzbek}
+	names   = []string{English, Japanese, Uzbek}
+	matcher = language.NewMatcher(tags)
+)
+
+// Negotiate picks the supported language that best matches an
+// Accept-Language header value. def is returned when the header is empty or
+// nothing matches.
+func Negotiate(acceptLanguage string, def string) string {
+	if acceptLanguage == "" {
+		return Normalize(def)
+	}
+
+	prefs, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(prefs) == 0 {
+		return Normalize(def)
+	}
+
+	_, index, confidence := matcher.Match(prefs...)
+	if confidence == language.No {
+		return Normalize(def)
+	}
+
+	return names[index]
+}
+
+// Normalize returns lang if it is supported and the fallback otherwise.
+func Normalize(lang string) string {
+	for _, n := range names {
+		if n == lang {
+			return lang
+		}
+	}
+
+	return Fallback
+}
+
+// Supported reports whether lang is one of the supported languages.
+func Supported(lang string) bool {
+	for _, n := range names {
+		if n == lang {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Has reports whether code is part of the catalog.
+func Has(code string) bool {
+	_, ok := catalog[code]
+	return ok
+}
+
+// T returns the message for code in lang, formatted with args. Missing
+// translations fall back to English and unknown codes to the code itself.
+func T(lang, code string, args ...interface{}) string {
+	messages, ok := catalog[code]
+	if !ok {
+		return code
+	}
+
+	format, ok := messages[lang]
+	if !ok {
+		format = messages[Fallback]
+	}
+	if len(args) == 0 {
+		return format
+	}
+
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,639 @@
+package i18n
+
+// Generic codes, one per kind of HTTP failure. They are used when an error
+// does not carry a more specific code.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeValidation       = "validation_failed"
+	CodeTooLarge         = "payload_too_large"
+	CodeTooManyRequests  = "too_many_requests"
+	CodeInternal         = "internal_error"
+	CodeUnavailable      = "service_unavailable"
+)
+
+// Field level codes.
+const (
+	CodeFieldRequired = "field_required"
+	CodeFieldNumber   = "field_must_be_number"
+	CodeFieldBoolean  = "field_must_be_boolean"
+	CodeFieldMissing  = "field_missing"
+)
+
+// Domain codes. Once published a code must not change its meaning.
+const (
+	CodeRequiredBlank          = "required_blank"
+	CodeParamRequired          = "param_required"
+	CodeDateFormat             = "invalid_date_format"
+	CodeInterval               = "invalid_interval"
+	CodeUserNotFound           = "user_not_found"
+	CodeLoginNotFound          = "login_not_found"
+	CodePasswordIncorrect      = "password_incorrect"
+	CodeEmployeeInvalid        = "employee_id_invalid"
+	CodeEmployeeTaken          = "employee_id_taken"
+	CodeEmployeeNotFound       = "employee_not_found"
+	CodeDepartmentInvalid      = "department_invalid"
+	CodeDepartmentNameTaken    = "department_name_taken"
+	CodeDepartmentInUse        = "department_in_use"
+	CodeDisplayNumber          = "display_number_invalid"
+	CodePositionInvalid        = "position_invalid"
+	CodePositionNameTaken      = "position_name_taken"
+	CodePositionInUse          = "position_in_use"
+	CodeRoleInvalid            = "role_invalid"
+	CodeEmailRequired          = "email_required"
+	CodeEmailInvalid           = "email_invalid"
+	CodeEmailTaken             = "email_taken"
+	CodePhoneInvalid           = "phone_invalid"
+	CodeHalfWidthOnly          = "half_width_only"
+	CodeAlreadyCheckedIn       = "already_checked_in"
+	CodeNotCheckedIn           = "not_checked_in"
+	CodeOutOfArea              = "out_of_area"
+	CodeAttendanceInconsistent = "attendance_inconsistent"
+	CodeCompanyInfoNotFound    = "company_info_not_found"
+	CodeLinkInvalid            = "link_invalid"
+	CodeLinkExpired            = "link_expired"
+	CodeLinkRequired           = "link_required"
+	CodeFileNotFound           = "file_not_found"
+	CodeMessageUnsupported     = "message_unsupported"
+	CodeWebhookURLInvalid      = "webhook_url_invalid"
+	CodeWebhookEventInvalid    = "webhook_event_invalid"
+	CodeLanguageInvalid        = "language_invalid"
+	CodeNotificationChannel    = "notification_channel_invalid"
+	CodeNotificationKind       = "notification_kind_invalid"
+	CodeReportDimension        = "report_dimension_invalid"
+	CodeReportMetric           = "report_metric_invalid"
+	CodeReportRange            = "report_range_invalid"
+	CodeRangeInvalid           = "range_invalid"
+	CodePresetInvalid          = "preset_invalid"
+	CodeBucketInvalid          = "bucket_invalid"
+	CodeImportMode             = "import_mode_invalid"
+	CodeImportDuplicate        = "import_duplicate_row"
+	CodeImportPlanApplied      = "import_plan_applied"
+	CodeImportPlanExpired      = "import_plan_expired"
+	CodeImportStale            = "import_row_stale"
+	CodeImportRejected         = "import_rows_rejected"
+	CodeJobKind                = "job_kind_invalid"
+	CodeJobFinished            = "job_finished"
+	CodeJobNoResult            = "job_result_unavailable"
+	CodeFileFormat             = "file_format_invalid"
+	CodeFileEncoding           = "file_encoding_invalid"
+	CodeFileHeader             = "file_header_unknown"
+	CodeFileLine               = "file_line_invalid"
+	CodeImportPolicy           = "import_policy_invalid"
+	CodeTimeFormat             = "invalid_time_format"
+	CodeWorkDayFuture          = "work_day_future"
+	CodePeriodOrder            = "period_order_invalid"
+	CodePeriodOverlap          = "period_overlap"
+	CodePeriodOpen             = "period_open"
+	CodeImportDayRejected      = "import_day_rejected"
+	CodeUploadMissing          = "upload_missing"
+	CodeUploadTooLarge         = "upload_too_large"
+	CodeUploadType             = "upload_type_not_allowed"
+	CodeUploadInvalid          = "upload_invalid"
+	CodePhotoCrop              = "photo_crop_invalid"
+	CodeProfileFieldLocked     = "profile_field_locked"
+	CodeProfileFieldUnknown    = "profile_field_unknown"
+	CodeEmailUnchanged         = "email_unchanged"
+	CodeEmailCodeTooSoon       = "email_code_too_soon"
+	CodeEmailCodeInvalid       = "email_code_invalid"
+	CodeEmailCodeExpired       = "email_code_expired"
+	CodeMailUnavailable        = "mail_unavailable"
+)
+
+// Codes of informational messages returned with successful responses.
+const (
+	MsgWelcome = "welcome"
+	MsgGoodbye = "goodbye"
+
+	MsgForgottenCheckoutSubject = "forgotten_checkout_subject"
+	MsgForgottenCheckoutClosed  = "forgotten_checkout_closed"
+	MsgForgottenCheckoutFlagged = "forgotten_checkout_flagged"
+
+	MsgCheckInReminderSubject  = "check_in_reminder_subject"
+	MsgCheckInReminder         = "check_in_reminder"
+	MsgCheckOutReminderSubject = "check_out_reminder_subject"
+	MsgCheckOutReminder        = "check_out_reminder"
+
+	MsgEmailCodeSubject = "email_code_subject"
+	MsgEmailCode        = "email_code"
+)
+
+var catalog = map[string]map[string]string{
+	CodeBadRequest: {
+		English:  "The request is invalid.",
+		Japanese: "リクエストが正しくありません。",
+		Uzbek:    "So'rov noto'g'ri.",
+	},
+	CodeUnauthorized: {
+		English:  "Authentication is required.",
+		Japanese: "認証が必要です。",
+		Uzbek:    "Avtorizatsiyadan o'tish talab qilinadi.",
+	},
+	CodeForbidden: {
+		English:  "You are not allowed to perform this action.",
+		Japanese: "この操作を行う権限がありません。",
+		Uzbek:    "Bu amalni bajarishga ruxsatingiz yo'q.",
+	},
+	CodeNotFound: {
+		English:  "The requested resource was not found.",
+		Japanese: "指定されたリソースが見つかりません。",
+		Uzbek:    "So'ralgan ma'lumot topilmadi.",
+	},
+	CodeMethodNotAllowed: {
+		English:  "This method is not allowed.",
+		Japanese: "このメソッドは許可されていません。",
+		Uzbek:    "Bu usulga ruxsat berilmagan.",
+	},
+	CodeConflict: {
+		English:  "The request conflicts with the current state.",
+		Japanese: "現在の状態と競合しています。",
+		Uzbek:    "So'rov joriy holatga zid.",
+	},
+	CodeValidation: {
+		English:  "Some fields are not valid.",
+		Japanese: "入力内容に誤りがあります。",
+		Uzbek:    "Ba'zi maydonlar noto'g'ri to'ldirilgan.",
+	},
+	CodeTooLarge: {
+		English:  "The request is too large.",
+		Japanese: "リクエストが大きすぎます。",
+		Uzbek:    "So'rov hajmi juda katta.",
+	},
+	CodeTooManyRequests: {
+		English:  "Too many requests. Please try again later.",
+		Japanese: "リクエストが多すぎます。しばらくしてから再度お試しください。",
+		Uzbek:    "So'rovlar juda ko'p. Keyinroq qayta urinib ko'ring.",
+	},
+	CodeInternal: {
+		English:  "An unexpected error occurred.",
+		Japanese: "予期しないエラーが発生しました。",
+		Uzbek:    "Kutilmagan xatolik yuz berdi.",
+	},
+	CodeUnavailable: {
+		English:  "The service is temporarily unavailable.",
+		Japanese: "サービスは一時的に利用できません。",
+		Uzbek:    "Xizmat vaqtincha ishlamayapti.",
+	},
+
+	CodeFieldRequired: {
+		English:  "This field is required.",
+		Japanese: "この項目は必須です。",
+		Uzbek:    "Bu maydon majburiy.",
+	},
+	CodeFieldNumber: {
+		English:  "Must be a number.",
+		Japanese: "数値を入力してください。",
+		Uzbek:    "Raqam bo'lishi kerak.",
+	},
+	CodeFieldBoolean: {
+		English:  "Must be true or false.",
+		Japanese: "true または false を指定してください。",
+		Uzbek:    "true yoki false bo'lishi kerak.",
+	},
+	CodeFieldMissing: {
+		English:  "The value is missing.",
+		Japanese: "値が指定されていません。",
+		Uzbek:    "Qiymat ko'rsatilmagan.",
+	},
+
+	CodeRequiredBlank: {
+		English:  "Required fields cannot be empty or contain only spaces.",
+		Japanese: "必須項目は空欄にできません、またはスペースのみを含むことはできません。",
+		Uzbek:    "Majburiy maydonlar bo'sh yoki faqat bo'sh joydan iborat bo'lishi mumkin emas.",
+	},
+	CodeParamRequired: {
+		English:  "The %s parameter is required.",
+		Japanese: "%s パラメータは必須です。",
+		Uzbek:    "%s parametri majburiy.",
+	},
+	CodeDateFormat: {
+		English:  "Invalid date format.",
+		Japanese: "日付の形式が正しくありません。",
+		Uzbek:    "Sana formati noto'g'ri.",
+	},
+	CodeInterval: {
+		English:  "Invalid interval.",
+		Japanese: "期間の指定が正しくありません。",
+		Uzbek:    "Interval noto'g'ri.",
+	},
+	CodeUserNotFound: {
+		English:  "User not found.",
+		Japanese: "ユーザーが見つかりません。",
+		Uzbek:    "Foydalanuvchi topilmadi.",
+	},
+	CodeLoginNotFound: {
+		English:  "The employee ID or email is incorrect.",
+		Japanese: "社員番号またはメールアドレス が間違っています",
+		Uzbek:    "Xodim raqami yoki elektron pochta noto'g'ri.",
+	},
+	CodePasswordIncorrect: {
+		English:  "The password is incorrect.",
+		Japanese: "パスワードが間違っています",
+		Uzbek:    "Parol noto'g'ri.",
+	},
+	CodeEmployeeInvalid: {
+		English:  "The employee ID is invalid or was deleted.",
+		Japanese: "無効または削除された社員番号",
+		Uzbek:    "Xodim raqami noto'g'ri yoki o'chirilgan.",
+	},
+	CodeEmployeeTaken: {
+		English:  "The employee ID is already in use.",
+		Japanese: "社員番号はすでに使用されています。",
+		Uzbek:    "Bu xodim raqami allaqachon band.",
+	},
+	CodeEmployeeNotFound: {
+		English:  "Employee not found.",
+		Japanese: "社員が見つかりません。",
+		Uzbek:    "Xodim topilmadi.",
+	},
+	CodeDepartmentInvalid: {
+		English:  "The department is invalid or was deleted.",
+		Japanese: "無効または削除された部門ID",
+		Uzbek:    "Bo'lim noto'g'ri yoki o'chirilgan.",
+	},
+	CodeDepartmentNameTaken: {
+		English:  "The department name is already in use.",
+		Japanese: "部門名はすでに使用されています。",
+		Uzbek:    "Bu bo'lim nomi allaqachon band.",
+	},
+	CodeDepartmentInUse: {
+		English:  "This department is used by active users. Delete the related users first.",
+		Japanese: "この部門はアクティブなユーザーに使われています。関連するユーザーを先に削除しないと、削除できません。",
+		Uzbek:    "Bu bo'lim faol xodimlarga biriktirilgan. Avval ularni o'chiring.",
+	},
+	CodeDisplayNumber: {
+		English:  "Invalid display number. It must be between 1 and %d.",
+		Japanese: "表示順が正しくありません。1 から %d の間で指定してください。",
+		Uzbek:    "Tartib raqami noto'g'ri. U 1 dan %d gacha bo'lishi kerak.",
+	},
+	CodePositionInvalid: {
+		English:  "The position is invalid or was deleted.",
+		Japanese: "無効または削除された役職ID",
+		Uzbek:    "Lavozim noto'g'ri yoki o'chirilgan.",
+	},
+	CodePositionNameTaken: {
+		English:  "This department already has a position with the same name.",
+		Japanese: "この部門にはすでに同じ名前の役職があります。",
+		Uzbek:    "Bu bo'limda shu nomli lavozim allaqachon mavjud.",
+	},
+	CodePositionInUse: {
+		English:  "This position is used by active users. Delete the related users first.",
+		Japanese: "このポジションはアクティブなユーザーに使われています。関連するユーザーを先に削除しないと、削除できません。",
+		Uzbek:    "Bu lavozim faol xodimlarga biriktirilgan. Avval ularni o'chiring.",
+	},
+	CodeRoleInvalid: {
+		English:  "Invalid role. The role must be EMPLOYEE or ADMIN.",
+		Japanese: "役割が正しくありません。EMPLOYEE または ADMIN を指定してください。",
+		Uzbek:    "Rol noto'g'ri. EMPLOYEE yoki ADMIN bo'lishi kerak.",
+	},
+	CodeEmailRequired: {
+		English:  "Email is required.",
+		Japanese: "メールアドレスは必須です",
+		Uzbek:    "Elektron pochta majburiy.",
+	},
+	CodeEmailInvalid: {
+		English:  "Invalid email format.",
+		Japanese: "無効なメールアドレス形式",
+		Uzbek:    "Elektron pochta formati noto'g'ri.",
+	},
+	CodeEmailTaken: {
+		English:  "The email is already in use.",
+		Japanese: "メールアドレス はすでに使用されています。",
+		Uzbek:    "Bu elektron pochta allaqachon band.",
+	},
+	CodePhoneInvalid: {
+		English:  "Invalid phone number format.",
+		Japanese: "無効な電話番号形式",
+		Uzbek:    "Telefon raqami formati noto'g'ri.",
+	},
+	CodeHalfWidthOnly: {
+		English:  "Only half-width characters are allowed.",
+		Japanese: "入力は半角文字のみ使用可能",
+		Uzbek:    "Faqat yarim kenglikdagi belgilar ruxsat etiladi.",
+	},
+	CodeAlreadyCheckedIn: {
+		English:  "You have already checked in.",
+		Japanese: "すでに出勤済みです。",
+		Uzbek:    "Siz allaqachon ishga kelganingizni belgilagansiz.",
+	},
+	CodeNotCheckedIn: {
+		English:  "You have not checked in.",
+		Japanese: "出勤していません",
+		Uzbek:    "Siz hali ishga kelganingizni belgilamagansiz.",
+	},
+	CodeOutOfArea: {
+		English:  "You cannot check in outside the office area.",
+		Japanese: "正常ないちではないためチェックインできません",
+		Uzbek:    "Ofis hududidan tashqarida ishga kelishni belgilab bo'lmaydi.",
+	},
+	CodeAttendanceInconsistent: {
+		English:  "The attendance record has a leave time but no come time.",
+		Japanese: "出勤時刻のない退勤記録があります。",
+		Uzbek:    "Davomat yozuvida kelish vaqtisiz ketish vaqti bor.",
+	},
+	CodeCompanyInfoNotFound: {
+		English:  "Company information not found.",
+		Japanese: "会社情報が見つかりません。",
+		Uzbek:    "Kompaniya ma'lumotlari topilmadi.",
+	},
+	CodeLinkInvalid: {
+		English:  "The link is invalid.",
+		Japanese: "リンクが無効です。",
+		Uzbek:    "Havola noto'g'ri.",
+	},
+	CodeLinkExpired: {
+		English:  "The link has expired.",
+		Japanese: "リンクの有効期限が切れています。",
+		Uzbek:    "Havolaning muddati tugagan.",
+	},
+	CodeLinkRequired: {
+		English:  "This file can only be opened through a signed link.",
+		Japanese: "このファイルは署名付きリンクからのみ開けます。",
+		Uzbek:    "Bu faylni faqat imzolangan havola orqali ochish mumkin.",
+	},
+	CodeFileNotFound: {
+		English:  "File not found.",
+		Japanese: "ファイルが見つかりません。",
+		Uzbek:    "Fayl topilmadi.",
+	},
+	CodeMessageUnsupported: {
+		English:  "Unsupported message type: %s.",
+		Japanese: "サポートされていないメッセージ種別です: %s。",
+		Uzbek:    "Qo'llab-quvvatlanmaydigan xabar turi: %s.",
+	},
+	CodeWebhookURLInvalid: {
+		English:  "The webhook URL must be an absolute http or https URL.",
+		Japanese: "Webhook の URL は http または https の絶対 URL である必要があります。",
+		Uzbek:    "Webhook URL manzili to'liq http yoki https manzil bo'lishi kerak.",
+	},
+	CodeWebhookEventInvalid: {
+		English:  "Unknown webhook event: %s.",
+		Japanese: "不明な Webhook イベントです: %s。",
+		Uzbek:    "Noma'lum webhook hodisasi: %s.",
+	},
+	CodeLanguageInvalid: {
+		English:  "Unsupported language: %s.",
+		Japanese: "サポートされていない言語です: %s。",
+		Uzbek:    "Qo'llab-quvvatlanmaydigan til: %s.",
+	},
+	CodeNotificationChannel: {
+		English:  "Unknown notification channel: %s.",
+		Japanese: "不明な通知チャネルです: %s。",
+		Uzbek:    "Noma'lum bildirishnoma kanali: %s.",
+	},
+	CodeNotificationKind: {
+		English:  "Unknown notification kind: %s.",
+		Japanese: "不明な通知種別です: %s。",
+		Uzbek:    "Noma'lum bildirishnoma turi: %s.",
+	},
+	CodeReportDimension: {
+		English:  "Unknown report dimension: %s.",
+		Japanese: "不明なレポートの集計軸です: %s。",
+		Uzbek:    "Noma'lum hisobot o'lchovi: %s.",
+	},
+	CodeReportMetric: {
+		English:  "Unknown report metric: %s.",
+		Japanese: "不明なレポートの指標です: %s。",
+		Uzbek:    "Noma'lum hisobot ko'rsatkichi: %s.",
+	},
+	CodeReportRange: {
+		English:  "The report range must start before it ends and span at most %d days.",
+		Japanese: "レポートの期間は開始日が終了日以前で、最大 %d 日間である必要があります。",
+		Uzbek:    "Hisobot davri tugashidan oldin boshlanishi va ko'pi bilan %d kunni qamrashi kerak.",
+	},
+	CodeRangeInvalid: {
+		English:  "The range must start before it ends and span at most %d days.",
+		Japanese: "期間は開始日が終了日以前で、最大 %d 日間である必要があります。",
+		Uzbek:    "Davr tugashidan oldin boshlanishi va ko'pi bilan %d kunni qamrashi kerak.",
+	},
+	CodePresetInvalid: {
+		English:  "Unknown period preset: %s.",
+		Japanese: "不明な期間のプリセットです: %s。",
+		Uzbek:    "Noma'lum davr shabloni: %s.",
+	},
+	CodeBucketInvalid: {
+		English:  "Unknown bucket: %s.",
+		Japanese: "不明な集計単位です: %s。",
+		Uzbek:    "Noma'lum guruhlash birligi: %s.",
+	},
+	CodeImportMode: {
+		English:  "Invalid import mode. It must be 1 (create), 2 (update) or 3 (delete).",
+		Japanese: "インポートのモードが正しくありません。1（登録）、2（更新）、3（削除）のいずれかを指定してください。",
+		Uzbek:    "Import rejimi noto'g'ri. U 1 (yaratish), 2 (yangilash) yoki 3 (o'chirish) bo'lishi kerak.",
+	},
+	CodeImportDuplicate: {
+		English:  "The value is already used in row %d of the file.",
+		Japanese: "この値はファイルの %d 行目ですでに使われています。",
+		Uzbek:    "Bu qiymat faylning %d-qatorida allaqachon ishlatilgan.",
+	},
+	CodeImportPlanApplied: {
+		English:  "This import has already been applied.",
+		Japanese: "このインポートはすでに適用されています。",
+		Uzbek:    "Bu import allaqachon qo'llangan.",
+	},
+	CodeImportPlanExpired: {
+		English:  "This import preview has expired. Upload the file again.",
+		Japanese: "インポートのプレビューの有効期限が切れました。ファイルをもう一度アップロードしてください。",
+		Uzbek:    "Import ko'rinishining muddati tugagan. Faylni qayta yuklang.",
+	},
+	CodeImportRejected: {
+		English:  "%d rows were rejected. Fix them, or import the valid rows only.",
+		Japanese: "%d 行が取り込めませんでした。修正するか、正しい行のみを取り込んでください。",
+		Uzbek:    "%d ta qator rad etildi. Ularni tuzating yoki faqat to'g'ri qatorlarni import qiling.",
+	},
+	CodeImportStale: {
+		English:  "Row %d no longer matches the data it was previewed against. Preview the import again.",
+		Japanese: "%d 行目の対象データがプレビュー後に変更されました。もう一度プレビューしてください。",
+		Uzbek:    "%d-qator ko'rib chiqilgan ma'lumotlarga endi mos kelmaydi. Importni qayta ko'rib chiqing.",
+	},
+	CodeJobKind: {
+		English:  "Unknown job kind %q.",
+		Japanese: "不明なジョブの種類です: %q",
+		Uzbek:    "Noma'lum ish turi: %q.",
+	},
+	CodeJobFinished: {
+		English:  "The job has already finished.",
+		Japanese: "このジョブは既に終了しています。",
+		Uzbek:    "Ish allaqachon tugagan.",
+	},
+	CodeJobNoResult: {
+		English:  "The job has no result to download.",
+		Japanese: "このジョブにはダウンロードできる結果がありません。",
+		Uzbek:    "Ishning yuklab olinadigan natijasi yo'q.",
+	},
+	CodeFileFormat: {
+		English:  "Unknown file format %q. Use xlsx, csv or jsonl.",
+		Japanese: "不明なファイル形式です: %q。xlsx、csv、jsonl のいずれかを指定してください。",
+		Uzbek:    "Noma'lum fayl formati: %q. xlsx, csv yoki jsonl dan foydalaning.",
+	},
+	CodeFileEncoding: {
+		English:  "Unknown encoding %q. Use utf-8 or shift_jis.",
+		Japanese: "不明な文字コードです: %q。utf-8 または shift_jis を指定してください。",
+		Uzbek:    "Noma'lum kodlash: %q. utf-8 yoki shift_jis dan foydalaning.",
+	},
+	CodeFileHeader: {
+		English:  "The header row has no known column.",
+		Japanese: "ヘッダー行に認識できる列がありません。",
+		Uzbek:    "Sarlavha qatorida ma'lum ustun yo'q.",
+	},
+	CodeFileLine: {
+		English:  "Line %d is not a JSON object with known keys.",
+		Japanese: "%d 行目は認識できるキーを持つ JSON オブジェクトではありません。",
+		Uzbek:    "%d-qator ma'lum kalitlarga ega JSON obyekt emas.",
+	},
+	CodeImportPolicy: {
+		English:  "Unknown conflict policy %q. Use skip, overwrite or merge.",
+		Japanese: "不明な競合ポリシー %q です。skip、overwrite、merge のいずれかを指定してください。",
+		Uzbek:    "Noma'lum ziddiyat siyosati %q. skip, overwrite yoki merge dan foydalaning.",
+	},
+	CodeTimeFormat: {
+		English:  "Invalid time format. Use HH:MM or HH:MM:SS.",
+		Japanese: "時刻の形式が正しくありません。HH:MM または HH:MM:SS で入力してください。",
+		Uzbek:    "Vaqt formati noto'g'ri. HH:MM yoki HH:MM:SS dan foydalaning.",
+	},
+	CodeWorkDayFuture: {
+		English:  "The work day is in the future.",
+		Japanese: "勤務日が未来の日付です。",
+		Uzbek:    "Ish kuni kelajakdagi sana.",
+	},
+	CodePeriodOrder: {
+		English:  "The leave time must not be before the come time.",
+		Japanese: "退勤時刻は出勤時刻以降である必要があります。",
+		Uzbek:    "Ketish vaqti kelish vaqtidan oldin bo'lmasligi kerak.",
+	},
+	CodePeriodOverlap: {
+		English:  "The period overlaps the period %s of the same day.",
+		Japanese: "同じ日の勤務時間 %s と重なっています。",
+		Uzbek:    "Davr o'sha kundagi %s davri bilan ustma-ust tushadi.",
+	},
+	CodePeriodOpen: {
+		English:  "Only the last period of a day may have no leave time.",
+		Japanese: "退勤時刻を省略できるのはその日の最後の勤務時間のみです。",
+		Uzbek:    "Faqat kunning oxirgi davri ketish vaqtisiz bo'lishi mumkin.",
+	},
+	CodeImportDayRejected: {
+		English:  "Row %d of the same employee and day was rejected.",
+		Japanese: "同じ社員・同じ日の %d 行目が取り込めませんでした。",
+		Uzbek:    "Xuddi shu xodim va kunning %d-qatori rad etildi.",
+	},
+	CodeUploadMissing: {
+		English:  "No file was uploaded.",
+		Japanese: "ファイルがアップロードされていません。",
+		Uzbek:    "Fayl yuklanmadi.",
+	},
+	CodeUploadTooLarge: {
+		English:  "The file is larger than the limit of %s.",
+		Japanese: "ファイルが上限の %s を超えています。",
+		Uzbek:    "Fayl %s chegarasidan katta.",
+	},
+	CodeUploadType: {
+		English:  "This kind of file cannot be uploaded here. Allowed: %s.",
+		Japanese: "この種類のファイルはアップロードできません。使用可能な形式: %s。",
+		Uzbek:    "Bu turdagi faylni bu yerga yuklab bo'lmaydi. Ruxsat etilganlar: %s.",
+	},
+	CodeUploadInvalid: {
+		English:  "The file is damaged or cannot be read.",
+		Japanese: "ファイルが破損しているか、読み取れません。",
+		Uzbek:    "Fayl buzilgan yoki uni o'qib bo'lmaydi.",
+	},
+	CodePhotoCrop: {
+		English:  "The crop must be a square within the photo.",
+		Japanese: "切り抜き範囲は写真内の正方形にしてください。",
+		Uzbek:    "Kesish sohasi rasm ichidagi kvadrat bo'lishi kerak.",
+	},
+	CodeProfileFieldLocked: {
+		English:  "%s cannot be changed from your profile. Ask an administrator.",
+		Japanese: "%s はプロフィールから変更できません。管理者に依頼してください。",
+		Uzbek:    "%s ni profildan o'zgartirib bo'lmaydi. Administratorga murojaat qiling.",
+	},
+	CodeProfileFieldUnknown: {
+		English:  "%s is not a profile field.",
+		Japanese: "%s はプロフィールの項目ではありません。",
+		Uzbek:    "%s profil maydoni emas.",
+	},
+	CodeEmailUnchanged: {
+		English:  "This is already your email.",
+		Japanese: "すでに登録されているメールアドレスです。",
+		Uzbek:    "Bu allaqachon sizning elektron pochtangiz.",
+	},
+	CodeEmailCodeTooSoon: {
+		English:  "A code was sent just now. Wait a minute before asking for another.",
+		Japanese: "確認コードを送信したばかりです。1分ほど待ってから再度お試しください。",
+		Uzbek:    "Kod hozirgina yuborildi. Yangisini so'rashdan oldin bir daqiqa kuting.",
+	},
+	CodeEmailCodeInvalid: {
+		English:  "The code is incorrect.",
+		Japanese: "確認コードが正しくありません。",
+		Uzbek:    "Kod noto'g'ri.",
+	},
+	CodeEmailCodeExpired: {
+		English:  "No code is pending, or it has expired. Ask for a new one.",
+		Japanese: "有効な確認コードがありません。もう一度コードを送信してください。",
+		Uzbek:    "Faol kod yo'q yoki uning muddati tugagan. Yangisini so'rang.",
+	},
+	CodeMailUnavailable: {
+		English:  "Mail cannot be sent at the moment.",
+		Japanese: "現在メールを送信できません。",
+		Uzbek:    "Hozirda xat yuborib bo'lmaydi.",
+	},
+
+	MsgWelcome: {
+		English:  "Welcome to work.",
+		Japanese: "仕事へようこそ",
+		Uzbek:    "Ishga xush kelibsiz.",
+	},
+	MsgGoodbye: {
+		English:  "Have a safe trip home.",
+		Japanese: "無事に帰宅",
+		Uzbek:    "Uyga eson-omon yetib boring.",
+	},
+	MsgEmailCodeSubject: {
+		English:  "Confirm your email",
+		Japanese: "メールアドレスの確認",
+		Uzbek:    "Elektron pochtangizni tasdiqlang",
+	},
+	MsgEmailCode: {
+		English:  "Your code to confirm this email is %s. It is valid for %d minutes.",
+		Japanese: "このメールアドレスを確認するためのコードは %s です。%d 分間有効です。",
+		Uzbek:    "Ushbu elektron pochtani tasdiqlash kodi: %s. U %d daqiqa amal qiladi.",
+	},
+	MsgForgottenCheckoutSubject: {
+		English:  "Missing check-out",
+		Japanese: "退勤打刻漏れ",
+		Uzbek:    "Ishdan chiqish qayd etilmagan",
+	},
+	MsgForgottenCheckoutClosed: {
+		English:  "You checked in on %s at %s but did not check out. Your leave time was recorded as %s. Please contact your administrator if this is wrong.",
+		Japanese: "%s の %s に出勤しましたが、退勤の打刻がありませんでした。退勤時刻は %s として記録されました。誤りがある場合は管理者に連絡してください。",
+		Uzbek:    "Siz %s kuni soat %s da ishga keldingiz, lekin ketishingiz qayd etilmadi. Ketish vaqtingiz %s deb yozildi. Agar bu noto'g'ri bo'lsa, administratorga murojaat qiling.",
+	},
+	MsgForgottenCheckoutFlagged: {
+		English:  "You checked in on %s at %s but did not check out. Please contact your administrator to record your leave time.",
+		Japanese: "%s の %s に出勤しましたが、退勤の打刻がありませんでした。退勤時刻の登録について管理者に連絡してください。",
+		Uzbek:    "Siz %s kuni soat %s da ishga keldingiz, lekin ketishingiz qayd etilmadi. Ketish vaqtingizni yozish uchun administratorga murojaat qiling.",
+	},
+	MsgCheckInReminderSubject: {
+		English:  "Check-in reminder",
+		Japanese: "出勤打刻のお知らせ",
+		Uzbek:    "Ishga kelishni qayd etish eslatmasi",
+	},
+	MsgCheckInReminder: {
+		English:  "You have not checked in today (%s). Please check in if you are at work.",
+		Japanese: "本日（%s）の出勤打刻がまだありません。出勤している場合は打刻してください。",
+		Uzbek:    "Bugun (%s) ishga kelganingiz qayd etilmagan. Agar ishda bo'lsangiz, qayd eting.",
+	},
+	MsgCheckOutReminderSubject: {
+		English:  "Check-out reminder",
+		Japanese: "退勤打刻のお知らせ",
+		Uzbek:    "Ishdan chiqishni qayd etish eslatmasi",
+	},
+	MsgCheckOutReminder: {
+		English:  "You are still checked in today (%s). Please check out when you leave.",
+		Japanese: "本日（%s）の退勤打刻がまだありません。退勤時に打刻してください。",
+		Uzbek:    "Bugun (%s) ishdan chiqishingiz hali qayd etilmagan. Ketayotganingizda qayd eting.",
+	},
+}
@@ -1,9 +1,9 @@
 package web
 
 import (
+	"attendance/backend/foundation/i18n"
 	"context"
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"reflect"
 	"strconv"
@@ -55,48 +55,34 @@ func (c *Context) Respond(data interface{}, statusCode int) error {
 	return nil
 }
 
-func (c *Context) RespondError(err error) error {
+// RequestID returns the ID assigned to the current request.
+func (c *Context) RequestID() string {
+	return GetRequestID(c.Ctx)
+}
 
-	// If the error was of the type *Error, the handler has
-	// a specific status code and error to return.
+// RespondError answers the request with the error envelope described by
+// ErrorResponse.
+func (c *Context) RespondError(err error) error {
 
-	if webErr, ok := Cause(err).(*Error); ok {
-		er := ErrorResponse{
-			Error:  webErr.Err.Error(),
-			Fields: webErr.Fields,
-		}
-		return c.Respond(er, webErr.Status)
+	// Keep the original error on the request values so middlewares running
+	// after the handler can log and report it.
+	if v, ok := GetValues(c.Ctx); ok {
+		v.Err = err
 	}
 
-	// If not, the handler sent any arbitrary error value so use 500.
-	er := ErrorResponse{
-		Error: err.Error(),
+	er, status := NewErrorResponse(c.Ctx, err)
+	if status >= http.StatusInternalServerError {
+		slog.ErrorContext(c.Ctx, "request failed", "error", err, "status", status)
 	}
-	return c.Respond(er, http.StatusInternalServerError)
+
+	return c.Respond(er, status)
 }
 
+// RespondMobileError answers the request with the error envelope.
+//
+// Deprecated: mobile clients read the same envelope; use RespondError.
 func (c *Context) RespondMobileError(err error) error {
-
-	// If the error was of the type *Error, the handler has
-	// a specific status code and error to return.
-	if webErr, ok := Cause(err).(*Error); ok {
-		errMsg := webErr.Err.Error()
-		if webErr.Fields != nil {
-			for _, f := range webErr.Fields {
-				errMsg += fmt.Sprintf("(%s: %s)", f.Field, f.Error)
-			}
-		}
-		er := MobileErrorResponse{
-			Error: errMsg,
-		}
-		return c.Respond(er, webErr.Status)
-	}
-
-	// If not, the handler sent any arbitrary error value so use 500.
-	er := ErrorResponse{
-		Error: err.Error(),
-	}
-	return c.Respond(er, http.StatusInternalServerError)
+	return c.RespondError(err)
 }
 
 func (c *Context) BindFunc(data interface{}, requiredFields ...string) error {
@@ -121,6 +107,7 @@ func (c *Context) GetQueryFunc(dataType reflect.Kind, query string) interface{}
 			if err != nil {
 				c.queryErrors = append(c.queryErrors, FieldError{
 					Error: "query must be number!",
+					Code:  i18n.CodeFieldNumber,
 					Field: query,
 				})
 			}
@@ -132,6 +119,7 @@ func (c *Context) GetQueryFunc(dataType reflect.Kind, query string) interface{}
 			if err != nil {
 				c.queryErrors = append(c.queryErrors, FieldError{
 					Error: "query must be float32!",
+					Code:  i18n.CodeFieldNumber,
 					Field: query,
 				})
 			}
@@ -144,6 +132,7 @@ func (c *Context) GetQueryFunc(dataType reflect.Kind, query string) interface{}
 			if err != nil {
 				c.queryErrors = append(c.queryErrors, FieldError{
 					Error: "query must be float32!",
+					Code:  i18n.CodeFieldNumber,
 					Field: query,
 				})
 			}
@@ -159,6 +148,7 @@ func (c *Context) GetQueryFunc(dataType reflect.Kind, query string) interface{}
 			if err != nil {
 				c.queryErrors = append(c.queryErrors, FieldError{
 					Error: "query must be boolean!",
+					Code:  i18n.CodeFieldBoolean,
 					Field: query,
 				})
 			}
@@ -190,6 +180,7 @@ func (c *Context) GetParam(paramType reflect.Kind, param string) interface{} {
 		if err != nil {
 			c.paramErrors = append(c.paramErrors, FieldError{
 				Error: "param must be number!",
+				Code:  i18n.CodeFieldNumber,
 				Field: param,
 			})
 		}
@@ -199,6 +190,7 @@ func (c *Context) GetParam(paramType reflect.Kind, param string) interface{} {
 		if value == "" {
 			c.paramErrors = append(c.paramErrors, FieldError{
 				Error: "param not found",
+				Code:  i18n.CodeFieldMissing,
 				Field: param,
 			})
 		}
@@ -240,11 +232,11 @@ func validateStruct(s interface{}, requiredFields ...string) error {
 
 		isSet := field.IsValid() && !field.IsZero()
 		if !isSet {
-			log.Print(isSet, fieldName, reflect.ValueOf(field))
 			for _, f := range requiredFields {
 				if f == fieldName {
 					errFields = append(errFields, FieldError{
 						Error: "field is required!",
+						Code:  i18n.CodeFieldRequired,
 						Field: fieldName,
 					})
 				}
@@ -1,24 +1,50 @@
 package web
 
+import (
+	"attendance/backend/foundation/i18n"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
 // FieldError is used to indicate an error with a specific request field.
+// Code, when set, is a catalog code used to localize Error.
 type FieldError struct {
 	Field string `json:"field"`
 	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
 }
 
-// ErrorResponse is the form area for API responses from failures in the API.
+// ErrorResponse is the envelope of every failed API response:
+//
+//	{
+//	  "status": false,
+//	  "data": null,
+//	  "code": "employee_not_found",
+//	  "message": "Employee not found.",
+//	  "localized_message": "社員が見つかりません。",
+//	  "error": "社員が見つかりません。",
+//	  "fields": [{"field": "Email", "error": "この項目は必須です。", "code": "field_required"}],
+//	  "request_id": "4f0c..."
+//	}
+//
+// Code is a stable machine readable identifier from the i18n catalog, Message
+// the English developer facing text and LocalizedMessage the text to show to
+// users in the language negotiated from Accept-Language. Error repeats
+// LocalizedMessage for older clients. Fields is only present for validation
+// failures.
 type ErrorResponse struct {
-	Error  string       `json:"error"`
-	Fields []FieldError `json:"fields,omitempty"`
-	Data   interface{}  `json:"data"`
-	Status bool         `json:"status"`
-}
-
-// MobileErrorResponse is the form area for API responses from failures in the API.
-type MobileErrorResponse struct {
-	Error  interface{} `json:"error"`
-	Data   interface{} `json:"data"`
-	Status bool        `json:"status"`
+	Status           bool         `json:"status"`
+	Data             interface{}  `json:"data"`
+	Code             string       `json:"code"`
+	Message          string       `json:"message"`
+	LocalizedMessage string       `json:"localized_message"`
+	Error            string       `json:"error"`
+	Fields           []FieldError `json:"fields,omitempty"`
+	RequestID        string       `json:"request_id,omitempty"`
 }
 
 // Error used to pass an error during the request through the
@@ -27,6 +53,8 @@ type Error struct {
 	Err    error
 	Status int
 	Fields []FieldError
+	Code   string
+	Args   []interface{}
 }
 
 // Error implements the error interface. It uses the default message of the
@@ -38,7 +66,135 @@ func (e *Error) Error() string {
 // NewRequestError wraps a provided error with an HTTP status code. This
 // function should be used when handlers encounter expected errors.
 func NewRequestError(err error, status int) error {
-	return &Error{err, status, nil}
+	return &Error{Err: err, Status: status}
+}
+
+// NewCodeError returns a request error identified by a catalog code. The
+// response message is rendered from the catalog in the language of the
+// request, args fill the placeholders of the message.
+func NewCodeError(code string, status int, args ...interface{}) error {
+	return &Error{
+		Err:    errors.New(i18n.T(i18n.English, code, args...)),
+		Status: status,
+		Code:   code,
+		Args:   args,
+	}
+}
+
+// codeForStatus returns the generic code of an HTTP status.
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return i18n.CodeBadRequest
+	case http.StatusUnauthorized:
+		return i18n.CodeUnauthorized
+	case http.StatusForbidden:
+		return i18n.CodeForbidden
+	case http.StatusNotFound:
+		return i18n.CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return i18n.CodeMethodNotAllowed
+	case http.StatusConflict:
+		return i18n.CodeConflict
+	case http.StatusUnprocessableEntity:
+		return i18n.CodeValidation
+	case http.StatusRequestEntityTooLarge:
+		return i18n.CodeTooLarge
+	case http.StatusTooManyRequests:
+		return i18n.CodeTooManyRequests
+	case http.StatusServiceUnavailable:
+		return i18n.CodeUnavailable
+	}
+	if status >= http.StatusInternalServerError {
+		return i18n.CodeInternal
+	}
+	return i18n.CodeBadRequest
+}
+
+// NewErrorResponse converts err into the error envelope and the status code
+// to answer with. Errors that are not a *Error are treated as internal
+// errors. Server errors only carry the catalog text and the request ID; their
+// cause is for the log, not for clients.
+func NewErrorResponse(ctx context.Context, err error) (ErrorResponse, int) {
+	lang := GetLang(ctx)
+	status := http.StatusInternalServerError
+	message := err.Error()
+	var code string
+	var args []interface{}
+	var fields []FieldError
+
+	if webErr, ok := Cause(err).(*Error); ok {
+		status = webErr.Status
+		message = webErr.Err.Error()
+		code = webErr.Code
+		args = webErr.Args
+		fields = make([]FieldError, len(webErr.Fields))
+		for i, f := range webErr.Fields {
+			if f.Code != "" {
+				f.Error = i18n.T(lang, f.Code)
+			}
+			fields[i] = f
+		}
+	}
+
+	// Catalog codes are rendered in the request language. Without one the
+	// message of the error is the most precise text there is, except for
+	// internal errors whose details are not meant for users and validation
+	// errors whose details are in the localized fields.
+	localized := message
+	switch {
+	case code != "" && i18n.Has(code):
+		localized = i18n.T(lang, code, args...)
+	case code == "" && status >= http.StatusInternalServerError:
+		code = codeForStatus(status)
+		localized = i18n.T(lang, code)
+	case code == "" && len(fields) > 0:
+		code = i18n.CodeValidation
+		localized = i18n.T(lang, code)
+	case code == "":
+		code = codeForStatus(status)
+	}
+
+	if status >= http.StatusInternalServerError {
+		if !i18n.Has(code) {
+			code = codeForStatus(status)
+			args = nil
+		}
+		message = i18n.T(i18n.English, code, args...)
+		localized = i18n.T(lang, code, args...)
+		fields = nil
+	}
+
+	return ErrorResponse{
+		Code:             code,
+		Message:          message,
+		LocalizedMessage: localized,
+		Error:            localized,
+		Fields:           fields,
+		RequestID:        GetRequestID(ctx),
+	}, status
+}
+
+// WriteError renders err as the error envelope on a plain http.ResponseWriter.
+// It is used by routes that are not served through a Handler, like file
+// downloads and event streams.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+	if _, ok := ctx.Value(KeyLang).(string); !ok {
+		ctx = WithLang(ctx, i18n.Negotiate(r.Header.Get("Accept-Language"), i18n.Fallback))
+	}
+
+	er, status := NewErrorResponse(ctx, err)
+	if er.RequestID == "" {
+		er.RequestID = w.Header().Get(RequestIDHeader)
+	}
+	if status >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "request failed", "error", err, "status", status, "path", r.URL.Path)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(er)
 }
 
 // shutdown is type used to help with the graceful termination of the service.
@@ -1,118 +1,59 @@
 package web
 
 import (
-	"bytes"
-	"encoding/csv"
-	"encoding/json"
-	"fmt"
-	"log"
-	"net/http"
-	"os"
+	"context"
+	"io"
+	"log/slog"
 	"strings"
-	"time"
-	"attendance/backend/internal/pkg/config"
-
-	"github.com/pkg/errors"
 )
 
-type Logger struct {
-	Folder string
-}
+// NewLogger constructs a structured logger that writes JSON records to w.
+// Every record logged with a request context carries the request ID of that
+// request, so handlers and repositories only need to pass their context.
+func NewLogger(w io.Writer, level slog.Leveler, service string) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     level,
+	})
 
-func NewLogger(folder string) *Logger {
-	if folder == "" {
-		folder = "logs"
-	}
-	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
-		log.Println("cannot create directory, reason:", err)
-	}
-	return &Logger{Folder: folder}
+	return slog.New(contextHandler{handler}).With("service", service)
 }
 
-func (l *Logger) WriteLog(ctx *Context, data interface{}) error {
-	var (
-		file *os.File
-		err  error
-		id   string
-	)
-
-	userId, ok := ctx.Value("user_id").(int64)
-	if !ok {
-		id = "-"
-	} else {
-		id = fmt.Sprintf("%d", userId)
-	}
-
-	name := fmt.Sprintf("%s/%s.csv", l.Folder, time.Now().Format("02-01-2006"))
-
-	file, err = os.OpenFile(name, os.O_RDWR|os.O_APPEND, 0755)
-	if err != nil {
-		file, err = os.Create(name)
-		if err != nil {
-			return err
-		}
-
-		headers := []string{"time", "url", "user_id", "request_method", "request_body", "request_useragent", "response"}
-
-		writer := csv.NewWriter(file)
-		if err = writer.Write(headers); err != nil {
-			return err
-		}
-
-		writer.Flush()
-	}
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	body := ctx.Value("body")
-
-	recordBuffer := []string{
-		fmt.Sprintf("Time: %s", time.Now().Format("02-01-2006 15:04:05")),
-		fmt.Sprintf("URL: %v", ctx.Request.URL),
-		fmt.Sprintf("UserID: %s", id),
-		fmt.Sprintf("Method: %s", ctx.Request.Method),
-		fmt.Sprintf("Request.Body: %v", body),
-		fmt.Sprintf("Useragent: %s", ctx.Request.UserAgent()),
-		fmt.Sprintf("Response.Body: %v", data),
-	}
-	if err = writer.Write(recordBuffer); err != nil {
-		return err
-	}
-
-	if err = l.SendBotMsg(recordBuffer); err != nil {
-		log.Println(err)
+// ParseLevel converts a textual log level (debug, info, warn, error) into a
+// slog.Level. Unknown values fall back to info.
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
 	}
+}
 
-	return nil
+// contextHandler decorates records with values stored in the request context.
+type contextHandler struct {
+	slog.Handler
 }
 
-func (l *Logger) SendBotMsg(recordBuffer []string) error {
-	cfg, err := config.NewConfig()
-	if err != nil {
-		fmt.Print("Error loading configuration: %v", err)
+// Handle adds the request ID to the record before passing it on.
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := GetRequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
 	}
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", cfg.ErrorBotToken)
-
-	for _, v := range cfg.ErrorChatID {
-		body, err := json.Marshal(map[string]interface{}{
-			"chat_id": v,
-			"text":    strings.Join(recordBuffer, "\n"),
-		})
-		if err != nil {
-			return err
-		}
 
-		response, err := http.Post(url, "application/json", bytes.NewBuffer(body))
-		if err != nil {
-			return err
-		}
+	return h.Handler.Handle(ctx, r)
+}
 
-		if response.StatusCode >= 300 && response.StatusCode < 200 {
-			log.Println(response.StatusCode)
-			return errors.New("status code was not okay")
-		}
-	}
+// WithAttrs implements slog.Handler.
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
 
-	return nil
+// WithGroup implements slog.Handler.
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
 }
@@ -1,9 +1,13 @@
 package web
 
 import (
+	"attendance/backend/foundation/i18n"
 	"context"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"runtime/debug"
 	"syscall"
 	"time"
 
@@ -16,11 +20,49 @@ type ctxKey int
 // KeyValues is how request values are stored/retrieved.
 const KeyValues ctxKey = 1
 
+// KeyLang is how the negotiated language of a request is stored/retrieved.
+const KeyLang ctxKey = 2
+
+// RequestIDHeader is the header used to accept and echo the request ID.
+const RequestIDHeader = "X-Request-ID"
+
 // Values represent state for each request.
 type Values struct {
 	TraceID    string
 	Now        time.Time
 	StatusCode int
+	Err        error
+}
+
+// GetValues returns the values stored for the request carried by ctx.
+func GetValues(ctx context.Context) (*Values, bool) {
+	v, ok := ctx.Value(KeyValues).(*Values)
+	return v, ok
+}
+
+// GetRequestID returns the ID assigned to the request carried by ctx, or an
+// empty string when ctx does not belong to a request.
+func GetRequestID(ctx context.Context) string {
+	if v, ok := GetValues(ctx); ok {
+		return v.TraceID
+	}
+
+	return ""
+}
+
+// WithLang returns a copy of ctx carrying lang as the request language.
+func WithLang(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, KeyLang, lang)
+}
+
+// GetLang returns the language negotiated for the request carried by ctx,
+// falling back to English outside of a request.
+func GetLang(ctx context.Context) string {
+	if lang, ok := ctx.Value(KeyLang).(string); ok {
+		return lang
+	}
+
+	return i18n.Fallback
 }
 
 // A Handler is a type that handles a http request within our own little mini framework.
@@ -41,19 +83,64 @@ type App struct {
 	shutdown    chan os.Signal
 	mw          []Middleware
 	DefaultLang string
+
+	// streams is cancelled when the server starts shutting down so that
+	// long-lived responses such as SSE streams end and can be drained.
+	streams     context.Context
+	stopStreams context.CancelFunc
 }
 
 // NewApp creates an App value that handle a set of routes for the application.
 func NewApp(shutdown chan os.Signal, defaultLang string, mw ...Middleware) *App {
-	engine := gin.Default()
+	// Requests are logged by the application middleware, so gin's own text
+	// logger is left out.
+	engine := gin.New()
+	engine.Use(recovery())
+	engine.HandleMethodNotAllowed = true
+	engine.NoRoute(func(c *gin.Context) {
+		WriteError(c.Writer, c.Request, NewCodeError(i18n.CodeNotFound, http.StatusNotFound))
+	})
+	engine.NoMethod(func(c *gin.Context) {
+		WriteError(c.Writer, c.Request, NewCodeError(i18n.CodeMethodNotAllowed, http.StatusMethodNotAllowed))
+	})
 
 	//engine.Static("/media", "./media")
 
+	streams, stopStreams := context.WithCancel(context.Background())
+
 	return &App{
 		Engine:      engine,
 		shutdown:    shutdown,
 		mw:          mw,
 		DefaultLang: defaultLang,
+		streams:     streams,
+		stopStreams: stopStreams,
+	}
+}
+
+// recovery is the last line of defence for panics outside of the handler
+// chain, e.g. in routes registered directly on gin. The client gets the
+// error envelope unless a response was already started.
+func recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			slog.ErrorContext(c.Request.Context(), "panic recovered", "panic", fmt.Sprint(rec), "stack", string(debug.Stack()), "path", c.Request.URL.Path)
+
+			if !c.Writer.Written() {
+				WriteError(c.Writer, c.Request, NewCodeError(i18n.CodeInternal, http.StatusInternalServerError))
+			}
+			c.Abort()
+		}()
+
+		c.Next()
 	}
 }
 
@@ -61,12 +148,35 @@ func NewApp(shutdown chan os.Signal, defaultLang string, mw ...Middleware) *App
 // issue is identified.
 
 func (a *App) SignalShutdown() {
-	a.shutdown <- syscall.SIGTERM
+	select {
+	case a.shutdown <- syscall.SIGTERM:
+	default:
+		// A shutdown is already pending.
+	}
+}
+
+// StreamContext returns a context derived from parent that is also cancelled
+// once StopStreams is called. Handlers holding a connection open for a long
+// time must use it so a shutdown does not wait for the client to leave.
+func (a *App) StreamContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	stop := context.AfterFunc(a.streams, cancel)
+
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// StopStreams ends all contexts handed out by StreamContext. It is meant to
+// be registered with http.Server.RegisterOnShutdown.
+func (a *App) StopStreams() {
+	a.stopStreams()
 }
 
 // handle performs the real work of applying boilerplate and framework code
 // for handler.
-func (a *App) handle(debug bool, method string, path string, handler Handler, mw ...Middleware) {
+func (a *App) handle(debug bool, stream bool, method string, path string, handler Handler, mw ...Middleware) {
 	if debug {
 		// Track all the handlers that are being registered so we don't have
 		// the same handlers registered twice to this singleton.
@@ -85,15 +195,6 @@ func (a *App) handle(debug bool, method string, path string, handler Handler, mw
 	// The function execute for each request.
 	h := func(c *gin.Context) {
 
-		//defer func() {
-		//	if r := recover(); r != nil {
-		//		c.JSON(http.StatusInternalServerError, map[string]interface{}{
-		//			"error":  fmt.Sprintf("Recovered error: %v", r),
-		//			"status": false,
-		//		})
-		//	}
-		//}()
-
 		// ###########################################
 		//// Start or expand a distributed trace.
 		ctx := c.Request.Context()
@@ -108,21 +209,35 @@ func (a *App) handle(debug bool, method string, path string, handler Handler, mw
 			Now: time.Now(),
 		}
 
-		lang := a.DefaultLang
-
-		if len(c.Request.Header["Accept-Language"]) > 0 {
-			lang = c.Request.Header["Accept-Language"][0]
-		}
+		lang := i18n.Negotiate(c.GetHeader("Accept-Language"), a.DefaultLang)
+		c.Header("Content-Language", lang)
 
 		ctx = context.WithValue(ctx, KeyValues, &v)
-		ctx = context.WithValue(ctx, "lang", lang)
-		ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+		ctx = WithLang(ctx, lang)
+
+		// Streams stay open until the client leaves or the server shuts
+		// down, every other request gets a deadline.
+		var cancel context.CancelFunc
+		if stream {
+			ctx, cancel = a.StreamContext(ctx)
+		} else {
+			ctx, cancel = context.WithTimeout(ctx, time.Second*10)
+		}
 		defer cancel()
 
 		webContext := NewContext(c, ctx)
 		if err := handler(webContext); err != nil {
-			a.SignalShutdown()
-			return
+			// Only integrity problems stop the service. Any other error
+			// belongs to this request and is answered if the handler
+			// has not done so already.
+			if IsShutdown(err) {
+				slog.ErrorContext(ctx, "shutdown requested by handler", "error", err)
+				a.SignalShutdown()
+				return
+			}
+			if !c.Writer.Written() {
+				_ = webContext.RespondError(err)
+			}
 		}
 	}
 
@@ -133,7 +248,14 @@ func (a *App) handle(debug bool, method string, path string, handler Handler, mw
 // HandleFunc sets a handler function for a given HTTP method and path pair
 // to the application server mux.
 func (a *App) HandleFunc(method string, path string, handler Handler, mw ...Middleware) {
-	a.handle(false, method, path, handler, mw...)
+	a.handle(false, false, method, path, handler, mw...)
+}
+
+// Stream registers a GET handler for a long-lived response such as Server-Sent
+// Events or a WebSocket. Its context has no deadline and is cancelled when the
+// server shuts down.
+func (a *App) Stream(path string, handler Handler, mw ...Middleware) {
+	a.handle(false, true, http.MethodGet, path, handler, mw...)
 }
 
 func (a *App) Get(path string, handler Handler, mw ...Middleware) {
@@ -245,6 +245,409 @@ var scheme = []Scheme{
                 AFTER INSERT OR UPDATE ON attendance_period
                 FOR EACH ROW EXECUTE FUNCTION notify_attendance_change();`,
 	},
+	{
+		Index:       14,
+		Description: "Create tables: webhook_subscription, webhook_delivery and the webhook triggers",
+		Query: `
+        CREATE TABLE IF NOT EXISTS webhook_subscription (
+            id serial primary key,
+            url text not null,
+            secret text not null,
+            events text[] not null,
+            description text,
+            active boolean not null default true,
+            created_at timestamp default now(),
+            created_by int references users(id),
+            updated_at timestamp,
+            updated_by int references users(id),
+            deleted_at timestamp,
+            deleted_by int references users(id)
+        );
+
+        CREATE TABLE IF NOT EXISTS webhook_delivery (
+            id bigserial primary key,
+            subscription_id int not null references webhook_subscription(id),
+            event text not null,
+            payload jsonb not null,
+            status text not null default 'pending',
+            attempts int not null default 0,
+            next_attempt_at timestamptz not null default now(),
+            last_attempt_at timestamptz,
+            response_status int,
+            response_body text,
+            error text,
+            duration_ms int,
+            created_at timestamptz not null default now(),
+            delivered_at timestamptz
+        );
+
+        CREATE INDEX IF NOT EXISTS webhook_delivery_due_idx
+            ON webhook_delivery (next_attempt_at) WHERE status = 'pending';
+        CREATE INDEX IF NOT EXISTS webhook_delivery_subscription_idx
+            ON webhook_delivery (subscription_id, created_at desc);
+
+        -- webhook_enqueue queues a delivery of the event for every active
+        -- subscription listening to it.
+        CREATE OR REPLACE FUNCTION webhook_enqueue(event_name text, event_data jsonb)
+        RETURNS void AS $$
+        BEGIN
+            INSERT INTO webhook_delivery (subscription_id, event, payload)
+            SELECT id, event_name, event_data
+            FROM webhook_subscription
+            WHERE active AND deleted_at IS NULL AND event_name = ANY(events);
+        END;
+        $$ LANGUAGE plpgsql;
+
+        CREATE OR REPLACE FUNCTION webhook_attendance_period()
+        RETURNS TRIGGER AS $$
+        DECLARE
+            event_name text;
+        BEGIN
+            IF TG_OP = 'INSERT' THEN
+                event_name := 'check_in';
+            ELSIF OLD.leave_time IS NULL AND NEW.leave_time IS NOT NULL THEN
+                event_name := 'check_out';
+            ELSE
+                RETURN NEW;
+            END IF;
+
+            PERFORM webhook_enqueue(event_name, (
+                SELECT jsonb_build_object(
+                    'employee_id', a.employee_id,
+                    'attendance_id', NEW.attendance_id,
+                    'period_id', NEW.id,
+                    'work_day', NEW.work_day,
+                    'come_time', NEW.come_time,
+                    'leave_time', NEW.leave_time
+                )
+                FROM attendance a WHERE a.id = NEW.attendance_id
+            ));
+            RETURN NEW;
+        END;
+        $$ LANGUAGE plpgsql;
+
+        CREATE TRIGGER webhook_attendance_period_trigger
+        AFTER INSERT OR UPDATE ON attendance_period
+        FOR EACH ROW EXECUTE FUNCTION webhook_attendance_period();
+
+        CREATE OR REPLACE FUNCTION webhook_users()
+        RETURNS TRIGGER AS $$
+        DECLARE
+            event_name text;
+        BEGIN
+            IF TG_OP = 'INSERT' THEN
+                event_name := 'user_created';
+            ELSIF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+                event_name := 'user_deleted';
+            ELSE
+                RETURN NEW;
+            END IF;
+
+            PERFORM webhook_enqueue(event_name, jsonb_build_object(
+                'id', NEW.id,
+                'employee_id', NEW.employee_id,
+                'role', NEW.role,
+                'first_name', NEW.first_name,
+                'last_name', NEW.last_name,
+                'nick_name', NEW.nick_name,
+                'department_id', NEW.department_id,
+                'position_id', NEW.position_id,
+                'email', NEW.email
+            ));
+            RETURN NEW;
+        END;
+        $$ LANGUAGE plpgsql;
+
+        CREATE TRIGGER webhook_users_trigger
+        AFTER INSERT OR UPDATE ON users
+        FOR EACH ROW EXECUTE FUNCTION webhook_users();`,
+	},
+	{
+		Index:       15,
+		Description: "Skip check_out webhooks for periods closed by the forgotten check-out job",
+		Query: `
+        CREATE OR REPLACE FUNCTION webhook_attendance_period()
+        RETURNS TRIGGER AS $$
+        DECLARE
+            event_name text;
+        BEGIN
+            IF TG_OP = 'INSERT' THEN
+                event_name := 'check_in';
+            ELSIF OLD.leave_time IS NULL AND NEW.leave_time IS NOT NULL THEN
+                -- The job sends forgot_leave_autofix instead.
+                IF current_setting('attendance.autofix', true) = 'on' THEN
+                    RETURN NEW;
+                END IF;
+                event_name := 'check_out';
+            ELSE
+                RETURN NEW;
+            END IF;
+
+            PERFORM webhook_enqueue(event_name, (
+                SELECT jsonb_build_object(
+                    'employee_id', a.employee_id,
+                    'attendance_id', NEW.attendance_id,
+                    'period_id', NEW.id,
+                    'work_day', NEW.work_day,
+                    'come_time', NEW.come_time,
+                    'leave_time', NEW.leave_time
+                )
+                FROM attendance a WHERE a.id = NEW.attendance_id
+            ));
+            RETURN NEW;
+        END;
+        $$ LANGUAGE plpgsql;`,
+	},
+	{
+		Index:       16,
+		Description: "Create tables: notification, notification_preference, reminder_log",
+		Query: `
+        CREATE TABLE IF NOT EXISTS notification (
+            id bigserial primary key,
+            user_id int not null references users(id),
+            kind text not null,
+            title text not null,
+            body text not null,
+            data jsonb,
+            read_at timestamptz,
+            created_at timestamptz not null default now()
+        );
+
+        CREATE INDEX IF NOT EXISTS notification_user_idx
+            ON notification (user_id, created_at desc);
+        CREATE INDEX IF NOT EXISTS notification_unread_idx
+            ON notification (user_id) WHERE read_at IS NULL;
+
+        CREATE TABLE IF NOT EXISTS notification_preference (
+            user_id int primary key references users(id),
+            lang text,
+            channels text[] not null,
+            muted text[] not null default '{}',
+            updated_at timestamptz not null default now()
+        );
+
+        CREATE TABLE IF NOT EXISTS reminder_log (
+            user_id int not null references users(id),
+            kind text not null,
+            work_day date not null,
+            created_at timestamptz not null default now(),
+            primary key (user_id, kind, work_day)
+        );`,
+	},
+	{
+		Index:       17,
+		Description: "Create trigger notifying notification changes",
+		Query: `
+        CREATE OR REPLACE FUNCTION notify_notification_change()
+        RETURNS TRIGGER AS $$
+        BEGIN
+            PERFORM pg_notify('notification_changes', json_build_object(
+                'operation', TG_OP,
+                'user_id', NEW.user_id
+            )::text);
+            RETURN NEW;
+        END;
+        $$ LANGUAGE plpgsql;
+
+        CREATE TRIGGER notification_changes_trigger
+        AFTER INSERT OR UPDATE OF read_at ON notification
+        FOR EACH ROW EXECUTE FUNCTION notify_notification_change();`,
+	},
+	{
+		Index:       18,
+		Description: "Alter table attendance: office_id. Create table: report_definition",
+		Query: `
+        ALTER TABLE attendance
+        ADD COLUMN IF NOT EXISTS office_id int references company_info(id);
+
+        CREATE TABLE IF NOT EXISTS report_definition (
+            id serial primary key,
+            name text not null,
+            definition jsonb not null,
+            created_at timestamp default now(),
+            created_by int references users(id),
+            updated_at timestamp,
+            updated_by int references users(id),
+            deleted_at timestamp,
+            deleted_by int references users(id)
+        );
+
+        CREATE INDEX IF NOT EXISTS report_definition_owner_idx
+            ON report_definition (created_by) WHERE deleted_at IS NULL;`,
+	},
+	{
+		Index:       19,
+		Description: "Create table: user_department_history",
+		Query: `
+        CREATE TABLE IF NOT EXISTS user_department_history (
+            id serial primary key,
+            user_id int not null references users(id),
+            department_id int references department(id),
+            valid_from timestamp not null default now()
+        );
+
+        CREATE INDEX IF NOT EXISTS user_department_history_user_idx
+            ON user_department_history (user_id, valid_from);
+
+        INSERT INTO user_department_history (user_id, department_id, valid_from)
+        SELECT id, department_id, '-infinity' FROM users
+        WHERE NOT EXISTS (SELECT 1 FROM user_department_history h WHERE h.user_id = users.id);
+
+        CREATE OR REPLACE FUNCTION track_user_department()
+        RETURNS TRIGGER AS $$
+        BEGIN
+            IF TG_OP = 'UPDATE' AND NEW.department_id IS NOT DISTINCT FROM OLD.department_id THEN
+                RETURN NEW;
+            END IF;
+            INSERT INTO user_department_history (user_id, department_id) VALUES (NEW.id, NEW.department_id);
+            RETURN NEW;
+        END;
+        $$ LANGUAGE plpgsql;
+
+        CREATE TRIGGER user_department_history_trigger
+        AFTER INSERT OR UPDATE OF department_id ON users
+        FOR EACH ROW EXECUTE FUNCTION track_user_department();`,
+	},
+	{
+		Index:       20,
+		Description: "Create table: import_plan",
+		Query: `
+        CREATE TABLE IF NOT EXISTS import_plan (
+            id text primary key,
+            mode int not null,
+            file_name text,
+            steps jsonb not null,
+            expires_at timestamp not null,
+            applied_at timestamp,
+            created_at timestamp default now(),
+            created_by int references users(id)
+        );`,
+	},
+	{
+		Index:       21,
+		Description: "Alter table import_plan: workbook",
+		Query: `
+        ALTER TABLE import_plan
+        ADD COLUMN IF NOT EXISTS workbook bytea;`,
+	},
+	{
+		Index:       22,
+		Description: "Create table: job",
+		Query: `
+        CREATE TABLE IF NOT EXISTS job (
+            id bigserial primary key,
+            kind text not null,
+            status text not null default 'queued',
+            params jsonb not null default '{}',
+            input bytea,
+            input_name text,
+            lang text,
+            progress int not null default 0,
+            total int not null default 0,
+            attempts int not null default 0,
+            cancel_requested boolean not null default false,
+            lease_until timestamptz,
+            summary jsonb,
+            result bytea,
+            result_name text,
+            result_type text,
+            error text,
+            created_at timestamptz not null default now(),
+            created_by int references users(id),
+            started_at timestamptz,
+            finished_at timestamptz
+        );
+
+        CREATE INDEX IF NOT EXISTS job_queue_idx
+            ON job (created_at) WHERE status IN ('queued', 'running');
+        CREATE INDEX IF NOT EXISTS job_created_by_idx
+            ON job (created_by, created_at desc);`,
+	},
+	{
+		Index:       23,
+		Description: "Skip attendance webhooks for periods written by a history import",
+		Query: `
+        CREATE OR REPLACE FUNCTION webhook_attendance_period()
+        RETURNS TRIGGER AS $$
+        DECLARE
+            event_name text;
+        BEGIN
+            -- Imported history is not something happening now.
+            IF current_setting('attendance.import', true) = 'on' THEN
+                RETURN NEW;
+            END IF;
+
+            IF TG_OP = 'INSERT' THEN
+                event_name := 'check_in';
+            ELSIF OLD.leave_time IS NULL AND NEW.leave_time IS NOT NULL THEN
+                -- The job sends forgot_leave_autofix instead.
+                IF current_setting('attendance.autofix', true) = 'on' THEN
+                    RETURN NEW;
+                END IF;
+                event_name := 'check_out';
+            ELSE
+                RETURN NEW;
+            END IF;
+
+            PERFORM webhook_enqueue(event_name, (
+                SELECT jsonb_build_object(
+                    'employee_id', a.employee_id,
+                    'attendance_id', NEW.attendance_id,
+                    'period_id', NEW.id,
+                    'work_day', NEW.work_day,
+                    'come_time', NEW.come_time,
+                    'leave_time', NEW.leave_time
+                )
+                FROM attendance a WHERE a.id = NEW.attendance_id
+            ));
+            RETURN NEW;
+        END;
+        $$ LANGUAGE plpgsql;`,
+	},
+	{
+		Index:       24,
+		Description: "Alter table import_plan: workbook_key",
+		Query: `
+        ALTER TABLE import_plan
+        ADD COLUMN IF NOT EXISTS workbook_key text;`,
+	},
+	{
+		Index:       25,
+		Description: "Alter table users: photo, photo_thumb",
+		Query: `
+        ALTER TABLE users
+        ADD COLUMN IF NOT EXISTS photo text,
+        ADD COLUMN IF NOT EXISTS photo_thumb text;`,
+	},
+	{
+		Index:       26,
+		Description: "Alter table company_info: profile_fields. Create table: email_change",
+		Query: `
+        ALTER TABLE company_info
+        ADD COLUMN IF NOT EXISTS profile_fields text[] not null default '{nick_name,phone}';
+
+        CREATE TABLE IF NOT EXISTS email_change (
+            user_id int primary key references users(id),
+            email text not null,
+            code_hash text not null,
+            attempts int not null default 0,
+            expires_at timestamptz not null,
+            created_at timestamptz not null default now()
+        );`,
+	},
+	{
+		Index:       27,
+		Description: "Alter table import_plan: password_hashes. Drop stored import passwords",
+		Query: `
+        ALTER TABLE import_plan
+        ADD COLUMN IF NOT EXISTS password_hashes jsonb;
+
+        UPDATE import_plan SET expires_at = now() WHERE applied_at IS NULL AND expires_at > now();
+        UPDATE import_plan SET workbook = NULL WHERE workbook IS NOT NULL;
+        UPDATE import_plan
+        SET steps = (SELECT jsonb_agg(s #- '{values,password_hash}') FROM jsonb_array_elements(steps) s)
+        WHERE steps::text LIKE '%password_hash%';`,
+	},
 }
 
 // Migrate creates the scheme in the database.
@@ -1,15 +1,16 @@
 package attendance
 
 import (
+	"attendance/backend/foundation/i18n"
 	"attendance/backend/foundation/web"
+	"attendance/backend/internal/pkg/period"
 	"attendance/backend/internal/repository/postgres/attendance"
 
-	"errors"
 	"fmt"
 	"math"
 	"net/http"
 	"reflect"
-	"strconv"
+	"time"
 
 	"github.com/Azure/go-autorest/autorest/date"
 )
@@ -95,8 +96,6 @@ func (uc Controller) GetList(c *web.Context) error {
 	if err != nil {
 		return c.RespondError(err)
 	}
-	fmt.Println("Absent color: ", colors.AbsentColor)
-	fmt.Println("Presnt color: ", colors.PresentColor)
 	return c.Respond(map[string]interface{}{
 
 		"Colors": map[string]interface{}{
@@ -137,19 +136,18 @@ func (uc Controller) GetHistoryById(c *web.Context) error {
 	// Get the 'month' query parameter
 	datestr := c.Query("date")
 	if datestr == "" {
-		return c.RespondError(web.NewRequestError(errors.New("date parameter is required"), http.StatusBadRequest))
+		return c.RespondError(web.NewCodeError(i18n.CodeParamRequired, http.StatusBadRequest, "date"))
 	}
 	parsedDate, err := date.ParseDate(datestr)
 	if err != nil {
-		return c.RespondError(web.NewRequestError(errors.New("invalid date format"), http.StatusBadRequest))
+		return c.RespondError(web.NewCodeError(i18n.CodeDateFormat, http.StatusBadRequest))
 	}
 
 	// Get the 'employee_id' query parameter
 	employee_id := c.Query("employee_id")
 	if employee_id == "" {
-		return c.RespondError(web.NewRequestError(errors.New("employee_id parameter is required"), http.StatusBadRequest))
+		return c.RespondError(web.NewCodeError(i18n.CodeParamRequired, http.StatusBadRequest, "employee_id"))
 	}
-	fmt.Println("Controlller employe_id", employee_id)
 	list, count, err := uc.attendance.GetHistoryById(c.Ctx, employee_id, parsedDate)
 	if err != nil {
 		return c.RespondError(err)
@@ -169,7 +167,13 @@ func (uc Controller) GetStatistics(c *web.Context) error {
 		return c.RespondError(err)
 	}
 
-	response, err := uc.attendance.GetStatistics(c.Ctx)
+	loc, _ := time.LoadLocation("Asia/Tokyo")
+	workDay, err := period.Day(c, time.Now().In(loc))
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	response, err := uc.attendance.GetStatistics(c.Ctx, workDay)
 	if err != nil {
 		return c.RespondError(err)
 	}
@@ -186,7 +190,13 @@ func (uc Controller) GetPieChartStatistics(c *web.Context) error {
 		return c.RespondError(err)
 	}
 
-	response, err := uc.attendance.GetPieChartStatistic(c.Ctx)
+	loc, _ := time.LoadLocation("Asia/Tokyo")
+	workDay, err := period.Day(c, time.Now().In(loc))
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	response, err := uc.attendance.GetPieChartStatistic(c.Ctx, workDay)
 	if err != nil {
 		return c.RespondError(err)
 	}
@@ -194,8 +204,6 @@ func (uc Controller) GetPieChartStatistics(c *web.Context) error {
 	if err != nil {
 		return c.RespondError(err)
 	}
-	fmt.Println("colors absent:", colors.AbsentColor)
-	fmt.Println("colors present:", colors.PresentColor)
 
 	return c.Respond(map[string]interface{}{
 		"Colors": map[string]interface{}{
@@ -212,7 +220,13 @@ func (uc Controller) GetBarChartStatistics(c *web.Context) error {
 		return c.RespondError(err)
 	}
 
-	response, err := uc.attendance.GetBarChartStatistic(c.Ctx)
+	loc, _ := time.LoadLocation("Asia/Tokyo")
+	workDay, err := period.Day(c, time.Now().In(loc))
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	response, err := uc.attendance.GetBarChartStatistic(c.Ctx, workDay)
 	if err != nil {
 		return c.RespondError(err)
 	}
@@ -225,29 +239,20 @@ func (uc Controller) GetBarChartStatistics(c *web.Context) error {
 
 func (uc Controller) GetGraphStatistic(c *web.Context) error {
 	var filter attendance.GraphRequest
-	// Get the 'month' query parameter
-	monthStr := c.Query("month")
-	if monthStr == "" {
-		return c.RespondError(web.NewRequestError(errors.New("month parameter is required"), http.StatusBadRequest))
-	}
 
-	parsedMonth, err := date.ParseDate(monthStr)
+	// from/to, a preset, or the legacy month and interval
+	loc, _ := time.LoadLocation("Asia/Tokyo")
+	dateRange, err := period.FromQuery(c, time.Now().In(loc))
 	if err != nil {
-		return c.RespondError(web.NewRequestError(errors.New("invalid date format"), http.StatusBadRequest))
-	}
-	filter.Month = parsedMonth
-
-	// Get the 'interval' query parameter
-	intervalStr := c.Query("interval")
-	if intervalStr == "" {
-		return c.RespondError(web.NewRequestError(errors.New("interval parameter is required"), http.StatusBadRequest))
+		return c.RespondError(err)
 	}
+	filter.Range = dateRange
 
-	interval, err := strconv.Atoi(intervalStr)
+	bucket, err := period.ParseBucket(c.Query("bucket"))
 	if err != nil {
-		return c.RespondError(web.NewRequestError(errors.New("invalid interval format"), http.StatusBadRequest))
+		return c.RespondError(err)
 	}
-	filter.Interval = interval
+	filter.Bucket = bucket
 
 	list, err := uc.attendance.GetGraphStatistic(c.Ctx, filter)
 	if err != nil {
@@ -331,6 +336,64 @@ func (uc Controller) Delete(c *web.Context) error {
 		"status": true,
 	}, http.StatusOK)
 }
+
+// ImportHistory imports attendance history from an uploaded file. The
+// response lists what happened to every row; nothing is written when
+// dry_run is set, or when rows are rejected and partial is not.
+func (uc Controller) ImportHistory(c *web.Context) error {
+	var request attendance.HistoryImportRequest
+	if err := c.BindFunc(&request); err != nil {
+		return c.RespondError(err)
+	}
+
+	result, err := uc.attendance.ImportHistory(c.Ctx, request)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   result,
+		"status": true,
+	}, http.StatusOK)
+}
+
+// ExportHistory downloads the attendance and its periods of a range, as
+// xlsx, csv or jsonl given by format. The range is taken like the one of the
+// statistics, so it is at most period.MaxDays long.
+func (uc Controller) ExportHistory(c *web.Context) error {
+	loc, _ := time.LoadLocation("Asia/Tokyo")
+	dateRange, err := period.FromQuery(c, time.Now().In(loc))
+	if err != nil {
+		return c.RespondError(err)
+	}
+	filter := attendance.HistoryExportFilter{
+		Range:    dateRange,
+		Format:   c.Query("format"),
+		Encoding: c.Query("encoding"),
+	}
+	if employeeID, ok := c.GetQueryFunc(reflect.String, "employee_id").(*string); ok {
+		filter.EmployeeID = employeeID
+	}
+	if err := c.ValidQuery(); err != nil {
+		return c.RespondError(err)
+	}
+
+	file, err := uc.attendance.ExportHistory(c.Ctx, filter)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	c.Header("Content-Type", file.ContentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Name))
+
+	_, err = c.Writer.Write(file.Data)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return nil
+}
+
 func (uc Controller) CreateByQRCode(c *web.Context) error {
 	var request attendance.EnterRequest
 	if err := c.BindFunc(&request); err != nil {
@@ -362,6 +425,7 @@ func (uc Controller) CreateByPhone(c *web.Context) error {
 	for _, office := range officeLocations {
 		distance := CalculateDistance(request.Latitude, request.Longitude, office.Latitude, office.Longitude)
 		if distance <= office.Radius {
+			request.OfficeID = &office.ID
 			response, err := uc.attendance.CreateByPhone(c.Ctx, request)
 			if err != nil {
 				return c.RespondError(err)
@@ -373,7 +437,7 @@ func (uc Controller) CreateByPhone(c *web.Context) error {
 			}, http.StatusOK)
 		}
 	}
-	return c.RespondError(web.NewRequestError(errors.New("正常ないちではないためチェックインできません"), http.StatusBadRequest))
+	return c.RespondError(web.NewCodeError(i18n.CodeOutOfArea, http.StatusBadRequest))
 
 }
 func (uc Controller) ExitByPhone(c *web.Context) error {
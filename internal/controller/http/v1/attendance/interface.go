@@ -4,6 +4,7 @@ import (
 	"attendance/backend/internal/repository/postgres/attendance"
 	"attendance/backend/internal/repository/postgres/companyInfo"
 	"context"
+	"time"
 
 	"github.com/Azure/go-autorest/autorest/date"
 )
@@ -16,10 +17,12 @@ type Attendance interface {
 	UpdateAll(ctx context.Context, request attendance.UpdateRequest) error
 	UpdateColumns(ctx context.Context, request attendance.UpdateRequest) error
 	Delete(ctx context.Context, id int) error
-	GetStatistics(ctx context.Context) (attendance.GetStatisticResponse, error)
-	GetPieChartStatistic(ctx context.Context) (attendance.PieChartResponse, error)
-	GetBarChartStatistic(ctx context.Context) ([]attendance.BarChartResponse, error)
+	GetStatistics(ctx context.Context, workDay time.Time) (attendance.GetStatisticResponse, error)
+	GetPieChartStatistic(ctx context.Context, workDay time.Time) (attendance.PieChartResponse, error)
+	GetBarChartStatistic(ctx context.Context, workDay time.Time) ([]attendance.BarChartResponse, error)
 	GetGraphStatistic(ctx context.Context, filter attendance.GraphRequest) ([]attendance.GraphResponse, error)
+	ImportHistory(ctx context.Context, request attendance.HistoryImportRequest) (attendance.HistoryImportResult, error)
+	ExportHistory(ctx context.Context, filter attendance.HistoryExportFilter) (attendance.ExportFile, error)
 
 	CreateByQRCode(ctx context.Context, request attendance.EnterRequest) (attendance.CreateResponse, string, error)
 	CreateByPhone(ctx context.Context, request attendance.EnterRequest) (attendance.CreateResponse, error)
@@ -1,11 +1,12 @@
 package auth
 
 import (
+	"attendance/backend/foundation/i18n"
 	"attendance/backend/foundation/web"
 	"attendance/backend/internal/commands"
 	"attendance/backend/internal/entity"
 	"attendance/backend/internal/repository/postgres/user"
-	"fmt"
+	"log/slog"
 	"net/http"
 	"regexp"
 
@@ -14,8 +15,9 @@ import (
 )
 
 var (
-	errIncorrectPassword   = errors.New("社員番号またはメールアドレス が間違っています")
-	errIncorrectEmployeeId = errors.New("パスワードが間違っています")
+	errIncorrectLogin    = web.NewCodeError(i18n.CodeLoginNotFound, http.StatusUnauthorized)
+	errIncorrectPassword = web.NewCodeError(i18n.CodePasswordIncorrect, http.StatusUnauthorized)
+	errPasswordNotSet    = web.NewCodeError(i18n.CodePasswordIncorrect, http.StatusNotFound)
 )
 
 type Controller struct {
@@ -46,11 +48,8 @@ func (uc Controller) SignIn(c *web.Context) error {
 	var data user.SignInRequest
 	err := c.BindFunc(&data, "EmployeeID", "Password")
 	if err != nil {
-		fmt.Println("Error binding request data:", err)
-		return c.RespondError(&web.Error{
-			Err:    errors.New("invalid request data"),
-			Status: http.StatusBadRequest,
-		})
+		slog.InfoContext(c.Ctx, "sign-in: invalid request data", "error", err)
+		return c.RespondError(web.NewCodeError(i18n.CodeBadRequest, http.StatusBadRequest))
 	}
 
 	var detail *entity.User
@@ -63,28 +62,19 @@ func (uc Controller) SignIn(c *web.Context) error {
 	}
 
 	if err != nil || detail == nil {
-		fmt.Println("User not found or invalid credentials for Identifier:", data.EmployeeID)
-		return c.RespondError(&web.Error{
-			Err:    errIncorrectPassword,
-			Status: http.StatusUnauthorized,
-		})
+		slog.InfoContext(c.Ctx, "sign-in: user not found", "identifier", data.EmployeeID)
+		return c.RespondError(errIncorrectLogin)
 	}
 
 	if detail.Password == nil {
-		fmt.Println("Password not found for Identifier:", data.EmployeeID)
-		return c.RespondError(&web.Error{
-			Err:    errIncorrectEmployeeId,
-			Status: http.StatusNotFound,
-		})
+		slog.WarnContext(c.Ctx, "sign-in: password not set", "identifier", data.EmployeeID)
+		return c.RespondError(errPasswordNotSet)
 	}
 
 	// Verify password
 	if err = bcrypt.CompareHashAndPassword([]byte(*detail.Password), []byte(data.Password)); err != nil {
-		fmt.Println("Incorrect password for Identifier:", data.EmployeeID)
-		return c.RespondError(&web.Error{
-			Err:    errIncorrectEmployeeId,
-			Status: http.StatusUnauthorized,
-		})
+		slog.InfoContext(c.Ctx, "sign-in: incorrect password", "identifier", data.EmployeeID)
+		return c.RespondError(errIncorrectPassword)
 	}
 
 	// Generate tokens
@@ -94,11 +84,8 @@ func (uc Controller) SignIn(c *web.Context) error {
 	}, "./private.pem")
 
 	if err != nil {
-		fmt.Println("Error generating tokens:", err)
-		return c.RespondError(&web.Error{
-			Err:    errors.New("token generation failed"),
-			Status: http.StatusInternalServerError,
-		})
+		slog.ErrorContext(c.Ctx, "sign-in: generating tokens", "error", err)
+		return c.RespondError(web.NewRequestError(errors.Wrap(err, "generating tokens"), http.StatusInternalServerError))
 	}
 
 	return c.Respond(map[string]interface{}{
@@ -4,18 +4,21 @@ import (
 	"attendance/backend/foundation/web"
 	"attendance/backend/internal/repository/postgres/companyInfo"
 	"attendance/backend/internal/service"
+	"attendance/backend/internal/storage"
+	"attendance/backend/internal/upload"
 	"net/http"
 	"reflect"
 )
 
 type Controller struct {
 	companyInfo CompanyInfo
+	files       storage.Storage
 }
 
 const companyDir = "company_info"
 
-func NewController(companyInfo CompanyInfo) *Controller {
-	return &Controller{companyInfo}
+func NewController(companyInfo CompanyInfo, files storage.Storage) *Controller {
+	return &Controller{companyInfo, files}
 }
 func (uc Controller) UpdateAll(c *web.Context) error {
 	id := c.GetParam(reflect.Int, "id").(int)
@@ -31,9 +34,9 @@ func (uc Controller) UpdateAll(c *web.Context) error {
 
 	// Check if image exists in the request
 	if request.Logo != nil {
-		path, err := service.Upload(request.Logo, companyDir)
+		path, err := service.Upload(c.Ctx, uc.files, upload.Logo, request.Logo, companyDir)
 		if err != nil {
-			return c.RespondError(err)
+			return c.RespondError(upload.RequestError(err))
 		}
 		request.Url = path
 	}
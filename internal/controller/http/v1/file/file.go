@@ -1,88 +1,113 @@
 package file
 
 import (
-	"github.com/gin-gonic/gin"
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/auth"
+	"attendance/backend/internal/storage"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
-	"attendance/backend/foundation/web"
-	"attendance/backend/internal/service/hashing"
+
+	"github.com/gin-gonic/gin"
 )
 
 type Controller struct {
 	*web.App
 	fileServerBasePath string
+	files              storage.Storage
+	signer             *storage.Signer
+	auth               *auth.Auth
 }
 
 type Config struct {
 	MediaBaseLink string `conf:"default:./media"`
 }
 
-type onlyFilesFS struct {
-	http.FileSystem
-}
-
-func NewController(app *web.App, fileServerBasePath string) *Controller {
-	return &Controller{app, fileServerBasePath}
+func NewController(app *web.App, fileServerBasePath string, files storage.Storage, signer *storage.Signer, a *auth.Auth) *Controller {
+	return &Controller{app, fileServerBasePath, files, signer, a}
 }
 
+// File serves a stored file. Files of the public directories are served to
+// anyone; every other file only through a link made by the signer, which,
+// when bound to a user, also needs the request to be authenticated as them.
 func (cf Controller) File(c *gin.Context) {
-	fs := gin.Dir("./media", false)
-	if _, noListing := fs.(*onlyFilesFS); noListing {
-		c.Writer.WriteHeader(http.StatusNotFound)
+	key, err := storage.CleanKey(c.Param("filepath"))
+	if err != nil {
+		web.WriteError(c.Writer, c.Request, web.NewCodeError(i18n.CodeFileNotFound, http.StatusNotFound))
+		return
 	}
 
-	file := c.Param("filepath")
-	if !strings.Contains(file[1:], "/") {
-		OpenH := hashing.OpenHash(file)
-		list := strings.Split(OpenH, " ")
-		if len(list) == 3 {
-			linkTime, err := time.Parse("02.01.2006 15:04:05 ", list[1]+" "+list[2])
-			if err != nil {
-				c.JSON(http.StatusBadRequest, map[string]any{
-					"error":  "incorrect link",
-					"status": false,
-				})
-				return
-			}
-			if linkTime.Before(time.Now().UTC()) {
-				c.JSON(http.StatusBadRequest, map[string]any{
-					"error":  "expired link",
-					"status": false,
-				})
-				return
-			}
-		} else {
-			c.JSON(http.StatusBadRequest, map[string]any{
-				"error":  "incorrect link",
-				"status": false,
-			})
-			return
-		}
-		// Check if file exists and/or if we have permission to access it
-		f, err := fs.Open(list[0])
+	query := c.Request.URL.Query()
+	if cf.signer.Public(key) && !storage.Signed(query) {
+		cf.serve(c, key)
+		return
+	}
+
+	link, err := cf.signer.Verify(key, query)
+	switch {
+	case errors.Is(err, storage.ErrLinkRequired):
+		web.WriteError(c.Writer, c.Request, web.NewCodeError(i18n.CodeLinkRequired, http.StatusForbidden))
+		return
+	case errors.Is(err, storage.ErrLinkExpired):
+		web.WriteError(c.Writer, c.Request, web.NewCodeError(i18n.CodeLinkExpired, http.StatusForbidden))
+		return
+	case err != nil:
+		web.WriteError(c.Writer, c.Request, web.NewCodeError(i18n.CodeLinkInvalid, http.StatusForbidden))
+		return
+	}
+
+	if link.UserID != 0 {
+		claims, err := cf.claims(c)
 		if err != nil {
-			c.JSON(http.StatusBadGateway, map[string]any{
-				"error":  "file not found" + err.Error(),
-				"status": false,
-			})
+			web.WriteError(c.Writer, c.Request, web.NewCodeError(i18n.CodeUnauthorized, http.StatusUnauthorized))
 			return
 		}
-		f.Close()
-
-		http.ServeFile(c.Writer, c.Request, "./media"+list[0])
-	} else {
-		f, err := fs.Open(file)
-		if err != nil {
-			c.JSON(http.StatusBadGateway, map[string]any{
-				"error":  "file not found",
-				"status": false,
-			})
+		if claims.UserId != link.UserID {
+			web.WriteError(c.Writer, c.Request, web.NewCodeError(i18n.CodeForbidden, http.StatusForbidden))
 			return
 		}
-		f.Close()
+	}
 
-		http.ServeFile(c.Writer, c.Request, "./media"+file)
+	// What signed links point to must not end up in shared caches, nor be
+	// kept longer than the link opens.
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(time.Until(link.Expires).Seconds())))
+	cf.serve(c, key)
+}
+
+// claims validates the token of the request, read from the Authorization
+// header, the access_token cookie or the access_token query parameter, as
+// images cannot send headers.
+func (cf Controller) claims(c *gin.Context) (auth.Claims, error) {
+	token := ""
+	if header := c.GetHeader("Authorization"); header != "" {
+		parts := strings.Split(header, " ")
+		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+			return auth.Claims{}, errors.New("expected authorization header format: Bearer <token>")
+		}
+		token = parts[1]
+	} else if cookie, err := c.Cookie("access_token"); err == nil && cookie != "" {
+		token = cookie
+	} else {
+		token = c.Query("access_token")
+	}
+	if token == "" {
+		return auth.Claims{}, errors.New("token required")
 	}
 
+	return cf.auth.ValidateToken(token)
+}
+
+// serve answers with the file stored under key.
+func (cf Controller) serve(c *gin.Context, key string) {
+	err := storage.Serve(c.Request.Context(), cf.files, c.Writer, c.Request, key)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		web.WriteError(c.Writer, c.Request, web.NewCodeError(i18n.CodeFileNotFound, http.StatusNotFound))
+		return
+	}
+	if err != nil && !c.Writer.Written() {
+		web.WriteError(c.Writer, c.Request, web.NewRequestError(err, http.StatusInternalServerError))
+	}
 }
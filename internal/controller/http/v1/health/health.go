@@ -0,0 +1,71 @@
+package health
+
+import (
+	"attendance/backend/foundation/web"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/uptrace/bun"
+)
+
+// Paths of the probes. They are left out of alerts: a failing probe is
+// polled on, and would alert on every poll.
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+type Controller struct {
+	db    *bun.DB
+	redis *redis.Client
+}
+
+func NewController(db *bun.DB, redis *redis.Client) *Controller {
+	return &Controller{db, redis}
+}
+
+// Liveness reports that the process is up and serving requests.
+func (hc Controller) Liveness(c *web.Context) error {
+	return c.Respond(map[string]interface{}{
+		"data":   "ok",
+		"status": true,
+	}, http.StatusOK)
+}
+
+// Readiness reports whether the service can handle traffic, that is whether
+// Postgres and Redis are reachable. Why one is not is only logged, the probe
+// needs no authentication.
+func (hc Controller) Readiness(c *web.Context) error {
+	ctx, cancel := context.WithTimeout(c.Ctx, 2*time.Second)
+	defer cancel()
+
+	checks := map[string]string{
+		"postgres": "ok",
+		"redis":    "ok",
+	}
+	ready := true
+
+	if err := hc.db.PingContext(ctx); err != nil {
+		slog.ErrorContext(c.Ctx, "readiness: pinging postgres", "error", err)
+		checks["postgres"] = "unavailable"
+		ready = false
+	}
+	if err := hc.redis.Ping(ctx).Err(); err != nil {
+		slog.ErrorContext(c.Ctx, "readiness: pinging redis", "error", err)
+		checks["redis"] = "unavailable"
+		ready = false
+	}
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   checks,
+		"status": ready,
+	}, status)
+}
@@ -0,0 +1,14 @@
+package job
+
+import (
+	"attendance/backend/internal/repository/postgres/job"
+	"context"
+)
+
+type Job interface {
+	GetList(ctx context.Context, filter job.Filter) ([]job.Response, int, error)
+	GetDetailById(ctx context.Context, id int64) (job.Response, error)
+	GetResult(ctx context.Context, id int64) (job.Result, error)
+	Create(ctx context.Context, request job.CreateRequest) (job.Response, error)
+	Cancel(ctx context.Context, id int64) (job.Response, error)
+}
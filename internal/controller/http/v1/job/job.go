@@ -0,0 +1,138 @@
+package job
+
+import (
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/repository/postgres/job"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+type Controller struct {
+	job Job
+}
+
+func NewController(job Job) *Controller {
+	return &Controller{job}
+}
+
+// job
+
+func (jc Controller) GetList(c *web.Context) error {
+	var filter job.Filter
+
+	if limit, ok := c.GetQueryFunc(reflect.Int, "limit").(*int); ok {
+		filter.Limit = limit
+	}
+	if offset, ok := c.GetQueryFunc(reflect.Int, "offset").(*int); ok {
+		filter.Offset = offset
+	}
+	if page, ok := c.GetQueryFunc(reflect.Int, "page").(*int); ok {
+		filter.Page = page
+	}
+	if kind, ok := c.GetQueryFunc(reflect.String, "kind").(*string); ok {
+		filter.Kind = kind
+	}
+	if status, ok := c.GetQueryFunc(reflect.String, "status").(*string); ok {
+		filter.Status = status
+	}
+
+	if err := c.ValidQuery(); err != nil {
+		return c.RespondError(err)
+	}
+
+	list, count, err := jc.job.GetList(c.Ctx, filter)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data": map[string]interface{}{
+			"results": list,
+			"count":   count,
+			"kinds":   job.Kinds,
+		},
+		"status": true,
+	}, http.StatusOK)
+}
+
+// GetDetailById returns a job with its status and progress; poll it until
+// the job has finished.
+func (jc Controller) GetDetailById(c *web.Context) error {
+	id := c.GetParam(reflect.Int, "id").(int)
+
+	if err := c.ValidParam(); err != nil {
+		return c.RespondError(err)
+	}
+
+	response, err := jc.job.GetDetailById(c.Ctx, int64(id))
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   response,
+		"status": true,
+	}, http.StatusOK)
+}
+
+// GetResult downloads the file a finished job produced.
+func (jc Controller) GetResult(c *web.Context) error {
+	id := c.GetParam(reflect.Int, "id").(int)
+
+	if err := c.ValidParam(); err != nil {
+		return c.RespondError(err)
+	}
+
+	result, err := jc.job.GetResult(c.Ctx, int64(id))
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	c.Header("Content-Type", result.ContentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", result.Name))
+
+	_, err = c.Writer.Write(result.Data)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return nil
+}
+
+// Create submits a job and returns it right away, queued.
+func (jc Controller) Create(c *web.Context) error {
+	var request job.CreateRequest
+	if err := c.BindFunc(&request, "Kind"); err != nil {
+		return c.RespondError(err)
+	}
+
+	response, err := jc.job.Create(c.Ctx, request)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   response,
+		"status": true,
+	}, http.StatusAccepted)
+}
+
+// Cancel cancels a queued or running job.
+func (jc Controller) Cancel(c *web.Context) error {
+	id := c.GetParam(reflect.Int, "id").(int)
+
+	if err := c.ValidParam(); err != nil {
+		return c.RespondError(err)
+	}
+
+	response, err := jc.job.Cancel(c.Ctx, int64(id))
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   response,
+		"status": true,
+	}, http.StatusOK)
+}
@@ -0,0 +1,20 @@
+package notification
+
+import (
+	"attendance/backend/internal/repository/postgres/notification"
+	"context"
+)
+
+type Notification interface {
+	GetList(ctx context.Context, filter notification.Filter) ([]notification.GetListResponse, int, error)
+	GetSince(ctx context.Context, after int64, limit int) ([]notification.GetListResponse, error)
+	GetUnreadCount(ctx context.Context) (int, error)
+	MarkRead(ctx context.Context, id int64) error
+	MarkAllRead(ctx context.Context) (int, error)
+	GetPreferences(ctx context.Context) (notification.PreferencesResponse, error)
+	UpdatePreferences(ctx context.Context, request notification.PreferencesRequest, defaults []string) error
+}
+
+type Inbox interface {
+	Subscribe(userID int) (<-chan struct{}, func())
+}
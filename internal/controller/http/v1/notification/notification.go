@@ -0,0 +1,290 @@
+package notification
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/auth"
+	"attendance/backend/internal/repository/postgres/notification"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// streamBatch bounds the notifications sent per stream event.
+const streamBatch = 50
+
+type Controller struct {
+	notification Notification
+	inbox        Inbox
+
+	// channels are used by users who did not choose any.
+	channels []string
+}
+
+func NewController(notification Notification, inbox Inbox, channels []string) *Controller {
+	return &Controller{notification, inbox, channels}
+}
+
+// GetList returns the inbox of the signed in user, optionally narrowed by
+// unread and kind, with the number of unread notifications.
+func (nc Controller) GetList(c *web.Context) error {
+	var filter notification.Filter
+
+	if limit, ok := c.GetQueryFunc(reflect.Int, "limit").(*int); ok {
+		filter.Limit = limit
+	}
+	if offset, ok := c.GetQueryFunc(reflect.Int, "offset").(*int); ok {
+		filter.Offset = offset
+	}
+	if page, ok := c.GetQueryFunc(reflect.Int, "page").(*int); ok {
+		filter.Page = page
+	}
+	if unread, ok := c.GetQueryFunc(reflect.Bool, "unread").(*bool); ok {
+		filter.Unread = unread
+	}
+	if kind, ok := c.GetQueryFunc(reflect.String, "kind").(*string); ok {
+		filter.Kind = kind
+	}
+
+	if err := c.ValidQuery(); err != nil {
+		return c.RespondError(err)
+	}
+
+	list, count, err := nc.notification.GetList(c.Ctx, filter)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	unread, err := nc.notification.GetUnreadCount(c.Ctx)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data": map[string]interface{}{
+			"results": list,
+			"count":   count,
+			"unread":  unread,
+		},
+		"status": true,
+	}, http.StatusOK)
+}
+
+func (nc Controller) MarkRead(c *web.Context) error {
+	id := c.GetParam(reflect.Int, "id").(int)
+
+	if err := c.ValidParam(); err != nil {
+		return c.RespondError(err)
+	}
+
+	err := nc.notification.MarkRead(c.Ctx, int64(id))
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   "ok!",
+		"status": true,
+	}, http.StatusOK)
+}
+
+func (nc Controller) MarkAllRead(c *web.Context) error {
+	count, err := nc.notification.MarkAllRead(c.Ctx)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data": map[string]interface{}{
+			"count": count,
+		},
+		"status": true,
+	}, http.StatusOK)
+}
+
+// Stream pushes the inbox of the signed in user via Server-Sent Events. An
+// "unread" event with the unread count is sent on connect and whenever it
+// changes, and a "notification" event, with the notification ID as event
+// ID, for every new notification. Clients reconnecting with a Last-Event-ID
+// receive the notifications they missed.
+func (nc Controller) Stream(c *web.Context) error {
+	claims, ok := c.Ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return c.RespondError(web.NewCodeError(i18n.CodeUnauthorized, http.StatusUnauthorized))
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	// Without a Last-Event-ID only notifications created from now on are
+	// pushed; older ones are in the list.
+	var last int64
+	if lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			return c.RespondError(web.NewCodeError(i18n.CodeBadRequest, http.StatusBadRequest))
+		}
+		last = id
+	} else {
+		limit := 1
+		latest, _, err := nc.notification.GetList(c.Ctx, notification.Filter{Limit: &limit})
+		if err != nil {
+			return c.RespondError(err)
+		}
+		if len(latest) > 0 {
+			last = latest[0].ID
+		}
+	}
+
+	w := c.Writer
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return c.RespondError(web.NewCodeError(i18n.CodeInternal, http.StatusInternalServerError))
+	}
+
+	// The stream outlives the server write timeout, so lift the deadline
+	// for this connection. The stream ends with the request context.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		slog.WarnContext(c.Ctx, "sse: clearing write deadline", "error", err)
+	}
+
+	// Subscribe before catching up, so nothing created in between is missed.
+	changed, cancel := nc.inbox.Subscribe(claims.UserId)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	send := func(id, event string, data interface{}) error {
+		jsonData, err := json.Marshal(map[string]interface{}{
+			"data":   data,
+			"status": true,
+		})
+		if err != nil {
+			return err
+		}
+
+		if id != "" {
+			fmt.Fprintf(w, "id: %s\n", id)
+		}
+		fmt.Fprintf(w, "event: %s\n", event)
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", jsonData); err != nil {
+			return err
+		}
+		flusher.Flush()
+
+		return nil
+	}
+
+	unread := -1
+	catchUp := func() error {
+		for {
+			list, err := nc.notification.GetSince(c.Ctx, last, streamBatch)
+			if err != nil {
+				return err
+			}
+			for _, n := range list {
+				if err := send(strconv.FormatInt(n.ID, 10), "notification", n); err != nil {
+					return err
+				}
+				last = n.ID
+			}
+			if len(list) < streamBatch {
+				break
+			}
+		}
+
+		count, err := nc.notification.GetUnreadCount(c.Ctx)
+		if err != nil {
+			return err
+		}
+		if count != unread {
+			unread = count
+			return send("", "unread", map[string]interface{}{"count": count})
+		}
+
+		return nil
+	}
+
+	if err := catchUp(); err != nil {
+		slog.DebugContext(c.Ctx, "sse: notification stream ended", "error", err)
+		return nil
+	}
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Ctx.Done():
+			slog.DebugContext(c.Ctx, "sse: connection closed")
+			return nil
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return nil
+			}
+			flusher.Flush()
+
+		case <-changed:
+			if err := catchUp(); err != nil {
+				slog.DebugContext(c.Ctx, "sse: notification stream ended", "error", err)
+				return nil
+			}
+		}
+	}
+}
+
+// GetPreferences returns the notification preferences of the signed in user,
+// with the default channels filled in when the user never chose any.
+func (nc Controller) GetPreferences(c *web.Context) error {
+	response, err := nc.notification.GetPreferences(c.Ctx)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	if response.Default {
+		response.Channels = nc.channels
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data": map[string]interface{}{
+			"preferences": response,
+			"channels":    notification.Channels,
+			"kinds":       notification.Kinds,
+		},
+		"status": true,
+	}, http.StatusOK)
+}
+
+// UpdatePreferences changes the notification preferences of the signed in
+// user. Fields left out keep their value.
+func (nc Controller) UpdatePreferences(c *web.Context) error {
+	var request notification.PreferencesRequest
+
+	if err := c.BindFunc(&request); err != nil {
+		return c.RespondError(err)
+	}
+
+	err := nc.notification.UpdatePreferences(c.Ctx, request, nc.channels)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   "ok!",
+		"status": true,
+	}, http.StatusOK)
+}
@@ -0,0 +1,15 @@
+package report
+
+import (
+	"attendance/backend/internal/repository/postgres/report"
+	"context"
+)
+
+type Report interface {
+	Run(ctx context.Context, definition report.Definition) (report.Report, error)
+	GetList(ctx context.Context, filter report.Filter) ([]report.GetListResponse, int, error)
+	GetDetailById(ctx context.Context, id int) (report.GetDetailByIdResponse, error)
+	Create(ctx context.Context, request report.CreateRequest) (report.CreateResponse, error)
+	UpdateColumns(ctx context.Context, request report.UpdateRequest) error
+	Delete(ctx context.Context, id int) error
+}
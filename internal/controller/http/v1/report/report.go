@@ -0,0 +1,241 @@
+package report
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/pkg/export"
+	"attendance/backend/internal/repository/postgres/report"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Output formats of a report.
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+type Controller struct {
+	report Report
+}
+
+func NewController(report Report) *Controller {
+	return &Controller{report}
+}
+
+// Run computes the report described by the request body. format=csv returns
+// a CSV file instead of JSON.
+func (rc Controller) Run(c *web.Context) error {
+	var definition report.Definition
+	if err := c.BindFunc(&definition, "Metrics"); err != nil {
+		return c.RespondError(err)
+	}
+
+	return rc.respond(c, "report", definition)
+}
+
+// RunSaved computes a saved report. from and to override the saved range.
+func (rc Controller) RunSaved(c *web.Context) error {
+	id := c.GetParam(reflect.Int, "id").(int)
+
+	if err := c.ValidParam(); err != nil {
+		return c.RespondError(err)
+	}
+
+	saved, err := rc.report.GetDetailById(c.Ctx, id)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	definition := saved.Definition
+	if from, ok := c.GetQueryFunc(reflect.String, "from").(*string); ok {
+		definition.From = from
+	}
+	if to, ok := c.GetQueryFunc(reflect.String, "to").(*string); ok {
+		definition.To = to
+	}
+
+	if err := c.ValidQuery(); err != nil {
+		return c.RespondError(err)
+	}
+
+	return rc.respond(c, saved.Name, definition)
+}
+
+func (rc Controller) respond(c *web.Context, name string, definition report.Definition) error {
+	format := c.DefaultQuery("format", formatJSON)
+	if format != formatJSON && format != formatCSV {
+		return c.RespondError(web.NewCodeError(i18n.CodeBadRequest, http.StatusBadRequest))
+	}
+
+	result, err := rc.report.Run(c.Ctx, definition)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	if format == formatJSON {
+		return c.Respond(map[string]interface{}{
+			"data": map[string]interface{}{
+				"columns": result.Columns,
+				"results": result.Records(),
+				"count":   len(result.Rows),
+			},
+			"status": true,
+		}, http.StatusOK)
+	}
+
+	filename := fmt.Sprintf("%s_%s_%s.csv", name, *definition.From, *definition.To)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// The byte order mark makes spreadsheet applications read the file as
+	// UTF-8, which names in Japanese need.
+	c.Writer.WriteString("\ufeff")
+
+	w := csv.NewWriter(c.Writer)
+	w.Write(result.Columns)
+	for _, row := range result.Rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			record[i] = export.CSVCell(formatValue(v))
+		}
+		w.Write(record)
+	}
+	w.Flush()
+
+	return w.Error()
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// saved reports
+
+func (rc Controller) GetList(c *web.Context) error {
+	var filter report.Filter
+
+	if limit, ok := c.GetQueryFunc(reflect.Int, "limit").(*int); ok {
+		filter.Limit = limit
+	}
+	if offset, ok := c.GetQueryFunc(reflect.Int, "offset").(*int); ok {
+		filter.Offset = offset
+	}
+	if page, ok := c.GetQueryFunc(reflect.Int, "page").(*int); ok {
+		filter.Page = page
+	}
+	if search, ok := c.GetQueryFunc(reflect.String, "search").(*string); ok {
+		filter.Search = search
+	}
+
+	if err := c.ValidQuery(); err != nil {
+		return c.RespondError(err)
+	}
+
+	list, count, err := rc.report.GetList(c.Ctx, filter)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data": map[string]interface{}{
+			"results":    list,
+			"count":      count,
+			"dimensions": report.Dimensions,
+			"metrics":    report.Metrics,
+		},
+		"status": true,
+	}, http.StatusOK)
+}
+
+func (rc Controller) GetDetailById(c *web.Context) error {
+	id := c.GetParam(reflect.Int, "id").(int)
+
+	if err := c.ValidParam(); err != nil {
+		return c.RespondError(err)
+	}
+
+	response, err := rc.report.GetDetailById(c.Ctx, id)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   response,
+		"status": true,
+	}, http.StatusOK)
+}
+
+func (rc Controller) Create(c *web.Context) error {
+	var request report.CreateRequest
+	if err := c.BindFunc(&request, "Name", "Definition"); err != nil {
+		return c.RespondError(err)
+	}
+
+	response, err := rc.report.Create(c.Ctx, request)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   response,
+		"status": true,
+	}, http.StatusOK)
+}
+
+func (rc Controller) UpdateColumns(c *web.Context) error {
+	id := c.GetParam(reflect.Int, "id").(int)
+
+	if err := c.ValidParam(); err != nil {
+		return c.RespondError(err)
+	}
+
+	var request report.UpdateRequest
+
+	if err := c.BindFunc(&request); err != nil {
+		return c.RespondError(err)
+	}
+
+	request.ID = id
+
+	err := rc.report.UpdateColumns(c.Ctx, request)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   "ok!",
+		"status": true,
+	}, http.StatusOK)
+}
+
+func (rc Controller) Delete(c *web.Context) error {
+	id := c.GetParam(reflect.Int, "id").(int)
+
+	if err := c.ValidParam(); err != nil {
+		return c.RespondError(err)
+	}
+
+	err := rc.report.Delete(c.Ctx, id)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   "ok!",
+		"status": true,
+	}, http.StatusOK)
+}
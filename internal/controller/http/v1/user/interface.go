@@ -1,6 +1,7 @@
 package user
 
 import (
+	"attendance/backend/internal/realtime"
 	"attendance/backend/internal/repository/postgres/companyInfo"
 	"attendance/backend/internal/repository/postgres/user"
 	"context"
@@ -18,14 +19,35 @@ type User interface {
 	GetFullName(ctx context.Context) (user.GetFullName, error)
 
 	Create(ctx context.Context, request user.CreateRequest) (user.CreateResponse, error)
-	CreateByExcell(ctx context.Context, request user.ExcellRequest) (int, []int, error)
-	UpdateByExcell(ctx context.Context, request user.ExcellRequest) (int, []int, error)
-	DeleteByExcell(ctx context.Context, request user.ExcellRequest) (int, []int, error)
+	Import(ctx context.Context, request user.ExcellRequest) (user.ImportPlan, user.ImportResult, error)
+	PreviewImport(ctx context.Context, request user.ExcellRequest) (user.ImportPlan, error)
+	GetImport(ctx context.Context, id string) (user.ImportPlan, error)
+	GetImportErrors(ctx context.Context, id string) ([]byte, error)
+	ApplyImport(ctx context.Context, id string, partial bool) (user.ImportResult, error)
 	ExportEmployee(ctx context.Context) (string, error)
+	Export(ctx context.Context, format, encoding string) (user.ExportFile, error)
 	ExportTemplate(ctx context.Context) (string, error)
 	UpdateColumns(ctx context.Context, request user.UpdateRequest) error
 	Delete(ctx context.Context, id int) error
+	SetPhoto(ctx context.Context, request user.PhotoRequest) (user.PhotoResponse, error)
+	DeletePhoto(ctx context.Context, id int) error
+
+	GetProfile(ctx context.Context) (user.Profile, error)
+	UpdateProfile(ctx context.Context, request user.ProfileRequest) error
+	GetProfileFields(ctx context.Context) ([]string, error)
+	UpdateProfileFields(ctx context.Context, request user.ProfileFieldsRequest) error
+	RequestEmailChange(ctx context.Context, request user.EmailChangeRequest) (user.EmailChange, error)
+	ConfirmEmailChange(ctx context.Context, request user.EmailConfirmRequest) (string, error)
+	GetMyQrCode(ctx context.Context) (string, error)
 }
 type CompanyInfo interface {
 	GetNewTableColor(ctx context.Context) (companyInfo.GetNewTableColorResponse, error)
 }
+type Mailer interface {
+	Configured() bool
+	Send(ctx context.Context, to []string, subject, body string) error
+}
+type Dashboard interface {
+	Subscribe(departments []int, lastEventID string) (*realtime.Subscription, []realtime.Message)
+	Unsubscribe(s *realtime.Subscription)
+}
@@ -0,0 +1,142 @@
+package user
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/repository/postgres/user"
+	"log/slog"
+	"net/http"
+	"path"
+	"time"
+)
+
+// GetProfile returns the profile of the signed in user.
+func (uc Controller) GetProfile(c *web.Context) error {
+	response, err := uc.user.GetProfile(c.Ctx)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   response,
+		"status": true,
+	}, http.StatusOK)
+}
+
+// UpdateProfile changes the editable fields of the signed in user's profile.
+func (uc Controller) UpdateProfile(c *web.Context) error {
+	var request user.ProfileRequest
+	if err := c.BindFunc(&request); err != nil {
+		return c.RespondError(err)
+	}
+
+	if err := uc.user.UpdateProfile(c.Ctx, request); err != nil {
+		return c.RespondError(err)
+	}
+
+	response, err := uc.user.GetProfile(c.Ctx)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   response,
+		"status": true,
+	}, http.StatusOK)
+}
+
+// GetProfileFields lists the fields users may change themselves, and those
+// that can be made editable.
+func (uc Controller) GetProfileFields(c *web.Context) error {
+	fields, err := uc.user.GetProfileFields(c.Ctx)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data": map[string]interface{}{
+			"fields":    fields,
+			"available": user.ProfileFields,
+		},
+		"status": true,
+	}, http.StatusOK)
+}
+
+// UpdateProfileFields sets the fields users may change themselves.
+func (uc Controller) UpdateProfileFields(c *web.Context) error {
+	var request user.ProfileFieldsRequest
+	if err := c.BindFunc(&request); err != nil {
+		return c.RespondError(err)
+	}
+
+	if err := uc.user.UpdateProfileFields(c.Ctx, request); err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   "ok!",
+		"status": true,
+	}, http.StatusOK)
+}
+
+// RequestEmailChange mails a code to the new email of the signed in user.
+// The email changes once the code is confirmed.
+func (uc Controller) RequestEmailChange(c *web.Context) error {
+	if !uc.mail.Configured() {
+		return c.RespondError(web.NewCodeError(i18n.CodeMailUnavailable, http.StatusServiceUnavailable))
+	}
+
+	var request user.EmailChangeRequest
+	if err := c.BindFunc(&request); err != nil {
+		return c.RespondError(err)
+	}
+
+	change, err := uc.user.RequestEmailChange(c.Ctx, request)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	lang := web.GetLang(c.Ctx)
+	minutes := int(time.Until(change.ExpiresAt).Round(time.Minute).Minutes())
+	err = uc.mail.Send(c.Ctx, []string{change.Email}, i18n.T(lang, i18n.MsgEmailCodeSubject), i18n.T(lang, i18n.MsgEmailCode, change.Code, minutes))
+	if err != nil {
+		slog.ErrorContext(c.Ctx, "sending email code", "error", err)
+		return c.RespondError(web.NewCodeError(i18n.CodeMailUnavailable, http.StatusServiceUnavailable))
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   change,
+		"status": true,
+	}, http.StatusOK)
+}
+
+// ConfirmEmailChange changes the email of the signed in user to the pending
+// one when the code matches.
+func (uc Controller) ConfirmEmailChange(c *web.Context) error {
+	var request user.EmailConfirmRequest
+	if err := c.BindFunc(&request); err != nil {
+		return c.RespondError(err)
+	}
+
+	email, err := uc.user.ConfirmEmailChange(c.Ctx, request)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   map[string]string{"email": email},
+		"status": true,
+	}, http.StatusOK)
+}
+
+// GetMyQrCode answers with the QR badge of the signed in user.
+func (uc Controller) GetMyQrCode(c *web.Context) error {
+	key, err := uc.user.GetMyQrCode(c.Ctx)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	c.Header("Content-Disposition", "inline; filename="+path.Base(key))
+
+	return uc.serveFile(c, key)
+}
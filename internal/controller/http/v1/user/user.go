@@ -1,33 +1,37 @@
 package user
 
 import (
+	"attendance/backend/foundation/i18n"
 	"attendance/backend/foundation/web"
-	"attendance/backend/internal/pkg/config"
+	"attendance/backend/internal/pkg/period"
+	"attendance/backend/internal/realtime"
 	"attendance/backend/internal/repository/postgres/user"
-	"context"
+	"attendance/backend/internal/storage"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
+	"path"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Azure/go-autorest/autorest/date"
-	"github.com/jackc/pgx/v4/pgxpool"
 	_ "github.com/lib/pq" // PostgreSQL driver
 )
 
 type Controller struct {
 	user         User
 	company_Info CompanyInfo
+	dashboard    Dashboard
+	files        storage.Storage
+	mail         Mailer
 }
 
-func NewController(user User, company_Info CompanyInfo) *Controller {
-	return &Controller{user, company_Info}
+func NewController(user User, company_Info CompanyInfo, dashboard Dashboard, files storage.Storage, mail Mailer) *Controller {
+	return &Controller{user, company_Info, dashboard, files, mail}
 }
 
 // user
@@ -93,102 +97,80 @@ func (uc Controller) GetQrCodeByEmployeeId(c *web.Context) error {
 	// Get the 'employee_id' query parameter
 	employeeID := c.Query("employee_id")
 	if employeeID == "" {
-		return c.RespondError(web.NewRequestError(errors.New("employee_id parameter is required"), http.StatusBadRequest))
+		return c.RespondError(web.NewCodeError(i18n.CodeParamRequired, http.StatusBadRequest, "employee_id"))
 	}
 
-	// Call the repository method to get the image file path
-	filePath, err := uc.user.GetQrCodeByEmployeeID(c.Ctx, employeeID)
+	// Call the repository method to get the key of the image
+	key, err := uc.user.GetQrCodeByEmployeeID(c.Ctx, employeeID)
 	if err != nil {
 		return c.RespondError(err)
 	}
 
-	// Open the QR code image file
-	file, err := os.Open(filePath)
-	if err != nil {
-		return c.RespondError(err)
-	}
-	defer file.Close()
-
-	// Set the content type to PNG
-	c.Header("Content-Type", "image/png")
-	c.Header("Content-Disposition", "inline; filename="+filepath.Base(filePath))
-	// Write the image data to the response
-	c.Status(http.StatusOK)
-	_, err = io.Copy(c.Writer, file)
-	if err != nil {
-		return c.RespondError(err)
-	}
+	c.Header("Content-Disposition", "inline; filename="+path.Base(key))
 
-	return nil
+	return uc.serveFile(c, key)
 }
 func (uc Controller) GetQrCodeList(c *web.Context) error {
 	// Generate the PDF containing QR codes for all employees
-	pdfFilename, err := uc.user.GetQrCodeList(c.Ctx)
-	if err != nil {
-		return c.RespondError(err)
-	}
-	file, err := os.Open(pdfFilename)
+	key, err := uc.user.GetQrCodeList(c.Ctx)
 	if err != nil {
 		return c.RespondError(err)
 	}
-	defer file.Close()
-	// Set the content type to PDF
-	c.Header("Content-Type", "application/pdf")
 	c.Header("Content-Disposition", "attachment; filename=\"qr_employees.pdf\"")
-	// Write the PDF to the response
-	_, err = io.Copy(c.Writer, file)
-	if err != nil {
-		return c.RespondError(err)
-	}
-	return nil
+	return uc.serveFile(c, key)
 }
 func (uc Controller) ExportEmployee(c *web.Context) error {
 	// Generate the Excel file containing employee data
-	xlsxFilename, err := uc.user.ExportEmployee(c.Ctx)
+	key, err := uc.user.ExportEmployee(c.Ctx)
 	if err != nil {
 		return c.RespondError(err) // Handle any error from generating the Excel file
 	}
 
-	file, err := os.Open(xlsxFilename)
+	c.Header("Content-Disposition", "attachment; filename=\"employee_list.xlsx\"")
+
+	return uc.serveFile(c, key)
+}
+// Export downloads the employee list as xlsx, csv or jsonl, given by format;
+// CSV files are written in the given encoding, utf-8 by default.
+func (uc Controller) Export(c *web.Context) error {
+	file, err := uc.user.Export(c.Ctx, c.Query("format"), c.Query("encoding"))
 	if err != nil {
 		return c.RespondError(err)
 	}
-	defer file.Close()
 
-	// Set the content type for Excel files
-	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-	c.Header("Content-Disposition", "attachment; filename=\"employee_list.xlsx\"")
+	c.Header("Content-Type", file.ContentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Name))
 
-	_, err = io.Copy(c.Writer, file)
+	_, err = c.Writer.Write(file.Data)
 	if err != nil {
 		return c.RespondError(err)
 	}
-	// os.Remove("employee_list.xlsx")
+
 	return nil
 }
+
 func (uc Controller) ExportTemplate(c *web.Context) error {
 	// Generate the Excel file containing employee data
-	xlsxFilename, err := uc.user.ExportTemplate(c.Ctx)
+	key, err := uc.user.ExportTemplate(c.Ctx)
 	if err != nil {
 		return c.RespondError(err) // Handle any error from generating the Excel file
 	}
 
-	file, err := os.Open(xlsxFilename)
-	if err != nil {
-		return c.RespondError(err)
-	}
-	defer file.Close()
-
-	// Set the content type for Excel files
-	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
 	c.Header("Content-Disposition", "attachment; filename=\"template.xlsx\"")
 
-	_, err = io.Copy(c.Writer, file)
-	if err != nil {
+	return uc.serveFile(c, key)
+}
+
+// serveFile answers with the file stored under key.
+func (uc Controller) serveFile(c *web.Context, key string) error {
+	if err := storage.Serve(c.Ctx, uc.files, c.Writer, c.Request, key); err != nil {
+		if c.Writer.Written() {
+			slog.ErrorContext(c.Ctx, "serving file", "key", key, "error", err)
+			return nil
+		}
 		return c.RespondError(err)
 	}
 
-	// os.Remove("template.xls")
 	return nil
 }
 
@@ -207,45 +189,117 @@ func (uc Controller) CreateUser(c *web.Context) error {
 		"status":       true,
 	}, http.StatusOK)
 }
+
+// CreateUserByExcell imports a workbook in one go. Unless partial is set a
+// workbook with rejected rows changes nothing; the rejected rows can be
+// downloaded, marked, from GetImportErrors.
 func (uc Controller) CreateUserByExcell(c *web.Context) error {
 	var request user.ExcellRequest
 	if err := c.BindFunc(&request); err != nil {
 		return c.RespondError(err)
 	}
 
-	var response int
-	var incomplete []int // Declare outside switch to use later
-	var err error        // Declare error variable
+	plan, result, err := uc.user.Import(c.Ctx, request)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	applied := result.Created + result.Updated + result.Deleted
+	status := 1
+	if plan.Summary.Rejected > 0 && applied > 0 {
+		status = 2
+	} else if plan.Summary.Rejected > 0 {
+		status = 3
+	}
 
-	switch request.Mode {
-	case 1: // Create mode
-		response, incomplete, err = uc.user.CreateByExcell(c.Ctx, request)
-	case 2: // Update mode
-		response, incomplete, err = uc.user.UpdateByExcell(c.Ctx, request)
-	case 3: // Delete mode
-		response, incomplete, err = uc.user.DeleteByExcell(c.Ctx, request)
-	default:
-		return c.RespondError(errors.New("invalid mode specified"))
+	var rejected []user.ImportRow
+	for _, row := range plan.Rows {
+		if len(row.Errors) > 0 {
+			rejected = append(rejected, row)
+		}
 	}
 
-	// Check for any error that occurred during the operation
+	return c.Respond(map[string]interface{}{
+		"ステータス": status,
+		"data": map[string]interface{}{
+			"id":       plan.ID,
+			"summary":  plan.Summary,
+			"result":   result,
+			"rejected": rejected,
+		},
+	}, http.StatusOK)
+}
+
+// PreviewImport checks an uploaded workbook without changing anything and
+// returns what importing it would do to every row. The plan is applied by
+// ApplyImport.
+func (uc Controller) PreviewImport(c *web.Context) error {
+	var request user.ExcellRequest
+	if err := c.BindFunc(&request); err != nil {
+		return c.RespondError(err)
+	}
+
+	plan, err := uc.user.PreviewImport(c.Ctx, request)
 	if err != nil {
 		return c.RespondError(err)
 	}
 
-	status := 0
+	return c.Respond(map[string]interface{}{
+		"data":   plan,
+		"status": true,
+	}, http.StatusOK)
+}
 
-	if status == len(incomplete) {
-		status = 1
-	} else if len(incomplete) > 0 && response != 0 {
-		status = 2
-	} else if response == 0 {
-		status = 3
+func (uc Controller) GetImport(c *web.Context) error {
+	plan, err := uc.user.GetImport(c.Ctx, c.Param("id"))
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   plan,
+		"status": true,
+	}, http.StatusOK)
+}
+
+// GetImportErrors downloads the workbook of a plan with its rejected rows
+// marked.
+func (uc Controller) GetImportErrors(c *web.Context) error {
+	workbook, err := uc.user.GetImportErrors(c.Ctx, c.Param("id"))
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Header("Content-Disposition", "attachment; filename=\"import_errors.xlsx\"")
+
+	_, err = c.Writer.Write(workbook)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return nil
+}
 
+// ApplyImport carries out a previewed plan, all rows or none. With partial
+// set the rejected rows are left out and the valid ones applied.
+func (uc Controller) ApplyImport(c *web.Context) error {
+	var partial bool
+	if p, ok := c.GetQueryFunc(reflect.Bool, "partial").(*bool); ok {
+		partial = *p
+	}
+	if err := c.ValidQuery(); err != nil {
+		return c.RespondError(err)
+	}
+
+	result, err := uc.user.ApplyImport(c.Ctx, c.Param("id"), partial)
+	if err != nil {
+		return c.RespondError(err)
 	}
 
 	return c.Respond(map[string]interface{}{
-		"ステータス": status,
+		"data":   result,
+		"status": true,
 	}, http.StatusOK)
 }
 
@@ -293,31 +347,73 @@ func (uc Controller) DeleteUser(c *web.Context) error {
 	}, http.StatusOK)
 }
 
-func (uc Controller) GetStatistics(c *web.Context) error {
-	var filter user.StatisticRequest
-	// Get the 'month' query parameter
-	monthStr := c.Query("month")
-	if monthStr == "" {
-		return c.RespondError(web.NewRequestError(errors.New("month parameter is required"), http.StatusBadRequest))
+// SetPhoto uploads the profile photo of the user in the path, or of the
+// signed in user on the route without one.
+func (uc Controller) SetPhoto(c *web.Context) error {
+	var request user.PhotoRequest
+	if c.Param("id") != "" {
+		request.ID = c.GetParam(reflect.Int, "id").(int)
+
+		if err := c.ValidParam(); err != nil {
+			return c.RespondError(err)
+		}
 	}
-	fmt.Println("Month", monthStr)
-	parsedMonth, err := date.ParseDate(monthStr)
+
+	if err := c.BindFunc(&request); err != nil {
+		return c.RespondError(err)
+	}
+
+	response, err := uc.user.SetPhoto(c.Ctx, request)
 	if err != nil {
-		return c.RespondError(web.NewRequestError(errors.New("invalid date format"), http.StatusBadRequest))
+		return c.RespondError(err)
 	}
-	filter.Month = parsedMonth
 
-	// Get the 'interval' query parameter
-	intervalStr := c.Query("interval")
-	if intervalStr == "" {
-		return c.RespondError(web.NewRequestError(errors.New("interval parameter is required"), http.StatusBadRequest))
+	return c.Respond(map[string]interface{}{
+		"data":   response,
+		"status": true,
+	}, http.StatusOK)
+}
+
+// DeletePhoto removes the profile photo of the user in the path, or of the
+// signed in user on the route without one.
+func (uc Controller) DeletePhoto(c *web.Context) error {
+	id := 0
+	if c.Param("id") != "" {
+		id = c.GetParam(reflect.Int, "id").(int)
+
+		if err := c.ValidParam(); err != nil {
+			return c.RespondError(err)
+		}
 	}
 
-	interval, err := strconv.Atoi(intervalStr)
+	err := uc.user.DeletePhoto(c.Ctx, id)
 	if err != nil {
-		return c.RespondError(web.NewRequestError(errors.New("invalid interval format"), http.StatusBadRequest))
+		return c.RespondError(err)
 	}
-	filter.Interval = interval
+
+	return c.Respond(map[string]interface{}{
+		"data":   "ok!",
+		"status": true,
+	}, http.StatusOK)
+}
+
+func (uc Controller) GetStatistics(c *web.Context) error {
+	var filter user.StatisticRequest
+
+	// from/to, a preset, or the legacy month and interval
+	loc, _ := time.LoadLocation("Asia/Tokyo")
+	dateRange, err := period.FromQuery(c, time.Now().In(loc))
+	if err != nil {
+		return c.RespondError(err)
+	}
+	filter.Range = dateRange
+
+	bucket, err := period.ParseBucket(c.Query("bucket"))
+	if err != nil {
+		return c.RespondError(err)
+	}
+	filter.Bucket = bucket
+
 	list, err := uc.user.GetStatistics(c.Ctx, filter)
 	if err != nil {
 		return c.RespondError(err)
@@ -336,11 +432,11 @@ func (uc Controller) GetMonthlyStatistics(c *web.Context) error {
 	// Get the 'month' query parameter
 	monthStr := c.Query("month")
 	if monthStr == "" {
-		return c.RespondError(web.NewRequestError(errors.New("month parameter is required"), http.StatusBadRequest))
+		return c.RespondError(web.NewCodeError(i18n.CodeParamRequired, http.StatusBadRequest, "month"))
 	}
 	parsedMonth, err := date.ParseDate(monthStr)
 	if err != nil {
-		return c.RespondError(web.NewRequestError(errors.New("invalid date format"), http.StatusBadRequest))
+		return c.RespondError(web.NewCodeError(i18n.CodeDateFormat, http.StatusBadRequest))
 	}
 	filter.Month = parsedMonth
 	list, err := uc.user.GetMonthlyStatistics(c.Ctx, filter)
@@ -377,144 +473,191 @@ func (uc Controller) GetEmployeeDashboard(c *web.Context) error {
 	}, http.StatusOK)
 }
 
-var dbPool *pgxpool.Pool
-
-func ConnectDB(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
-	config, err := pgxpool.ParseConfig(dsn)
+// GetDashboardListSSE streams the dashboard via Server-Sent Events. The first
+// event carries the full list in the same shape as before, later "diff"
+// events carry only the employees that changed. Clients reconnecting with a
+// Last-Event-ID receive the diffs they missed instead of a new snapshot.
+// department_id, given repeated or comma separated, limits the stream to
+// those departments. A date other than today streams the single snapshot
+// of that day, followed by heartbeats only.
+func (uc Controller) GetDashboardListSSE(c *web.Context) error {
+	departments, err := departmentIDs(c)
 	if err != nil {
-		return nil, err
+		return c.RespondError(err)
 	}
-	config.MaxConns = 1000 // Poolning maksimal hajmini oshirish
-	pool, err := pgxpool.ConnectConfig(ctx, config)
+
+	loc, _ := time.LoadLocation("Asia/Tokyo")
+	today := time.Now().In(loc)
+	workDay, err := period.Day(c, today)
 	if err != nil {
-		return nil, err
+		return c.RespondError(err)
 	}
-	return pool, nil
-}
+	history := workDay.Format(period.Layout) != today.Format(period.Layout)
 
-// GetDashboardListSSE streams data to the client via Server-Sent Events (SSE)
-func (uc Controller) GetDashboardListSSE(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	colors, err := uc.company_Info.GetNewTableColor(c.Ctx)
+	if err != nil {
+		return c.RespondError(err)
+	}
 
+	w := c.Writer
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
-		return
+		return c.RespondError(web.NewCodeError(i18n.CodeInternal, http.StatusInternalServerError))
 	}
 
-	ctx := r.Context()
-
-	// Initialize database pool if not already done
-	if dbPool == nil {
-		yamlConfig, err := config.NewConfig()
-		if err != nil {
-			log.Printf("Error loading configuration: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
+	// The stream outlives the server write timeout, so lift the deadline
+	// for this connection. The stream ends with the request context.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		slog.WarnContext(c.Ctx, "sse: clearing write deadline", "error", err)
+	}
 
-		dsn := fmt.Sprintf("postgres://%v:%v@%v:%v/%v?sslmode=disable",
-			yamlConfig.DBUsername, yamlConfig.DBPassword, yamlConfig.DBHost, yamlConfig.DBPort, yamlConfig.DBName)
-		dbPool, err = ConnectDB(ctx, dsn)
+	var (
+		initial []realtime.Message
+		updates <-chan realtime.Message
+	)
+	// updates stays nil for other days, so it never delivers
+	if history {
+		snapshot, err := realtime.PastSnapshot(c.Ctx, uc.user, workDay, departments)
 		if err != nil {
-			log.Printf("Database connection error: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
+			return c.RespondError(err)
 		}
+		initial = []realtime.Message{snapshot}
+	} else {
+		sub, messages := uc.dashboard.Subscribe(departments, lastEventID)
+		defer uc.dashboard.Unsubscribe(sub)
+		initial, updates = messages, sub.C
 	}
 
-	dbConn, err := dbPool.Acquire(ctx)
-	if err != nil {
-		log.Printf("Failed to acquire database connection: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-	defer dbConn.Release()
-
-	// Start listening for database notifications
-	_, err = dbConn.Exec(ctx, "LISTEN attendance_changes")
-	if err != nil {
-		log.Printf("Failed to set up LISTEN: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	// Send initial data to the client
-	go func() {
-		var filter user.Filter
-		// You can extract filters from the request query if needed
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	send := func(m realtime.Message) error {
+		var (
+			event string
+			data  map[string]interface{}
+		)
+		switch m.Type {
+		case realtime.TypeSnapshot:
+			data = map[string]interface{}{
+				"bold": colors.TextBold,
+				"Colors": map[string]interface{}{
+					"new_present_color": colors.NewPresentColor,
+					"new_absent_color":  colors.NewAbsentColor,
+				},
+				"data": map[string]interface{}{
+					"results": m.Snapshot.Results,
+					"count":   m.Snapshot.Count,
+				},
+				"status": true,
+			}
+		case realtime.TypeDiff:
+			event = m.Type
+			data = map[string]interface{}{
+				"data": map[string]interface{}{
+					"changes": m.Changes,
+				},
+				"status": true,
+			}
+		default:
+			event = m.Type
+			data = map[string]interface{}{
+				"data":   m.Data,
+				"status": true,
+			}
+		}
 
-		list, count, err := uc.user.GetDashboardList(ctx, filter)
+		jsonData, err := json.Marshal(data)
 		if err != nil {
-			log.Printf("Error fetching dashboard list: %v", err)
-			return
+			return err
 		}
-		colors, err := uc.company_Info.GetNewTableColor(ctx)
-		if err != nil {
-			log.Printf("Error fetching dashboard list: %v", err)
-			return
+
+		if m.ID != "" {
+			fmt.Fprintf(w, "id: %s\n", m.ID)
 		}
+		if event != "" {
+			fmt.Fprintf(w, "event: %s\n", event)
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", jsonData); err != nil {
+			return err
+		}
+		flusher.Flush()
 
-		data := map[string]interface{}{
-
-			"bold": colors.TextBold,
-			"Colors": map[string]interface{}{
-				"new_present_color": colors.NewPresentColor,
-				"new_absent_color":  colors.NewAbsentColor,
-			},
-			"data": map[string]interface{}{
-				"results": list,
-				"count":   count,
-			},
-			"status": true,
+		return nil
+	}
+
+	for _, m := range initial {
+		if err := send(m); err != nil {
+			slog.DebugContext(c.Ctx, "sse: client gone", "error", err)
+			return nil
 		}
-		jsonData, _ := json.Marshal(data)
+	}
 
-		// Send the data
-		fmt.Fprintf(w, "data: %s\n\n", jsonData)
-		flusher.Flush()
-	}()
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
 
-	// Wait for database notifications and send updates
 	for {
-		notification, err := dbConn.Conn().WaitForNotification(ctx)
-		if err != nil {
-			if ctx.Err() != nil { // Handle client disconnect
-				log.Println("Client disconnected.")
-				break
+		select {
+		case <-c.Ctx.Done():
+			slog.DebugContext(c.Ctx, "sse: connection closed")
+			return nil
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return nil
 			}
-			log.Printf("Error waiting for notification: %v", err)
-			break
-		}
-
-		if notification.Channel == "attendance_changes" {
-			log.Println("Attendance changed notification received.")
+			flusher.Flush()
 
-			var filter user.Filter
-			// Add your filter setup here if needed
+		case m, ok := <-updates:
+			if !ok {
+				// The hub stopped or this client fell behind; it reconnects
+				// and resumes from its last event ID.
+				slog.DebugContext(c.Ctx, "sse: subscription closed")
+				return nil
+			}
+			if err := send(m); err != nil {
+				slog.DebugContext(c.Ctx, "sse: client gone", "error", err)
+				return nil
+			}
+		}
+	}
+}
 
-			list, count, err := uc.user.GetDashboardList(ctx, filter)
-			if err != nil {
-				log.Printf("Error fetching updated dashboard list: %v", err)
+// departmentIDs reads the department_id query parameter, given repeated or
+// comma separated.
+func departmentIDs(c *web.Context) ([]int, error) {
+	var ids []int
+	for _, value := range c.QueryArray("department_id") {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
 				continue
 			}
-
-			data := map[string]interface{}{
-				"data": map[string]interface{}{
-					"results": list,
-					"count":   count,
-				},
-				"status": true,
+			id, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, &web.Error{
+					Err:    errors.New("some queries are not valid"),
+					Status: http.StatusBadRequest,
+					Fields: []web.FieldError{{
+						Error: "query must be number!",
+						Code:  i18n.CodeFieldNumber,
+						Field: "department_id",
+					}},
+				}
 			}
-			jsonData, _ := json.Marshal(data)
-
-			// Send the updated data
-			fmt.Fprintf(w, "data: %s\n\n", jsonData)
-			flusher.Flush()
+			ids = append(ids, id)
 		}
 	}
 
-	log.Println("SSE connection closed.")
+	return ids, nil
 }
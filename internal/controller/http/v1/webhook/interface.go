@@ -0,0 +1,16 @@
+package webhook
+
+import (
+	"attendance/backend/internal/repository/postgres/webhook"
+	"context"
+)
+
+type Webhook interface {
+	GetList(ctx context.Context, filter webhook.Filter) ([]webhook.GetListResponse, int, error)
+	GetDetailById(ctx context.Context, id int) (webhook.GetDetailByIdResponse, error)
+	GetDeliveries(ctx context.Context, filter webhook.DeliveryFilter) ([]webhook.DeliveryResponse, int, error)
+	Create(ctx context.Context, request webhook.CreateRequest) (webhook.CreateResponse, error)
+	UpdateColumns(ctx context.Context, request webhook.UpdateRequest) error
+	Redeliver(ctx context.Context, id int64) error
+	Delete(ctx context.Context, id int) error
+}
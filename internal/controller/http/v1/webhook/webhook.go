@@ -0,0 +1,198 @@
+package webhook
+
+import (
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/repository/postgres/webhook"
+	"net/http"
+	"reflect"
+)
+
+type Controller struct {
+	webhook Webhook
+}
+
+func NewController(webhook Webhook) *Controller {
+	return &Controller{webhook}
+}
+
+// webhook
+
+func (wc Controller) GetList(c *web.Context) error {
+	var filter webhook.Filter
+
+	if limit, ok := c.GetQueryFunc(reflect.Int, "limit").(*int); ok {
+		filter.Limit = limit
+	}
+	if offset, ok := c.GetQueryFunc(reflect.Int, "offset").(*int); ok {
+		filter.Offset = offset
+	}
+	if page, ok := c.GetQueryFunc(reflect.Int, "page").(*int); ok {
+		filter.Page = page
+	}
+	if event, ok := c.GetQueryFunc(reflect.String, "event").(*string); ok {
+		filter.Event = event
+	}
+	if active, ok := c.GetQueryFunc(reflect.Bool, "active").(*bool); ok {
+		filter.Active = active
+	}
+
+	if err := c.ValidQuery(); err != nil {
+		return c.RespondError(err)
+	}
+
+	list, count, err := wc.webhook.GetList(c.Ctx, filter)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data": map[string]interface{}{
+			"results": list,
+			"count":   count,
+			"events":  webhook.Events,
+		},
+		"status": true,
+	}, http.StatusOK)
+}
+
+func (wc Controller) GetDetailById(c *web.Context) error {
+	id := c.GetParam(reflect.Int, "id").(int)
+
+	if err := c.ValidParam(); err != nil {
+		return c.RespondError(err)
+	}
+
+	response, err := wc.webhook.GetDetailById(c.Ctx, id)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   response,
+		"status": true,
+	}, http.StatusOK)
+}
+
+// Create registers a subscription. The secret used to sign deliveries is
+// generated unless given, and only returned here.
+func (wc Controller) Create(c *web.Context) error {
+	var request webhook.CreateRequest
+	if err := c.BindFunc(&request, "URL", "Events"); err != nil {
+		return c.RespondError(err)
+	}
+
+	response, err := wc.webhook.Create(c.Ctx, request)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   response,
+		"status": true,
+	}, http.StatusOK)
+}
+
+func (wc Controller) UpdateColumns(c *web.Context) error {
+	id := c.GetParam(reflect.Int, "id").(int)
+
+	if err := c.ValidParam(); err != nil {
+		return c.RespondError(err)
+	}
+
+	var request webhook.UpdateRequest
+
+	if err := c.BindFunc(&request); err != nil {
+		return c.RespondError(err)
+	}
+
+	request.ID = id
+
+	err := wc.webhook.UpdateColumns(c.Ctx, request)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   "ok!",
+		"status": true,
+	}, http.StatusOK)
+}
+
+func (wc Controller) Delete(c *web.Context) error {
+	id := c.GetParam(reflect.Int, "id").(int)
+
+	if err := c.ValidParam(); err != nil {
+		return c.RespondError(err)
+	}
+
+	err := wc.webhook.Delete(c.Ctx, id)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   "ok!",
+		"status": true,
+	}, http.StatusOK)
+}
+
+// GetDeliveries returns the delivery log, optionally narrowed by
+// subscription_id, event and status.
+func (wc Controller) GetDeliveries(c *web.Context) error {
+	var filter webhook.DeliveryFilter
+
+	if limit, ok := c.GetQueryFunc(reflect.Int, "limit").(*int); ok {
+		filter.Limit = limit
+	}
+	if offset, ok := c.GetQueryFunc(reflect.Int, "offset").(*int); ok {
+		filter.Offset = offset
+	}
+	if page, ok := c.GetQueryFunc(reflect.Int, "page").(*int); ok {
+		filter.Page = page
+	}
+	if subscriptionID, ok := c.GetQueryFunc(reflect.Int, "subscription_id").(*int); ok {
+		filter.SubscriptionID = subscriptionID
+	}
+	if event, ok := c.GetQueryFunc(reflect.String, "event").(*string); ok {
+		filter.Event = event
+	}
+	if status, ok := c.GetQueryFunc(reflect.String, "status").(*string); ok {
+		filter.Status = status
+	}
+
+	if err := c.ValidQuery(); err != nil {
+		return c.RespondError(err)
+	}
+
+	list, count, err := wc.webhook.GetDeliveries(c.Ctx, filter)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data": map[string]interface{}{
+			"results": list,
+			"count":   count,
+		},
+		"status": true,
+	}, http.StatusOK)
+}
+
+// Redeliver queues a delivery again.
+func (wc Controller) Redeliver(c *web.Context) error {
+	id := c.GetParam(reflect.Int, "id").(int)
+
+	if err := c.ValidParam(); err != nil {
+		return c.RespondError(err)
+	}
+
+	err := wc.webhook.Redeliver(c.Ctx, int64(id))
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	return c.Respond(map[string]interface{}{
+		"data":   "ok!",
+		"status": true,
+	}, http.StatusOK)
+}
@@ -0,0 +1,21 @@
+package ws
+
+import (
+	"attendance/backend/internal/realtime"
+	"attendance/backend/internal/repository/postgres/attendance"
+	"attendance/backend/internal/repository/postgres/companyInfo"
+	"context"
+	"encoding/json"
+)
+
+type Hub interface {
+	Subscribe(departments []int, lastEventID string) (*realtime.Subscription, []realtime.Message)
+	Unsubscribe(s *realtime.Subscription)
+	Publish(typ string, data json.RawMessage)
+}
+type Attendance interface {
+	CreateByQRCode(ctx context.Context, request attendance.EnterRequest) (attendance.CreateResponse, string, error)
+}
+type CompanyInfo interface {
+	GetNewTableColor(ctx context.Context) (companyInfo.GetNewTableColorResponse, error)
+}
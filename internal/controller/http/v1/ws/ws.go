@@ -0,0 +1,441 @@
+// Package ws serves the WebSocket API used by the dashboard and the kiosks.
+//
+// Every frame is a JSON object:
+//
+//	{"type": "diff", "id": "...", "data": {...}}
+//
+// Clients send "subscribe" to choose departments or resume from an event ID,
+// "scan" to register a QR code scan and "ping" for an application level
+// heartbeat. The server sends "snapshot" and "diff" from the realtime hub,
+// the "reload" and "config" commands pushed by an admin, "scan_result",
+// "pong" and "error". Replies carry the id of the request they answer.
+package ws
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/auth"
+	"attendance/backend/internal/realtime"
+	"attendance/backend/internal/repository/postgres/attendance"
+	"attendance/backend/internal/repository/postgres/companyInfo"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Message types sent by clients.
+const (
+	TypeSubscribe = "subscribe"
+	TypeScan      = "scan"
+	TypePing      = "ping"
+)
+
+// Message types sent by the server, next to the hub ones.
+const (
+	TypeScanResult = "scan_result"
+	TypePong       = "pong"
+	TypeError      = "error"
+)
+
+const (
+	// Time allowed to write a frame.
+	writeWait = 10 * time.Second
+
+	// Time allowed to read the next pong or message from the client.
+	pongWait = 60 * time.Second
+
+	// Pings are sent with this period, which must be less than pongWait.
+	pingPeriod = pongWait * 9 / 10
+
+	// Largest frame accepted from a client.
+	maxMessageSize = 64 << 10
+)
+
+// Message is a frame sent to the client.
+type Message struct {
+	Type string      `json:"type"`
+	ID   string      `json:"id,omitempty"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// request is a frame received from the client.
+type request struct {
+	Type string          `json:"type"`
+	ID   string          `json:"id"`
+	Data json.RawMessage `json:"data"`
+}
+
+type subscribeRequest struct {
+	DepartmentIDs []int  `json:"department_ids"`
+	LastEventID   string `json:"last_event_id"`
+}
+
+// CommandRequest is the body of the command endpoint.
+type CommandRequest struct {
+	Type string          `json:"type" form:"type"`
+	Data json.RawMessage `json:"data" form:"data" swaggertype:"object"`
+}
+
+type Controller struct {
+	hub          Hub
+	attendance   Attendance
+	company_Info CompanyInfo
+	upgrader     websocket.Upgrader
+}
+
+// NewController constructs the controller. Browsers may connect from the
+// listed origins besides the API's own; an empty list allows only the
+// latter. Clients that send no Origin, like kiosks, are always allowed.
+func NewController(hub Hub, attendance Attendance, company_Info CompanyInfo, origins []string) *Controller {
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		allowed[strings.ToLower(strings.TrimRight(o, "/"))] = true
+	}
+
+	return &Controller{
+		hub:          hub,
+		attendance:   attendance,
+		company_Info: company_Info,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				if origin == "" {
+					return true
+				}
+				u, err := url.Parse(origin)
+				if err != nil {
+					return false
+				}
+				if strings.EqualFold(u.Host, r.Host) {
+					return true
+				}
+				return allowed[strings.ToLower(origin)]
+			},
+		},
+	}
+}
+
+// Connect upgrades the request to a WebSocket and serves the client until
+// either side closes the connection, the token expires or the server shuts
+// down. department_id and last_event_id query parameters set the initial
+// subscription, like on the SSE stream.
+func (wc Controller) Connect(c *web.Context) error {
+	claims, ok := c.Ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return c.RespondError(web.NewCodeError(i18n.CodeUnauthorized, http.StatusUnauthorized))
+	}
+
+	var initial subscribeRequest
+	for _, value := range c.QueryArray("department_id") {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			id, err := strconv.Atoi(part)
+			if err != nil {
+				return c.RespondError(&web.Error{
+					Err:    errors.New("some queries are not valid"),
+					Status: http.StatusBadRequest,
+					Fields: []web.FieldError{{
+						Error: "query must be number!",
+						Code:  i18n.CodeFieldNumber,
+						Field: "department_id",
+					}},
+				})
+			}
+			initial.DepartmentIDs = append(initial.DepartmentIDs, id)
+		}
+	}
+	initial.LastEventID = c.Query("last_event_id")
+
+	colors, err := wc.company_Info.GetNewTableColor(c.Ctx)
+	if err != nil {
+		return c.RespondError(err)
+	}
+
+	// The upgrader answers failed handshakes itself.
+	conn, err := wc.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		slog.DebugContext(c.Ctx, "ws: upgrade failed", "error", err)
+		return nil
+	}
+	defer conn.Close()
+
+	s := session{
+		conn:        conn,
+		ctx:         c.Ctx,
+		hub:         wc.hub,
+		attendance:  wc.attendance,
+		claims:      claims,
+		colors:      colors,
+		out:         make(chan Message, 16),
+		subscribe:   make(chan subscribeRequest, 1),
+		done:        make(chan struct{}),
+		subscribing: initial,
+	}
+	s.serve()
+
+	return nil
+}
+
+// Command pushes a "reload" or "config" command to every connected client.
+// @Summary Push a command to realtime clients
+// @Tags ws
+// @Security ApiKeyAuth
+// @Param body body CommandRequest true "command"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} web.ErrorResponse
+// @Router /api/v1/ws/command [post]
+func (wc Controller) Command(c *web.Context) error {
+	var request CommandRequest
+	if err := c.BindFunc(&request, "Type"); err != nil {
+		return c.RespondError(err)
+	}
+
+	switch request.Type {
+	case realtime.TypeReload, realtime.TypeConfig:
+	default:
+		return c.RespondError(web.NewCodeError(i18n.CodeMessageUnsupported, http.StatusBadRequest, request.Type))
+	}
+
+	wc.hub.Publish(request.Type, request.Data)
+
+	return c.Respond(map[string]interface{}{
+		"data":   "ok!",
+		"status": true,
+	}, http.StatusOK)
+}
+
+// session is one connected client. The reader runs on the handler goroutine
+// and the writer owns every write to the connection.
+type session struct {
+	conn       *websocket.Conn
+	ctx        context.Context
+	hub        Hub
+	attendance Attendance
+	claims     auth.Claims
+	colors     companyInfo.GetNewTableColorResponse
+
+	out       chan Message
+	subscribe chan subscribeRequest
+	done      chan struct{}
+
+	subscribing subscribeRequest
+}
+
+func (s *session) serve() {
+	written := make(chan struct{})
+	go func() {
+		s.write()
+		close(written)
+	}()
+
+	s.conn.SetReadLimit(maxMessageSize)
+	s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	defer func() {
+		close(s.done)
+		<-written
+	}()
+
+	for {
+		var r request
+		if err := s.conn.ReadJSON(&r); err != nil {
+			var syntax *json.SyntaxError
+			var typ *json.UnmarshalTypeError
+			if errors.As(err, &syntax) || errors.As(err, &typ) {
+				s.fail("", web.NewCodeError(i18n.CodeBadRequest, http.StatusBadRequest))
+				continue
+			}
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				slog.DebugContext(s.ctx, "ws: read", "error", err)
+			}
+			return
+		}
+		s.conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		s.handle(r)
+	}
+}
+
+func (s *session) handle(r request) {
+	switch r.Type {
+	case TypePing:
+		s.send(Message{Type: TypePong, ID: r.ID})
+
+	case TypeSubscribe:
+		var sub subscribeRequest
+		if len(r.Data) > 0 {
+			if err := json.Unmarshal(r.Data, &sub); err != nil {
+				s.fail(r.ID, web.NewCodeError(i18n.CodeBadRequest, http.StatusBadRequest))
+				return
+			}
+		}
+		select {
+		case <-s.subscribe:
+		default:
+		}
+		s.subscribe <- sub
+
+	case TypeScan:
+		var scan attendance.EnterRequest
+		if err := json.Unmarshal(r.Data, &scan); err != nil {
+			s.fail(r.ID, web.NewCodeError(i18n.CodeBadRequest, http.StatusBadRequest))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
+		response, message, err := s.attendance.CreateByQRCode(ctx, scan)
+		cancel()
+		if err != nil {
+			s.fail(r.ID, err)
+			return
+		}
+
+		s.send(Message{Type: TypeScanResult, ID: r.ID, Data: map[string]interface{}{
+			"data":    response,
+			"message": message,
+			"status":  true,
+		}})
+
+	default:
+		s.fail(r.ID, web.NewCodeError(i18n.CodeMessageUnsupported, http.StatusBadRequest, r.Type))
+	}
+}
+
+// fail answers request id with the error envelope of the REST API.
+func (s *session) fail(id string, err error) {
+	response, status := web.NewErrorResponse(s.ctx, err)
+	if status >= http.StatusInternalServerError {
+		slog.ErrorContext(s.ctx, "ws: handling message", "error", err)
+	}
+	s.send(Message{Type: TypeError, ID: id, Data: response})
+}
+
+func (s *session) send(m Message) {
+	select {
+	case s.out <- m:
+	case <-s.done:
+	}
+}
+
+func (s *session) write() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	// Close the connection so the reader stops as well.
+	defer s.conn.Close()
+
+	// The connection lives no longer than the token it was opened with.
+	var expired <-chan time.Time
+	if s.claims.ExpiresAt > 0 {
+		timer := time.NewTimer(time.Until(time.Unix(s.claims.ExpiresAt, 0)))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	sub, initial := s.hub.Subscribe(s.subscribing.DepartmentIDs, s.subscribing.LastEventID)
+	defer func() { s.hub.Unsubscribe(sub) }()
+
+	if !s.writeHub(initial...) {
+		return
+	}
+
+	for {
+		select {
+		case <-s.done:
+			return
+
+		case <-s.ctx.Done():
+			s.close(websocket.CloseGoingAway, "server shutting down")
+			return
+
+		case <-expired:
+			s.close(websocket.ClosePolicyViolation, "token expired")
+			return
+
+		case <-ticker.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+
+		case m := <-s.out:
+			if !s.writeJSON(m) {
+				return
+			}
+
+		case req := <-s.subscribe:
+			s.hub.Unsubscribe(sub)
+			sub, initial = s.hub.Subscribe(req.DepartmentIDs, req.LastEventID)
+			if !s.writeHub(initial...) {
+				return
+			}
+
+		case m, ok := <-sub.C:
+			if !ok {
+				// The hub stopped or this client fell behind; it reconnects
+				// and resumes from its last event ID.
+				s.close(websocket.CloseTryAgainLater, "resubscribe")
+				return
+			}
+			if !s.writeHub(m) {
+				return
+			}
+		}
+	}
+}
+
+func (s *session) writeHub(messages ...realtime.Message) bool {
+	for _, m := range messages {
+		out := Message{Type: m.Type, ID: m.ID}
+		switch m.Type {
+		case realtime.TypeSnapshot:
+			out.Data = map[string]interface{}{
+				"colors":  s.colors,
+				"results": m.Snapshot.Results,
+				"count":   m.Snapshot.Count,
+			}
+		case realtime.TypeDiff:
+			out.Data = map[string]interface{}{
+				"changes": m.Changes,
+			}
+		default:
+			if len(m.Data) > 0 {
+				out.Data = m.Data
+			}
+		}
+
+		if !s.writeJSON(out) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *session) writeJSON(m Message) bool {
+	s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := s.conn.WriteJSON(m); err != nil {
+		slog.DebugContext(s.ctx, "ws: write", "error", err)
+		return false
+	}
+
+	return true
+}
+
+func (s *session) close(code int, text string) {
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(writeWait))
+}
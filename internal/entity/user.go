@@ -14,4 +14,6 @@ type User struct {
 	Password     *string `json:"password"   bun:"password"`
 	Role         *string `json:"role"       bun:"role"`
 	Email        *string `json:"email" bun:"email"`
+	Photo        *string `json:"photo" bun:"photo"`
+	PhotoThumb   *string `json:"photo_thumb" bun:"photo_thumb"`
 }
@@ -0,0 +1,359 @@
+// Package jobs runs the slow work of the API in the background. Jobs are
+// queued in the job table by the API and a Runner on every instance claims
+// them and runs them on a bounded pool of workers.
+//
+// A worker holds a lease on its job and extends it on every heartbeat, which
+// also records the progress and notices cancellation. A job whose worker died
+// is claimed again once the lease ran out, until its attempt budget is spent.
+package jobs
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/auth"
+	job_postgres "attendance/backend/internal/repository/postgres/job"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Store is the job queue.
+type Store interface {
+	Claim(ctx context.Context, kinds []string, lease time.Duration) (*job_postgres.Job, error)
+	Heartbeat(ctx context.Context, id int64, attempt, progress, total int, lease time.Duration) (bool, error)
+	Release(ctx context.Context, id int64, attempt int) error
+	Finish(ctx context.Context, id int64, attempt int, outcome job_postgres.Outcome) error
+}
+
+// Handler runs the jobs of one kind. The context of Run carries the claims
+// and language of the admin who submitted the job and is cancelled when the
+// job is cancelled or the runner stops. The summary of the outcome is kept
+// even when Run fails.
+type Handler interface {
+	Kind() string
+	Run(ctx context.Context, job job_postgres.Job, progress *Progress) (job_postgres.Outcome, error)
+}
+
+// Progress is the progress of a running job, recorded on every heartbeat.
+type Progress struct {
+	done  atomic.Int64
+	total atomic.Int64
+}
+
+// Set records that done of total steps are done.
+func (p *Progress) Set(done, total int) {
+	p.done.Store(int64(done))
+	p.total.Store(int64(total))
+}
+
+func (p *Progress) get() (int, int) {
+	return int(p.done.Load()), int(p.total.Load())
+}
+
+// Config tunes the runner.
+type Config struct {
+	// Workers is the number of jobs run concurrently.
+	Workers int
+
+	// PerKind is the number of jobs of one kind run concurrently.
+	PerKind int
+
+	// PollInterval is how often the queue is checked for jobs.
+	PollInterval time.Duration
+
+	// Heartbeat is how often a running job records its progress.
+	Heartbeat time.Duration
+
+	// Lease is how long a job stays claimed without a heartbeat.
+	Lease time.Duration
+
+	// MaxAttempts is the attempt budget of a job.
+	MaxAttempts int
+}
+
+// DefaultConfig returns the configuration used for zero values.
+func DefaultConfig() Config {
+	return Config{
+		Workers:      2,
+		PerKind:      1,
+		PollInterval: 2 * time.Second,
+		Heartbeat:    5 * time.Second,
+		Lease:        time.Minute,
+		MaxAttempts:  3,
+	}
+}
+
+// Runner claims and runs jobs.
+type Runner struct {
+	cfg      Config
+	store    Store
+	handlers map[string]Handler
+
+	mu      sync.Mutex
+	running map[string]int
+	freed   chan struct{}
+}
+
+// NewRunner constructs a runner of the jobs handlers know. Zero values in
+// cfg are taken from DefaultConfig.
+func NewRunner(cfg Config, store Store, handlers ...Handler) *Runner {
+	def := DefaultConfig()
+	if cfg.Workers <= 0 {
+		cfg.Workers = def.Workers
+	}
+	if cfg.PerKind <= 0 {
+		cfg.PerKind = def.PerKind
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = def.PollInterval
+	}
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = def.Heartbeat
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = def.Lease
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = def.MaxAttempts
+	}
+
+	r := &Runner{
+		cfg:      cfg,
+		store:    store,
+		handlers: make(map[string]Handler, len(handlers)),
+		running:  make(map[string]int),
+		freed:    make(chan struct{}, 1),
+	}
+	for _, h := range handlers {
+		r.handlers[h.Kind()] = h
+	}
+
+	return r
+}
+
+// Run runs jobs until ctx is done. Jobs in flight are stopped and put back in
+// the queue before it returns.
+func (r *Runner) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		for ctx.Err() == nil && r.claim(ctx, &wg) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.freed:
+		}
+	}
+}
+
+// claim starts one job if a worker is free and reports whether it did.
+func (r *Runner) claim(ctx context.Context, wg *sync.WaitGroup) bool {
+	kinds := r.free()
+	if len(kinds) == 0 {
+		return false
+	}
+
+	job, err := r.store.Claim(ctx, kinds, r.cfg.Lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("jobs: claiming job", "error", err)
+		}
+		return false
+	}
+	if job == nil {
+		return false
+	}
+
+	r.mu.Lock()
+	r.running[job.Kind]++
+	r.mu.Unlock()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			r.mu.Lock()
+			r.running[job.Kind]--
+			r.mu.Unlock()
+
+			select {
+			case r.freed <- struct{}{}:
+			default:
+			}
+		}()
+
+		r.run(ctx, *job)
+	}()
+
+	return true
+}
+
+// free returns the kinds a job can be started of.
+func (r *Runner) free() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var total int
+	for _, n := range r.running {
+		total += n
+	}
+	if total >= r.cfg.Workers {
+		return nil
+	}
+
+	var kinds []string
+	for kind := range r.handlers {
+		if r.running[kind] < r.cfg.PerKind {
+			kinds = append(kinds, kind)
+		}
+	}
+
+	return kinds
+}
+
+func (r *Runner) run(ctx context.Context, job job_postgres.Job) {
+	log := slog.With("job_id", job.ID, "kind", job.Kind, "attempt", job.Attempts)
+
+	// Record the outcome even when shutting down.
+	finish := func(outcome job_postgres.Outcome) {
+		fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		err := r.store.Finish(fctx, job.ID, job.Attempts, outcome)
+		if errors.Is(err, job_postgres.ErrLeaseLost) {
+			log.Warn("jobs: lease lost, outcome dropped", "status", outcome.Status)
+			return
+		}
+		if err != nil {
+			log.Error("jobs: recording outcome", "error", err)
+		}
+	}
+
+	if job.Attempts > r.cfg.MaxAttempts {
+		log.Warn("jobs: giving up")
+		finish(job_postgres.Outcome{
+			Status: job_postgres.StatusFailed,
+			Error:  fmt.Sprintf("gave up after %d attempts", job.Attempts-1),
+		})
+		return
+	}
+
+	// Jobs are submitted by admins only, and act as the admin who did.
+	jctx := context.WithValue(ctx, auth.Key, auth.Claims{UserId: job.CreatedBy, Role: auth.RoleAdmin})
+	jctx = web.WithLang(jctx, i18n.Normalize(job.Lang))
+	jctx, cancel := context.WithCancel(jctx)
+	defer cancel()
+
+	var progress Progress
+	var cancelled atomic.Bool
+	stop := make(chan struct{})
+	beats := make(chan struct{})
+	go func() {
+		defer close(beats)
+		r.heartbeat(ctx, job, &progress, stop, func() {
+			cancelled.Store(true)
+			cancel()
+		})
+	}()
+
+	log.Info("jobs: started")
+	start := time.Now()
+	outcome, err := r.handle(jctx, job, &progress)
+	close(stop)
+	<-beats
+
+	switch {
+	case cancelled.Load():
+		log.Info("jobs: cancelled", "duration", time.Since(start))
+		outcome.Status, outcome.Result, outcome.Error = job_postgres.StatusCancelled, nil, ""
+
+	case ctx.Err() != nil:
+		// Shutting down, leave the job to the next runner.
+		rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if err := r.store.Release(rctx, job.ID, job.Attempts); err != nil {
+			log.Error("jobs: releasing job", "error", err)
+		}
+		log.Info("jobs: released")
+		return
+
+	case err != nil:
+		log.Warn("jobs: failed", "duration", time.Since(start), "error", err)
+		outcome.Status, outcome.Result, outcome.Error = job_postgres.StatusFailed, nil, message(job.Lang, err)
+
+	default:
+		log.Info("jobs: succeeded", "duration", time.Since(start))
+		outcome.Status = job_postgres.StatusSucceeded
+	}
+
+	finish(outcome)
+}
+
+// handle runs the handler of job, turning a panic into an error.
+func (r *Runner) handle(ctx context.Context, job job_postgres.Job, progress *Progress) (outcome job_postgres.Outcome, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			slog.Error("jobs: job panicked", "job_id", job.ID, "kind", job.Kind, "error", fmt.Sprint(rec), "stack", string(debug.Stack()))
+			err = fmt.Errorf("panic: %v", rec)
+		}
+	}()
+
+	h, ok := r.handlers[job.Kind]
+	if !ok {
+		return job_postgres.Outcome{}, fmt.Errorf("no handler for %q", job.Kind)
+	}
+
+	return h.Run(ctx, job, progress)
+}
+
+// heartbeat records the progress of a job and extends its lease until stop
+// is closed, calling cancel once the job was asked to cancel or was lost.
+func (r *Runner) heartbeat(ctx context.Context, job job_postgres.Job, progress *Progress, stop <-chan struct{}, cancel func()) {
+	ticker := time.NewTicker(r.cfg.Heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		done, total := progress.get()
+		stopped, err := r.store.Heartbeat(ctx, job.ID, job.Attempts, done, total, r.cfg.Lease)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("jobs: recording progress", "job_id", job.ID, "error", err)
+			}
+			continue
+		}
+		if stopped {
+			cancel()
+			return
+		}
+	}
+}
+
+// message returns the error of a failed job in the language of the admin who
+// submitted it.
+func message(lang string, err error) string {
+	var webErr *web.Error
+	if errors.As(err, &webErr) && webErr.Code != "" {
+		return i18n.T(i18n.Normalize(lang), webErr.Code, webErr.Args...)
+	}
+
+	return err.Error()
+}
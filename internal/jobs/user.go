@@ -0,0 +1,123 @@
+package jobs
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	job_postgres "attendance/backend/internal/repository/postgres/job"
+	"attendance/backend/internal/repository/postgres/user"
+	"attendance/backend/internal/storage"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Users is the part of the user repository the user jobs use.
+type Users interface {
+	PreviewFile(ctx context.Context, mode int, file user.ImportFile) (user.ImportPlan, error)
+	ApplyImport(ctx context.Context, id string, partial bool) (user.ImportResult, error)
+	Export(ctx context.Context, format, encoding string) (user.ExportFile, error)
+	GenerateQrCodes(ctx context.Context, progress func(done, total int)) (string, error)
+}
+
+// UserImport imports the workbook of a job the way the create_excell
+// endpoint does. The plan it made is kept in the summary, so its rejected
+// rows can be downloaded from the import endpoints.
+type UserImport struct {
+	Users Users
+}
+
+func (UserImport) Kind() string {
+	return job_postgres.KindUserImport
+}
+
+func (h UserImport) Run(ctx context.Context, job job_postgres.Job, progress *Progress) (job_postgres.Outcome, error) {
+	var params job_postgres.ImportParams
+	if err := json.Unmarshal(job.Params, &params); err != nil {
+		return job_postgres.Outcome{}, errors.Wrap(err, "decoding job params")
+	}
+
+	progress.Set(0, 2)
+	plan, err := h.Users.PreviewFile(ctx, params.Mode, user.ImportFile{
+		Name:     job.InputName,
+		Format:   params.Format,
+		Encoding: params.Encoding,
+		Data:     job.Input,
+	})
+	if err != nil {
+		return job_postgres.Outcome{}, err
+	}
+	progress.Set(1, 2)
+
+	summary := map[string]interface{}{
+		"import_id": plan.ID,
+		"summary":   plan.Summary,
+	}
+	if plan.Summary.Rejected > 0 && !params.Partial {
+		return job_postgres.Outcome{Summary: summary}, web.NewCodeError(i18n.CodeImportRejected, http.StatusUnprocessableEntity, plan.Summary.Rejected)
+	}
+
+	result, err := h.Users.ApplyImport(ctx, plan.ID, params.Partial)
+	if err != nil {
+		return job_postgres.Outcome{Summary: summary}, err
+	}
+	summary["result"] = result
+
+	return job_postgres.Outcome{Summary: summary}, nil
+}
+
+// UserExport exports the employee list in the format of the job.
+type UserExport struct {
+	Users Users
+}
+
+func (UserExport) Kind() string {
+	return job_postgres.KindUserExport
+}
+
+func (h UserExport) Run(ctx context.Context, job job_postgres.Job, progress *Progress) (job_postgres.Outcome, error) {
+	var params job_postgres.ExportParams
+	if err := json.Unmarshal(job.Params, &params); err != nil {
+		return job_postgres.Outcome{}, errors.Wrap(err, "decoding job params")
+	}
+
+	progress.Set(0, 1)
+	file, err := h.Users.Export(ctx, params.Format, params.Encoding)
+	if err != nil {
+		return job_postgres.Outcome{}, err
+	}
+
+	return job_postgres.Outcome{Result: &job_postgres.Result{Name: file.Name, ContentType: file.ContentType, Data: file.Data}}, nil
+}
+
+// QrCodeList regenerates the QR codes of every employee and the PDF listing
+// them.
+type QrCodeList struct {
+	Users Users
+	Files storage.Storage
+}
+
+func (QrCodeList) Kind() string {
+	return job_postgres.KindQrCodeList
+}
+
+func (h QrCodeList) Run(ctx context.Context, job job_postgres.Job, progress *Progress) (job_postgres.Outcome, error) {
+	key, err := h.Users.GenerateQrCodes(ctx, progress.Set)
+	if err != nil {
+		return job_postgres.Outcome{}, err
+	}
+
+	return outcomeFile(ctx, h.Files, key, "qr_employees.pdf", "application/pdf")
+}
+
+// outcomeFile returns an outcome offering the file stored under key for
+// download.
+func outcomeFile(ctx context.Context, files storage.Storage, key, name, contentType string) (job_postgres.Outcome, error) {
+	data, err := storage.ReadAll(ctx, files, key)
+	if err != nil {
+		return job_postgres.Outcome{}, errors.Wrap(err, "reading job result")
+	}
+
+	return job_postgres.Outcome{Result: &job_postgres.Result{Name: name, ContentType: contentType, Data: data}}, nil
+}
@@ -0,0 +1,70 @@
+// Package mailer sends plain text mail over SMTP.
+package mailer
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// ErrNotConfigured is returned by Send when no SMTP host is set.
+var ErrNotConfigured = errors.New("mailer: no SMTP host configured")
+
+// Mailer sends mail through one SMTP server. Authentication is skipped when
+// Username is empty, which suits local relays.
+type Mailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Configured reports whether mail can be sent.
+func (m *Mailer) Configured() bool {
+	return m != nil && m.Host != ""
+}
+
+// Send sends a plain text mail to every recipient.
+func (m *Mailer) Send(ctx context.Context, to []string, subject, body string) error {
+	if !m.Configured() {
+		return ErrNotConfigured
+	}
+	if len(to) == 0 {
+		return nil
+	}
+
+	msg := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + strings.Join(to, ", "),
+		"Subject: " + mime.QEncoding.Encode("UTF-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	// net/smtp has no context support, so the send runs in the background
+	// and is abandoned when ctx expires.
+	errc := make(chan error, 1)
+	go func() {
+		errc <- smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, m.From, to, []byte(msg))
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
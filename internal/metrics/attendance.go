@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"attendance/backend/internal/repository/postgres/attendance"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AttendanceCounter provides the live attendance numbers.
+type AttendanceCounter interface {
+	GetLiveCounters(ctx context.Context) (attendance.LiveCounters, error)
+}
+
+// attendanceCollector queries the attendance counters on every scrape.
+type attendanceCollector struct {
+	counter   AttendanceCounter
+	clockedIn *prometheus.Desc
+	punches   *prometheus.Desc
+}
+
+// NewAttendanceCollector returns a collector with the number of employees
+// currently clocked in and the punches recorded during the last minute.
+func NewAttendanceCollector(counter AttendanceCounter) prometheus.Collector {
+	return &attendanceCollector{
+		counter: counter,
+		clockedIn: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "clocked_in_employees"),
+			"Number of employees currently clocked in.",
+			nil, nil,
+		),
+		punches: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "punches_per_minute"),
+			"Check-ins and check-outs recorded during the last minute.",
+			nil, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *attendanceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.clockedIn
+	ch <- c.punches
+}
+
+// Collect implements prometheus.Collector. A failed query leaves the gauges
+// out of the scrape instead of failing it.
+func (c *attendanceCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	counters, err := c.counter.GetLiveCounters(ctx)
+	if err != nil {
+		slog.Error("metrics: collecting attendance counters", "error", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.clockedIn, prometheus.GaugeValue, float64(counters.ClockedIn))
+	ch <- prometheus.MustNewConstMetric(c.punches, prometheus.GaugeValue, float64(counters.PunchesPerMinute))
+}
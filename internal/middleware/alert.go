@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/auth"
+	"attendance/backend/internal/notifier"
+	"slices"
+	"time"
+)
+
+// Alert reports failed requests to the notifier once the handler chain has
+// finished. Which statuses are reported is decided by the notifier config;
+// requests to the routes in skip are never reported.
+func Alert(n *notifier.Notifier, skip ...string) web.Middleware {
+	return func(handler web.Handler) web.Handler {
+		return func(c *web.Context) error {
+			err := handler(c)
+			if slices.Contains(skip, c.FullPath()) {
+				return err
+			}
+
+			// Only the path is sent: streams take the access token in the
+			// query.
+			event := notifier.Event{
+				Time:      time.Now(),
+				Method:    c.Request.Method,
+				URL:       c.Request.URL.Path,
+				Status:    c.Writer.Status(),
+				UserAgent: c.Request.UserAgent(),
+			}
+			if v, ok := web.GetValues(c.Ctx); ok {
+				event.RequestID = v.TraceID
+				if v.StatusCode != 0 {
+					event.Status = v.StatusCode
+				}
+				if v.Err != nil {
+					event.Error = v.Err.Error()
+				}
+			}
+			if err != nil {
+				event.Error = err.Error()
+			}
+			if claims, ok := c.Ctx.Value(auth.Key).(auth.Claims); ok {
+				event.UserID = claims.UserId
+			}
+
+			n.Notify(event)
+
+			return err
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"attendance/backend/foundation/i18n"
 	"attendance/backend/foundation/web"
 	"attendance/backend/internal/auth"
 	"context"
@@ -14,31 +15,67 @@ import (
 )
 
 func Authenticate(a *auth.Auth, role ...string) web.Middleware {
+	return authenticate(a, bearerToken, role...)
+}
+
+// AuthenticateStream is Authenticate for event streams. Browsers cannot set
+// headers on an EventSource, so the token may also be passed in the
+// access_token query parameter or cookie.
+func AuthenticateStream(a *auth.Auth, role ...string) web.Middleware {
+	return authenticate(a, streamToken, role...)
+}
+
+// bearerToken reads the token from the Authorization header.
+func bearerToken(c *web.Context) (string, error) {
+	// Expecting: Bearer <token>
+	authStr := c.Request.Header.Get("authorization")
+
+	// Parse the authorization header.
+	parts := strings.Split(authStr, " ")
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return "", errors.New("expected authorization header format: Bearer <token>")
+	}
+
+	return parts[1], nil
+}
+
+// streamToken reads the token from the Authorization header, the
+// access_token query parameter or the access_token cookie, in that order.
+func streamToken(c *web.Context) (string, error) {
+	if c.Request.Header.Get("authorization") != "" {
+		return bearerToken(c)
+	}
+	if token := c.Query("access_token"); token != "" {
+		return token, nil
+	}
+	if token, err := c.Cookie("access_token"); err == nil && token != "" {
+		return token, nil
+	}
+
+	return "", errors.New("expected a bearer token, access_token query parameter or cookie")
+}
+
+func authenticate(a *auth.Auth, token func(c *web.Context) (string, error), role ...string) web.Middleware {
 	// This is the actual middleware function to be executed.
 	m := func(handler web.Handler) web.Handler {
 
 		// Create the handler that will be attached in the middleware chain.
 		h := func(c *web.Context) error {
 
-			// Expecting: Bearer <token>
-			authStr := c.Request.Header.Get("authorization")
-
-			// Parse the authorization header.
-			parts := strings.Split(authStr, " ")
-			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-				err := errors.New("expected authorization header format: Bearer <token>")
+			tokenStr, err := token(c)
+			if err != nil {
 				return c.RespondError(web.NewRequestError(err, http.StatusUnauthorized))
 			}
 
 			// Validate the token is signed by us.
-			claims, err := a.ValidateToken(parts[1])
+			claims, err := a.ValidateToken(tokenStr)
 			if err != nil {
 				return c.RespondError(web.NewRequestError(err, http.StatusUnauthorized))
 			}
 
 			//check role inside token data
 			if ok := claims.Authorized(role...); !ok && (len(role) > 0) {
-				return c.RespondError(web.NewRequestError(errors.New("attempted action is not allowed"), http.StatusUnauthorized))
+				return c.RespondError(web.NewCodeError(i18n.CodeForbidden, http.StatusUnauthorized))
 			}
 
 			// check if claims from database
@@ -71,14 +108,14 @@ func ValidateEmailAndPhoneInput() web.Middleware {
 
 			// Validate email if provided.
 			if email == "" {
-				return c.RespondError(web.NewRequestError(errors.New("メールアドレスは必須です"), http.StatusBadRequest))
+				return c.RespondError(web.NewCodeError(i18n.CodeEmailRequired, http.StatusBadRequest))
 			}
 			if !emailRegex.MatchString(email) {
-				return c.RespondError(web.NewRequestError(errors.New("無効なメールアドレス形式"), http.StatusBadRequest))
+				return c.RespondError(web.NewCodeError(i18n.CodeEmailInvalid, http.StatusBadRequest))
 			}
 
 			if !phoneRegex.MatchString(phone) {
-				return c.RespondError(web.NewRequestError(errors.New("無効な電話番号形式"), http.StatusBadRequest))
+				return c.RespondError(web.NewCodeError(i18n.CodePhoneInvalid, http.StatusBadRequest))
 			}
 
 			// Proceed to the next handler if validation passes.
@@ -107,8 +144,16 @@ func ValidateHalfWidthInput() web.Middleware {
 			for field, values := range c.Request.Form {
 				for _, value := range values {
 					if !isHalfWidth(value) {
-						errMsg := fmt.Sprintf("入力は半角文字のみ使用可能 '%s'", field)
-						return c.RespondError(web.NewRequestError(errors.New(errMsg), http.StatusBadRequest))
+						return c.RespondError(&web.Error{
+							Err:    fmt.Errorf("only half-width characters are allowed in %q", field),
+							Status: http.StatusBadRequest,
+							Code:   i18n.CodeHalfWidthOnly,
+							Fields: []web.FieldError{{
+								Field: field,
+								Error: i18n.T(i18n.English, i18n.CodeHalfWidthOnly),
+								Code:  i18n.CodeHalfWidthOnly,
+							}},
+						})
 					}
 				}
 			}
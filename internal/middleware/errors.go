@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"attendance/backend/foundation/web"
+)
+
+// Errors answers errors returned by the handler chain with the error
+// envelope, so the logger, metrics and alert middlewares running after it
+// see the status the client gets. The error is still returned for them;
+// shutdown errors are left to the application.
+func Errors() web.Middleware {
+	return func(handler web.Handler) web.Handler {
+		return func(c *web.Context) error {
+			err := handler(c)
+			if err == nil || web.IsShutdown(err) {
+				return err
+			}
+
+			if !c.Writer.Written() {
+				_ = c.RespondError(err)
+			}
+
+			return err
+		}
+	}
+}
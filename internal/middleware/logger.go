@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/auth"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Logger writes one structured access record per request once the handler
+// chain has finished. Client errors are logged as warnings and server errors
+// as errors so they can be filtered by level.
+func Logger(log *slog.Logger) web.Middleware {
+	return func(handler web.Handler) web.Handler {
+		return func(c *web.Context) error {
+			start := time.Now()
+
+			err := handler(c)
+
+			status := c.Writer.Status()
+			reqErr := err
+			if v, ok := web.GetValues(c.Ctx); ok {
+				if v.StatusCode != 0 {
+					status = v.StatusCode
+				}
+				if reqErr == nil {
+					reqErr = v.Err
+				}
+			}
+
+			attrs := []any{
+				"method", c.Request.Method,
+				"path", c.Request.URL.Path,
+				"route", c.FullPath(),
+				"status", status,
+				"latency_ms", time.Since(start).Milliseconds(),
+				"client_ip", c.ClientIP(),
+				"user_agent", c.Request.UserAgent(),
+			}
+			if claims, ok := c.Ctx.Value(auth.Key).(auth.Claims); ok {
+				attrs = append(attrs, "user_id", claims.UserId)
+			}
+			if reqErr != nil {
+				attrs = append(attrs, "error", reqErr.Error())
+			}
+
+			level := slog.LevelInfo
+			switch {
+			case status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
+
+			log.Log(c.Ctx, level, "request completed", attrs...)
+
+			return err
+		}
+	}
+}
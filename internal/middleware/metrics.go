@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/metrics"
+	"time"
+)
+
+// Metrics records latency and status of every request under the route
+// template it was registered with.
+func Metrics(m *metrics.Metrics) web.Middleware {
+	return func(handler web.Handler) web.Handler {
+		return func(c *web.Context) error {
+			start := time.Now()
+
+			err := handler(c)
+
+			status := c.Writer.Status()
+			if v, ok := web.GetValues(c.Ctx); ok && v.StatusCode != 0 {
+				status = v.StatusCode
+			}
+
+			route := c.FullPath()
+			if route == "" {
+				route = "unmatched"
+			}
+
+			m.ObserveRequest(c.Request.Method, route, status, time.Since(start))
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// Panics recovers from panics in the handler chain, logs the stack trace and
+// answers with an internal error instead of dropping the connection. It is
+// the innermost application middleware so the logger, metrics and alert
+// middlewares still see the failed request.
+func Panics() web.Middleware {
+	return func(handler web.Handler) web.Handler {
+		return func(c *web.Context) (err error) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+
+				slog.ErrorContext(c.Ctx, "panic recovered", "panic", fmt.Sprint(rec), "stack", string(debug.Stack()))
+
+				// The panic value stays out of the response body but is
+				// recorded on the request for the logger and alerts.
+				err = c.RespondError(web.NewCodeError(i18n.CodeInternal, http.StatusInternalServerError))
+				if v, ok := web.GetValues(c.Ctx); ok {
+					v.Err = fmt.Errorf("panic: %v", rec)
+				}
+			}()
+
+			return handler(c)
+		}
+	}
+}
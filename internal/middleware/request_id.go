@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"attendance/backend/foundation/web"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// maxRequestIDLength bounds request IDs accepted from clients so that a
+// caller cannot inflate every log record of the request.
+const maxRequestIDLength = 64
+
+// RequestID assigns an ID to every request. An ID sent by the client in the
+// X-Request-ID header is reused, otherwise a new one is generated. The ID is
+// stored in the request values and echoed back in the response header.
+func RequestID() web.Middleware {
+	return func(handler web.Handler) web.Handler {
+		return func(c *web.Context) error {
+			id := strings.TrimSpace(c.Request.Header.Get(web.RequestIDHeader))
+			if id == "" || len(id) > maxRequestIDLength {
+				id = newRequestID()
+			}
+
+			if v, ok := web.GetValues(c.Ctx); ok {
+				v.TraceID = id
+			}
+			c.Header(web.RequestIDHeader, id)
+
+			return handler(c)
+		}
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,91 @@
+package notification
+
+import (
+	"attendance/backend/internal/mailer"
+	notification_postgres "attendance/backend/internal/repository/postgres/notification"
+	webhook_postgres "attendance/backend/internal/repository/postgres/webhook"
+	"context"
+	"encoding/json"
+
+	"github.com/uptrace/bun"
+)
+
+// Email sends messages by mail. Recipients without an email address are
+// skipped, and so is everyone while no SMTP host is configured.
+type Email struct {
+	Mailer *mailer.Mailer
+}
+
+// Name implements Channel.
+func (Email) Name() string {
+	return notification_postgres.ChannelEmail
+}
+
+// Send implements Channel.
+func (e Email) Send(ctx context.Context, to notification_postgres.Recipient, msg Message) error {
+	if !e.Mailer.Configured() || to.Email == nil || *to.Email == "" {
+		return nil
+	}
+
+	return e.Mailer.Send(ctx, []string{*to.Email}, msg.Title, msg.Body)
+}
+
+// Webhook queues a notification webhook event for the subscriptions
+// listening to it.
+type Webhook struct {
+	DB bun.IDB
+}
+
+// Name implements Channel.
+func (Webhook) Name() string {
+	return notification_postgres.ChannelWebhook
+}
+
+// Send implements Channel.
+func (w Webhook) Send(ctx context.Context, to notification_postgres.Recipient, msg Message) error {
+	return webhook_postgres.Enqueue(ctx, w.DB, webhook_postgres.EventNotification, map[string]interface{}{
+		"user_id":     to.UserID,
+		"employee_id": to.EmployeeID,
+		"kind":        msg.Kind,
+		"title":       msg.Title,
+		"body":        msg.Body,
+		"data":        msg.Data,
+	})
+}
+
+// InboxStore keeps the in-app inbox.
+type InboxStore interface {
+	CreateInbox(ctx context.Context, request notification_postgres.InboxRequest) (int64, error)
+}
+
+// Inbox stores messages in the in-app inbox of the recipient.
+type Inbox struct {
+	Store InboxStore
+}
+
+// Name implements Channel.
+func (Inbox) Name() string {
+	return notification_postgres.ChannelInbox
+}
+
+// Send implements Channel.
+func (i Inbox) Send(ctx context.Context, to notification_postgres.Recipient, msg Message) error {
+	var data json.RawMessage
+	if msg.Data != nil {
+		b, err := json.Marshal(msg.Data)
+		if err != nil {
+			return err
+		}
+		data = b
+	}
+
+	_, err := i.Store.CreateInbox(ctx, notification_postgres.InboxRequest{
+		UserID: to.UserID,
+		Kind:   msg.Kind,
+		Title:  msg.Title,
+		Body:   msg.Body,
+		Data:   data,
+	})
+
+	return err
+}
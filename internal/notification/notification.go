@@ -0,0 +1,174 @@
+// Package notification sends notifications to users through pluggable
+// channels. Every user picks the channels they are reached on and the kinds
+// they do not want to hear about; users who never did get the defaults.
+package notification
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/internal/repository/postgres/attendance"
+	notification_postgres "attendance/backend/internal/repository/postgres/notification"
+	"context"
+	"log/slog"
+)
+
+// Message is one notification, already in the language of its recipient.
+type Message struct {
+	Kind  string
+	Title string
+	Body  string
+
+	// Data is attached, JSON encoded, where the channel has room for it.
+	Data interface{}
+}
+
+// Channel delivers messages. Send returns nil without doing anything when
+// the recipient cannot be reached on the channel.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, to notification_postgres.Recipient, msg Message) error
+}
+
+// Store looks up recipients.
+type Store interface {
+	ClaimReminders(ctx context.Context, kind string, workDay string) ([]notification_postgres.Recipient, error)
+	GetRecipients(ctx context.Context, employeeIDs []string) ([]notification_postgres.Recipient, error)
+}
+
+// Config configures the service.
+type Config struct {
+	// Lang is used for recipients who did not choose a language.
+	Lang string
+
+	// Channels are used for recipients who did not choose any.
+	Channels []string
+}
+
+// Service sends notifications.
+type Service struct {
+	cfg      Config
+	store    Store
+	channels map[string]Channel
+}
+
+// NewService constructs a service delivering through channels. Default
+// channels that are not given are ignored.
+func NewService(cfg Config, store Store, channels ...Channel) *Service {
+	s := Service{
+		cfg:      cfg,
+		store:    store,
+		channels: make(map[string]Channel, len(channels)),
+	}
+	for _, c := range channels {
+		s.channels[c.Name()] = c
+	}
+
+	return &s
+}
+
+// Send delivers the message built by build to every recipient, in their
+// language and through their channels. Failures are logged, a channel
+// failing does not keep the others from being tried.
+func (s *Service) Send(ctx context.Context, recipients []notification_postgres.Recipient, build func(lang string) Message) {
+	for _, to := range recipients {
+		lang := s.cfg.Lang
+		if to.Lang != nil {
+			lang = *to.Lang
+		}
+		msg := build(i18n.Normalize(lang))
+
+		channels := s.cfg.Channels
+		if to.HasPreferences {
+			if muted(to.Muted, msg.Kind) {
+				continue
+			}
+			channels = to.Channels
+		}
+
+		for _, name := range channels {
+			c, ok := s.channels[name]
+			if !ok {
+				continue
+			}
+			if err := c.Send(ctx, to, msg); err != nil {
+				slog.Error("notification: sending", "error", err, "channel", name, "kind", msg.Kind, "user_id", to.UserID)
+			}
+		}
+	}
+}
+
+// Remind sends the reminders of kind that are due on workDay and returns how
+// many users were reminded. Every user is reminded once a day.
+func (s *Service) Remind(ctx context.Context, kind string, workDay string) (int, error) {
+	recipients, err := s.store.ClaimReminders(ctx, kind, workDay)
+	if err != nil {
+		return 0, err
+	}
+
+	title, body := i18n.MsgCheckInReminderSubject, i18n.MsgCheckInReminder
+	if kind == notification_postgres.KindCheckOutReminder {
+		title, body = i18n.MsgCheckOutReminderSubject, i18n.MsgCheckOutReminder
+	}
+
+	s.Send(ctx, recipients, func(lang string) Message {
+		return Message{
+			Kind:  kind,
+			Title: i18n.T(lang, title),
+			Body:  i18n.T(lang, body, workDay),
+			Data:  map[string]interface{}{"work_day": workDay},
+		}
+	})
+
+	return len(recipients), nil
+}
+
+// NotifyForgottenCheckouts tells employees their attendance was closed for
+// them. It implements scheduler.CheckoutNotifier.
+func (s *Service) NotifyForgottenCheckouts(ctx context.Context, closed []attendance.ForgottenCheckout) {
+	byEmployee := make(map[string]attendance.ForgottenCheckout, len(closed))
+	ids := make([]string, 0, len(closed))
+	for _, f := range closed {
+		byEmployee[f.EmployeeID] = f
+		ids = append(ids, f.EmployeeID)
+	}
+
+	recipients, err := s.store.GetRecipients(ctx, ids)
+	if err != nil {
+		slog.Error("notification: selecting forgotten check-out recipients", "error", err)
+		return
+	}
+
+	for _, to := range recipients {
+		f := byEmployee[to.EmployeeID]
+		s.Send(ctx, []notification_postgres.Recipient{to}, func(lang string) Message {
+			var body string
+			if f.LeaveTime != nil {
+				body = i18n.T(lang, i18n.MsgForgottenCheckoutClosed, f.WorkDay, f.ComeTime, *f.LeaveTime)
+			} else {
+				body = i18n.T(lang, i18n.MsgForgottenCheckoutFlagged, f.WorkDay, f.ComeTime)
+			}
+
+			return Message{
+				Kind:  notification_postgres.KindForgottenCheckout,
+				Title: i18n.T(lang, i18n.MsgForgottenCheckoutSubject),
+				Body:  body,
+				Data: map[string]interface{}{
+					"attendance_id": f.AttendanceID,
+					"work_day":      f.WorkDay,
+					"come_time":     f.ComeTime,
+					"leave_time":    f.LeaveTime,
+					"policy":        f.Policy,
+				},
+			}
+		})
+	}
+}
+
+func muted(kinds []string, kind string) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
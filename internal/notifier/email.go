@@ -0,0 +1,47 @@
+package notifier
+
+import (
+	"attendance/backend/internal/mailer"
+	"context"
+	"fmt"
+)
+
+// Email sends reports over SMTP. Authentication is skipped when Username is
+// empty, which suits local relays.
+type Email struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	To       []string
+	Subject  string
+}
+
+// Name implements Sink.
+func (e *Email) Name() string {
+	return "email"
+}
+
+// Send implements Sink. The whole batch is sent as a single plain text mail.
+func (e *Email) Send(ctx context.Context, events []Event) error {
+	if len(e.To) == 0 {
+		return nil
+	}
+
+	subject := e.Subject
+	if subject == "" {
+		subject = "Attendance API errors"
+	}
+	subject = fmt.Sprintf("%s (%d)", subject, len(events))
+
+	m := mailer.Mailer{
+		Host:     e.Host,
+		Port:     e.Port,
+		Username: e.Username,
+		Password: e.Password,
+		From:     e.From,
+	}
+
+	return m.Send(ctx, e.To, subject, joinEvents(events))
+}
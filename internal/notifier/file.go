@@ -0,0 +1,64 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File appends reports as JSON lines to a local file. It is meant for
+// development machines and as a fallback when no remote channel is set up.
+type File struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// NewFile constructs a File sink writing to path.
+func NewFile(path string) *File {
+	return &File{Path: path}
+}
+
+// Name implements Sink.
+func (f *File) Name() string {
+	return "file"
+}
+
+// Send implements Sink.
+func (f *File) Send(_ context.Context, events []Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(f.Path), os.ModePerm); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	enc := json.NewEncoder(file)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Nop discards every report.
+type Nop struct{}
+
+// Name implements Sink.
+func (Nop) Name() string {
+	return "nop"
+}
+
+// Send implements Sink.
+func (Nop) Send(context.Context, []Event) error {
+	return nil
+}
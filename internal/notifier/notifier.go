@@ -0,0 +1,292 @@
+// Package notifier delivers error reports to external channels such as chat
+// bots, webhooks and e-mail. Reports are queued and sent in batches by a
+// background goroutine so that a slow channel never delays an API response.
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event describes a failed request worth reporting.
+type Event struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	Method    string    `json:"method"`
+	URL       string    `json:"url"`
+	Status    int       `json:"status"`
+	UserID    int       `json:"user_id,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// String renders the event as a short multi-line text message.
+func (e Event) String() string {
+	lines := []string{fmt.Sprintf("Time: %s", e.Time.Format("02-01-2006 15:04:05"))}
+	if e.Method != "" {
+		lines = append(lines,
+			fmt.Sprintf("Status: %d", e.Status),
+			fmt.Sprintf("Request: %s %s", e.Method, e.URL),
+		)
+	}
+	if e.RequestID != "" {
+		lines = append(lines, fmt.Sprintf("RequestID: %s", e.RequestID))
+	}
+	if e.UserID != 0 {
+		lines = append(lines, fmt.Sprintf("UserID: %d", e.UserID))
+	}
+	if e.UserAgent != "" {
+		lines = append(lines, fmt.Sprintf("Useragent: %s", e.UserAgent))
+	}
+	if e.Error != "" {
+		lines = append(lines, fmt.Sprintf("Error: %s", e.Error))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Sink is a channel that error reports are delivered to.
+type Sink interface {
+	// Name identifies the sink in logs.
+	Name() string
+
+	// Send delivers a batch of events. Implementations must honour ctx.
+	Send(ctx context.Context, events []Event) error
+}
+
+// Config controls filtering, batching and rate limiting of reports.
+type Config struct {
+	// MinStatus is the lowest response status that is reported.
+	MinStatus int
+
+	// QueueSize is the number of events buffered before new ones are dropped.
+	QueueSize int
+
+	// BatchSize is the maximum number of events sent in a single message.
+	BatchSize int
+
+	// FlushInterval is how long events may wait before a partial batch is sent.
+	FlushInterval time.Duration
+
+	// RateLimit is the maximum number of batches a sink receives per
+	// RatePeriod. Events over the limit are dropped and counted.
+	RateLimit  int
+	RatePeriod time.Duration
+
+	// SendTimeout bounds a single delivery to a sink.
+	SendTimeout time.Duration
+}
+
+// DefaultConfig reports server errors only, in batches of up to 10 events,
+// and sends a sink at most 20 messages per minute.
+func DefaultConfig() Config {
+	return Config{
+		MinStatus:     http.StatusInternalServerError,
+		QueueSize:     1000,
+		BatchSize:     10,
+		FlushInterval: 5 * time.Second,
+		RateLimit:     20,
+		RatePeriod:    time.Minute,
+		SendTimeout:   10 * time.Second,
+	}
+}
+
+// Notifier queues events and fans them out to its sinks asynchronously.
+type Notifier struct {
+	cfg    Config
+	sinks  []*limitedSink
+	queue  chan Event
+	done   chan struct{}
+	once   sync.Once
+	abort  sync.Once
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	closed bool
+}
+
+// New constructs a Notifier and starts its delivery goroutine. Zero values in
+// cfg are replaced by the matching DefaultConfig values.
+func New(cfg Config, sinks ...Sink) *Notifier {
+	def := DefaultConfig()
+	if cfg.MinStatus == 0 {
+		cfg.MinStatus = def.MinStatus
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = def.QueueSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = def.BatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = def.FlushInterval
+	}
+	if cfg.RateLimit <= 0 {
+		cfg.RateLimit = def.RateLimit
+	}
+	if cfg.RatePeriod <= 0 {
+		cfg.RatePeriod = def.RatePeriod
+	}
+	if cfg.SendTimeout <= 0 {
+		cfg.SendTimeout = def.SendTimeout
+	}
+
+	n := Notifier{
+		cfg:   cfg,
+		queue: make(chan Event, cfg.QueueSize),
+		done:  make(chan struct{}),
+	}
+	for _, s := range sinks {
+		if s != nil {
+			n.sinks = append(n.sinks, newLimitedSink(s, cfg.RateLimit, cfg.RatePeriod))
+		}
+	}
+
+	n.wg.Add(1)
+	go n.run()
+
+	return &n
+}
+
+// Notify queues an event for delivery. Events below the configured minimum
+// status are ignored and events are dropped when the queue is full, so Notify
+// never blocks the caller.
+func (n *Notifier) Notify(e Event) {
+	if n == nil || len(n.sinks) == 0 || e.Status < n.cfg.MinStatus {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return
+	}
+
+	select {
+	case n.queue <- e:
+	default:
+		slog.Warn("notifier: queue full, dropping event", "request_id", e.RequestID, "status", e.Status)
+	}
+}
+
+// Close stops accepting events and flushes the queued ones. It returns when
+// everything was delivered or ctx is done.
+func (n *Notifier) Close(ctx context.Context) error {
+	if n == nil {
+		return nil
+	}
+
+	n.once.Do(func() {
+		n.mu.Lock()
+		n.closed = true
+		close(n.queue)
+		n.mu.Unlock()
+	})
+
+	finished := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		n.abort.Do(func() { close(n.done) })
+		return ctx.Err()
+	}
+}
+
+func (n *Notifier) run() {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(n.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, n.cfg.BatchSize)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		n.deliver(batch)
+		batch = make([]Event, 0, n.cfg.BatchSize)
+	}
+
+	for {
+		select {
+		case e, ok := <-n.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= n.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-n.done:
+			return
+		}
+	}
+}
+
+func (n *Notifier) deliver(events []Event) {
+	for _, s := range n.sinks {
+		ctx, cancel := context.WithTimeout(context.Background(), n.cfg.SendTimeout)
+		if err := s.Send(ctx, events); err != nil {
+			slog.Error("notifier: delivering events", "sink", s.Name(), "events", len(events), "error", err)
+		}
+		cancel()
+	}
+}
+
+// limitedSink wraps a Sink with a fixed-window rate limit. Batches over the
+// limit are dropped and the number of lost events is reported with the next
+// batch that gets through.
+type limitedSink struct {
+	Sink
+	limit   int
+	period  time.Duration
+	window  time.Time
+	sent    int
+	dropped int
+}
+
+func newLimitedSink(s Sink, limit int, period time.Duration) *limitedSink {
+	return &limitedSink{Sink: s, limit: limit, period: period}
+}
+
+func (l *limitedSink) Send(ctx context.Context, events []Event) error {
+	now := time.Now()
+	if now.Sub(l.window) >= l.period {
+		l.window = now
+		l.sent = 0
+	}
+
+	if l.sent >= l.limit {
+		l.dropped += len(events)
+		return nil
+	}
+	l.sent++
+
+	if l.dropped > 0 {
+		events = append([]Event{{
+			Time:  now,
+			Error: fmt.Sprintf("%d events were suppressed by the rate limit", l.dropped),
+		}}, events...)
+		l.dropped = 0
+	}
+
+	return l.Sink.Send(ctx, events)
+}
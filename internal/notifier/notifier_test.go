@@ -0,0 +1,108 @@
+package notifier_test
+
+import (
+	"attendance/backend/internal/notifier"
+	"attendance/backend/internal/notifier/notifiertest"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTelegramSendsToEveryChat(t *testing.T) {
+	srv := notifiertest.NewServer()
+	defer srv.Close()
+
+	tg := notifier.NewTelegram(srv.URL, "123:token", []string{"10", "20"})
+	if err := tg.Send(context.Background(), []notifier.Event{{Method: "GET", URL: "/x", Status: 500}}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 2 {
+		t.Fatalf("got %d requests, want 2", len(reqs))
+	}
+	for i, chat := range []string{"10", "20"} {
+		if reqs[i].Path != "/bot123:token/sendMessage" {
+			t.Errorf("request %d: path %q", i, reqs[i].Path)
+		}
+		if reqs[i].Body["chat_id"] != chat {
+			t.Errorf("request %d: chat_id %v, want %s", i, reqs[i].Body["chat_id"], chat)
+		}
+		if text, _ := reqs[i].Body["text"].(string); !strings.Contains(text, "/x") {
+			t.Errorf("request %d: text %q does not mention the URL", i, text)
+		}
+	}
+}
+
+func TestWebhookSendsEvents(t *testing.T) {
+	srv := notifiertest.NewServer()
+	defer srv.Close()
+
+	wh := notifier.NewWebhook(srv.URL + "/hook")
+	if err := wh.Send(context.Background(), []notifier.Event{{Status: 502}, {Status: 503}}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("got %d requests, want 1", len(reqs))
+	}
+	if events, _ := reqs[0].Body["events"].([]interface{}); len(events) != 2 {
+		t.Errorf("got %d events, want 2", len(events))
+	}
+}
+
+func TestSinkErrorsHideToken(t *testing.T) {
+	srv := notifiertest.NewServer()
+	srv.Status = http.StatusInternalServerError
+	defer srv.Close()
+
+	closed := notifiertest.NewServer()
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		baseURL string
+	}{
+		{"status", srv.URL},
+		{"connection refused", closed.URL},
+		{"invalid url", "http://bad host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := notifier.NewTelegram(tt.baseURL, "secret-token", []string{"1"}).Send(context.Background(), []notifier.Event{{}})
+			if err == nil {
+				t.Fatal("Send succeeded, want an error")
+			}
+			if strings.Contains(err.Error(), "secret-token") {
+				t.Errorf("error %q contains the token", err)
+			}
+		})
+	}
+}
+
+func TestNotifierFiltersAndFlushesOnClose(t *testing.T) {
+	srv := notifiertest.NewServer()
+	defer srv.Close()
+
+	n := notifier.New(notifier.Config{FlushInterval: time.Hour}, notifier.NewWebhook(srv.URL))
+	n.Notify(notifier.Event{Status: 404})
+	n.Notify(notifier.Event{Status: 500})
+	n.Notify(notifier.Event{Status: 503})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("got %d requests, want 1", len(reqs))
+	}
+	if events, _ := reqs[0].Body["events"].([]interface{}); len(events) != 2 {
+		t.Errorf("got %d events, want the 2 server errors", len(events))
+	}
+}
@@ -0,0 +1,59 @@
+// Package notifiertest provides a local HTTP stand-in for the remote services
+// used by the notifier sinks, so deliveries can be inspected without network
+// access.
+package notifiertest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Request is a request captured by the Server.
+type Request struct {
+	Method string
+	Path   string
+	Body   map[string]interface{}
+}
+
+// Server records every request it receives and answers with Status. It can
+// be used as the base URL of the Telegram sink or the URL of the Webhook sink.
+type Server struct {
+	*httptest.Server
+	Status int
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+// NewServer starts a Server answering 200 OK. Callers must Close it.
+func NewServer() *Server {
+	s := Server{Status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return &s
+}
+
+// Requests returns a copy of the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	req := Request{Method: r.Method, Path: r.URL.Path}
+	_ = json.Unmarshal(body, &req.Body)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	status := s.Status
+	s.mu.Unlock()
+
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(`{"ok":true}`))
+}
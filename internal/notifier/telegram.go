@@ -0,0 +1,67 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DefaultTelegramURL is the Telegram Bot API endpoint.
+const DefaultTelegramURL = "https://api.telegram.org"
+
+// Telegram sends reports to one or more Telegram chats through a bot.
+type Telegram struct {
+	BaseURL string
+	Token   string
+	ChatIDs []string
+	Client  *http.Client
+}
+
+// NewTelegram constructs a Telegram sink. An empty baseURL selects the public
+// Bot API; tests point it at a local stand-in instead.
+func NewTelegram(baseURL, token string, chatIDs []string) *Telegram {
+	if baseURL == "" {
+		baseURL = DefaultTelegramURL
+	}
+
+	return &Telegram{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+		ChatIDs: chatIDs,
+		Client:  &http.Client{},
+	}
+}
+
+// Name implements Sink.
+func (t *Telegram) Name() string {
+	return "telegram"
+}
+
+// Send implements Sink. All events of a batch are joined into one message
+// per chat.
+func (t *Telegram) Send(ctx context.Context, events []Event) error {
+	url := fmt.Sprintf("%s/bot%s/sendMessage", t.BaseURL, t.Token)
+	text := joinEvents(events)
+
+	for _, id := range t.ChatIDs {
+		err := postJSON(ctx, t.Client, url, map[string]interface{}{
+			"chat_id": id,
+			"text":    text,
+		})
+		if err != nil {
+			return fmt.Errorf("chat %s: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+func joinEvents(events []Event) string {
+	parts := make([]string, 0, len(events))
+	for _, e := range events {
+		parts = append(parts, e.String())
+	}
+
+	return strings.Join(parts, "\n\n")
+}
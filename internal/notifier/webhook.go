@@ -0,0 +1,76 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+)
+
+// Webhook posts reports to a Slack-style incoming webhook. The payload has a
+// "text" field understood by Slack, Mattermost and similar chat tools, and
+// the raw events for consumers that want structured data.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhook constructs a Webhook sink.
+func NewWebhook(url string) *Webhook {
+	return &Webhook{URL: url, Client: &http.Client{}}
+}
+
+// Name implements Sink.
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+// Send implements Sink.
+func (w *Webhook) Send(ctx context.Context, events []Event) error {
+	return postJSON(ctx, w.Client, w.URL, map[string]interface{}{
+		"text":   joinEvents(events),
+		"events": events,
+	})
+}
+
+// postJSON posts payload to url. URLs carry secrets, like the token of a
+// Telegram bot or the key of a chat webhook, so errors never include them.
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return withoutURL(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return withoutURL(err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// withoutURL drops the URL from the errors of net/http and net/url.
+func withoutURL(err error) error {
+	var urlErr *neturl.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s request: %w", urlErr.Op, urlErr.Err)
+	}
+
+	return err
+}
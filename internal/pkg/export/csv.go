@@ -0,0 +1,41 @@
+// Package export keeps exported files safe to open in spreadsheet
+// applications.
+package export
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CSVCell returns s as it is safe to write in a CSV file. Spreadsheet
+// applications run cells starting with =, +, -, @, a tab or a carriage return
+// as formulas, so those are prefixed with an apostrophe; numbers are kept.
+func CSVCell(s string) string {
+	if s == "" || !strings.ContainsAny(s[:1], "=+-@\t\r") {
+		return s
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return s
+	}
+
+	return "'" + s
+}
+
+// CSVValue undoes CSVCell, so that exported files can be imported again.
+func CSVValue(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsAny(s[1:2], "=+-@\t\r") {
+		return s[1:]
+	}
+
+	return s
+}
+
+// CSVRecord applies CSVCell to every cell of a record.
+func CSVRecord(cells []string) []string {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		record[i] = CSVCell(cell)
+	}
+
+	return record
+}
@@ -0,0 +1,25 @@
+package export
+
+import "testing"
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"Tanaka", "Tanaka"},
+		{"=1+2", "'=1+2"},
+		{"+81 90", "'+81 90"},
+		{"-cmd", "'-cmd"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"-12.5", "-12.5"},
+		{"+81", "+81"},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		if got := CSVCell(tt.in); got != tt.want {
+			t.Errorf("CSVCell(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Package period resolves the date range parameters of the statistics
+// endpoints into a validated range of days and splits it into buckets.
+package period
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Layout is the format of the days taken and returned.
+const Layout = "2006-01-02"
+
+// MaxDays is the longest range accepted, by the statistics and the reports
+// alike: two years, so a year can be compared with the one before.
+const MaxDays = 731
+
+// Presets select the calendar period around an anchor day.
+const (
+	PresetWeek    = "week"
+	PresetMonth   = "month"
+	PresetQuarter = "quarter"
+	PresetYear    = "year"
+)
+
+// Presets are the known presets.
+var Presets = []string{PresetWeek, PresetMonth, PresetQuarter, PresetYear}
+
+// Buckets group the days of a series.
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// Buckets are the known buckets.
+var Buckets = []string{BucketDay, BucketWeek, BucketMonth}
+
+// Range is an inclusive range of days, both at midnight UTC.
+type Range struct {
+	From time.Time
+	To   time.Time
+}
+
+// Bucket is a part of a range.
+type Bucket struct {
+	From time.Time
+	To   time.Time
+}
+
+// Query reads request query parameters; *web.Context implements it.
+type Query interface {
+	Query(key string) string
+}
+
+// FromQuery resolves the range of a request. It takes, in this order:
+//
+//   - from and to, two days;
+//   - preset, the week, month, quarter or year around date, today by default;
+//   - month and interval, the legacy parameters, where interval 0, 1 and 2
+//     select days 1-10, 11-20 and 21 to the end of the month. Without an
+//     interval the whole month is taken.
+func FromQuery(q Query, now time.Time) (Range, error) {
+	from, to := q.Query("from"), q.Query("to")
+	if from != "" || to != "" {
+		if from == "" {
+			return Range{}, web.NewCodeError(i18n.CodeParamRequired, http.StatusBadRequest, "from")
+		}
+		if to == "" {
+			return Range{}, web.NewCodeError(i18n.CodeParamRequired, http.StatusBadRequest, "to")
+		}
+
+		start, err := parseDay(from)
+		if err != nil {
+			return Range{}, err
+		}
+		end, err := parseDay(to)
+		if err != nil {
+			return Range{}, err
+		}
+
+		return New(start, end)
+	}
+
+	if preset := q.Query("preset"); preset != "" {
+		anchor := day(now)
+		if s := q.Query("date"); s != "" {
+			d, err := parseDay(s)
+			if err != nil {
+				return Range{}, err
+			}
+			anchor = d
+		}
+
+		return Preset(preset, anchor)
+	}
+
+	if month := q.Query("month"); month != "" {
+		m, err := parseDay(month)
+		if err != nil {
+			return Range{}, err
+		}
+
+		s := q.Query("interval")
+		if s == "" {
+			return Preset(PresetMonth, m)
+		}
+		interval, err := strconv.Atoi(s)
+		if err != nil {
+			return Range{}, web.NewCodeError(i18n.CodeInterval, http.StatusBadRequest)
+		}
+
+		return Interval(m, interval)
+	}
+
+	return Range{}, web.NewCodeError(i18n.CodeParamRequired, http.StatusBadRequest, "from")
+}
+
+// Day resolves the optional date parameter of a request, today when it is
+// not given.
+func Day(q Query, now time.Time) (time.Time, error) {
+	s := q.Query("date")
+	if s == "" {
+		return day(now), nil
+	}
+
+	return parseDay(s)
+}
+
+// New validates a range.
+func New(from, to time.Time) (Range, error) {
+	from, to = day(from), day(to)
+	if to.Before(from) || int(to.Sub(from).Hours()/24)+1 > MaxDays {
+		return Range{}, web.NewCodeError(i18n.CodeRangeInvalid, http.StatusBadRequest, MaxDays)
+	}
+
+	return Range{From: from, To: to}, nil
+}
+
+// Preset returns the calendar period of kind preset containing anchor. Weeks
+// start on Monday.
+func Preset(preset string, anchor time.Time) (Range, error) {
+	anchor = day(anchor)
+	y, m, _ := anchor.Date()
+
+	var from time.Time
+	switch preset {
+	case PresetWeek:
+		from = anchor.AddDate(0, 0, -(int(anchor.Weekday())+6)%7)
+		return New(from, from.AddDate(0, 0, 6))
+	case PresetMonth:
+		from = time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+		return New(from, from.AddDate(0, 1, -1))
+	case PresetQuarter:
+		from = time.Date(y, m-(m-1)%3, 1, 0, 0, 0, 0, time.UTC)
+		return New(from, from.AddDate(0, 3, -1))
+	case PresetYear:
+		from = time.Date(y, time.January, 1, 0, 0, 0, 0, time.UTC)
+		return New(from, from.AddDate(1, 0, -1))
+	}
+
+	return Range{}, web.NewCodeError(i18n.CodePresetInvalid, http.StatusBadRequest, preset)
+}
+
+// Interval returns the legacy ten day interval of month.
+func Interval(month time.Time, interval int) (Range, error) {
+	y, m, _ := month.Date()
+	first := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+
+	switch interval {
+	case 0:
+		return New(first, first.AddDate(0, 0, 9))
+	case 1:
+		return New(first.AddDate(0, 0, 10), first.AddDate(0, 0, 19))
+	case 2:
+		return New(first.AddDate(0, 0, 20), first.AddDate(0, 1, -1))
+	}
+
+	return Range{}, web.NewCodeError(i18n.CodeInterval, http.StatusBadRequest)
+}
+
+// ParseBucket validates a bucket, empty meaning BucketDay.
+func ParseBucket(bucket string) (string, error) {
+	if bucket == "" {
+		return BucketDay, nil
+	}
+	for _, b := range Buckets {
+		if b == bucket {
+			return bucket, nil
+		}
+	}
+
+	return "", web.NewCodeError(i18n.CodeBucketInvalid, http.StatusBadRequest, bucket)
+}
+
+// Days returns every day of the range.
+func (r Range) Days() []time.Time {
+	var days []time.Time
+	for d := r.From; !d.After(r.To); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+
+	return days
+}
+
+// Split splits the range into consecutive buckets. The first and the last
+// bucket are cut to the range.
+func (r Range) Split(bucket string) []Bucket {
+	var buckets []Bucket
+	for from := r.From; !from.After(r.To); {
+		var next time.Time
+		switch bucket {
+		case BucketWeek:
+			next = from.AddDate(0, 0, 7-(int(from.Weekday())+6)%7)
+		case BucketMonth:
+			next = time.Date(from.Year(), from.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		default:
+			next = from.AddDate(0, 0, 1)
+		}
+
+		to := next.AddDate(0, 0, -1)
+		if to.After(r.To) {
+			to = r.To
+		}
+		buckets = append(buckets, Bucket{From: from, To: to})
+		from = next
+	}
+
+	return buckets
+}
+
+// Contains reports whether the day is in the bucket.
+func (b Bucket) Contains(d time.Time) bool {
+	return !d.Before(b.From) && !d.After(b.To)
+}
+
+func parseDay(s string) (time.Time, error) {
+	d, err := time.Parse(Layout, s)
+	if err != nil {
+		return time.Time{}, web.NewCodeError(i18n.CodeDateFormat, http.StatusBadRequest)
+	}
+
+	return d, nil
+}
+
+func day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,138 @@
+package period
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type query url.Values
+
+func (q query) Query(key string) string {
+	return url.Values(q).Get(key)
+}
+
+func date(s string) time.Time {
+	d, err := time.Parse(Layout, s)
+	if err != nil {
+		panic(err)
+	}
+
+	return d
+}
+
+func TestFromQuery(t *testing.T) {
+	now := time.Date(2024, time.May, 15, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		query    string
+		from, to string
+		code     string
+	}{
+		{"from and to", "from=2024-01-01&to=2024-01-31", "2024-01-01", "2024-01-31", ""},
+		{"single day", "from=2024-02-29&to=2024-02-29", "2024-02-29", "2024-02-29", ""},
+		{"longest", "from=2023-01-01&to=2024-12-31", "2023-01-01", "2024-12-31", ""},
+		{"too long", "from=2023-01-01&to=2025-01-01", "", "", i18n.CodeRangeInvalid},
+		{"reversed", "from=2024-02-01&to=2024-01-01", "", "", i18n.CodeRangeInvalid},
+		{"from only", "from=2024-01-01", "", "", i18n.CodeParamRequired},
+		{"to only", "to=2024-01-01", "", "", i18n.CodeParamRequired},
+		{"bad date", "from=2024-1-1&to=2024-01-31", "", "", i18n.CodeDateFormat},
+		{"week today", "preset=week", "2024-05-13", "2024-05-19", ""},
+		{"week of sunday", "preset=week&date=2024-05-19", "2024-05-13", "2024-05-19", ""},
+		{"month", "preset=month&date=2024-02-10", "2024-02-01", "2024-02-29", ""},
+		{"quarter", "preset=quarter&date=2024-08-20", "2024-07-01", "2024-09-30", ""},
+		{"year", "preset=year", "2024-01-01", "2024-12-31", ""},
+		{"unknown preset", "preset=decade", "", "", i18n.CodePresetInvalid},
+		{"legacy month", "month=2024-02-01", "2024-02-01", "2024-02-29", ""},
+		{"legacy interval 0", "month=2024-02-01&interval=0", "2024-02-01", "2024-02-10", ""},
+		{"legacy interval 2", "month=2024-02-01&interval=2", "2024-02-21", "2024-02-29", ""},
+		{"legacy interval 3", "month=2024-02-01&interval=3", "", "", i18n.CodeInterval},
+		{"legacy interval text", "month=2024-02-01&interval=x", "", "", i18n.CodeInterval},
+		{"nothing", "", "", "", i18n.CodeParamRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := FromQuery(query(values), now)
+			if tt.code != "" {
+				var webErr *web.Error
+				if !errors.As(err, &webErr) || webErr.Code != tt.code {
+					t.Fatalf("got error %v, want code %s", err, tt.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromQuery: %v", err)
+			}
+			if !r.From.Equal(date(tt.from)) || !r.To.Equal(date(tt.to)) {
+				t.Errorf("got %s to %s, want %s to %s", r.From.Format(Layout), r.To.Format(Layout), tt.from, tt.to)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	r := Range{From: date("2024-01-30"), To: date("2024-03-05")}
+
+	tests := []struct {
+		bucket string
+		want   [][2]string
+	}{
+		{BucketMonth, [][2]string{
+			{"2024-01-30", "2024-01-31"},
+			{"2024-02-01", "2024-02-29"},
+			{"2024-03-01", "2024-03-05"},
+		}},
+		{BucketWeek, [][2]string{
+			{"2024-01-30", "2024-02-04"},
+			{"2024-02-05", "2024-02-11"},
+			{"2024-02-12", "2024-02-18"},
+			{"2024-02-19", "2024-02-25"},
+			{"2024-02-26", "2024-03-03"},
+			{"2024-03-04", "2024-03-05"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.bucket, func(t *testing.T) {
+			got := r.Split(tt.bucket)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d buckets, want %d", len(got), len(tt.want))
+			}
+			for i, b := range got {
+				if !b.From.Equal(date(tt.want[i][0])) || !b.To.Equal(date(tt.want[i][1])) {
+					t.Errorf("bucket %d: got %s to %s, want %s to %s", i, b.From.Format(Layout), b.To.Format(Layout), tt.want[i][0], tt.want[i][1])
+				}
+			}
+		})
+	}
+
+	if days := len(r.Split(BucketDay)); days != len(r.Days()) || days != 36 {
+		t.Errorf("got %d day buckets and %d days, want 36", days, len(r.Days()))
+	}
+}
+
+func TestParseBucket(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"", BucketDay, true},
+		{BucketWeek, BucketWeek, true},
+		{BucketMonth, BucketMonth, true},
+		{"year", "", false},
+	}
+	for _, tt := range tests {
+		got, err := ParseBucket(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseBucket(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
@@ -1,13 +1,14 @@
 package postgresql
 
 import (
+	"attendance/backend/foundation/i18n"
 	"attendance/backend/foundation/web"
 	"attendance/backend/internal/auth"
 	"attendance/backend/internal/pkg/config"
 	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"reflect"
 	"strings"
@@ -51,7 +52,7 @@ type Database struct {
 func NewDB(cfg Config) *Database {
 	yamlConfig, err := config.NewConfig() // Call the exported function
 	if err != nil {
-		fmt.Printf("error loading configuration: %v", err)
+		slog.Error("loading configuration", "error", err)
 	}
 
 	dsn := fmt.Sprintf("postgres://%v:%v@%v:%v/%v?sslmode=disable", yamlConfig.DBUsername, yamlConfig.DBPassword, yamlConfig.DBHost, yamlConfig.DBPort, yamlConfig.DBName)
@@ -64,10 +65,32 @@ func NewDB(cfg Config) *Database {
 		bundebug.WithVerbose(true),
 		bundebug.FromEnv("BUNDEBUG"),
 	))
+	db.AddQueryHook(errorLogHook{})
 
 	return &Database{DB: db, DBName: yamlConfig.DBName, DBPassword: yamlConfig.DBPassword, DBUser: yamlConfig.DBUsername, ServerBaseUrl: yamlConfig.BaseUrl, DefaultLang: cfg.DefaultLang}
 }
 
+// errorLogHook logs failed queries together with the ID of the request that
+// issued them, so a database error can be traced back to the API call.
+type errorLogHook struct{}
+
+func (errorLogHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (errorLogHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	if event.Err == nil || errors.Is(event.Err, sql.ErrNoRows) {
+		return
+	}
+
+	slog.ErrorContext(ctx, "query failed",
+		"error", event.Err,
+		"operation", event.Operation(),
+		"query", event.Query,
+		"duration_ms", time.Since(event.StartTime).Milliseconds(),
+	)
+}
+
 func (d Database) DeleteRow(ctx context.Context, table string, id int) error {
 	claims, err := d.CheckClaims(ctx)
 	if err != nil {
@@ -91,7 +114,7 @@ func (d Database) DeleteRow(ctx context.Context, table string, id int) error {
 func (d Database) CheckClaims(ctx context.Context, role ...string) (auth.Claims, error) {
 	claims, ok := ctx.Value(auth.Key).(auth.Claims)
 	if !ok {
-		return auth.Claims{}, web.NewRequestError(errors.New("claims missing from context"), http.StatusBadRequest)
+		return auth.Claims{}, web.NewCodeError(i18n.CodeUnauthorized, http.StatusBadRequest)
 	}
 
 	for _, r := range role {
@@ -108,11 +131,11 @@ func (d Database) CheckClaims(ctx context.Context, role ...string) (auth.Claims,
 }
 
 func (d Database) GetLang(ctx context.Context) string {
-	if value, ok := ctx.Value("lang").(string); ok {
+	if value, ok := ctx.Value(web.KeyLang).(string); ok {
 		return value
 	}
 
-	return d.DefaultLang
+	return i18n.Normalize(d.DefaultLang)
 }
 
 func (d Database) ValidateStruct(s interface{}, requiredFields ...string) error {
@@ -134,11 +157,11 @@ func (d Database) ValidateStruct(s interface{}, requiredFields ...string) error
 
 		isSet := field.IsValid() && !field.IsZero()
 		if !isSet {
-			log.Print(isSet, fieldName, reflect.ValueOf(field))
 			for _, f := range requiredFields {
 				if f == fieldName {
 					errFields = append(errFields, web.FieldError{
 						Error: "field is required!",
+						Code:  i18n.CodeFieldRequired,
 						Field: fieldName,
 					})
 				}
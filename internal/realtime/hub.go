@@ -0,0 +1,503 @@
+// Package realtime keeps the attendance dashboard up to date for connected
+// clients. A single Hub listens for database notifications, recomputes the
+// dashboard once per burst of changes and fans the differences out to every
+// subscriber, whatever transport the subscriber uses. An Inbox does the same
+// for the notification inbox of every user.
+package realtime
+
+import (
+	"attendance/backend/internal/repository/postgres/user"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Channel is the Postgres notification channel fed by the attendance trigger.
+const Channel = "attendance_changes"
+
+// Message types.
+const (
+	TypeSnapshot = "snapshot"
+	TypeDiff     = "diff"
+
+	// Commands are pushed to every client as they are, without history.
+	TypeReload = "reload"
+	TypeConfig = "config"
+)
+
+// Change operations.
+const (
+	OpAdded   = "added"
+	OpUpdated = "updated"
+	OpRemoved = "removed"
+)
+
+// Source loads the current dashboard.
+type Source interface {
+	GetDashboardList(ctx context.Context, filter user.Filter) ([]user.DepartmentResult, int, error)
+}
+
+// Config tunes the hub.
+type Config struct {
+	// DSN of the Postgres database to LISTEN on.
+	DSN string
+
+	// Debounce is how long the hub waits after a notification for further
+	// ones before reloading the dashboard.
+	Debounce time.Duration
+
+	// RefreshInterval reloads the dashboard even without notifications, so
+	// changes that fire no trigger, like the day rollover, reach clients.
+	RefreshInterval time.Duration
+
+	// History is the number of diffs kept for clients resuming with a
+	// Last-Event-ID.
+	History int
+
+	// Buffer is the number of messages queued per subscriber. Subscribers
+	// falling further behind are disconnected and must resume.
+	Buffer int
+}
+
+// Change is one employee entering, changing or leaving the dashboard.
+type Change struct {
+	Op           string                `json:"op"`
+	DepartmentID int                   `json:"department_id"`
+	Employee     user.GetDashboardlist `json:"employee"`
+}
+
+// Snapshot is the full dashboard.
+type Snapshot struct {
+	Results []user.DepartmentResult `json:"results"`
+	Count   int                     `json:"count"`
+}
+
+// Message is delivered to subscribers. Snapshot is set for TypeSnapshot,
+// Changes for TypeDiff and Data for commands.
+type Message struct {
+	ID       string
+	Type     string
+	Snapshot *Snapshot
+	Changes  []Change
+	Data     json.RawMessage
+}
+
+// Subscription receives the messages matching its department filter. C is
+// closed when the subscriber lagged behind or the hub stopped.
+type Subscription struct {
+	C           <-chan Message
+	c           chan Message
+	departments map[int]bool
+}
+
+// Hub owns the single LISTEN connection and the subscribers.
+type Hub struct {
+	cfg    Config
+	source Source
+	epoch  string
+
+	mu        sync.Mutex
+	ready     bool
+	seq       uint64
+	results   []user.DepartmentResult
+	count     int
+	employees map[string]Change
+	history   []Message
+	subs      map[*Subscription]struct{}
+	stopped   bool
+}
+
+// NewHub constructs a hub. Zero values in cfg get sensible defaults.
+func NewHub(cfg Config, source Source) *Hub {
+	if cfg.Debounce <= 0 {
+		cfg.Debounce = 250 * time.Millisecond
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = time.Minute
+	}
+	if cfg.History <= 0 {
+		cfg.History = 256
+	}
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = 64
+	}
+
+	return &Hub{
+		cfg:       cfg,
+		source:    source,
+		epoch:     strconv.FormatInt(time.Now().UnixNano(), 36),
+		employees: make(map[string]Change),
+		subs:      make(map[*Subscription]struct{}),
+	}
+}
+
+// Run listens for notifications and refreshes the dashboard until ctx is
+// done. All subscriptions are closed when it returns.
+func (h *Hub) Run(ctx context.Context) {
+	defer h.stop()
+
+	notify := make(chan struct{}, 1)
+	// Changes may have happened while the connection was down, so a
+	// reconnect triggers a refresh like a notification does.
+	go listen(ctx, h.cfg.DSN, Channel, func() { signal(notify) }, func(string) { signal(notify) })
+
+	h.refresh(ctx)
+
+	ticker := time.NewTicker(h.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-notify:
+			if debounce == nil {
+				debounce = time.After(h.cfg.Debounce)
+			}
+		case <-debounce:
+			debounce = nil
+			h.refresh(ctx)
+		case <-ticker.C:
+			h.refresh(ctx)
+		}
+	}
+}
+
+// Subscribe registers a subscriber interested in departments; an empty list
+// means all departments. lastEventID is the ID of the last message the
+// client saw. The returned messages bring the client up to date and must be
+// sent before anything read from the subscription.
+func (h *Hub) Subscribe(departments []int, lastEventID string) (*Subscription, []Message) {
+	c := make(chan Message, h.cfg.Buffer)
+	s := Subscription{C: c, c: c, departments: departmentSet(departments)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.stopped {
+		close(c)
+		return &s, nil
+	}
+	h.subs[&s] = struct{}{}
+
+	// Until the first load finished there is nothing to send; the snapshot
+	// follows through the channel.
+	if !h.ready {
+		return &s, nil
+	}
+
+	if missed, ok := h.since(lastEventID); ok {
+		var initial []Message
+		for _, m := range missed {
+			if m, ok := s.filter(m); ok {
+				initial = append(initial, m)
+			}
+		}
+		return &s, initial
+	}
+
+	snapshot, _ := s.filter(h.snapshot())
+	return &s, []Message{snapshot}
+}
+
+// PastSnapshot loads the dashboard of a past day from source, narrowed to
+// departments like a subscription is. Past days are not kept up to date by
+// the hub.
+func PastSnapshot(ctx context.Context, source Source, day time.Time, departments []int) (Message, error) {
+	results, count, err := source.GetDashboardList(ctx, user.Filter{Date: &day})
+	if err != nil {
+		return Message{}, err
+	}
+
+	s := Subscription{departments: departmentSet(departments)}
+	m, _ := s.filter(Message{Type: TypeSnapshot, Snapshot: &Snapshot{Results: results, Count: count}})
+
+	return m, nil
+}
+
+// Publish pushes a command to every subscriber. Commands are not kept for
+// resuming clients.
+func (h *Hub) Publish(typ string, data json.RawMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.broadcast(Message{Type: typ, Data: data})
+}
+
+// Unsubscribe removes the subscription. It is safe to call more than once.
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.c)
+	}
+}
+
+// departmentSet returns nil, meaning all departments, for no ids.
+func departmentSet(ids []int) map[int]bool {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	set := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+
+	return set
+}
+
+func signal(notify chan<- struct{}) {
+	select {
+	case notify <- struct{}{}:
+	default:
+	}
+}
+
+// refresh reloads the dashboard and broadcasts what changed.
+func (h *Hub) refresh(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	results, count, err := h.source.GetDashboardList(ctx, user.Filter{})
+	if err != nil {
+		slog.Error("realtime: loading dashboard", "error", err)
+		return
+	}
+
+	employees := index(results)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	changes := diff(h.employees, employees)
+	first := !h.ready
+
+	h.results = results
+	h.count = count
+	h.employees = employees
+	h.ready = true
+
+	if first {
+		h.broadcast(h.snapshot())
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	h.seq++
+	m := Message{ID: h.id(h.seq), Type: TypeDiff, Changes: changes}
+
+	h.history = append(h.history, m)
+	if len(h.history) > h.cfg.History {
+		h.history = h.history[len(h.history)-h.cfg.History:]
+	}
+
+	h.broadcast(m)
+}
+
+// broadcast must be called with h.mu held.
+func (h *Hub) broadcast(m Message) {
+	for s := range h.subs {
+		fm, ok := s.filter(m)
+		if !ok {
+			continue
+		}
+
+		select {
+		case s.c <- fm:
+		default:
+			// The subscriber can resume from its last event ID.
+			delete(h.subs, s)
+			close(s.c)
+		}
+	}
+}
+
+// snapshot must be called with h.mu held.
+func (h *Hub) snapshot() Message {
+	return Message{
+		ID:       h.id(h.seq),
+		Type:     TypeSnapshot,
+		Snapshot: &Snapshot{Results: h.results, Count: h.count},
+	}
+}
+
+// since returns the diffs after lastEventID. ok is false when the ID is
+// unknown, belongs to an earlier run or is older than the history, in which
+// case the client needs a snapshot. It must be called with h.mu held.
+func (h *Hub) since(lastEventID string) ([]Message, bool) {
+	epoch, seq, ok := parseID(lastEventID)
+	if !ok || epoch != h.epoch || seq > h.seq {
+		return nil, false
+	}
+	if seq == h.seq {
+		return nil, true
+	}
+	if len(h.history) == 0 {
+		return nil, false
+	}
+
+	_, oldest, _ := parseID(h.history[0].ID)
+	if seq+1 < oldest {
+		return nil, false
+	}
+
+	var missed []Message
+	for _, m := range h.history {
+		if _, s, _ := parseID(m.ID); s > seq {
+			missed = append(missed, m)
+		}
+	}
+
+	return missed, true
+}
+
+func (h *Hub) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stopped = true
+	for s := range h.subs {
+		delete(h.subs, s)
+		close(s.c)
+	}
+}
+
+func (h *Hub) id(seq uint64) string {
+	return h.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+func parseID(id string) (string, uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return "", 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return epoch, n, true
+}
+
+// filter narrows m to the departments of the subscription. ok is false when
+// nothing is left to send.
+func (s *Subscription) filter(m Message) (Message, bool) {
+	if s.departments == nil {
+		return m, true
+	}
+
+	switch m.Type {
+	case TypeSnapshot:
+		snapshot := Snapshot{}
+		for _, d := range m.Snapshot.Results {
+			if len(d.Employees) == 0 || d.Employees[0].DepartmentID == nil || !s.departments[*d.Employees[0].DepartmentID] {
+				continue
+			}
+			snapshot.Results = append(snapshot.Results, d)
+			for _, e := range d.Employees {
+				if e.EmployeeID != nil {
+					snapshot.Count++
+				}
+			}
+		}
+		m.Snapshot = &snapshot
+		return m, true
+
+	case TypeDiff:
+		var changes []Change
+		for _, c := range m.Changes {
+			if s.departments[c.DepartmentID] {
+				changes = append(changes, c)
+			}
+		}
+		m.Changes = changes
+		return m, len(changes) > 0
+
+	default:
+		return m, true
+	}
+}
+
+// index maps employees by employee ID. Rows of departments without employees
+// carry no employee and are left out.
+func index(results []user.DepartmentResult) map[string]Change {
+	employees := make(map[string]Change)
+	for _, d := range results {
+		for _, e := range d.Employees {
+			if e.EmployeeID == nil || e.DepartmentID == nil {
+				continue
+			}
+			employees[*e.EmployeeID] = Change{DepartmentID: *e.DepartmentID, Employee: e}
+		}
+	}
+
+	return employees
+}
+
+func diff(old, cur map[string]Change) []Change {
+	var changes []Change
+
+	for id, c := range cur {
+		prev, ok := old[id]
+		switch {
+		case !ok:
+			c.Op = OpAdded
+			changes = append(changes, c)
+		case !sameEmployee(prev.Employee, c.Employee):
+			c.Op = OpUpdated
+			changes = append(changes, c)
+			// A department move is also a removal from the old department
+			// for subscribers filtering on it.
+			if prev.DepartmentID != c.DepartmentID {
+				prev.Op = OpRemoved
+				changes = append(changes, prev)
+			}
+		}
+	}
+	for id, prev := range old {
+		if _, ok := cur[id]; !ok {
+			prev.Op = OpRemoved
+			changes = append(changes, prev)
+		}
+	}
+
+	return changes
+}
+
+// sameEmployee compares employees as sent. Photo links keep their expiry
+// for a while, so they only differ when the photo changed or the links are
+// renewed, which reaches screens before the old ones expire.
+func sameEmployee(a, b user.GetDashboardlist) bool {
+	return eqInt(a.ID, b.ID) &&
+		eqInt(a.DepartmentID, b.DepartmentID) &&
+		eqInt(a.DisplayNumber, b.DisplayNumber) &&
+		eqString(a.DepartmentName, b.DepartmentName) &&
+		a.DepartmentNickName == b.DepartmentNickName &&
+		eqString(a.LastName, b.LastName) &&
+		a.NickName == b.NickName &&
+		eqBool(a.Status, b.Status) &&
+		eqString(a.PhotoURL, b.PhotoURL) &&
+		eqString(a.ThumbnailURL, b.ThumbnailURL)
+}
+
+func eqInt(a, b *int) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func eqString(a, b *string) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func eqBool(a, b *bool) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
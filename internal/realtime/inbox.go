@@ -0,0 +1,90 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+)
+
+// InboxChannel is the Postgres notification channel fed by the notification
+// trigger.
+const InboxChannel = "notification_changes"
+
+// Inbox wakes the notification streams of a user whenever their inbox
+// changes. It only tells that something changed; streams load what did
+// themselves, so no state is kept here and nothing is lost on reconnects.
+type Inbox struct {
+	dsn string
+
+	mu   sync.Mutex
+	subs map[int]map[chan struct{}]struct{}
+}
+
+// NewInbox constructs an inbox listening on the database at dsn.
+func NewInbox(dsn string) *Inbox {
+	return &Inbox{
+		dsn:  dsn,
+		subs: make(map[int]map[chan struct{}]struct{}),
+	}
+}
+
+// Run listens for inbox changes until ctx is done.
+func (i *Inbox) Run(ctx context.Context) {
+	listen(ctx, i.dsn, InboxChannel, i.wakeAll, i.notify)
+}
+
+// Subscribe returns a channel that receives a value whenever the inbox of
+// userID changed. Wake-ups are coalesced, so the receiver must catch up on
+// everything that changed. cancel must be called when done.
+func (i *Inbox) Subscribe(userID int) (<-chan struct{}, func()) {
+	c := make(chan struct{}, 1)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.subs[userID] == nil {
+		i.subs[userID] = make(map[chan struct{}]struct{})
+	}
+	i.subs[userID][c] = struct{}{}
+
+	return c, func() {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+
+		delete(i.subs[userID], c)
+		if len(i.subs[userID]) == 0 {
+			delete(i.subs, userID)
+		}
+	}
+}
+
+func (i *Inbox) notify(payload string) {
+	var change struct {
+		UserID int `json:"user_id"`
+	}
+	if err := json.Unmarshal([]byte(payload), &change); err != nil {
+		slog.Error("realtime: decoding inbox change", "error", err, "payload", payload)
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for c := range i.subs[change.UserID] {
+		signal(c)
+	}
+}
+
+// wakeAll wakes every stream after a reconnect, since changes may have
+// happened while the connection was down.
+func (i *Inbox) wakeAll() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, subs := range i.subs {
+		for c := range subs {
+			signal(c)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// listen LISTENs on channel until ctx is done, reconnecting with backoff
+// when the connection drops; the backoff starts over once LISTEN succeeds
+// again. connected is called on every (re)connect, since
+// notifications sent while disconnected are lost, and notify with the
+// payload of every notification.
+func listen(ctx context.Context, dsn, channel string, connected func(), notify func(payload string)) {
+	backoff := time.Second
+
+	for ctx.Err() == nil {
+		err := listenOnce(ctx, dsn, channel, func() {
+			backoff = time.Second
+			connected()
+		}, notify)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("realtime: listener stopped, reconnecting", "error", err, "channel", channel, "backoff", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func listenOnce(ctx context.Context, dsn, channel string, connected func(), notify func(payload string)) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return fmt.Errorf("connecting: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+channel); err != nil {
+		return fmt.Errorf("listening: %w", err)
+	}
+	slog.Info("realtime: listening", "channel", channel)
+
+	connected()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("waiting for notification: %w", err)
+		}
+		notify(n.Payload)
+	}
+}
@@ -1,20 +1,26 @@
 package attendance
 
 import (
+	"attendance/backend/foundation/i18n"
 	"attendance/backend/foundation/web"
 	"attendance/backend/internal/auth"
+	"attendance/backend/internal/pkg/period"
 	"attendance/backend/internal/pkg/repository/postgresql"
 	"attendance/backend/internal/repository/postgres"
 	"attendance/backend/internal/repository/postgres/companyInfo"
+	"attendance/backend/internal/repository/postgres/webhook"
 	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/Azure/go-autorest/autorest/date"
 	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
 )
 
 type Repository struct {
@@ -25,7 +31,7 @@ func NewRepository(database *postgresql.Database) *Repository {
 	return &Repository{Database: database}
 }
 func (r *Repository) GetOfficeLocations(ctx context.Context) ([]OfficeLocation, error) {
-	query := `SELECT latitude, longitude,radius FROM company_info`
+	query := `SELECT id, latitude, longitude,radius FROM company_info`
 	rows, err := r.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -35,12 +41,12 @@ func (r *Repository) GetOfficeLocations(ctx context.Context) ([]OfficeLocation,
 	var locations []OfficeLocation
 	for rows.Next() {
 		var loc OfficeLocation
-		if err := rows.Scan(&loc.Latitude, &loc.Longitude, &loc.Radius); err != nil {
+		if err := rows.Scan(&loc.ID, &loc.Latitude, &loc.Longitude, &loc.Radius); err != nil {
 			return nil, err
 		}
 		locations = append(locations, loc)
 	}
-	fmt.Println("Locations:", locations)
+	slog.DebugContext(ctx, "office locations loaded", "count", len(locations))
 	return locations, nil
 }
 
@@ -375,7 +381,7 @@ func (r Repository) CreateByPhone(ctx context.Context, request EnterRequest) (Cr
 	var exists bool
 	err = r.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE employee_id = ? AND deleted_at IS NULL)", request.EmployeeID).Scan(&exists)
 	if !exists {
-		return CreateResponse{}, web.NewRequestError(errors.New("無効または削除された社員番号"), http.StatusBadRequest)
+		return CreateResponse{}, web.NewCodeError(i18n.CodeEmployeeInvalid, http.StatusBadRequest)
 	}
 	if err != nil {
 		return CreateResponse{}, web.NewRequestError(errors.Wrap(err, "checking EmployeeID existence"), http.StatusInternalServerError)
@@ -391,7 +397,7 @@ func (r Repository) CreateByPhone(ctx context.Context, request EnterRequest) (Cr
 	}
 
 	if existingAttendance.ComeTime != nil {
-		return CreateResponse{}, web.NewRequestError(errors.New("すでに出勤済みです。"), http.StatusBadRequest)
+		return CreateResponse{}, web.NewCodeError(i18n.CodeAlreadyCheckedIn, http.StatusBadRequest)
 	}
 	err = r.fixIncompleteAttendance(ctx, request.EmployeeID, claims)
 	if err != nil {
@@ -411,7 +417,7 @@ func (r Repository) ExitByPhone(ctx context.Context, request ExitByPhoneRequest)
 	var exists bool
 	err = r.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE employee_id = ? AND deleted_at IS NULL)", request.EmployeeID).Scan(&exists)
 	if !exists {
-		return CreateResponse{}, web.NewRequestError(errors.New("無効または削除された社員番号"), http.StatusBadRequest)
+		return CreateResponse{}, web.NewCodeError(i18n.CodeEmployeeInvalid, http.StatusBadRequest)
 	}
 	if err != nil {
 		return CreateResponse{}, web.NewRequestError(errors.Wrap(err, "checking EmployeeID existence"), http.StatusInternalServerError)
@@ -422,14 +428,14 @@ func (r Repository) ExitByPhone(ctx context.Context, request ExitByPhoneRequest)
 		return CreateResponse{}, err
 	}
 	if existingAttendance.ComeTime != nil && existingAttendance.LeaveTime != nil {
-		return CreateResponse{}, web.NewRequestError(errors.New("出勤していません"), http.StatusBadRequest)
+		return CreateResponse{}, web.NewCodeError(i18n.CodeNotCheckedIn, http.StatusBadRequest)
 	}
 
 	if existingAttendance.ComeTime != nil {
 		return r.handleExistingAttendance(ctx, claims, existingAttendance, request.EmployeeID)
 	}
 
-	return CreateResponse{}, web.NewRequestError(errors.New("出勤していません"), http.StatusBadRequest)
+	return CreateResponse{}, web.NewCodeError(i18n.CodeNotCheckedIn, http.StatusBadRequest)
 }
 
 func (r Repository) CreateByQRCode(ctx context.Context, request EnterRequest) (CreateResponse, string, error) {
@@ -444,7 +450,7 @@ func (r Repository) CreateByQRCode(ctx context.Context, request EnterRequest) (C
 	var exists bool
 	err = r.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE employee_id = ? AND deleted_at IS NULL)", request.EmployeeID).Scan(&exists)
 	if !exists {
-		return CreateResponse{}, "", web.NewRequestError(errors.New("無効または削除された社員番号"), http.StatusBadRequest)
+		return CreateResponse{}, "", web.NewCodeError(i18n.CodeEmployeeInvalid, http.StatusBadRequest)
 	}
 	if err != nil {
 		return CreateResponse{}, "", web.NewRequestError(errors.Wrap(err, "checking EmployeeID existence"), http.StatusInternalServerError)
@@ -456,20 +462,20 @@ func (r Repository) CreateByQRCode(ctx context.Context, request EnterRequest) (C
 	}
 	if existingAttendance.ComeTime != nil && existingAttendance.LeaveTime != nil {
 		response, err := r.resetLeaveTimeAndCreatePeriod(ctx, claims, existingAttendance, request.EmployeeID)
-		return response, "仕事へようこそ", err
+		return response, i18n.T(r.GetLang(ctx), i18n.MsgWelcome), err
 	}
 
 	if existingAttendance.ComeTime != nil {
 
 		response, err := r.handleExistingAttendance(ctx, claims, existingAttendance, request.EmployeeID)
-		return response, "無事に帰宅", err
+		return response, i18n.T(r.GetLang(ctx), i18n.MsgGoodbye), err
 	}
 	err = r.fixIncompleteAttendance(ctx, request.EmployeeID, claims)
 	if err != nil {
 		return CreateResponse{}, "", err
 	}
 	response, err := r.createNewAttendance(ctx, claims, request)
-	return response, "仕事へようこそ", err
+	return response, i18n.T(r.GetLang(ctx), i18n.MsgWelcome), err
 }
 func (r Repository) fixIncompleteAttendance(ctx context.Context, employeeID *string, claims auth.Claims) error {
 	var workEndTime, lastWorkDay string
@@ -495,7 +501,7 @@ func (r Repository) fixIncompleteAttendance(ctx context.Context, employeeID *str
 	if err != nil {
 		// If no attendance record exists, skip fixing and return nil
 		if errors.Is(err, sql.ErrNoRows) {
-			fmt.Println("No incomplete attendance record found, skipping...")
+			slog.DebugContext(ctx, "no incomplete attendance record found", "employee_id", *employeeID)
 			return nil
 		}
 		return fmt.Errorf("failed to fetch attendance's id, workday: %w", err)
@@ -519,7 +525,7 @@ func (r Repository) handleExistingAttendance(ctx context.Context, claims auth.Cl
 	if existingAttendance.LeaveTime == nil {
 		return r.updateLeaveTime(ctx, claims, existingAttendance, employeeID)
 	}
-	return CreateResponse{}, web.NewRequestError(errors.New("ERR on come_time dont hava data but leave_time has data"), http.StatusBadRequest)
+	return CreateResponse{}, web.NewCodeError(i18n.CodeAttendanceInconsistent, http.StatusBadRequest)
 }
 
 func (r Repository) getExistingAttendance(ctx context.Context, employeeID *string) (CreateResponse, error) {
@@ -641,7 +647,7 @@ func (r Repository) createNewAttendance(ctx context.Context, claims auth.Claims,
 		CreatedBy:  claims.UserId,
 	}
 
-	err := r.insertAttendance(ctx, &response)
+	err := r.insertAttendance(ctx, &response, request.OfficeID)
 	if err != nil {
 		return CreateResponse{}, err
 	}
@@ -737,13 +743,13 @@ func (r Repository) createAttendancePeriod(ctx context.Context, attendanceID int
 	return periods.ID, err
 }
 
-func (r Repository) insertAttendance(ctx context.Context, response *CreateResponse) error {
+func (r Repository) insertAttendance(ctx context.Context, response *CreateResponse, officeID *int) error {
 
 	createdAt := response.CreatedAt.Format("2006-01-02 15:04:05")
 
 	query := `
-		INSERT INTO attendance (employee_id, work_day, come_time, leave_time, created_at, created_by)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO attendance (employee_id, work_day, come_time, leave_time, created_at, created_by, office_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 		RETURNING id;
 	`
 
@@ -756,6 +762,7 @@ func (r Repository) insertAttendance(ctx context.Context, response *CreateRespon
 		response.LeaveTime,
 		createdAt, // string ko’rinishida, time zone yo‘q
 		response.CreatedBy,
+		officeID,
 	).Scan(&response.ID)
 
 	return err
@@ -857,12 +864,18 @@ func (r Repository) Delete(ctx context.Context, id int) error {
 	return r.DeleteRow(ctx, "attendance", id)
 }
 
-func (r Repository) GetStatistics(ctx context.Context) (GetStatisticResponse, error) {
+// GetStatistics returns the attendance figures of workDay, counted over the
+// roster of that day.
+func (r Repository) GetStatistics(ctx context.Context, workDay time.Time) (GetStatisticResponse, error) {
 	loc, _ := time.LoadLocation("Asia/Tokyo")
 	currentTime := time.Now().In(loc) // Yapon vaqtini olamiz
-	workDay := currentTime.Format("2006-01-02")
+	day := workDay.Format("2006-01-02")
 	var response GetStatisticResponse
 	timeNow := currentTime.Format("15:04:05")
+	if day < currentTime.Format("2006-01-02") {
+		// The whole day is over
+		timeNow = "24:00:00"
+	}
 	// Create an instance of companyInfo.Repository
 	companyRepo := companyInfo.NewRepository(r.Database)
 
@@ -879,19 +892,24 @@ func (r Repository) GetStatistics(ctx context.Context) (GetStatisticResponse, er
 	lateTime := companyInfoResponse.LateTime
 	overEndTime := companyInfoResponse.OverEndTime
 
-	query := `
+	query := fmt.Sprintf(`
+   WITH roster AS %s,
+   day_attendance AS (
+    SELECT a.* FROM attendance a JOIN roster r ON r.employee_id = a.employee_id
+    WHERE a.deleted_at IS NULL AND a.work_day = ?
+   )
    SELECT
-    (SELECT COUNT(DISTINCT employee_id) FROM users WHERE role='EMPLOYEE' AND deleted_at IS NULL) AS total_employee,
-    (SELECT COUNT(employee_id) FROM attendance WHERE come_time >= ? AND come_time < ? AND deleted_at IS NULL AND work_day = ?) AS on_time,
-    (SELECT COUNT(DISTINCT u.employee_id) FROM users u LEFT JOIN attendance a ON u.employee_id = a.employee_id
-     AND a.work_day = ? WHERE role='EMPLOYEE' AND u.deleted_at IS NULL AND a.employee_id IS NULL) AS absent,
-    (SELECT COUNT(employee_id) FROM attendance WHERE come_time > ? AND deleted_at IS NULL AND work_day =?) AS late_arrival,
-    (SELECT COUNT(employee_id) FROM attendance WHERE leave_time < ? AND deleted_at IS NULL AND work_day = ?) AS early_departures,
-    (SELECT COUNT(employee_id) FROM attendance WHERE come_time < ? AND deleted_at IS NULL AND work_day = ?) AS early_come,
-    (SELECT COUNT(employee_id) FROM attendance WHERE (leave_time IS NOT NULL AND ? < ? AND work_day = ?) OR (leave_time > ? AND work_day = ?)) AS over_time;
- 	`
-
-	err = r.DB.QueryRowContext(ctx, query, startTime, lateTime, workDay, workDay, startTime, workDay, endTime, workDay, startTime, workDay, endTime, timeNow, workDay, overEndTime, workDay).Scan(
+    (SELECT COUNT(DISTINCT employee_id) FROM roster WHERE role='EMPLOYEE') AS total_employee,
+    (SELECT COUNT(employee_id) FROM day_attendance WHERE come_time >= ? AND come_time < ?) AS on_time,
+    (SELECT COUNT(DISTINCT u.employee_id) FROM roster u LEFT JOIN day_attendance a ON u.employee_id = a.employee_id
+     WHERE u.role='EMPLOYEE' AND a.employee_id IS NULL) AS absent,
+    (SELECT COUNT(employee_id) FROM day_attendance WHERE come_time > ?) AS late_arrival,
+    (SELECT COUNT(employee_id) FROM day_attendance WHERE leave_time < ?) AS early_departures,
+    (SELECT COUNT(employee_id) FROM day_attendance WHERE come_time < ?) AS early_come,
+    (SELECT COUNT(employee_id) FROM day_attendance WHERE (leave_time IS NOT NULL AND ? < ?) OR (leave_time > ?)) AS over_time;
+ 	`, postgres.Roster(workDay))
+
+	err = r.DB.QueryRowContext(ctx, query, day, startTime, lateTime, startTime, endTime, startTime, endTime, timeNow, overEndTime).Scan(
 		&response.TotalEmployee,
 		&response.OnTime,
 		&response.Absent,
@@ -907,31 +925,29 @@ func (r Repository) GetStatistics(ctx context.Context) (GetStatisticResponse, er
 	return response, nil
 }
 
-func (r Repository) GetPieChartStatistic(ctx context.Context) (PieChartResponse, error) {
-	loc, _ := time.LoadLocation("Asia/Tokyo")
-	currentTime := time.Now().In(loc) // Yapon vaqtini olamiz
-	workDay := currentTime.Format("2006-01-02")
-
-	query := `
+// GetPieChartStatistic returns the share of the roster of workDay that came
+// and that was absent.
+func (r Repository) GetPieChartStatistic(ctx context.Context, workDay time.Time) (PieChartResponse, error) {
+	query := fmt.Sprintf(`
   WITH today_attendance AS (
     SELECT
-        COUNT(DISTINCT a.employee_id) FILTER (WHERE a.work_day = ? AND u.role = 'EMPLOYEE') AS come_count,
-        COUNT(DISTINCT u.employee_id) FILTER (WHERE u.role = 'EMPLOYEE') AS total_count,
-        COUNT(u.employee_id) FILTER (WHERE a.employee_id IS NULL AND u.deleted_at IS NULL AND u.role = 'EMPLOYEE') AS absent_count
-    FROM users u
-    LEFT JOIN attendance a ON a.employee_id = u.employee_id AND a.work_day = ?
-    WHERE u.deleted_at IS NULL
+        COUNT(DISTINCT a.employee_id) AS come_count,
+        COUNT(DISTINCT u.employee_id) AS total_count,
+        COUNT(u.employee_id) FILTER (WHERE a.employee_id IS NULL) AS absent_count
+    FROM %s u
+    LEFT JOIN attendance a ON a.employee_id = u.employee_id AND a.work_day = ? AND a.deleted_at IS NULL
+    WHERE u.role = 'EMPLOYEE'
 )
 SELECT
     COALESCE(ROUND(100.0 * come_count / GREATEST(1, total_count), 2), 0) AS come_percentage,
     COALESCE(ROUND(100.0 * absent_count / GREATEST(1, total_count), 2), 0) AS absent_percentage
 FROM today_attendance;
- `
+ `, postgres.Roster(workDay))
 
 	var detail PieChartResponse
 	var comePercentage, absentPercentage float64
 
-	row := r.QueryRowContext(ctx, query, workDay, workDay)
+	row := r.QueryRowContext(ctx, query, workDay.Format("2006-01-02"))
 	err := row.Scan(&comePercentage, &absentPercentage)
 	if err != nil {
 		return PieChartResponse{}, web.NewRequestError(errors.Wrap(err, "response pie chart data not found"), http.StatusBadRequest)
@@ -947,20 +963,19 @@ func Int(i int) *int {
 	return &i
 }
 
-func (r Repository) GetBarChartStatistic(ctx context.Context) ([]BarChartResponse, error) {
-	loc, _ := time.LoadLocation("Asia/Tokyo")
-	currentTime := time.Now().In(loc) // Yapon vaqtini olamiz
-	workDay := currentTime.Format("2006-01-02")
-	query := `
+// GetBarChartStatistic returns the share of each department that came on
+// workDay, with departments and their members as they were that day.
+func (r Repository) GetBarChartStatistic(ctx context.Context, workDay time.Time) ([]BarChartResponse, error) {
+	query := fmt.Sprintf(`
     WITH today_attendance AS (
         SELECT
-            COUNT(DISTINCT a.employee_id) FILTER (WHERE a.work_day = ?) AS come_count,
+            COUNT(DISTINCT a.employee_id) AS come_count,
             COUNT(DISTINCT u.employee_id) AS total_count,
             u.department_id
         FROM department d
-        LEFT JOIN users u ON d.id = u.department_id AND u.deleted_at IS NULL
-        LEFT JOIN attendance a ON a.employee_id = u.employee_id AND a.deleted_at IS NULL
-        WHERE d.deleted_at IS NULL
+        LEFT JOIN %s u ON d.id = u.department_id
+        LEFT JOIN attendance a ON a.employee_id = u.employee_id AND a.work_day = ? AND a.deleted_at IS NULL
+        WHERE %s
         GROUP BY u.department_id
     )
     SELECT
@@ -968,10 +983,10 @@ func (r Repository) GetBarChartStatistic(ctx context.Context) ([]BarChartRespons
         COALESCE(ROUND(100.0 * come_count / GREATEST(1, total_count), 2), 0) AS percentage
     FROM department d
     LEFT JOIN today_attendance ON d.id = today_attendance.department_id
-    WHERE d.deleted_at IS NULL;
-    `
+    WHERE %s;
+    `, postgres.Roster(workDay), postgres.ExistedOn("d", workDay), postgres.ExistedOn("d", workDay))
 
-	rows, err := r.DB.QueryContext(ctx, query, workDay)
+	rows, err := r.DB.QueryContext(ctx, query, workDay.Format("2006-01-02"))
 	if err != nil {
 		return nil, err
 	}
@@ -993,22 +1008,10 @@ func (r Repository) GetBarChartStatistic(ctx context.Context) ([]BarChartRespons
 
 	return results, nil
 }
-func (r Repository) GetGraphStatistic(ctx context.Context, filter GraphRequest) ([]GraphResponse, error) {
-	var startDay, endDay int
-	switch filter.Interval {
-	case 0:
-		startDay, endDay = 1, 10
-	case 1:
-		startDay, endDay = 11, 20
-	case 2:
-		startDay, endDay = 21, 31 // Adjust for months with fewer than 31 days later
-	default:
-		return nil, web.NewRequestError(errors.New("invalid interval"), http.StatusBadRequest)
-	}
-
-	startDate := time.Date(filter.Month.Year(), filter.Month.Month(), startDay, 0, 0, 0, 0, time.UTC)
-	endDate := time.Date(filter.Month.Year(), filter.Month.Month(), endDay, 23, 59, 59, 999999999, time.UTC)
 
+// GetGraphStatistic returns the share of users who came, per day of the
+// range. Week and month buckets average the days they span.
+func (r Repository) GetGraphStatistic(ctx context.Context, filter GraphRequest) ([]GraphResponse, error) {
 	query := `
  WITH today_attendance AS (
     SELECT
@@ -1033,13 +1036,12 @@ func (r Repository) GetGraphStatistic(ctx context.Context, filter GraphRequest)
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.QueryContext(ctx, startDate, endDate)
+	rows, err := stmt.QueryContext(ctx, filter.Range.From.Format(period.Layout), filter.Range.To.Format(period.Layout))
 	if err != nil {
 		return nil, web.NewRequestError(errors.Wrap(err, "selecting attendance filter"), http.StatusInternalServerError)
 	}
 	defer rows.Close()
 
-	var list []GraphResponse
 	attendanceMap := make(map[string]float64)
 
 	for rows.Next() {
@@ -1051,23 +1053,218 @@ func (r Repository) GetGraphStatistic(ctx context.Context, filter GraphRequest)
 
 		attendanceMap[workDayString] = percentage
 	}
+	if err = rows.Err(); err != nil {
+		return nil, web.NewRequestError(errors.Wrap(err, "scanning Graph response"), http.StatusInternalServerError)
+	}
 
-	for day := startDay; day <= endDay; day++ {
-		workDay := time.Date(filter.Month.Year(), filter.Month.Month(), day, 0, 0, 0, 0, time.UTC)
-		workDayString := workDay.Format("2006-01-02")
-		percentage, exists := attendanceMap[workDayString]
+	list := make([]GraphResponse, 0)
+	for _, bucket := range filter.Range.Split(filter.Bucket) {
+		var sum float64
+		days := 0
+		for d := bucket.From; !d.After(bucket.To); d = d.AddDate(0, 0, 1) {
+			sum += attendanceMap[d.Format(period.Layout)]
+			days++
+		}
 
-		if !exists {
-			percentage = 0
+		response := GraphResponse{
+			WorkDay:    &date.Date{Time: bucket.From},
+			Percentage: math.Round(100*sum/float64(days)) / 100,
+		}
+		if filter.Bucket != period.BucketDay {
+			response.EndDay = &date.Date{Time: bucket.To}
 		}
+		list = append(list, response)
+	}
 
-		parsedWorkDay, _ := date.ParseDate(workDayString) // Convert to *date.Date
+	return list, nil
+}
 
-		list = append(list, GraphResponse{
-			WorkDay:    &parsedWorkDay,
-			Percentage: percentage,
-		})
+// GetLiveCounters returns the number of employees currently clocked in and
+// the check-ins and check-outs recorded during the last minute. It is used by
+// the metrics collector and therefore does not check claims.
+func (r Repository) GetLiveCounters(ctx context.Context) (LiveCounters, error) {
+	loc, _ := time.LoadLocation("Asia/Tokyo")
+	currentTime := time.Now().In(loc)
+	workDay := currentTime.Format("2006-01-02")
+	since := currentTime.Add(-time.Minute).Format("15:04:05")
+	// Around midnight the window would wrap to the previous day; counting
+	// from the start of the day is close enough for a gauge.
+	if currentTime.Add(-time.Minute).Format("2006-01-02") != workDay {
+		since = "00:00:00"
 	}
 
-	return list, nil
+	var counters LiveCounters
+
+	query := `
+		SELECT
+			(SELECT COUNT(DISTINCT employee_id)
+			   FROM attendance
+			  WHERE deleted_at IS NULL AND status = TRUE AND work_day = ?),
+			(SELECT COUNT(*) FILTER (WHERE come_time >= ?) + COUNT(*) FILTER (WHERE leave_time >= ?)
+			   FROM attendance_period
+			  WHERE work_day = ?)
+	`
+	err := r.QueryRowContext(ctx, query, workDay, since, since, workDay).Scan(&counters.ClockedIn, &counters.PunchesPerMinute)
+	if err != nil {
+		return LiveCounters{}, errors.Wrap(err, "selecting live counters")
+	}
+
+	return counters, nil
+}
+
+// Policies of the end-of-day job for attendances without a check-out.
+const (
+	// CheckoutPolicyEndTime closes the attendance at the company end time.
+	CheckoutPolicyEndTime = "end_time"
+
+	// CheckoutPolicyLastSeen closes the attendance at the last recorded punch.
+	CheckoutPolicyLastSeen = "last_seen"
+
+	// CheckoutPolicyFlagOnly marks the attendance as forgotten and leaves it
+	// open for an administrator to fix.
+	CheckoutPolicyFlagOnly = "flag_only"
+)
+
+// closeForgottenCheckoutsLock is the advisory lock key of the end-of-day job.
+const closeForgottenCheckoutsLock = "attendance:close_forgotten_checkouts"
+
+// GetCompanySchedule returns the company working hours. It is used by the
+// background jobs and therefore does not check claims.
+func (r Repository) GetCompanySchedule(ctx context.Context) (CompanySchedule, error) {
+	var schedule CompanySchedule
+
+	query := `
+		SELECT
+			COALESCE(to_char(start_time, 'HH24:MI:SS'), ''),
+			COALESCE(to_char(late_time, 'HH24:MI:SS'), ''),
+			COALESCE(to_char(end_time, 'HH24:MI:SS'), ''),
+			COALESCE(to_char(over_end_time, 'HH24:MI:SS'), '')
+		FROM company_info
+		WHERE deleted_at IS NULL
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	err := r.QueryRowContext(ctx, query).Scan(&schedule.StartTime, &schedule.LateTime, &schedule.EndTime, &schedule.OverEndTime)
+	if err != nil {
+		return CompanySchedule{}, errors.Wrap(err, "selecting company schedule")
+	}
+
+	return schedule, nil
+}
+
+// CloseForgottenCheckouts applies policy to every attendance of work days up
+// to and including through that has no check-out yet, marks it forget_leave
+// and queues the forgot_leave_autofix webhook. Everything happens in one
+// transaction holding an advisory lock, so replicas running the job at the
+// same time do not step on each other: the ones not getting the lock return
+// postgres.ErrLocked. It does not check claims.
+func (r Repository) CloseForgottenCheckouts(ctx context.Context, through string, policy string) ([]ForgottenCheckout, error) {
+	switch policy {
+	case CheckoutPolicyEndTime, CheckoutPolicyLastSeen, CheckoutPolicyFlagOnly:
+	default:
+		return nil, fmt.Errorf("unknown forgotten check-out policy %q", policy)
+	}
+
+	var closed []ForgottenCheckout
+
+	err := r.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var locked bool
+		if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock(hashtext(?))", closeForgottenCheckoutsLock).Scan(&locked); err != nil {
+			return errors.Wrap(err, "taking advisory lock")
+		}
+		if !locked {
+			return postgres.ErrLocked
+		}
+
+		// Period check-outs written below must not look like real ones to
+		// the webhook trigger.
+		if _, err := tx.ExecContext(ctx, "SET LOCAL attendance.autofix = 'on'"); err != nil {
+			return errors.Wrap(err, "marking transaction")
+		}
+
+		query := `
+			SELECT
+				a.id,
+				a.employee_id,
+				to_char(a.work_day, 'YYYY-MM-DD'),
+				to_char(a.come_time, 'HH24:MI:SS'),
+				to_char(CASE ?
+					WHEN 'end_time' THEN GREATEST(a.come_time, COALESCE(c.end_time, a.come_time))
+					WHEN 'last_seen' THEN GREATEST(a.come_time, COALESCE(p.last_seen, a.come_time))
+				END, 'HH24:MI:SS'),
+				u.email,
+				u.first_name,
+				u.last_name
+			FROM attendance a
+			LEFT JOIN LATERAL (
+				SELECT GREATEST(MAX(come_time), MAX(leave_time)) AS last_seen
+				FROM attendance_period
+				WHERE attendance_id = a.id
+			) p ON TRUE
+			LEFT JOIN LATERAL (
+				SELECT end_time FROM company_info WHERE deleted_at IS NULL ORDER BY created_at DESC LIMIT 1
+			) c ON TRUE
+			LEFT JOIN users u ON u.employee_id = a.employee_id AND u.deleted_at IS NULL
+			WHERE a.deleted_at IS NULL
+				AND a.leave_time IS NULL
+				AND a.forget_leave IS NOT TRUE
+				AND a.work_day <= ?
+			ORDER BY a.work_day, a.id
+		`
+		rows, err := tx.QueryContext(ctx, query, policy, through)
+		if err != nil {
+			return errors.Wrap(err, "selecting forgotten check-outs")
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			f := ForgottenCheckout{Policy: policy}
+			if err := rows.Scan(&f.AttendanceID, &f.EmployeeID, &f.WorkDay, &f.ComeTime, &f.LeaveTime, &f.Email, &f.FirstName, &f.LastName); err != nil {
+				return errors.Wrap(err, "scanning forgotten check-out")
+			}
+			closed = append(closed, f)
+		}
+		if err := rows.Err(); err != nil {
+			return errors.Wrap(err, "iterating forgotten check-outs")
+		}
+		rows.Close()
+
+		for _, f := range closed {
+			q := tx.NewUpdate().
+				Table("attendance").
+				Where("id = ? AND leave_time IS NULL", f.AttendanceID).
+				Set("status = ?", false).
+				Set("forget_leave = ?", true).
+				Set("updated_at = ?", time.Now())
+			if f.LeaveTime != nil {
+				q.Set("leave_time = ?", *f.LeaveTime)
+			}
+			if _, err := q.Exec(ctx); err != nil {
+				return errors.Wrapf(err, "closing attendance %d", f.AttendanceID)
+			}
+
+			if f.LeaveTime != nil {
+				_, err := tx.NewUpdate().
+					Table("attendance_period").
+					Where("attendance_id = ? AND leave_time IS NULL", f.AttendanceID).
+					Set("leave_time = GREATEST(come_time, ?::time)", *f.LeaveTime).
+					Set("updated_at = ?", time.Now()).
+					Exec(ctx)
+				if err != nil {
+					return errors.Wrapf(err, "closing periods of attendance %d", f.AttendanceID)
+				}
+			}
+
+			if err := webhook.Enqueue(ctx, tx, webhook.EventForgotLeaveAutofix, f); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return closed, nil
 }
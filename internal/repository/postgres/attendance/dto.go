@@ -1,6 +1,9 @@
 package attendance
 
 import (
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/pkg/period"
+	"mime/multipart"
 	"time"
 
 	"github.com/Azure/go-autorest/autorest/date"
@@ -18,6 +21,7 @@ type Filter struct {
 	Date         *string
 }
 type OfficeLocation struct {
+	ID        int     `json:"id" bun:"id"`
 	Latitude  float64 `json:"latitude" bun:"latitude"`
 	Longitude float64 `json:"longitude" bun:"longitude"`
 	Radius    float64 `json:"radius" bun:"radius"`
@@ -117,6 +121,9 @@ type EnterRequest struct {
 	Latitude   float64 `json:"latitude" form:"latitude"`
 	Longitude  float64 `json:"longitude" form:"longitude"`
 	EmployeeID *string `json:"employee_id" form:"employee_id"`
+
+	// OfficeID is the office the check-in was made at, when known.
+	OfficeID *int `json:"-" form:"-"`
 }
 
 type UpdateRequest struct {
@@ -141,12 +148,14 @@ type PieChartResponse struct {
 	Absent *int `json:"absent" bun:"absent"`
 }
 type GraphRequest struct {
-	Month    date.Date
-	Interval int
+	Range  period.Range
+	Bucket string
 }
 type GraphResponse struct {
 	Percentage float64    `json:"percentage" bun:"percentage"`
 	WorkDay    *date.Date `json:"work_day" bun:"work_day"`
+	// EndDay is the last day of a week or month bucket.
+	EndDay *date.Date `json:"end_day,omitempty" bun:"-"`
 }
 type BarChartResponse struct {
 	Department *string  `json:"department" bun:"department"`
@@ -165,3 +174,106 @@ type Attendance struct {
 	UpdatedBy  int        `json:"updated_by" bun:"updated_by"`
 	DeletedAt  *time.Time `json:"deleted_at,omitempty" bun:"deleted_at"`
 }
+
+// LiveCounters is a snapshot of the current attendance activity used for
+// monitoring.
+type LiveCounters struct {
+	ClockedIn        int
+	PunchesPerMinute int
+}
+
+// CompanySchedule holds the working hours the background jobs are timed by.
+// Times are formatted HH:MM:SS and empty when not set.
+type CompanySchedule struct {
+	StartTime   string
+	LateTime    string
+	EndTime     string
+	OverEndTime string
+}
+
+// ForgottenCheckout is an attendance closed or flagged by the end-of-day job.
+// LeaveTime is nil when the record was only flagged.
+type ForgottenCheckout struct {
+	AttendanceID int     `json:"attendance_id"`
+	EmployeeID   string  `json:"employee_id"`
+	WorkDay      string  `json:"work_day"`
+	ComeTime     string  `json:"come_time"`
+	LeaveTime    *string `json:"leave_time"`
+	Policy       string  `json:"policy"`
+	Email        *string `json:"-"`
+	FirstName    *string `json:"-"`
+	LastName     *string `json:"-"`
+}
+
+// Conflict policies of a history import, for days the employee already has
+// an attendance of.
+const (
+	// HistorySkip keeps the attendance as it is.
+	HistorySkip = "skip"
+	// HistoryOverwrite deletes the attendance and imports the day anew.
+	HistoryOverwrite = "overwrite"
+	// HistoryMerge adds the imported periods to the attendance.
+	HistoryMerge = "merge"
+)
+
+// HistoryCreate is the action of a day without an attendance yet.
+const HistoryCreate = "create"
+
+// HistoryImportRequest uploads attendance history. Policy is HistorySkip by
+// default. DryRun validates the file without changing anything; Partial
+// imports the valid days even when others are rejected.
+type HistoryImportRequest struct {
+	File     *multipart.FileHeader `json:"-" form:"file"`
+	Policy   string                `json:"policy" form:"policy"`
+	Format   string                `json:"format" form:"format"`
+	Encoding string                `json:"encoding" form:"encoding"`
+	Partial  bool                  `json:"partial" form:"partial"`
+	DryRun   bool                  `json:"dry_run" form:"dry_run"`
+}
+
+// HistoryRow is what happens to one row of the file, that is one period.
+// Rejected rows have the action HistorySkip and carry the reasons in Errors.
+type HistoryRow struct {
+	Row        int              `json:"row"`
+	Line       int              `json:"line,omitempty"`
+	Action     string           `json:"action"`
+	EmployeeID string           `json:"employee_id,omitempty"`
+	WorkDay    string           `json:"work_day,omitempty"`
+	Errors     []web.FieldError `json:"errors,omitempty"`
+}
+
+// HistorySummary counts the rows of the file by action.
+type HistorySummary struct {
+	Create    int `json:"create"`
+	Overwrite int `json:"overwrite"`
+	Merge     int `json:"merge"`
+	Skip      int `json:"skip"`
+	// Rejected counts the skipped rows that have errors.
+	Rejected int `json:"rejected"`
+}
+
+// HistoryImportResult reports an import. Applied is false when nothing was
+// written, because of DryRun or rejected rows; Days counts the attendances
+// written by action.
+type HistoryImportResult struct {
+	Policy  string         `json:"policy"`
+	Applied bool           `json:"applied"`
+	Summary HistorySummary `json:"summary"`
+	Days    map[string]int `json:"days"`
+	Rows    []HistoryRow   `json:"rows"`
+}
+
+// HistoryExportFilter selects the attendance exported.
+type HistoryExportFilter struct {
+	Range      period.Range
+	EmployeeID *string
+	Format     string
+	Encoding   string
+}
+
+// ExportFile is an exported file.
+type ExportFile struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
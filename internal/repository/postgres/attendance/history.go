@@ -0,0 +1,703 @@
+package attendance
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/auth"
+	"attendance/backend/internal/pkg/period"
+	"attendance/backend/internal/service/hashing"
+	"attendance/backend/internal/upload"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// Columns of a history file. Every row is one period of a work day; the
+// rows of an employee and day together make the attendance of the day. A
+// row may list several periods in the periods column instead, as in
+// "09:00-12:00;13:00-18:00", in which case its come and leave time are
+// ignored. Only the last period of a day may be left without a leave time.
+const (
+	historyEmployeeID = "employee_id"
+	historyWorkDay    = "work_day"
+	historyComeTime   = "come_time"
+	historyLeaveTime  = "leave_time"
+	historyPeriods    = "periods"
+)
+
+var historyColumns = []string{historyEmployeeID, historyWorkDay, historyComeTime, historyLeaveTime, historyPeriods}
+
+// historySheet is the sheet of exported history workbooks.
+const historySheet = "勤怠履歴"
+
+// historyLayout is the history table of every format. Columns are known by
+// their names and by their Japanese titles, in workbooks too.
+var historyLayout = func() hashing.Layout {
+	layout := hashing.Layout{
+		Sheet:         historySheet,
+		Header:        []string{"社員番号", "勤務日", "出勤時刻", "退勤時刻", "勤務時間"},
+		ExcelByHeader: true,
+		Columns:       make(map[string]int),
+	}
+	for i, column := range historyColumns {
+		layout.Columns[column] = i
+		layout.Columns[layout.Header[i]] = i
+	}
+
+	return layout
+}()
+
+// historyExportColumns are the columns of an export. The first four are the
+// period, or the attendance itself when it has no periods, so an export can
+// be imported again.
+var historyExportColumns = []string{
+	historyEmployeeID,
+	historyWorkDay,
+	historyComeTime,
+	historyLeaveTime,
+	"attendance_id",
+	"period_id",
+	"attendance_come_time",
+	"attendance_leave_time",
+	"status",
+	"forget_leave",
+	"office_id",
+}
+
+var (
+	historyDayLayouts  = []string{"2006-01-02", "2006/01/02", "2006/1/2", "2006-1-2", "01-02-06"}
+	historyTimeLayouts = []string{"15:04:05", "15:04"}
+)
+
+// clock is a time of day in seconds since midnight.
+type clock int
+
+func parseClock(s string) (clock, bool) {
+	for _, layout := range historyTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return clock(t.Hour()*3600 + t.Minute()*60 + t.Second()), true
+		}
+	}
+
+	return 0, false
+}
+
+func (c clock) String() string {
+	return fmt.Sprintf("%02d:%02d:%02d", c/3600, c/60%60, c%60)
+}
+
+// historyPeriod is a period of a day. step is the row it is imported from,
+// nil for the periods already recorded.
+type historyPeriod struct {
+	come  clock
+	leave *clock
+	step  *historyStep
+	field string
+}
+
+func (p historyPeriod) String() string {
+	if p.leave == nil {
+		return p.come.String() + "-"
+	}
+
+	return p.come.String() + "-" + p.leave.String()
+}
+
+// historyStep is a row of the file with the periods read from it.
+type historyStep struct {
+	HistoryRow
+	periods []historyPeriod
+}
+
+type historyKey struct {
+	employeeID string
+	workDay    string
+}
+
+// historyDay is the attendance of an employee and day in the file, with the
+// attendance already recorded for it.
+type historyDay struct {
+	historyKey
+	steps []*historyStep
+	// attendanceIDs are the attendances recorded for the day, usually one.
+	attendanceIDs []int
+	existing      []historyPeriod
+	action        string
+	periods       []historyPeriod
+}
+
+// errHistoryRollback undoes a history import that is not to be applied.
+var errHistoryRollback = errors.New("history import not applied")
+
+// ImportHistory imports attendance history from an Excel, CSV or JSON Lines
+// file. Days the employee already has an attendance of are handled by the
+// policy of the request. The file is checked against the attendance in the
+// same transaction it is applied in, all days or none unless the request is
+// partial. Imported periods fire no webhooks.
+func (r Repository) ImportHistory(ctx context.Context, request HistoryImportRequest) (HistoryImportResult, error) {
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return HistoryImportResult{}, err
+	}
+	if err := r.ValidateStruct(&request, "File"); err != nil {
+		return HistoryImportResult{}, err
+	}
+
+	policy := strings.ToLower(request.Policy)
+	switch policy {
+	case "":
+		policy = HistorySkip
+	case HistorySkip, HistoryOverwrite, HistoryMerge:
+	default:
+		return HistoryImportResult{}, web.NewCodeError(i18n.CodeImportPolicy, http.StatusBadRequest, request.Policy)
+	}
+
+	file, err := upload.Read(upload.Import, request.File)
+	if err != nil {
+		return HistoryImportResult{}, upload.RequestError(err)
+	}
+
+	format, err := hashing.DetectFormat(request.Format, file.OriginalName)
+	if err != nil {
+		return HistoryImportResult{}, err
+	}
+	table, err := hashing.ReadTable(file.Data, format, request.Encoding, historyLayout)
+	if err != nil {
+		return HistoryImportResult{}, err
+	}
+
+	loc, _ := time.LoadLocation("Asia/Tokyo")
+	now := time.Now().In(loc)
+	lang := web.GetLang(ctx)
+
+	var steps []*historyStep
+	for i, row := range table.Rows {
+		if i == 0 || blankRow(row) {
+			continue
+		}
+		step := &historyStep{HistoryRow: HistoryRow{Row: i + 1, Line: table.Lines[i]}}
+		readHistoryRow(lang, step, row, now.Format("2006-01-02"))
+		steps = append(steps, step)
+	}
+
+	result := HistoryImportResult{Policy: policy, Days: make(map[string]int)}
+
+	err = r.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.ExecContext(ctx, "SET LOCAL attendance.import = 'on'"); err != nil {
+			return errors.Wrap(err, "marking transaction")
+		}
+
+		days, err := planHistory(ctx, tx, lang, policy, steps)
+		if err != nil {
+			return err
+		}
+
+		for _, step := range steps {
+			result.Rows = append(result.Rows, step.HistoryRow)
+			switch step.Action {
+			case HistoryCreate:
+				result.Summary.Create++
+			case HistoryOverwrite:
+				result.Summary.Overwrite++
+			case HistoryMerge:
+				result.Summary.Merge++
+			default:
+				result.Summary.Skip++
+				if len(step.Errors) > 0 {
+					result.Summary.Rejected++
+				}
+			}
+		}
+		if request.DryRun || (result.Summary.Rejected > 0 && !request.Partial) {
+			return errHistoryRollback
+		}
+
+		for _, day := range days {
+			if day.action == HistorySkip || rejectedDay(day) {
+				continue
+			}
+			if err := applyHistoryDay(ctx, tx, claims.UserId, now, day); err != nil {
+				return err
+			}
+			result.Days[day.action]++
+		}
+		result.Applied = true
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, errHistoryRollback) {
+		var webErr *web.Error
+		if errors.As(err, &webErr) {
+			return HistoryImportResult{}, err
+		}
+		return HistoryImportResult{}, web.NewRequestError(errors.Wrap(err, "importing attendance history"), http.StatusInternalServerError)
+	}
+
+	return result, nil
+}
+
+func historyFail(lang string, step *historyStep, column string, code string, args ...interface{}) {
+	step.Errors = append(step.Errors, web.FieldError{
+		Field: column,
+		Error: i18n.T(lang, code, args...),
+		Code:  code,
+	})
+}
+
+// readHistoryRow validates the cells of a row and reads its periods.
+func readHistoryRow(lang string, step *historyStep, row []string, today string) {
+	cells := make(map[string]string, len(historyColumns))
+	for i, column := range historyColumns {
+		if i < len(row) {
+			cells[column] = strings.TrimSpace(row[i])
+		}
+	}
+
+	step.EmployeeID = cells[historyEmployeeID]
+	if step.EmployeeID == "" {
+		historyFail(lang, step, historyEmployeeID, i18n.CodeFieldRequired)
+	}
+
+	if s := cells[historyWorkDay]; s == "" {
+		historyFail(lang, step, historyWorkDay, i18n.CodeFieldRequired)
+	} else if day, ok := parseHistoryDay(s); !ok {
+		historyFail(lang, step, historyWorkDay, i18n.CodeDateFormat)
+	} else {
+		step.WorkDay = day
+		if day > today {
+			historyFail(lang, step, historyWorkDay, i18n.CodeWorkDayFuture)
+		}
+	}
+
+	if list := cells[historyPeriods]; list != "" {
+		for _, part := range strings.FieldsFunc(list, func(r rune) bool { return r == ';' || r == ',' || r == '\n' }) {
+			come, leave, _ := strings.Cut(strings.NewReplacer("~", "-", "〜", "-").Replace(part), "-")
+			readHistoryPeriod(lang, step, historyPeriods, historyPeriods, strings.TrimSpace(come), strings.TrimSpace(leave))
+		}
+		return
+	}
+
+	if cells[historyComeTime] == "" {
+		historyFail(lang, step, historyComeTime, i18n.CodeFieldRequired)
+		return
+	}
+	readHistoryPeriod(lang, step, historyComeTime, historyLeaveTime, cells[historyComeTime], cells[historyLeaveTime])
+}
+
+func readHistoryPeriod(lang string, step *historyStep, comeField, leaveField, come, leave string) {
+	p := historyPeriod{step: step, field: comeField}
+
+	var ok bool
+	if p.come, ok = parseClock(come); !ok {
+		historyFail(lang, step, comeField, i18n.CodeTimeFormat)
+		return
+	}
+	if leave != "" {
+		l, ok := parseClock(leave)
+		if !ok {
+			historyFail(lang, step, leaveField, i18n.CodeTimeFormat)
+			return
+		}
+		if l < p.come {
+			historyFail(lang, step, leaveField, i18n.CodePeriodOrder)
+			return
+		}
+		p.leave = &l
+	}
+
+	step.periods = append(step.periods, p)
+}
+
+func parseHistoryDay(s string) (string, bool) {
+	for _, layout := range historyDayLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("2006-01-02"), true
+		}
+	}
+
+	return "", false
+}
+
+// planHistory checks the steps against the users, former employees
+// included, and the attendance of the database, locking the attendance
+// involved, and decides the action of every step. It returns the days of the file in the order of the file.
+func planHistory(ctx context.Context, tx bun.Tx, lang, policy string, steps []*historyStep) ([]*historyDay, error) {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, step := range steps {
+		if step.EmployeeID != "" && !seen[step.EmployeeID] {
+			seen[step.EmployeeID] = true
+			ids = append(ids, step.EmployeeID)
+		}
+	}
+
+	known := make(map[string]bool, len(ids))
+	if len(ids) > 0 {
+		rows, err := tx.QueryContext(ctx, "SELECT DISTINCT employee_id FROM users WHERE employee_id IN (?)", bun.In(ids))
+		if err != nil {
+			return nil, errors.Wrap(err, "selecting employees")
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				return nil, errors.Wrap(err, "scanning employee")
+			}
+			known[id] = true
+		}
+		if err := rows.Err(); err != nil {
+			return nil, errors.Wrap(err, "reading employees")
+		}
+		rows.Close()
+	}
+
+	var days []*historyDay
+	byKey := make(map[historyKey]*historyDay)
+	var from, to string
+	for _, step := range steps {
+		if step.EmployeeID != "" && !known[step.EmployeeID] {
+			historyFail(lang, step, historyEmployeeID, i18n.CodeEmployeeNotFound)
+		}
+		if step.EmployeeID == "" || step.WorkDay == "" {
+			step.Action = HistorySkip
+			continue
+		}
+
+		key := historyKey{employeeID: step.EmployeeID, workDay: step.WorkDay}
+		day, ok := byKey[key]
+		if !ok {
+			day = &historyDay{historyKey: key}
+			byKey[key] = day
+			days = append(days, day)
+		}
+		day.steps = append(day.steps, step)
+
+		if from == "" || step.WorkDay < from {
+			from = step.WorkDay
+		}
+		if step.WorkDay > to {
+			to = step.WorkDay
+		}
+	}
+
+	if len(days) > 0 {
+		if err := loadHistoryDays(ctx, tx, ids, from, to, byKey); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, day := range days {
+		day.action = HistoryCreate
+		if len(day.attendanceIDs) > 0 {
+			day.action = policy
+		}
+		if day.action != HistorySkip && !rejectedDay(day) {
+			if day.action == HistoryMerge {
+				day.periods = append(day.periods, day.existing...)
+			}
+			for _, step := range day.steps {
+				day.periods = append(day.periods, step.periods...)
+			}
+			checkHistoryPeriods(lang, day.periods)
+		}
+
+		// A day is imported whole or not at all.
+		rejected := 0
+		for _, step := range day.steps {
+			if len(step.Errors) > 0 {
+				rejected = step.Row
+				break
+			}
+		}
+		for _, step := range day.steps {
+			step.Action = day.action
+			if rejected == 0 {
+				continue
+			}
+			if len(step.Errors) == 0 {
+				historyFail(lang, step, historyWorkDay, i18n.CodeImportDayRejected, rejected)
+			}
+			step.Action = HistorySkip
+		}
+	}
+
+	return days, nil
+}
+
+func rejectedDay(day *historyDay) bool {
+	for _, step := range day.steps {
+		if len(step.Errors) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// loadHistoryDays fills in the attendance recorded for the days of the file,
+// locking it. An attendance without periods counts as one period.
+func loadHistoryDays(ctx context.Context, tx bun.Tx, ids []string, from, to string, byKey map[historyKey]*historyDay) error {
+	query := `
+		SELECT
+			a.id,
+			a.employee_id,
+			to_char(a.work_day, 'YYYY-MM-DD'),
+			to_char(COALESCE(p.come_time, a.come_time), 'HH24:MI:SS'),
+			to_char(CASE WHEN p.id IS NULL THEN a.leave_time ELSE p.leave_time END, 'HH24:MI:SS')
+		FROM attendance a
+		LEFT JOIN attendance_period p ON p.attendance_id = a.id
+		WHERE a.deleted_at IS NULL
+			AND a.employee_id IN (?)
+			AND a.work_day BETWEEN ? AND ?
+		ORDER BY a.id
+		FOR UPDATE OF a
+	`
+	rows, err := tx.QueryContext(ctx, query, bun.In(ids), from, to)
+	if err != nil {
+		return errors.Wrap(err, "selecting attendance")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id                  int
+			employeeID, workDay string
+			come                string
+			leave               *string
+		)
+		if err := rows.Scan(&id, &employeeID, &workDay, &come, &leave); err != nil {
+			return errors.Wrap(err, "scanning attendance")
+		}
+		day, ok := byKey[historyKey{employeeID: employeeID, workDay: workDay}]
+		if !ok {
+			continue
+		}
+
+		if n := len(day.attendanceIDs); n == 0 || day.attendanceIDs[n-1] != id {
+			day.attendanceIDs = append(day.attendanceIDs, id)
+		}
+		p := historyPeriod{}
+		p.come, _ = parseClock(come)
+		if leave != nil {
+			l, _ := parseClock(*leave)
+			p.leave = &l
+		}
+		day.existing = append(day.existing, p)
+	}
+
+	return errors.Wrap(rows.Err(), "reading attendance")
+}
+
+// checkHistoryPeriods sorts the periods of a day and rejects the imported
+// ones overlapping another or left open before another.
+func checkHistoryPeriods(lang string, periods []historyPeriod) {
+	sort.SliceStable(periods, func(i, j int) bool {
+		return periods[i].come < periods[j].come
+	})
+
+	for i := 1; i < len(periods); i++ {
+		prev, cur := periods[i-1], periods[i]
+
+		// Blame the imported period, the later one when both are.
+		blamed, other := cur, prev
+		if cur.step == nil {
+			blamed, other = prev, cur
+		}
+		if blamed.step == nil {
+			continue
+		}
+
+		switch {
+		case prev.leave == nil:
+			if prev.step != nil {
+				blamed = prev
+			}
+			historyFail(lang, blamed.step, blamed.field, i18n.CodePeriodOpen)
+		case cur.come < *prev.leave:
+			historyFail(lang, blamed.step, blamed.field, i18n.CodePeriodOverlap, other.String())
+		}
+	}
+}
+
+// applyHistoryDay writes the attendance of a day.
+func applyHistoryDay(ctx context.Context, tx bun.Tx, userID int, now time.Time, day *historyDay) error {
+	first, last := day.periods[0], day.periods[len(day.periods)-1]
+	var leave *string
+	if last.leave != nil {
+		s := last.leave.String()
+		leave = &s
+	}
+	// Only an attendance of today still open means the employee is in.
+	status := leave == nil && day.workDay == now.Format("2006-01-02")
+	stamp := now.Format("2006-01-02 15:04:05")
+
+	var attendanceID int
+	switch day.action {
+	case HistoryOverwrite:
+		_, err := tx.NewUpdate().
+			Table("attendance").
+			Where("id IN (?)", bun.In(day.attendanceIDs)).
+			Set("deleted_at = ?", stamp).
+			Set("deleted_by = ?", userID).
+			Exec(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "deleting attendance of %s on %s", day.employeeID, day.workDay)
+		}
+		fallthrough
+
+	case HistoryCreate:
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO attendance (employee_id, work_day, come_time, leave_time, status, forget_leave, created_at, created_by)
+			VALUES (?, ?, ?, ?, ?, false, ?, ?)
+			RETURNING id`,
+			day.employeeID, day.workDay, first.come.String(), leave, status, stamp, userID).Scan(&attendanceID)
+		if err != nil {
+			return errors.Wrapf(err, "inserting attendance of %s on %s", day.employeeID, day.workDay)
+		}
+
+	case HistoryMerge:
+		attendanceID = day.attendanceIDs[0]
+		_, err := tx.NewUpdate().
+			Table("attendance").
+			Where("id = ?", attendanceID).
+			Set("come_time = ?", first.come.String()).
+			Set("leave_time = ?", leave).
+			Set("status = ?", status).
+			Set("updated_at = ?", stamp).
+			Set("updated_by = ?", userID).
+			Exec(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "merging attendance of %s on %s", day.employeeID, day.workDay)
+		}
+	}
+
+	for _, p := range day.periods {
+		if p.step == nil {
+			continue
+		}
+		var leave *string
+		if p.leave != nil {
+			s := p.leave.String()
+			leave = &s
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO attendance_period (attendance_id, work_day, come_time, leave_time, updated_at)
+			VALUES (?, ?, ?, ?, ?)`,
+			attendanceID, day.workDay, p.come.String(), leave, stamp)
+		if err != nil {
+			return errors.Wrapf(err, "inserting period of %s on %s", day.employeeID, day.workDay)
+		}
+	}
+
+	return nil
+}
+
+// ExportHistory returns the attendance and its periods selected by filter
+// as a file of its format, one row per period.
+func (r Repository) ExportHistory(ctx context.Context, filter HistoryExportFilter) (ExportFile, error) {
+	if _, err := r.CheckClaims(ctx, auth.RoleAdmin); err != nil {
+		return ExportFile{}, err
+	}
+
+	format, err := hashing.DetectFormat(filter.Format, "")
+	if err != nil {
+		return ExportFile{}, err
+	}
+
+	where := []string{"a.deleted_at IS NULL", "a.work_day BETWEEN ? AND ?"}
+	args := []interface{}{filter.Range.From.Format(period.Layout), filter.Range.To.Format(period.Layout)}
+	if filter.EmployeeID != nil {
+		where = append(where, "a.employee_id = ?")
+		args = append(args, *filter.EmployeeID)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			a.employee_id,
+			to_char(a.work_day, 'YYYY-MM-DD'),
+			to_char(COALESCE(p.come_time, a.come_time), 'HH24:MI:SS'),
+			COALESCE(to_char(CASE WHEN p.id IS NULL THEN a.leave_time ELSE p.leave_time END, 'HH24:MI:SS'), ''),
+			a.id::text,
+			COALESCE(p.id::text, ''),
+			COALESCE(to_char(a.come_time, 'HH24:MI:SS'), ''),
+			COALESCE(to_char(a.leave_time, 'HH24:MI:SS'), ''),
+			COALESCE(a.status::text, ''),
+			COALESCE(a.forget_leave::text, ''),
+			COALESCE(a.office_id::text, '')
+		FROM attendance a
+		LEFT JOIN attendance_period p ON p.attendance_id = a.id
+		WHERE %s
+		ORDER BY a.work_day, a.employee_id, a.id, p.come_time, p.id
+	`, strings.Join(where, " AND "))
+
+	rows, err := r.QueryContext(ctx, query, args...)
+	if err != nil {
+		return ExportFile{}, web.NewRequestError(errors.Wrap(err, "selecting attendance history"), http.StatusInternalServerError)
+	}
+	defer rows.Close()
+
+	var records [][]string
+	for rows.Next() {
+		record := make([]string, len(historyExportColumns))
+		cells := make([]interface{}, len(record))
+		for i := range record {
+			cells[i] = &record[i]
+		}
+		if err := rows.Scan(cells...); err != nil {
+			return ExportFile{}, web.NewRequestError(errors.Wrap(err, "scanning attendance history"), http.StatusInternalServerError)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return ExportFile{}, web.NewRequestError(errors.Wrap(err, "reading attendance history"), http.StatusInternalServerError)
+	}
+
+	if format == hashing.FormatExcel {
+		data, err := hashing.TableWorkbook(historySheet, append([][]string{historyExportColumns}, records...))
+		if err != nil {
+			return ExportFile{}, web.NewRequestError(errors.Wrap(err, "writing attendance history"), http.StatusInternalServerError)
+		}
+
+		return ExportFile{
+			Name:        "attendance_history.xlsx",
+			ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			Data:        data,
+		}, nil
+	}
+
+	var buf bytes.Buffer
+	if err := hashing.WriteTable(&buf, format, filter.Encoding, historyExportColumns, records); err != nil {
+		var webErr *web.Error
+		if errors.As(err, &webErr) {
+			return ExportFile{}, err
+		}
+		return ExportFile{}, web.NewRequestError(errors.Wrap(err, "writing attendance history"), http.StatusInternalServerError)
+	}
+
+	file := ExportFile{Name: "attendance_history.csv", ContentType: "text/csv; charset=utf-8", Data: buf.Bytes()}
+	switch {
+	case format == hashing.FormatJSONL:
+		file.Name, file.ContentType = "attendance_history.jsonl", "application/x-ndjson"
+	case strings.EqualFold(filter.Encoding, hashing.EncodingShiftJIS):
+		file.ContentType = "text/csv; charset=shift_jis"
+	}
+
+	return file, nil
+}
+
+func blankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+
+	return true
+}
@@ -1,6 +1,7 @@
 package companyInfo
 
 import (
+	"attendance/backend/foundation/i18n"
 	"attendance/backend/foundation/web"
 	"attendance/backend/internal/pkg/repository/postgresql"
 	"context"
@@ -70,10 +71,7 @@ func (r Repository) GetInfo(ctx context.Context) (GetInfoResponse, error) {
 		Scan(ctx)
 	if err != nil {
 
-		return GetInfoResponse{}, &web.Error{
-			Err:    errors.New("company data not found!"),
-			Status: http.StatusNotFound,
-		}
+		return GetInfoResponse{}, web.NewCodeError(i18n.CodeCompanyInfoNotFound, http.StatusNotFound)
 	}
 	return detail, nil
 }
@@ -88,10 +86,7 @@ func (r Repository) GetAttendanceColor(ctx context.Context) (GetAttendanceColorR
 		Scan(ctx)
 	if err != nil {
 
-		return GetAttendanceColorResponse{}, &web.Error{
-			Err:    errors.New("company  attendance colors not found!"),
-			Status: http.StatusUnauthorized,
-		}
+		return GetAttendanceColorResponse{}, web.NewCodeError(i18n.CodeCompanyInfoNotFound, http.StatusUnauthorized)
 	}
 	return detail, nil
 }
@@ -106,10 +101,7 @@ func (r Repository) GetNewTableColor(ctx context.Context) (GetNewTableColorRespo
 		Scan(ctx)
 	if err != nil {
 
-		return GetNewTableColorResponse{}, &web.Error{
-			Err:    errors.New("company  New Table colors not found!"),
-			Status: http.StatusUnauthorized,
-		}
+		return GetNewTableColorResponse{}, web.NewCodeError(i18n.CodeCompanyInfoNotFound, http.StatusUnauthorized)
 	}
 	return detail, nil
 }
@@ -1,6 +1,7 @@
 package department
 
 import (
+	"attendance/backend/foundation/i18n"
 	"attendance/backend/foundation/web"
 	"attendance/backend/internal/entity"
 	"attendance/backend/internal/pkg/repository/postgresql"
@@ -196,7 +197,7 @@ func (r Repository) Create(ctx context.Context, request CreateRequest) (CreateRe
 
 	// Check if any of the fields are empty
 	if *request.Name == "" {
-		return CreateResponse{}, web.NewRequestError(errors.New("必須項目は空欄にできません、またはスペースのみを含むことはできません。"), http.StatusBadRequest)
+		return CreateResponse{}, web.NewCodeError(i18n.CodeRequiredBlank, http.StatusBadRequest)
 	}
 
 	// Check if the department name already exists
@@ -208,7 +209,7 @@ func (r Repository) Create(ctx context.Context, request CreateRequest) (CreateRe
 	}
 
 	if exists {
-		return CreateResponse{}, web.NewRequestError(errors.New("部門名はすでに使用されています。"), http.StatusBadRequest)
+		return CreateResponse{}, web.NewCodeError(i18n.CodeDepartmentNameTaken, http.StatusBadRequest)
 	}
 
 	// Get the last display number from the department table
@@ -219,10 +220,7 @@ func (r Repository) Create(ctx context.Context, request CreateRequest) (CreateRe
 
 	// Check if the new department's display number is valid
 	if request.DisplayNumber <= 0 || request.DisplayNumber > LastDisplayNumber+1 {
-		return CreateResponse{}, web.NewRequestError(
-			errors.Errorf("Invalid Display Number. The maximum allowed is %d or less than this number", LastDisplayNumber+1),
-			http.StatusBadRequest,
-		)
+		return CreateResponse{}, web.NewCodeError(i18n.CodeDisplayNumber, http.StatusBadRequest, LastDisplayNumber+1)
 	}
 	if request.DisplayNumber <= LastDisplayNumber {
 		_, err = r.ExecContext(ctx, `
@@ -264,7 +262,7 @@ func (r Repository) UpdateColumns(ctx context.Context, request UpdateRequest) er
 
 	// Check if any of the fields are empty
 	if *request.Name == "" {
-		return web.NewRequestError(errors.New("必須項目は空欄にできません、またはスペースのみを含むことはできません。"), http.StatusBadRequest)
+		return web.NewCodeError(i18n.CodeRequiredBlank, http.StatusBadRequest)
 	}
 
 	// Check if the department name already exists
@@ -277,7 +275,7 @@ func (r Repository) UpdateColumns(ctx context.Context, request UpdateRequest) er
 	}
 
 	if exists {
-		return web.NewRequestError(errors.New("部門名はすでに使用されています。"), http.StatusBadRequest)
+		return web.NewCodeError(i18n.CodeDepartmentNameTaken, http.StatusBadRequest)
 	}
 
 	// Get the last display number from the department table
@@ -294,10 +292,7 @@ func (r Repository) UpdateColumns(ctx context.Context, request UpdateRequest) er
 
 	// Validate the requested display number
 	if request.DisplayNumber < 1 || request.DisplayNumber > LastDisplayNumber {
-		return web.NewRequestError(
-			errors.Errorf("Invalid Display Number. The maximum allowed is %d or more than 0", LastDisplayNumber),
-			http.StatusBadRequest,
-		)
+		return web.NewCodeError(i18n.CodeDisplayNumber, http.StatusBadRequest, LastDisplayNumber)
 	}
 
 	// Update the display numbers of other departments
@@ -338,7 +333,6 @@ func (r Repository) UpdateColumns(ctx context.Context, request UpdateRequest) er
 	if request.Nickname != nil {
 		q.Set("department_nickname = ?", request.Nickname)
 	}
-	fmt.Println("Request:", request.Nickname)
 	q.Set("updated_at = ?", time.Now())
 	q.Set("updated_by = ?", claims.UserId)
 
@@ -367,7 +361,7 @@ func (r Repository) Delete(ctx context.Context, id int) error {
 	}
 
 	if exists {
-		return web.NewRequestError(errors.New("この部門はアクティブなユーザーに使われています。関連するユーザーを先に削除しないと、削除できません。"), http.StatusBadRequest)
+		return web.NewCodeError(i18n.CodeDepartmentInUse, http.StatusBadRequest)
 	}
 	// Fetch the current display number for the department being updated
 	var CurrentDisplayNumber int
@@ -0,0 +1,90 @@
+package job
+
+import (
+	"encoding/json"
+	"mime/multipart"
+	"time"
+)
+
+type Filter struct {
+	Limit  *int
+	Offset *int
+	Page   *int
+	Kind   *string
+	Status *string
+}
+
+// CreateRequest submits a job. Mode, Partial and Excell are the parameters
+// of KindUserImport, Format and Encoding those of KindUserImport and
+// KindUserExport; KindQrCodeList takes none.
+type CreateRequest struct {
+	Kind     *string               `json:"kind" form:"kind"`
+	Mode     int                   `json:"mode" form:"mode"`
+	Partial  bool                  `json:"partial" form:"partial"`
+	Format   string                `json:"format" form:"format"`
+	Encoding string                `json:"encoding" form:"encoding"`
+	Excell   *multipart.FileHeader `json:"-" form:"excell"`
+}
+
+// ImportParams are the parameters of KindUserImport.
+type ImportParams struct {
+	Mode     int    `json:"mode"`
+	Partial  bool   `json:"partial"`
+	Format   string `json:"format,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// ExportParams are the parameters of KindUserExport.
+type ExportParams struct {
+	Format   string `json:"format"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// Response is a job as shown to the user who submitted it. ResultURL is set
+// once there is a result to download.
+type Response struct {
+	ID              int64           `json:"id"`
+	Kind            string          `json:"kind"`
+	Status          string          `json:"status"`
+	Params          json.RawMessage `json:"params"`
+	FileName        *string         `json:"file_name"`
+	Progress        int             `json:"progress"`
+	Total           int             `json:"total"`
+	Attempts        int             `json:"attempts"`
+	CancelRequested bool            `json:"cancel_requested"`
+	Summary         json.RawMessage `json:"summary"`
+	Error           *string         `json:"error"`
+	ResultURL       *string         `json:"result_url"`
+	CreatedAt       time.Time       `json:"created_at"`
+	StartedAt       *time.Time      `json:"started_at"`
+	FinishedAt      *time.Time      `json:"finished_at"`
+}
+
+// Result is the file a job produced.
+type Result struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Job is a job claimed by the runner.
+type Job struct {
+	ID        int64
+	Kind      string
+	Params    json.RawMessage
+	Input     []byte
+	InputName string
+	Lang      string
+	Attempts  int
+	CreatedBy int
+	CreatedAt time.Time
+}
+
+// Outcome is how a run ended. Summary is shown with the job, Result is
+// offered for download.
+type Outcome struct {
+	Status  string
+	Summary interface{}
+	Result  *Result
+	Error   string
+}
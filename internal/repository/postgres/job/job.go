@@ -0,0 +1,457 @@
+package job
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/auth"
+	"attendance/backend/internal/pkg/repository/postgresql"
+	"attendance/backend/internal/repository/postgres"
+	"attendance/backend/internal/repository/postgres/user"
+	"attendance/backend/internal/service/hashing"
+	"attendance/backend/internal/upload"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// Kinds of jobs.
+const (
+	KindUserImport = "user_import"
+	KindUserExport = "user_export"
+	KindQrCodeList = "qr_code_list"
+)
+
+// Kinds lists every kind of job that can be submitted.
+var Kinds = []string{KindUserImport, KindUserExport, KindQrCodeList}
+
+// Job statuses. A queued job is waiting for a worker, a running one has been
+// claimed by a worker whose lease has not run out.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// ErrLeaseLost is returned by Finish when the job is no longer the caller's:
+// its lease ran out and another worker claimed it, or it was settled.
+var ErrLeaseLost = errors.New("job lease lost")
+
+type Repository struct {
+	*postgresql.Database
+}
+
+func NewRepository(database *postgresql.Database) *Repository {
+	return &Repository{Database: database}
+}
+
+// Create queues a job for the current admin. The uploaded workbook of an
+// import is stored with the job, the request it came with does not outlive
+// the response.
+func (r Repository) Create(ctx context.Context, request CreateRequest) (Response, error) {
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return Response{}, err
+	}
+
+	if err := r.ValidateStruct(&request, "Kind"); err != nil {
+		return Response{}, err
+	}
+
+	params := []byte("{}")
+	var input []byte
+	var inputName sql.NullString
+
+	switch *request.Kind {
+	case KindUserImport:
+		if err := r.ValidateStruct(&request, "Excell"); err != nil {
+			return Response{}, err
+		}
+		switch request.Mode {
+		case user.ImportModeCreate, user.ImportModeUpdate, user.ImportModeDelete:
+		default:
+			return Response{}, web.NewCodeError(i18n.CodeImportMode, http.StatusBadRequest)
+		}
+
+		file, err := upload.Read(upload.Import, request.Excell)
+		if err != nil {
+			return Response{}, upload.RequestError(err)
+		}
+
+		format, err := hashing.DetectFormat(request.Format, file.OriginalName)
+		if err != nil {
+			return Response{}, err
+		}
+
+		params, err = json.Marshal(ImportParams{Mode: request.Mode, Partial: request.Partial, Format: format, Encoding: request.Encoding})
+		if err != nil {
+			return Response{}, web.NewRequestError(errors.Wrap(err, "encoding job params"), http.StatusInternalServerError)
+		}
+
+		input = file.Data
+		inputName = sql.NullString{String: file.OriginalName, Valid: true}
+
+	case KindUserExport:
+		format, err := hashing.DetectFormat(request.Format, "")
+		if err != nil {
+			return Response{}, err
+		}
+
+		params, err = json.Marshal(ExportParams{Format: format, Encoding: request.Encoding})
+		if err != nil {
+			return Response{}, web.NewRequestError(errors.Wrap(err, "encoding job params"), http.StatusInternalServerError)
+		}
+
+	case KindQrCodeList:
+
+	default:
+		return Response{}, web.NewCodeError(i18n.CodeJobKind, http.StatusBadRequest, *request.Kind)
+	}
+
+	var id int64
+	err = r.QueryRowContext(ctx, `
+		INSERT INTO job (kind, params, input, input_name, lang, created_by)
+		VALUES (?, ?::jsonb, ?, ?, ?, ?)
+		RETURNING id`,
+		*request.Kind, string(params), input, inputName, r.GetLang(ctx), claims.UserId).Scan(&id)
+	if err != nil {
+		return Response{}, web.NewRequestError(errors.Wrap(err, "inserting job"), http.StatusInternalServerError)
+	}
+
+	return r.get(ctx, id, claims.UserId)
+}
+
+// GetList returns the jobs of the current admin, newest first.
+func (r Repository) GetList(ctx context.Context, filter Filter) ([]Response, int, error) {
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	whereQuery := fmt.Sprintf(`
+			WHERE
+				j.created_by = %d
+			`, claims.UserId)
+
+	if filter.Kind != nil {
+		whereQuery += fmt.Sprintf(` AND j.kind = '%s'`, strings.Replace(*filter.Kind, "'", "''", -1))
+	}
+	if filter.Status != nil {
+		whereQuery += fmt.Sprintf(` AND j.status = '%s'`, strings.Replace(*filter.Status, "'", "''", -1))
+	}
+
+	orderQuery := "ORDER BY j.created_at desc, j.id desc"
+
+	var limitQuery, offsetQuery string
+
+	if filter.Page != nil && filter.Limit != nil {
+		offset := (*filter.Page - 1) * (*filter.Limit)
+		filter.Offset = &offset
+	}
+
+	if filter.Limit != nil {
+		limitQuery += fmt.Sprintf(" LIMIT %d", *filter.Limit)
+	}
+
+	if filter.Offset != nil {
+		offsetQuery += fmt.Sprintf(" OFFSET %d", *filter.Offset)
+	}
+
+	rows, err := r.QueryContext(ctx, fmt.Sprintf(`%s %s %s %s %s`, selectQuery, whereQuery, orderQuery, limitQuery, offsetQuery))
+	if err != nil {
+		return nil, 0, web.NewRequestError(errors.Wrap(err, "selecting jobs"), http.StatusInternalServerError)
+	}
+	defer rows.Close()
+
+	var list []Response
+
+	for rows.Next() {
+		detail, err := scanResponse(rows)
+		if err != nil {
+			return nil, 0, web.NewRequestError(errors.Wrap(err, "scanning jobs"), http.StatusInternalServerError)
+		}
+
+		list = append(list, detail)
+	}
+
+	var count int
+	err = r.QueryRowContext(ctx, fmt.Sprintf(`SELECT count(j.id) FROM job j %s`, whereQuery)).Scan(&count)
+	if err != nil {
+		return nil, 0, web.NewRequestError(errors.Wrap(err, "counting jobs"), http.StatusInternalServerError)
+	}
+
+	return list, count, nil
+}
+
+// GetDetailById returns a job of the current admin.
+func (r Repository) GetDetailById(ctx context.Context, id int64) (Response, error) {
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return Response{}, err
+	}
+
+	return r.get(ctx, id, claims.UserId)
+}
+
+// GetResult returns the file a job of the current admin produced.
+func (r Repository) GetResult(ctx context.Context, id int64) (Result, error) {
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var result Result
+	var name, contentType sql.NullString
+	err = r.QueryRowContext(ctx, `
+		SELECT result, result_name, result_type
+		FROM job
+		WHERE id = ? AND created_by = ?`, id, claims.UserId).Scan(&result.Data, &name, &contentType)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Result{}, web.NewRequestError(postgres.ErrNotFound, http.StatusNotFound)
+		}
+		return Result{}, web.NewRequestError(errors.Wrap(err, "selecting job result"), http.StatusInternalServerError)
+	}
+	if result.Data == nil {
+		return Result{}, web.NewCodeError(i18n.CodeJobNoResult, http.StatusConflict)
+	}
+	result.Name, result.ContentType = name.String, contentType.String
+
+	return result, nil
+}
+
+// Cancel cancels a job of the current admin. A queued job is cancelled right
+// away, a running one is stopped by its worker on the next heartbeat.
+func (r Repository) Cancel(ctx context.Context, id int64) (Response, error) {
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return Response{}, err
+	}
+
+	err = r.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var status string
+		err := tx.QueryRowContext(ctx, "SELECT status FROM job WHERE id = ? AND created_by = ? FOR UPDATE", id, claims.UserId).Scan(&status)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return web.NewRequestError(postgres.ErrNotFound, http.StatusNotFound)
+			}
+			return web.NewRequestError(errors.Wrap(err, "selecting job"), http.StatusInternalServerError)
+		}
+
+		switch status {
+		case StatusQueued:
+			_, err = tx.ExecContext(ctx, "UPDATE job SET status = ?, finished_at = now() WHERE id = ?", StatusCancelled, id)
+		case StatusRunning:
+			_, err = tx.ExecContext(ctx, "UPDATE job SET cancel_requested = true WHERE id = ?", id)
+		default:
+			return web.NewCodeError(i18n.CodeJobFinished, http.StatusConflict)
+		}
+		if err != nil {
+			return web.NewRequestError(errors.Wrap(err, "cancelling job"), http.StatusInternalServerError)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return Response{}, err
+	}
+
+	return r.get(ctx, id, claims.UserId)
+}
+
+// Claim takes the oldest job of one of kinds that is queued, or running on a
+// worker whose lease ran out, and counts the attempt. The worker owns the job
+// until lease has passed and must extend it with Heartbeat. The attempt
+// fences the worker off once another one claimed the job: Heartbeat, Release
+// and Finish only act for the latest attempt. It returns nil
+// when there is nothing to run. Jobs asked to cancel whose worker is gone are
+// settled as cancelled on the way.
+func (r Repository) Claim(ctx context.Context, kinds []string, lease time.Duration) (*Job, error) {
+	_, err := r.ExecContext(ctx, `
+		UPDATE job SET status = ?, lease_until = NULL, finished_at = now()
+		WHERE status = ? AND cancel_requested AND lease_until < now()`, StatusCancelled, StatusRunning)
+	if err != nil {
+		return nil, errors.Wrap(err, "settling cancelled jobs")
+	}
+
+	var j Job
+	var params []byte
+	var inputName, lang sql.NullString
+	var createdBy sql.NullInt64
+	err = r.QueryRowContext(ctx, `
+		UPDATE job
+		SET status = ?,
+			attempts = attempts + 1,
+			started_at = COALESCE(started_at, now()),
+			lease_until = now() + make_interval(secs => ?)
+		WHERE id = (
+			SELECT id
+			FROM job
+			WHERE kind IN (?) AND NOT cancel_requested
+				AND (status = ? OR (status = ? AND lease_until < now()))
+			ORDER BY created_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, kind, params, input, input_name, lang, attempts, created_by, created_at`,
+		StatusRunning, lease.Seconds(), bun.In(kinds), StatusQueued, StatusRunning).Scan(
+		&j.ID, &j.Kind, &params, &j.Input, &inputName, &lang, &j.Attempts, &createdBy, &j.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "claiming job")
+	}
+	j.Params = params
+	j.InputName, j.Lang, j.CreatedBy = inputName.String, lang.String, int(createdBy.Int64)
+
+	return &j, nil
+}
+
+// Heartbeat records the progress of a running job and extends its lease. It
+// reports whether the worker of attempt should stop: the job was asked to
+// cancel, or is no longer its own.
+func (r Repository) Heartbeat(ctx context.Context, id int64, attempt, progress, total int, lease time.Duration) (bool, error) {
+	var cancel bool
+	err := r.QueryRowContext(ctx, `
+		UPDATE job
+		SET progress = ?, total = ?, lease_until = now() + make_interval(secs => ?)
+		WHERE id = ? AND status = ? AND attempts = ?
+		RETURNING cancel_requested`,
+		progress, total, lease.Seconds(), id, StatusRunning, attempt).Scan(&cancel)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// The job is no longer ours, stop working on it.
+			return true, nil
+		}
+		return false, errors.Wrap(err, "recording job progress")
+	}
+
+	return cancel, nil
+}
+
+// Release puts a running job back in the queue, for a worker shutting down.
+func (r Repository) Release(ctx context.Context, id int64, attempt int) error {
+	_, err := r.ExecContext(ctx, `
+		UPDATE job SET status = ?, lease_until = NULL
+		WHERE id = ? AND status = ? AND attempts = ?`, StatusQueued, id, StatusRunning, attempt)
+	if err != nil {
+		return errors.Wrap(err, "releasing job")
+	}
+
+	return nil
+}
+
+// Finish records the outcome of attempt. It returns ErrLeaseLost, recording
+// nothing, when the job is no longer the attempt's.
+func (r Repository) Finish(ctx context.Context, id int64, attempt int, outcome Outcome) error {
+	q := r.NewUpdate().Table("job").
+		Where("id = ?", id).
+		Where("status = ?", StatusRunning).
+		Where("attempts = ?", attempt).
+		Set("status = ?", outcome.Status).
+		Set("lease_until = NULL").
+		Set("finished_at = now()")
+
+	if outcome.Summary != nil {
+		summary, err := json.Marshal(outcome.Summary)
+		if err != nil {
+			return errors.Wrap(err, "encoding job summary")
+		}
+		q.Set("summary = ?::jsonb", string(summary))
+	}
+	if outcome.Result != nil {
+		q.Set("result = ?", outcome.Result.Data).
+			Set("result_name = ?", outcome.Result.Name).
+			Set("result_type = ?", outcome.Result.ContentType)
+	}
+	if outcome.Error != "" {
+		q.Set("error = ?", outcome.Error)
+	}
+	if outcome.Status == StatusSucceeded {
+		q.Set("progress = total")
+	}
+
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "recording job outcome")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
+
+const selectQuery = `
+		SELECT
+			j.id,
+			j.kind,
+			j.status,
+			j.params,
+			j.input_name,
+			j.progress,
+			j.total,
+			j.attempts,
+			j.cancel_requested,
+			j.summary,
+			j.error,
+			j.result IS NOT NULL,
+			j.created_at,
+			j.started_at,
+			j.finished_at
+		FROM job j`
+
+func (r Repository) get(ctx context.Context, id int64, userID int) (Response, error) {
+	row := r.QueryRowContext(ctx, selectQuery+` WHERE j.id = ? AND j.created_by = ?`, id, userID)
+
+	detail, err := scanResponse(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Response{}, web.NewRequestError(postgres.ErrNotFound, http.StatusNotFound)
+		}
+		return Response{}, web.NewRequestError(errors.Wrap(err, "scanning job"), http.StatusInternalServerError)
+	}
+
+	return detail, nil
+}
+
+func scanResponse(row interface{ Scan(...interface{}) error }) (Response, error) {
+	var detail Response
+	var params, summary []byte
+	var hasResult bool
+	if err := row.Scan(
+		&detail.ID,
+		&detail.Kind,
+		&detail.Status,
+		&params,
+		&detail.FileName,
+		&detail.Progress,
+		&detail.Total,
+		&detail.Attempts,
+		&detail.CancelRequested,
+		&summary,
+		&detail.Error,
+		&hasResult,
+		&detail.CreatedAt,
+		&detail.StartedAt,
+		&detail.FinishedAt); err != nil {
+		return Response{}, err
+	}
+	detail.Params = params
+	detail.Summary = summary
+	if hasResult {
+		url := fmt.Sprintf("/api/v1/job/%d/result", detail.ID)
+		detail.ResultURL = &url
+	}
+
+	return detail, nil
+}
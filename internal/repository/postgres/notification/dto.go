@@ -0,0 +1,61 @@
+package notification
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Recipient is a user a notification is sent to. Channels and Muted are
+// only meaningful when HasPreferences is set, otherwise the defaults apply.
+type Recipient struct {
+	UserID         int
+	EmployeeID     string
+	Email          *string
+	FirstName      *string
+	LastName       *string
+	Lang           *string
+	HasPreferences bool
+	Channels       []string
+	Muted          []string
+}
+
+// InboxRequest is a notification stored in the in-app inbox.
+type InboxRequest struct {
+	UserID int
+	Kind   string
+	Title  string
+	Body   string
+	Data   json.RawMessage
+}
+
+type PreferencesResponse struct {
+	Lang      *string    `json:"lang"`
+	Channels  []string   `json:"channels"`
+	Muted     []string   `json:"muted"`
+	Default   bool       `json:"default"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+type PreferencesRequest struct {
+	Lang     *string  `json:"lang" form:"lang"`
+	Channels []string `json:"channels" form:"channels"`
+	Muted    []string `json:"muted" form:"muted"`
+}
+
+type Filter struct {
+	Limit  *int
+	Offset *int
+	Page   *int
+	Unread *bool
+	Kind   *string
+}
+
+type GetListResponse struct {
+	ID        int64           `json:"id"`
+	Kind      string          `json:"kind"`
+	Title     string          `json:"title"`
+	Body      string          `json:"body"`
+	Data      json.RawMessage `json:"data"`
+	ReadAt    *time.Time      `json:"read_at"`
+	CreatedAt time.Time       `json:"created_at"`
+}
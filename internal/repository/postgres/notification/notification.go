@@ -0,0 +1,454 @@
+package notification
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/pkg/repository/postgresql"
+	"attendance/backend/internal/repository/postgres"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+// Kinds of notifications. Users can mute each of them.
+const (
+	KindCheckInReminder   = "check_in_reminder"
+	KindCheckOutReminder  = "check_out_reminder"
+	KindForgottenCheckout = "forgotten_checkout"
+)
+
+// Kinds lists every notification kind.
+var Kinds = []string{
+	KindCheckInReminder,
+	KindCheckOutReminder,
+	KindForgottenCheckout,
+}
+
+// Channels notifications are delivered through.
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelInbox   = "inbox"
+)
+
+// Channels lists every channel.
+var Channels = []string{
+	ChannelEmail,
+	ChannelWebhook,
+	ChannelInbox,
+}
+
+type Repository struct {
+	*postgresql.Database
+}
+
+func NewRepository(database *postgresql.Database) *Repository {
+	return &Repository{Database: database}
+}
+
+// ClaimReminders returns the employees due a reminder of kind on workDay and
+// records that they got it, so every employee is reminded once a day however
+// many replicas ask. Employees who muted kind are left out. It does not check
+// claims.
+func (r Repository) ClaimReminders(ctx context.Context, kind string, workDay string) ([]Recipient, error) {
+	var due string
+	switch kind {
+	case KindCheckInReminder:
+		due = `NOT EXISTS (
+				SELECT 1 FROM attendance a
+				WHERE a.employee_id = u.employee_id AND a.work_day = ? AND a.deleted_at IS NULL)`
+	case KindCheckOutReminder:
+		due = `EXISTS (
+				SELECT 1 FROM attendance a
+				WHERE a.employee_id = u.employee_id AND a.work_day = ? AND a.deleted_at IS NULL
+					AND a.leave_time IS NULL)`
+	default:
+		return nil, fmt.Errorf("no reminder for notification kind %q", kind)
+	}
+
+	query := fmt.Sprintf(`
+		WITH claimed AS (
+			INSERT INTO reminder_log (user_id, kind, work_day)
+			SELECT u.id, ?, ?
+			FROM users u
+			WHERE u.deleted_at IS NULL AND u.role = 'EMPLOYEE'
+				AND %s
+				AND NOT EXISTS (
+					SELECT 1 FROM notification_preference p
+					WHERE p.user_id = u.id AND ? = ANY(p.muted))
+			ON CONFLICT DO NOTHING
+			RETURNING user_id
+		)
+		%s
+		JOIN claimed c ON c.user_id = u.id
+	`, due, recipientQuery)
+
+	rows, err := r.QueryContext(ctx, query, kind, workDay, workDay, kind)
+	if err != nil {
+		return nil, errors.Wrapf(err, "claiming %s", kind)
+	}
+
+	return scanRecipients(rows)
+}
+
+// GetRecipients returns the users with the given employee IDs. It does not
+// check claims.
+func (r Repository) GetRecipients(ctx context.Context, employeeIDs []string) ([]Recipient, error) {
+	if len(employeeIDs) == 0 {
+		return nil, nil
+	}
+
+	query := recipientQuery + `
+		WHERE u.deleted_at IS NULL AND u.employee_id = ANY(?)
+	`
+
+	rows, err := r.QueryContext(ctx, query, pgdialect.Array(employeeIDs))
+	if err != nil {
+		return nil, errors.Wrap(err, "selecting recipients")
+	}
+
+	return scanRecipients(rows)
+}
+
+const recipientQuery = `
+		SELECT
+			u.id,
+			u.employee_id,
+			u.email,
+			u.first_name,
+			u.last_name,
+			p.lang,
+			p.user_id IS NOT NULL,
+			p.channels,
+			p.muted
+		FROM users u
+		LEFT JOIN notification_preference p ON p.user_id = u.id`
+
+func scanRecipients(rows *sql.Rows) ([]Recipient, error) {
+	defer rows.Close()
+
+	var list []Recipient
+	for rows.Next() {
+		var recipient Recipient
+		if err := rows.Scan(
+			&recipient.UserID,
+			&recipient.EmployeeID,
+			&recipient.Email,
+			&recipient.FirstName,
+			&recipient.LastName,
+			&recipient.Lang,
+			&recipient.HasPreferences,
+			pgdialect.Array(&recipient.Channels),
+			pgdialect.Array(&recipient.Muted)); err != nil {
+			return nil, errors.Wrap(err, "scanning recipient")
+		}
+		list = append(list, recipient)
+	}
+
+	return list, rows.Err()
+}
+
+// CreateInbox stores a notification in the in-app inbox of a user. It does
+// not check claims.
+func (r Repository) CreateInbox(ctx context.Context, request InboxRequest) (int64, error) {
+	var data interface{}
+	if len(request.Data) > 0 {
+		data = string(request.Data)
+	}
+
+	var id int64
+	err := r.QueryRowContext(ctx, `
+		INSERT INTO notification (user_id, kind, title, body, data)
+		VALUES (?, ?, ?, ?, ?::jsonb)
+		RETURNING id`, request.UserID, request.Kind, request.Title, request.Body, data).Scan(&id)
+	if err != nil {
+		return 0, errors.Wrap(err, "creating notification")
+	}
+
+	return id, nil
+}
+
+// GetPreferences returns the preferences of the signed in user. Default is
+// true, and Channels nil, when the user never changed them.
+func (r Repository) GetPreferences(ctx context.Context) (PreferencesResponse, error) {
+	claims, err := r.CheckClaims(ctx)
+	if err != nil {
+		return PreferencesResponse{}, err
+	}
+
+	var response PreferencesResponse
+	err = r.QueryRowContext(ctx, `
+		SELECT lang, channels, muted, updated_at
+		FROM notification_preference
+		WHERE user_id = ?`, claims.UserId).Scan(
+		&response.Lang,
+		pgdialect.Array(&response.Channels),
+		pgdialect.Array(&response.Muted),
+		&response.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return PreferencesResponse{Muted: []string{}, Default: true}, nil
+	}
+	if err != nil {
+		return PreferencesResponse{}, web.NewRequestError(errors.Wrap(err, "selecting notification preferences"), http.StatusInternalServerError)
+	}
+
+	return response, nil
+}
+
+// UpdatePreferences changes the preferences of the signed in user. Fields
+// left out keep their value.
+func (r Repository) UpdatePreferences(ctx context.Context, request PreferencesRequest, defaults []string) error {
+	claims, err := r.CheckClaims(ctx)
+	if err != nil {
+		return err
+	}
+
+	var lang interface{}
+	if request.Lang != nil && *request.Lang != "" {
+		if !i18n.Supported(*request.Lang) {
+			return web.NewCodeError(i18n.CodeLanguageInvalid, http.StatusBadRequest, *request.Lang)
+		}
+		lang = *request.Lang
+	}
+
+	var channels, muted interface{}
+	if request.Channels != nil {
+		list, err := validate(request.Channels, Channels, i18n.CodeNotificationChannel)
+		if err != nil {
+			return err
+		}
+		channels = pgdialect.Array(list)
+	}
+	if request.Muted != nil {
+		list, err := validate(request.Muted, Kinds, i18n.CodeNotificationKind)
+		if err != nil {
+			return err
+		}
+		muted = pgdialect.Array(list)
+	}
+
+	_, err = r.ExecContext(ctx, `
+		INSERT INTO notification_preference (user_id, lang, channels, muted, updated_at)
+		VALUES (?, ?, COALESCE(?, ?), COALESCE(?, '{}'), now())
+		ON CONFLICT (user_id) DO UPDATE SET
+			lang = CASE WHEN ? THEN EXCLUDED.lang ELSE notification_preference.lang END,
+			channels = COALESCE(?, notification_preference.channels),
+			muted = COALESCE(?, notification_preference.muted),
+			updated_at = now()`,
+		claims.UserId, lang, channels, pgdialect.Array(defaults), muted,
+		request.Lang != nil, channels, muted)
+	if err != nil {
+		return web.NewRequestError(errors.Wrap(err, "updating notification preferences"), http.StatusInternalServerError)
+	}
+
+	return nil
+}
+
+func validate(values []string, known []string, code string) ([]string, error) {
+	list := []string{}
+	seen := make(map[string]bool)
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if seen[v] {
+			continue
+		}
+
+		valid := false
+		for _, k := range known {
+			if v == k {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, web.NewCodeError(code, http.StatusBadRequest, v)
+		}
+
+		seen[v] = true
+		list = append(list, v)
+	}
+
+	return list, nil
+}
+
+// GetList returns the inbox of the signed in user, newest first.
+func (r Repository) GetList(ctx context.Context, filter Filter) ([]GetListResponse, int, error) {
+	claims, err := r.CheckClaims(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	whereQuery := fmt.Sprintf(`
+			WHERE
+				user_id = %d
+			`, claims.UserId)
+
+	if filter.Unread != nil {
+		if *filter.Unread {
+			whereQuery += ` AND read_at IS NULL`
+		} else {
+			whereQuery += ` AND read_at IS NOT NULL`
+		}
+	}
+	if filter.Kind != nil {
+		whereQuery += fmt.Sprintf(` AND kind = '%s'`, strings.Replace(*filter.Kind, "'", "''", -1))
+	}
+
+	orderQuery := "ORDER BY id desc"
+
+	var limitQuery, offsetQuery string
+
+	if filter.Page != nil && filter.Limit != nil {
+		offset := (*filter.Page - 1) * (*filter.Limit)
+		filter.Offset = &offset
+	}
+
+	if filter.Limit != nil {
+		limitQuery += fmt.Sprintf(" LIMIT %d", *filter.Limit)
+	}
+
+	if filter.Offset != nil {
+		offsetQuery += fmt.Sprintf(" OFFSET %d", *filter.Offset)
+	}
+
+	list, err := r.selectInbox(ctx, fmt.Sprintf("%s %s %s %s", whereQuery, orderQuery, limitQuery, offsetQuery))
+	if err != nil {
+		return nil, 0, web.NewRequestError(errors.Wrap(err, "selecting notifications"), http.StatusInternalServerError)
+	}
+
+	var count int
+	err = r.QueryRowContext(ctx, fmt.Sprintf(`SELECT count(id) FROM notification %s`, whereQuery)).Scan(&count)
+	if err != nil {
+		return nil, 0, web.NewRequestError(errors.Wrap(err, "counting notifications"), http.StatusInternalServerError)
+	}
+
+	return list, count, nil
+}
+
+// GetSince returns the notifications of the signed in user created after the
+// one with ID after, oldest first. It feeds the notification stream.
+func (r Repository) GetSince(ctx context.Context, after int64, limit int) ([]GetListResponse, error) {
+	claims, err := r.CheckClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := r.selectInbox(ctx, fmt.Sprintf(`
+			WHERE
+				user_id = %d AND id > %d
+			ORDER BY id
+			LIMIT %d`, claims.UserId, after, limit))
+	if err != nil {
+		return nil, web.NewRequestError(errors.Wrap(err, "selecting new notifications"), http.StatusInternalServerError)
+	}
+
+	return list, nil
+}
+
+func (r Repository) selectInbox(ctx context.Context, conditions string) ([]GetListResponse, error) {
+	rows, err := r.QueryContext(ctx, fmt.Sprintf(`
+		SELECT
+			id,
+			kind,
+			title,
+			body,
+			data,
+			read_at,
+			created_at
+		FROM notification
+		%s
+	`, conditions))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []GetListResponse{}
+	for rows.Next() {
+		var (
+			detail GetListResponse
+			data   []byte
+		)
+		if err = rows.Scan(
+			&detail.ID,
+			&detail.Kind,
+			&detail.Title,
+			&detail.Body,
+			&data,
+			&detail.ReadAt,
+			&detail.CreatedAt); err != nil {
+			return nil, err
+		}
+		if len(data) > 0 {
+			detail.Data = data
+		}
+
+		list = append(list, detail)
+	}
+
+	return list, rows.Err()
+}
+
+// GetUnreadCount returns the number of unread notifications of the signed in
+// user.
+func (r Repository) GetUnreadCount(ctx context.Context) (int, error) {
+	claims, err := r.CheckClaims(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = r.QueryRowContext(ctx, `
+		SELECT count(id) FROM notification WHERE user_id = ? AND read_at IS NULL`, claims.UserId).Scan(&count)
+	if err != nil {
+		return 0, web.NewRequestError(errors.Wrap(err, "counting unread notifications"), http.StatusInternalServerError)
+	}
+
+	return count, nil
+}
+
+// MarkRead marks a notification of the signed in user as read. Marking it
+// again keeps the first read time.
+func (r Repository) MarkRead(ctx context.Context, id int64) error {
+	claims, err := r.CheckClaims(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.ExecContext(ctx, `
+		UPDATE notification SET read_at = COALESCE(read_at, now())
+		WHERE id = ? AND user_id = ?`, id, claims.UserId)
+	if err != nil {
+		return web.NewRequestError(errors.Wrap(err, "marking notification read"), http.StatusInternalServerError)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return web.NewRequestError(postgres.ErrNotFound, http.StatusNotFound)
+	}
+
+	return nil
+}
+
+// MarkAllRead marks every notification of the signed in user as read and
+// returns how many were unread.
+func (r Repository) MarkAllRead(ctx context.Context) (int, error) {
+	claims, err := r.CheckClaims(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.ExecContext(ctx, `
+		UPDATE notification SET read_at = now()
+		WHERE user_id = ? AND read_at IS NULL`, claims.UserId)
+	if err != nil {
+		return 0, web.NewRequestError(errors.Wrap(err, "marking notifications read"), http.StatusInternalServerError)
+	}
+	n, _ := result.RowsAffected()
+
+	return int(n), nil
+}
@@ -1,6 +1,7 @@
 package position
 
 import (
+	"attendance/backend/foundation/i18n"
 	"attendance/backend/foundation/web"
 	"attendance/backend/internal/auth"
 	"attendance/backend/internal/entity"
@@ -185,7 +186,7 @@ func (r Repository) Create(ctx context.Context, request CreateRequest) (CreateRe
 
 	// Check if any of the fields are empty
 	if *request.Name == "" {
-		return CreateResponse{}, web.NewRequestError(errors.New("必須項目は空欄にできません、またはスペースのみを含むことはできません。"), http.StatusBadRequest)
+		return CreateResponse{}, web.NewCodeError(i18n.CodeRequiredBlank, http.StatusBadRequest)
 	}
 
 	// Check if the department exists
@@ -195,7 +196,7 @@ func (r Repository) Create(ctx context.Context, request CreateRequest) (CreateRe
 		return CreateResponse{}, web.NewRequestError(errors.Wrap(err, "checking department existence"), http.StatusInternalServerError)
 	}
 	if !exists {
-		return CreateResponse{}, web.NewRequestError(errors.New("無効または削除された部門ID"), http.StatusBadRequest)
+		return CreateResponse{}, web.NewCodeError(i18n.CodeDepartmentInvalid, http.StatusBadRequest)
 	}
 
 	// Check if the position name already exists for this department
@@ -204,7 +205,7 @@ func (r Repository) Create(ctx context.Context, request CreateRequest) (CreateRe
 		return CreateResponse{}, web.NewRequestError(errors.Wrap(err, "checking position duplication"), http.StatusInternalServerError)
 	}
 	if exists {
-		return CreateResponse{}, web.NewRequestError(errors.New("この部門にはすでに同じ名前の役職があります。"), http.StatusBadRequest)
+		return CreateResponse{}, web.NewCodeError(i18n.CodePositionNameTaken, http.StatusBadRequest)
 	}
 
 	var response CreateResponse
@@ -236,7 +237,7 @@ func (r Repository) UpdateAll(ctx context.Context, request UpdateRequest) error
 
 	// Check if any of the fields are empty
 	if *request.Name == "" {
-		return web.NewRequestError(errors.New("必須項目は空欄にできません、またはスペースのみを含むことはできません。"), http.StatusBadRequest)
+		return web.NewCodeError(i18n.CodeRequiredBlank, http.StatusBadRequest)
 	}
 
 	// Check if the department exists
@@ -246,7 +247,7 @@ func (r Repository) UpdateAll(ctx context.Context, request UpdateRequest) error
 		return web.NewRequestError(errors.Wrap(err, "checking department existence"), http.StatusInternalServerError)
 	}
 	if !exists {
-		return web.NewRequestError(errors.New("無効または削除された部門ID"), http.StatusBadRequest)
+		return web.NewCodeError(i18n.CodeDepartmentInvalid, http.StatusBadRequest)
 	}
 
 	// Check if the new name is already used in the same department by another record
@@ -255,7 +256,7 @@ func (r Repository) UpdateAll(ctx context.Context, request UpdateRequest) error
 		return web.NewRequestError(errors.Wrap(err, "checking position duplication"), http.StatusInternalServerError)
 	}
 	if exists {
-		return web.NewRequestError(errors.New("この部門にはすでに同じ名前の役職があります。"), http.StatusBadRequest)
+		return web.NewCodeError(i18n.CodePositionNameTaken, http.StatusBadRequest)
 	}
 
 	q := r.NewUpdate().Table("position").Where("deleted_at IS NULL AND id = ?", request.ID)
@@ -288,7 +289,7 @@ func (r Repository) UpdateColumns(ctx context.Context, request UpdateRequest) er
 		*request.Name = strings.TrimSpace(*request.Name)
 
 		if *request.Name == "" {
-			return web.NewRequestError(errors.New("必須項目は空欄にできません、またはスペースのみを含むことはできません。"), http.StatusBadRequest)
+			return web.NewCodeError(i18n.CodeRequiredBlank, http.StatusBadRequest)
 		}
 	}
 
@@ -299,7 +300,7 @@ func (r Repository) UpdateColumns(ctx context.Context, request UpdateRequest) er
 		return web.NewRequestError(errors.Wrap(err, "checking department existence"), http.StatusInternalServerError)
 	}
 	if !exists {
-		return web.NewRequestError(errors.New("無効または削除された部門ID"), http.StatusBadRequest)
+		return web.NewCodeError(i18n.CodeDepartmentInvalid, http.StatusBadRequest)
 	}
 
 	// Check if the new name is already used in the same department by another record
@@ -309,7 +310,7 @@ func (r Repository) UpdateColumns(ctx context.Context, request UpdateRequest) er
 			return web.NewRequestError(errors.Wrap(err, "checking position duplication"), http.StatusInternalServerError)
 		}
 		if exists {
-			return web.NewRequestError(errors.New("この部門にはすでに同じ名前の役職があります。"), http.StatusBadRequest)
+			return web.NewCodeError(i18n.CodePositionNameTaken, http.StatusBadRequest)
 		}
 	}
 
@@ -351,7 +352,7 @@ func (r Repository) Delete(ctx context.Context, id int) error {
 	}
 
 	if exists {
-		return web.NewRequestError(errors.New("このポジションはアクティブなユーザーに使われています。関連するユーザーを先に削除しないと、削除できません。"), http.StatusBadRequest)
+		return web.NewCodeError(i18n.CodePositionInUse, http.StatusBadRequest)
 	}
 	return r.DeleteRow(ctx, "position", id)
 }
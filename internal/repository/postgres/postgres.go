@@ -13,4 +13,7 @@ var (
 
 	// ErrForbidden occurs when a area tries to do something that is forbidden to them according to our access control policies.
 	ErrForbidden = errors.New("attempted action is not allowed")
+
+	// ErrLocked occurs when a job is skipped because another instance holds its lock.
+	ErrLocked = errors.New("locked by another instance")
 )
@@ -0,0 +1,89 @@
+package report
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Definition describes a report. From and To are dates formatted
+// YYYY-MM-DD; they are optional in saved definitions and can be given when
+// the report is run. OfficeID narrows the report to the employees of that
+// office, those who checked in there most within the range, absences
+// included.
+type Definition struct {
+	From            *string  `json:"from,omitempty" form:"from"`
+	To              *string  `json:"to,omitempty" form:"to"`
+	GroupBy         []string `json:"group_by" form:"group_by"`
+	Metrics         []string `json:"metrics" form:"metrics"`
+	DepartmentID    *int     `json:"department_id,omitempty" form:"department_id"`
+	PositionID      *int     `json:"position_id,omitempty" form:"position_id"`
+	OfficeID        *int     `json:"office_id,omitempty" form:"office_id"`
+	EmployeeID      *string  `json:"employee_id,omitempty" form:"employee_id"`
+	IncludeWeekends bool     `json:"include_weekends" form:"include_weekends"`
+}
+
+// Report is the result of running a definition. Every row holds one value
+// per column.
+type Report struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// Records returns the rows as objects keyed by column.
+func (r Report) Records() []map[string]interface{} {
+	records := make([]map[string]interface{}, 0, len(r.Rows))
+	for _, row := range r.Rows {
+		record := make(map[string]interface{}, len(r.Columns))
+		for i, column := range r.Columns {
+			record[column] = row[i]
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+type Filter struct {
+	Limit  *int
+	Offset *int
+	Page   *int
+	Search *string
+}
+
+type GetListResponse struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Definition Definition `json:"definition"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  *time.Time `json:"updated_at"`
+}
+
+type GetDetailByIdResponse struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Definition Definition `json:"definition"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  *time.Time `json:"updated_at"`
+}
+
+type CreateRequest struct {
+	Name       *string     `json:"name" form:"name"`
+	Definition *Definition `json:"definition" form:"definition"`
+}
+
+type CreateResponse struct {
+	bun.BaseModel `bun:"table:report_definition"`
+
+	ID         int        `json:"id" bun:"-"`
+	Name       string     `json:"name" bun:"name"`
+	Definition Definition `json:"definition" bun:"definition,type:jsonb"`
+	CreatedAt  time.Time  `json:"-" bun:"created_at"`
+	CreatedBy  int        `json:"-" bun:"created_by"`
+}
+
+type UpdateRequest struct {
+	ID         int         `json:"id" form:"id"`
+	Name       *string     `json:"name" form:"name"`
+	Definition *Definition `json:"definition" form:"definition"`
+}
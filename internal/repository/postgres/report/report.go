@@ -0,0 +1,518 @@
+package report
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/auth"
+	"attendance/backend/internal/pkg/period"
+	"attendance/backend/internal/pkg/repository/postgresql"
+	"attendance/backend/internal/repository/postgres"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Dimensions a report can be grouped by.
+const (
+	DimensionDay        = "day"
+	DimensionWeek       = "week"
+	DimensionMonth      = "month"
+	DimensionDepartment = "department"
+	DimensionPosition   = "position"
+	DimensionOffice     = "office"
+	DimensionEmployee   = "employee"
+)
+
+// Metrics a report can compute. Rates are percentages and hours decimal
+// hours, both rounded to two places.
+const (
+	MetricPresent        = "present"
+	MetricAbsent         = "absent"
+	MetricAttendanceRate = "attendance_rate"
+	MetricLateCount      = "late_count"
+	MetricAvgHours       = "avg_hours"
+	MetricTotalHours     = "total_hours"
+	MetricOvertimeHours  = "overtime_hours"
+)
+
+type column struct {
+	name string
+	expr string
+}
+
+// dimensions maps every dimension to its columns. Dimensions with an ID
+// carry the name next to it.
+var dimensions = map[string][]column{
+	DimensionDay:        {{"day", "to_char(b.work_day, 'YYYY-MM-DD')"}},
+	DimensionWeek:       {{"week", "to_char(date_trunc('week', b.work_day), 'YYYY-MM-DD')"}},
+	DimensionMonth:      {{"month", "to_char(b.work_day, 'YYYY-MM')"}},
+	DimensionDepartment: {{"department_id", "b.department_id"}, {"department", "d.name"}},
+	DimensionPosition:   {{"position_id", "b.position_id"}, {"position", "p.name"}},
+	DimensionOffice:     {{"office_id", "b.office_id"}, {"office", "o.company_name"}},
+	DimensionEmployee:   {{"employee_id", "b.employee_id"}, {"employee", "concat_ws(' ', b.last_name, b.first_name)"}},
+}
+
+var metrics = map[string]string{
+	MetricPresent:        "count(*) FILTER (WHERE b.present)",
+	MetricAbsent:         "count(*) FILTER (WHERE NOT b.present)",
+	MetricAttendanceRate: "round(100.0 * count(*) FILTER (WHERE b.present) / GREATEST(1, count(*)), 2)::float8",
+	MetricLateCount:      "count(*) FILTER (WHERE b.late)",
+	MetricAvgHours:       "COALESCE(round(avg(b.hours) FILTER (WHERE b.present)::numeric, 2), 0)::float8",
+	MetricTotalHours:     "COALESCE(round(sum(b.hours)::numeric, 2), 0)::float8",
+	MetricOvertimeHours:  "COALESCE(round(sum(b.overtime)::numeric, 2), 0)::float8",
+}
+
+// Dimensions lists every dimension.
+var Dimensions = []string{
+	DimensionDay,
+	DimensionWeek,
+	DimensionMonth,
+	DimensionDepartment,
+	DimensionPosition,
+	DimensionOffice,
+	DimensionEmployee,
+}
+
+// Metrics lists every metric.
+var Metrics = []string{
+	MetricPresent,
+	MetricAbsent,
+	MetricAttendanceRate,
+	MetricLateCount,
+	MetricAvgHours,
+	MetricTotalHours,
+	MetricOvertimeHours,
+}
+
+type Repository struct {
+	*postgresql.Database
+}
+
+func NewRepository(database *postgresql.Database) *Repository {
+	return &Repository{Database: database}
+}
+
+// Run computes a report. Every employee is expected on every day of the
+// range, weekends only when IncludeWeekends is set, from the day they were
+// created until the day they were deleted. A day is late when the first
+// check-in is after the company late_time and overtime is the time worked
+// past end_time. Employees belong to the office they checked in at most
+// within the range, which days without a check-in count for.
+func (r Repository) Run(ctx context.Context, definition Definition) (Report, error) {
+	if _, err := r.CheckClaims(ctx, auth.RoleAdmin); err != nil {
+		return Report{}, err
+	}
+
+	from, to, err := validateRange(definition)
+	if err != nil {
+		return Report{}, err
+	}
+	if err := validate(definition); err != nil {
+		return Report{}, err
+	}
+
+	var (
+		report  Report
+		selects []string
+		groups  []string
+	)
+	for _, dimension := range definition.GroupBy {
+		for _, c := range dimensions[dimension] {
+			report.Columns = append(report.Columns, c.name)
+			selects = append(selects, fmt.Sprintf("%s AS %s", c.expr, c.name))
+			groups = append(groups, fmt.Sprint(len(selects)))
+		}
+	}
+	for _, metric := range definition.Metrics {
+		report.Columns = append(report.Columns, metric)
+		selects = append(selects, fmt.Sprintf("%s AS %s", metrics[metric], metric))
+	}
+
+	whereQuery := ""
+	if definition.DepartmentID != nil {
+		whereQuery += fmt.Sprintf(` AND u.department_id = %d`, *definition.DepartmentID)
+	}
+	if definition.PositionID != nil {
+		whereQuery += fmt.Sprintf(` AND u.position_id = %d`, *definition.PositionID)
+	}
+	if definition.OfficeID != nil {
+		whereQuery += fmt.Sprintf(` AND h.office_id = %d`, *definition.OfficeID)
+	}
+	if definition.EmployeeID != nil {
+		whereQuery += fmt.Sprintf(` AND u.employee_id = '%s'`, strings.Replace(*definition.EmployeeID, "'", "''", -1))
+	}
+
+	var groupQuery, orderQuery string
+	if len(groups) > 0 {
+		groupQuery = "GROUP BY " + strings.Join(groups, ", ")
+		orderQuery = "ORDER BY " + strings.Join(groups, ", ")
+	}
+
+	query := fmt.Sprintf(`
+		WITH days AS (
+			SELECT d::date AS work_day
+			FROM generate_series(?::date, ?::date, interval '1 day') AS d
+			WHERE ? OR extract(isodow FROM d) < 6
+		),
+		schedule AS (
+			SELECT COALESCE(late_time, start_time) AS late_time, end_time
+			FROM company_info
+			WHERE deleted_at IS NULL
+			ORDER BY created_at DESC
+			LIMIT 1
+		),
+		worked AS (
+			SELECT
+				a.employee_id,
+				a.work_day,
+				min(a.office_id) AS office_id,
+				min(a.come_time) AS come_time,
+				max(a.leave_time) AS leave_time,
+				COALESCE(sum(extract(epoch FROM ap.leave_time - ap.come_time)), 0) / 3600.0 AS hours
+			FROM attendance a
+			LEFT JOIN attendance_period ap ON ap.attendance_id = a.id AND ap.leave_time IS NOT NULL
+			WHERE a.deleted_at IS NULL AND a.work_day BETWEEN ? AND ?
+			GROUP BY a.employee_id, a.work_day
+		),
+		home AS (
+			SELECT DISTINCT ON (a.employee_id) a.employee_id, a.office_id
+			FROM attendance a
+			WHERE a.deleted_at IS NULL AND a.office_id IS NOT NULL AND a.work_day BETWEEN ? AND ?
+			GROUP BY a.employee_id, a.office_id
+			ORDER BY a.employee_id, count(*) DESC, a.office_id
+		),
+		base AS (
+			SELECT
+				days.work_day,
+				u.employee_id,
+				u.first_name,
+				u.last_name,
+				u.department_id,
+				u.position_id,
+				COALESCE(w.office_id, h.office_id) AS office_id,
+				w.employee_id IS NOT NULL AS present,
+				COALESCE(w.come_time > s.late_time, false) AS late,
+				w.hours,
+				CASE WHEN w.leave_time > s.end_time
+					THEN extract(epoch FROM w.leave_time - s.end_time) / 3600.0
+					ELSE 0 END AS overtime
+			FROM days
+			JOIN users u ON u.role = 'EMPLOYEE'
+				AND COALESCE(u.created_at::date <= days.work_day, true)
+				AND (u.deleted_at IS NULL OR u.deleted_at::date > days.work_day)
+			LEFT JOIN worked w ON w.employee_id = u.employee_id AND w.work_day = days.work_day
+			LEFT JOIN home h ON h.employee_id = u.employee_id
+			LEFT JOIN schedule s ON true
+			WHERE true %s
+		)
+		SELECT %s
+		FROM base b
+		LEFT JOIN department d ON d.id = b.department_id
+		LEFT JOIN position p ON p.id = b.position_id
+		LEFT JOIN company_info o ON o.id = b.office_id
+		%s %s
+	`, whereQuery, strings.Join(selects, ", "), groupQuery, orderQuery)
+
+	rows, err := r.QueryContext(ctx, query, from, to, definition.IncludeWeekends, from, to, from, to)
+	if err != nil {
+		return Report{}, web.NewRequestError(errors.Wrap(err, "running report"), http.StatusInternalServerError)
+	}
+	defer rows.Close()
+
+	report.Rows = [][]interface{}{}
+	for rows.Next() {
+		row := make([]interface{}, len(report.Columns))
+		dest := make([]interface{}, len(row))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return Report{}, web.NewRequestError(errors.Wrap(err, "scanning report"), http.StatusInternalServerError)
+		}
+		for i, v := range row {
+			if b, ok := v.([]byte); ok {
+				row[i] = string(b)
+			}
+		}
+
+		report.Rows = append(report.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return Report{}, web.NewRequestError(errors.Wrap(err, "reading report"), http.StatusInternalServerError)
+	}
+
+	return report, nil
+}
+
+func validateRange(definition Definition) (string, string, error) {
+	if definition.From == nil || *definition.From == "" {
+		return "", "", web.NewCodeError(i18n.CodeParamRequired, http.StatusBadRequest, "from")
+	}
+	if definition.To == nil || *definition.To == "" {
+		return "", "", web.NewCodeError(i18n.CodeParamRequired, http.StatusBadRequest, "to")
+	}
+
+	from, err := time.Parse("2006-01-02", *definition.From)
+	if err != nil {
+		return "", "", web.NewCodeError(i18n.CodeDateFormat, http.StatusBadRequest)
+	}
+	to, err := time.Parse("2006-01-02", *definition.To)
+	if err != nil {
+		return "", "", web.NewCodeError(i18n.CodeDateFormat, http.StatusBadRequest)
+	}
+	if to.Before(from) || int(to.Sub(from).Hours()/24)+1 > period.MaxDays {
+		return "", "", web.NewCodeError(i18n.CodeReportRange, http.StatusBadRequest, period.MaxDays)
+	}
+
+	return from.Format("2006-01-02"), to.Format("2006-01-02"), nil
+}
+
+// validate checks the dimensions and metrics of a definition. The range is
+// checked when the report is run.
+func validate(definition Definition) error {
+	seen := make(map[string]bool)
+	for _, dimension := range definition.GroupBy {
+		if _, ok := dimensions[dimension]; !ok || seen[dimension] {
+			return web.NewCodeError(i18n.CodeReportDimension, http.StatusBadRequest, dimension)
+		}
+		seen[dimension] = true
+	}
+
+	if len(definition.Metrics) == 0 {
+		return web.NewCodeError(i18n.CodeParamRequired, http.StatusBadRequest, "metrics")
+	}
+	for _, metric := range definition.Metrics {
+		if _, ok := metrics[metric]; !ok || seen[metric] {
+			return web.NewCodeError(i18n.CodeReportMetric, http.StatusBadRequest, metric)
+		}
+		seen[metric] = true
+	}
+
+	return nil
+}
+
+// saved definitions
+
+// GetList returns the report definitions saved by the signed in admin.
+func (r Repository) GetList(ctx context.Context, filter Filter) ([]GetListResponse, int, error) {
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	whereQuery := fmt.Sprintf(`
+			WHERE
+				deleted_at IS NULL AND created_by = %d
+			`, claims.UserId)
+
+	if filter.Search != nil {
+		whereQuery += fmt.Sprintf(` AND name ILIKE '%%%s%%'`, strings.Replace(*filter.Search, "'", "''", -1))
+	}
+
+	orderQuery := "ORDER BY created_at desc"
+
+	var limitQuery, offsetQuery string
+
+	if filter.Page != nil && filter.Limit != nil {
+		offset := (*filter.Page - 1) * (*filter.Limit)
+		filter.Offset = &offset
+	}
+
+	if filter.Limit != nil {
+		limitQuery += fmt.Sprintf(" LIMIT %d", *filter.Limit)
+	}
+
+	if filter.Offset != nil {
+		offsetQuery += fmt.Sprintf(" OFFSET %d", *filter.Offset)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			id,
+			name,
+			definition,
+			created_at,
+			updated_at
+		FROM report_definition
+		%s %s %s %s
+	`, whereQuery, orderQuery, limitQuery, offsetQuery)
+
+	rows, err := r.QueryContext(ctx, query)
+	if err != nil {
+		return nil, 0, web.NewRequestError(errors.Wrap(err, "selecting reports"), http.StatusInternalServerError)
+	}
+	defer rows.Close()
+
+	var list []GetListResponse
+
+	for rows.Next() {
+		var (
+			detail     GetListResponse
+			definition []byte
+		)
+		if err = rows.Scan(
+			&detail.ID,
+			&detail.Name,
+			&definition,
+			&detail.CreatedAt,
+			&detail.UpdatedAt); err != nil {
+			return nil, 0, web.NewRequestError(errors.Wrap(err, "scanning report list"), http.StatusInternalServerError)
+		}
+		if err = json.Unmarshal(definition, &detail.Definition); err != nil {
+			return nil, 0, web.NewRequestError(errors.Wrap(err, "decoding report definition"), http.StatusInternalServerError)
+		}
+
+		list = append(list, detail)
+	}
+
+	var count int
+	err = r.QueryRowContext(ctx, fmt.Sprintf(`SELECT count(id) FROM report_definition %s`, whereQuery)).Scan(&count)
+	if err != nil {
+		return nil, 0, web.NewRequestError(errors.Wrap(err, "counting reports"), http.StatusInternalServerError)
+	}
+
+	return list, count, nil
+}
+
+// GetDetailById returns a report definition saved by the signed in admin.
+func (r Repository) GetDetailById(ctx context.Context, id int) (GetDetailByIdResponse, error) {
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return GetDetailByIdResponse{}, err
+	}
+
+	var (
+		detail     GetDetailByIdResponse
+		definition []byte
+	)
+	err = r.QueryRowContext(ctx, `
+		SELECT
+			id,
+			name,
+			definition,
+			created_at,
+			updated_at
+		FROM report_definition
+		WHERE deleted_at IS NULL AND id = ? AND created_by = ?
+	`, id, claims.UserId).Scan(
+		&detail.ID,
+		&detail.Name,
+		&definition,
+		&detail.CreatedAt,
+		&detail.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return GetDetailByIdResponse{}, web.NewRequestError(postgres.ErrNotFound, http.StatusNotFound)
+	}
+	if err != nil {
+		return GetDetailByIdResponse{}, web.NewRequestError(errors.Wrap(err, "selecting report"), http.StatusInternalServerError)
+	}
+	if err = json.Unmarshal(definition, &detail.Definition); err != nil {
+		return GetDetailByIdResponse{}, web.NewRequestError(errors.Wrap(err, "decoding report definition"), http.StatusInternalServerError)
+	}
+
+	return detail, nil
+}
+
+func (r Repository) Create(ctx context.Context, request CreateRequest) (CreateResponse, error) {
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return CreateResponse{}, err
+	}
+
+	if err := r.ValidateStruct(&request, "Name", "Definition"); err != nil {
+		return CreateResponse{}, err
+	}
+
+	name := strings.TrimSpace(*request.Name)
+	if name == "" {
+		return CreateResponse{}, web.NewCodeError(i18n.CodeRequiredBlank, http.StatusBadRequest)
+	}
+	if err := validateSaved(*request.Definition); err != nil {
+		return CreateResponse{}, err
+	}
+
+	response := CreateResponse{
+		Name:       name,
+		Definition: *request.Definition,
+		CreatedAt:  time.Now(),
+		CreatedBy:  claims.UserId,
+	}
+
+	_, err = r.NewInsert().Model(&response).Returning("id").Exec(ctx, &response.ID)
+	if err != nil {
+		return CreateResponse{}, web.NewRequestError(errors.Wrap(err, "creating report"), http.StatusInternalServerError)
+	}
+
+	return response, nil
+}
+
+func (r Repository) UpdateColumns(ctx context.Context, request UpdateRequest) error {
+	if err := r.ValidateStruct(&request, "ID"); err != nil {
+		return err
+	}
+
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	q := r.NewUpdate().Table("report_definition").Where("deleted_at IS NULL AND id = ? AND created_by = ?", request.ID, claims.UserId)
+
+	if request.Name != nil {
+		name := strings.TrimSpace(*request.Name)
+		if name == "" {
+			return web.NewCodeError(i18n.CodeRequiredBlank, http.StatusBadRequest)
+		}
+		q.Set("name = ?", name)
+	}
+	if request.Definition != nil {
+		if err := validateSaved(*request.Definition); err != nil {
+			return err
+		}
+		definition, err := json.Marshal(request.Definition)
+		if err != nil {
+			return web.NewRequestError(errors.Wrap(err, "encoding report definition"), http.StatusInternalServerError)
+		}
+		q.Set("definition = ?::jsonb", string(definition))
+	}
+
+	q.Set("updated_at = ?", time.Now())
+	q.Set("updated_by = ?", claims.UserId)
+
+	result, err := q.Exec(ctx)
+	if err != nil {
+		return web.NewRequestError(errors.Wrap(err, "updating report"), http.StatusInternalServerError)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return web.NewRequestError(postgres.ErrNotFound, http.StatusNotFound)
+	}
+
+	return nil
+}
+
+func (r Repository) Delete(ctx context.Context, id int) error {
+	if _, err := r.GetDetailById(ctx, id); err != nil {
+		return err
+	}
+
+	return r.DeleteRow(ctx, "report_definition", id)
+}
+
+// validateSaved checks a definition about to be saved. The range may be
+// left out, but must be valid when given.
+func validateSaved(definition Definition) error {
+	if definition.From != nil || definition.To != nil {
+		if _, _, err := validateRange(definition); err != nil {
+			return err
+		}
+	}
+
+	return validate(definition)
+}
@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"fmt"
+	"time"
+)
+
+// ExistedOn returns a condition holding for the rows of alias that were
+// created by the end of day and not deleted by then.
+func ExistedOn(alias string, day time.Time) string {
+	end := day.AddDate(0, 0, 1).Format("2006-01-02")
+
+	return fmt.Sprintf("(%[1]s.created_at IS NULL OR %[1]s.created_at < '%[2]s') AND (%[1]s.deleted_at IS NULL OR %[1]s.deleted_at >= '%[2]s')", alias, end)
+}
+
+// Roster returns a subquery selecting the users as they were at the end of
+// day, in the department they were in then. Department moves are read from
+// user_department_history; users without history keep their department.
+func Roster(day time.Time) string {
+	end := day.AddDate(0, 0, 1).Format("2006-01-02")
+
+	return fmt.Sprintf(`(
+        SELECT
+            u.id,
+            u.employee_id,
+            u.role,
+            u.first_name,
+            u.last_name,
+            u.nick_name,
+            u.position_id,
+            u.photo,
+            u.photo_thumb,
+            CASE WHEN h.user_id IS NULL THEN u.department_id ELSE h.department_id END AS department_id
+        FROM users u
+        LEFT JOIN LATERAL (
+            SELECT user_id, department_id
+            FROM user_department_history
+            WHERE user_id = u.id AND valid_from < '%s'
+            ORDER BY valid_from DESC, id DESC
+            LIMIT 1
+        ) h ON true
+        WHERE %s
+    )`, end, ExistedOn("u", day))
+}
@@ -1,6 +1,8 @@
 package user
 
 import (
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/pkg/period"
 	"mime/multipart"
 	"time"
 
@@ -15,6 +17,9 @@ type Filter struct {
 	Search       *string
 	DepartmentID *int
 	PositionID   *int
+
+	// Date selects the day of the dashboard list, today when nil.
+	Date *time.Time
 }
 
 type SignInRequest struct {
@@ -44,6 +49,8 @@ type GetListResponse struct {
 	Position     *string `json:"position"`
 	Phone        *string `json:"phone"`
 	Email        *string `json:"email"`
+	PhotoURL     *string `json:"photo_url"`
+	ThumbnailURL *string `json:"photo_thumb_url"`
 }
 
 type GetDetailByIdResponse struct {
@@ -57,12 +64,81 @@ type GetDetailByIdResponse struct {
 	Position     *string `json:"position"`
 	Phone        *string `json:"phone"`
 	Email        *string `json:"email"`
+	PhotoURL     *string `json:"photo_url"`
+	ThumbnailURL *string `json:"photo_thumb_url"`
 }
 
+// ExcellRequest uploads an employee file. Format is xlsx, csv or jsonl, by
+// default the one the file extension stands for; Encoding is the encoding of
+// a CSV file, utf-8 or shift_jis, detected when not given.
 type ExcellRequest struct {
 	Mode   int                   `json:"mode" form:"mode"`
 	Excell *multipart.FileHeader `json:"-" form:"excell"`
+	// Partial applies the valid rows even when others are rejected.
+	Partial  bool   `json:"partial" form:"partial"`
+	Format   string `json:"format" form:"format"`
+	Encoding string `json:"encoding" form:"encoding"`
+}
+
+// ImportFile is an uploaded employee file.
+type ImportFile struct {
+	Name     string
+	Format   string
+	Encoding string
+	Data     []byte
+}
+
+// ExportFile is an exported employee list.
+type ExportFile struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// ImportPlan is the previewed outcome of an Excel import. Applying it
+// carries out exactly these rows.
+type ImportPlan struct {
+	ID        string        `json:"id"`
+	Mode      int           `json:"mode"`
+	FileName  string        `json:"file_name"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	Expired   bool          `json:"expired"`
+	AppliedAt *time.Time    `json:"applied_at"`
+	Summary   ImportSummary `json:"summary"`
+	Rows      []ImportRow   `json:"rows"`
+}
+
+type ImportSummary struct {
+	Create int `json:"create"`
+	Update int `json:"update"`
+	Delete int `json:"delete"`
+	Skip   int `json:"skip"`
+	// Rejected counts the skipped rows that have errors.
+	Rejected int `json:"rejected"`
+}
+
+// ImportRow is what happens to one row of the file. Skipped rows carry the
+// reasons in Errors, unless there was nothing to change.
+type ImportRow struct {
+	Row        int                     `json:"row"`
+	Line       int                     `json:"line,omitempty"`
+	Action     string                  `json:"action"`
+	EmployeeID string                  `json:"employee_id,omitempty"`
+	Changes    map[string]ImportChange `json:"changes,omitempty"`
+	Errors     []web.FieldError        `json:"errors,omitempty"`
+}
+
+type ImportChange struct {
+	Old *string `json:"old"`
+	New *string `json:"new"`
+}
+
+type ImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
 }
+
 type ExcellUpload struct {
 	Excell *multipart.FileHeader `json:"-" form:"excell"`
 	Url    string                `json:"url" form:"-"`
@@ -83,6 +159,8 @@ type GetDashboardlist struct {
 	LastName           *string `json:"last_name"`
 	NickName           string  `json:"nick_name"`
 	Status             *bool   `json:"status"`
+	PhotoURL           *string `json:"photo_url"`
+	ThumbnailURL       *string `json:"photo_thumb_url"`
 }
 
 type GetDepartmentlist struct {
@@ -94,7 +172,7 @@ type GetFullName struct {
 	bun.BaseModel `bun:"table:users"`
 
 	FullName   *string `json:"full_name"`
-	EmployeeID *string `json:employee_id"`
+	EmployeeID *string `json:"employee_id"`
 }
 
 type CreateResponse struct {
@@ -167,9 +245,71 @@ type UpdateRequest struct {
 	Phone        *string `json:"phone" form:"phone"`
 	Email        *string `json:"email" form:"email"`
 }
+
+// PhotoRequest uploads the profile photo of user ID, zero for the one
+// signed in. The crop is a square in pixels of the upright photo; without
+// one the largest centred square is taken.
+type PhotoRequest struct {
+	ID       int                   `json:"-" form:"-"`
+	Photo    *multipart.FileHeader `json:"-" form:"photo"`
+	CropX    *int                  `json:"crop_x" form:"crop_x"`
+	CropY    *int                  `json:"crop_y" form:"crop_y"`
+	CropSize *int                  `json:"crop_size" form:"crop_size"`
+}
+type PhotoResponse struct {
+	PhotoURL     *string `json:"photo_url"`
+	ThumbnailURL *string `json:"photo_thumb_url"`
+}
+
+// Profile is the profile of the signed in user. EditableFields lists the
+// fields they may change themselves; PendingEmail is the address waiting
+// for its code, if any.
+type Profile struct {
+	ID             int      `json:"id"`
+	EmployeeID     *string  `json:"employee_id"`
+	FirstName      *string  `json:"first_name"`
+	LastName       *string  `json:"last_name"`
+	NickName       *string  `json:"nick_name"`
+	Phone          *string  `json:"phone"`
+	Email          *string  `json:"email"`
+	PendingEmail   *string  `json:"pending_email"`
+	DepartmentID   *int     `json:"department_id"`
+	Department     *string  `json:"department"`
+	PositionID     *int     `json:"position_id"`
+	Position       *string  `json:"position"`
+	PhotoURL       *string  `json:"photo_url"`
+	ThumbnailURL   *string  `json:"photo_thumb_url"`
+	EditableFields []string `json:"editable_fields"`
+}
+
+// ProfileRequest changes the profile of the signed in user. Fields left out
+// keep their value; the email is changed with EmailChangeRequest.
+type ProfileRequest struct {
+	FirstName *string `json:"first_name" form:"first_name"`
+	LastName  *string `json:"last_name" form:"last_name"`
+	NickName  *string `json:"nick_name" form:"nick_name"`
+	Phone     *string `json:"phone" form:"phone"`
+}
+type ProfileFieldsRequest struct {
+	Fields []string `json:"fields" form:"fields"`
+}
+type EmailChangeRequest struct {
+	Email string `json:"email" form:"email"`
+}
+
+// EmailChange is a pending change of email, confirmed by the code sent to
+// the new address.
+type EmailChange struct {
+	Email     string    `json:"email"`
+	Code      string    `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+type EmailConfirmRequest struct {
+	Code string `json:"code" form:"code"`
+}
 type StatisticRequest struct {
-	Month    date.Date
-	Interval int
+	Range  period.Range
+	Bucket string
 }
 
 type StatisticResponse struct {
@@ -177,11 +317,17 @@ type StatisticResponse struct {
 	ComeTime   *string `json:"come_time" bun:"come_time"`
 	LeaveTime  *string `json:"leave_time,omitempty" bun:"leave_time"`
 	TotalHours string  `json:"total_hours" bun:"total_hours"`
+	// EndDay and Days are set on week and month buckets, which sum the
+	// hours of the Days worked from WorkDay to EndDay.
+	EndDay *string `json:"end_day,omitempty" bun:"-"`
+	Days   *int    `json:"days,omitempty" bun:"-"`
 }
 type DashboardResponse struct {
 	ComeTime   *string `json:"come_time" bun:"come_time"`
 	LeaveTime  *string `json:"leave_time" bun:"leave_time"`
 	TotalHours string  `json:"total_hours" bun:"total_hours"`
+
+	UnreadNotifications int `json:"unread_notifications" bun:"-"`
 }
 type MonthlyStatisticRequest struct {
 	Month date.Date
@@ -0,0 +1,74 @@
+package user
+
+import (
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/auth"
+	"attendance/backend/internal/service/hashing"
+	"attendance/backend/internal/storage"
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Export returns the employee list as a file of format. CSV and JSON Lines
+// files are keyed by the column names, so they can be imported again; the
+// password column is left empty.
+func (r *Repository) Export(ctx context.Context, format, encoding string) (ExportFile, error) {
+	if _, err := r.CheckClaims(ctx, auth.RoleAdmin); err != nil {
+		return ExportFile{}, err
+	}
+
+	format, err := hashing.DetectFormat(format, "")
+	if err != nil {
+		return ExportFile{}, err
+	}
+
+	if format == hashing.FormatExcel {
+		key, err := r.ExportEmployee(ctx)
+		if err != nil {
+			return ExportFile{}, err
+		}
+		data, err := storage.ReadAll(ctx, r.Files, key)
+		if err != nil {
+			return ExportFile{}, web.NewRequestError(errors.Wrap(err, "reading employee list"), http.StatusInternalServerError)
+		}
+
+		return ExportFile{
+			Name:        "employee_list.xlsx",
+			ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			Data:        data,
+		}, nil
+	}
+
+	list, err := r.employees(ctx)
+	if err != nil {
+		return ExportFile{}, err
+	}
+
+	rows := make([][]string, 0, len(list))
+	for _, e := range list {
+		rows = append(rows, []string{e.EmployeeID, e.LastName, e.FirstName, e.NickName, e.Role, "", e.DepartmentName, e.PositionName, e.Phone, e.Email})
+	}
+
+	var buf bytes.Buffer
+	if err := hashing.WriteTable(&buf, format, encoding, importColumns, rows); err != nil {
+		var webErr *web.Error
+		if errors.As(err, &webErr) {
+			return ExportFile{}, err
+		}
+		return ExportFile{}, web.NewRequestError(errors.Wrap(err, "writing employee list"), http.StatusInternalServerError)
+	}
+
+	file := ExportFile{Name: "employee_list.csv", ContentType: "text/csv; charset=utf-8", Data: buf.Bytes()}
+	switch {
+	case format == hashing.FormatJSONL:
+		file.Name, file.ContentType = "employee_list.jsonl", "application/x-ndjson"
+	case strings.EqualFold(encoding, hashing.EncodingShiftJIS):
+		file.ContentType = "text/csv; charset=shift_jis"
+	}
+
+	return file, nil
+}
@@ -0,0 +1,924 @@
+package user
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/auth"
+	"attendance/backend/internal/repository/postgres"
+	"attendance/backend/internal/service"
+	"attendance/backend/internal/service/hashing"
+	"attendance/backend/internal/storage"
+	"attendance/backend/internal/upload"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Import modes, as sent with the uploaded file.
+const (
+	ImportModeCreate = 1
+	ImportModeUpdate = 2
+	ImportModeDelete = 3
+)
+
+// Import row actions.
+const (
+	ImportCreate = "create"
+	ImportUpdate = "update"
+	ImportDelete = "delete"
+	ImportSkip   = "skip"
+)
+
+// ImportPlanTTL is how long a preview can be applied.
+const ImportPlanTTL = 30 * time.Minute
+
+// Columns of the employee sheet.
+const (
+	columnEmployeeID = "employee_id"
+	columnLastName   = "last_name"
+	columnFirstName  = "first_name"
+	columnNickName   = "nick_name"
+	columnRole       = "role"
+	columnPassword   = "password"
+	columnDepartment = "department"
+	columnPosition   = "position"
+	columnPhone      = "phone"
+	columnEmail      = "email"
+)
+
+var importColumns = []string{
+	columnEmployeeID,
+	columnLastName,
+	columnFirstName,
+	columnNickName,
+	columnRole,
+	columnPassword,
+	columnDepartment,
+	columnPosition,
+	columnPhone,
+	columnEmail,
+}
+
+// importLayout is the employee table of every format. CSV and JSON Lines
+// columns are known by the column names and by the titles of the sheet.
+var importLayout = func() hashing.Layout {
+	layout := hashing.Layout{
+		Sheet:   hashing.EmployeeSheet,
+		Header:  []string{"社員番号", "姓", "名", "表示名", "権限", "パスワード", "部署", "役職", "電話番号", "メールアドレス"},
+		Columns: make(map[string]int),
+	}
+	for i, column := range importColumns {
+		layout.Columns[column] = i
+		layout.Columns[layout.Header[i]] = i
+	}
+
+	return layout
+}()
+
+var (
+	importEmailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+	importPhoneRegex = regexp.MustCompile(`^\+?\d+$`)
+)
+
+// importStep is a row of a stored plan, with what is needed to apply it.
+type importStep struct {
+	ImportRow
+
+	// UserID and Version identify the user updated or deleted, as it was
+	// when previewed.
+	UserID  int    `json:"user_id,omitempty"`
+	Version string `json:"version,omitempty"`
+
+	Values *importValues `json:"values,omitempty"`
+}
+
+type importValues struct {
+	LastName     string `json:"last_name"`
+	FirstName    string `json:"first_name"`
+	NickName     string `json:"nick_name"`
+	Role         string `json:"role"`
+	DepartmentID int    `json:"department_id"`
+	PositionID   int    `json:"position_id"`
+	Phone        string `json:"phone"`
+	Email        string `json:"email"`
+}
+
+type importUser struct {
+	ID           int
+	EmployeeID   string
+	LastName     string
+	FirstName    string
+	NickName     string
+	Role         string
+	DepartmentID *int
+	PositionID   *int
+	Phone        string
+	Email        string
+	Version      string
+}
+
+// importState holds what rows are checked against.
+type importState struct {
+	lang        string
+	departments map[string]int
+	positions   map[string]int
+	names       map[string]map[int]string
+	users       map[string]importUser
+	emails      map[string]string
+	fileIDs     map[string]int
+	fileEmails  map[string]int
+}
+
+// PreviewImport checks every row of the uploaded file against the database
+// without changing anything and stores the resulting plan for ApplyImport.
+func (r Repository) PreviewImport(ctx context.Context, request ExcellRequest) (ImportPlan, error) {
+	file, err := r.readUpload(ctx, request)
+	if err != nil {
+		return ImportPlan{}, err
+	}
+
+	return r.PreviewFile(ctx, request.Mode, file)
+}
+
+// PreviewFile is PreviewImport for a file that was already read. Rows are
+// numbered as in the workbook of GetImportErrors, where the header is row 1;
+// rows of CSV and JSON Lines files also carry their line in the file.
+func (r Repository) PreviewFile(ctx context.Context, mode int, file ImportFile) (ImportPlan, error) {
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return ImportPlan{}, err
+	}
+
+	switch mode {
+	case ImportModeCreate, ImportModeUpdate, ImportModeDelete:
+	default:
+		return ImportPlan{}, web.NewCodeError(i18n.CodeImportMode, http.StatusBadRequest)
+	}
+
+	format, err := hashing.DetectFormat(file.Format, file.Name)
+	if err != nil {
+		return ImportPlan{}, err
+	}
+	table, err := hashing.ReadTable(file.Data, format, file.Encoding, importLayout)
+	if err != nil {
+		return ImportPlan{}, err
+	}
+	rows := table.Rows
+
+	state, err := r.loadImportState(ctx)
+	if err != nil {
+		return ImportPlan{}, err
+	}
+
+	steps := make([]importStep, 0, len(rows))
+	for i, row := range rows {
+		if i == 0 || blankRow(row) {
+			continue
+		}
+
+		step := importStep{ImportRow: ImportRow{Row: i + 1, Line: table.Lines[i]}}
+		switch mode {
+		case ImportModeCreate:
+			state.planCreate(&step, row)
+		case ImportModeUpdate:
+			state.planUpdate(&step, row)
+		case ImportModeDelete:
+			state.planDelete(&step, row)
+		}
+		if len(step.Errors) > 0 {
+			step.Action, step.Values = ImportSkip, nil
+		}
+		steps = append(steps, step)
+	}
+
+	// Passwords are hashed right away and kept apart from the steps, which
+	// clients see.
+	password := importLayout.Columns[columnPassword]
+	hashes := make(map[int]string)
+	for _, step := range steps {
+		if step.Action != ImportCreate {
+			continue
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(strings.TrimSpace(rows[step.Row-1][password])), bcrypt.DefaultCost)
+		if err != nil {
+			return ImportPlan{}, web.NewRequestError(errors.Wrap(err, "hashing password"), http.StatusInternalServerError)
+		}
+		hashes[step.Row] = string(hash)
+	}
+	encodedHashes, err := json.Marshal(hashes)
+	if err != nil {
+		return ImportPlan{}, web.NewRequestError(errors.Wrap(err, "encoding import passwords"), http.StatusInternalServerError)
+	}
+
+	// The rejected rows of any format are marked on a workbook, which is
+	// stored without the passwords.
+	workbook := file.Data
+	if format != hashing.FormatExcel {
+		if workbook, err = hashing.TableWorkbook(hashing.EmployeeSheet, rows); err != nil {
+			return ImportPlan{}, web.NewRequestError(errors.Wrap(err, "writing import workbook"), http.StatusInternalServerError)
+		}
+	}
+	if workbook, err = service.BlankExcelColumn(workbook, hashing.EmployeeSheet, password+1); err != nil {
+		return ImportPlan{}, web.NewRequestError(errors.Wrap(err, "removing passwords from import workbook"), http.StatusInternalServerError)
+	}
+
+	id, err := newImportID()
+	if err != nil {
+		return ImportPlan{}, web.NewRequestError(errors.Wrap(err, "generating import id"), http.StatusInternalServerError)
+	}
+	encoded, err := json.Marshal(steps)
+	if err != nil {
+		return ImportPlan{}, web.NewRequestError(errors.Wrap(err, "encoding import plan"), http.StatusInternalServerError)
+	}
+
+	workbookKey := importWorkbookKey(id)
+	if err := storage.PutBytes(ctx, r.Files, workbookKey, workbook, ""); err != nil {
+		return ImportPlan{}, web.NewRequestError(errors.Wrap(err, "storing import workbook"), http.StatusInternalServerError)
+	}
+
+	plan := ImportPlan{
+		ID:       id,
+		Mode:     mode,
+		FileName: file.Name,
+	}
+	err = r.QueryRowContext(ctx, `
+		INSERT INTO import_plan (id, mode, file_name, steps, password_hashes, workbook_key, expires_at, created_by)
+		VALUES (?, ?, ?, ?::jsonb, ?::jsonb, ?, now() + make_interval(secs => ?), ?)
+		RETURNING expires_at`,
+		plan.ID, plan.Mode, plan.FileName, string(encoded), string(encodedHashes), workbookKey, ImportPlanTTL.Seconds(), claims.UserId).Scan(&plan.ExpiresAt)
+	if err != nil {
+		r.removeImportWorkbook(ctx, workbookKey)
+		return ImportPlan{}, web.NewRequestError(errors.Wrap(err, "inserting import plan"), http.StatusInternalServerError)
+	}
+
+	plan.setRows(steps)
+
+	return plan, nil
+}
+
+// importWorkbookKey returns the key the workbook of a plan is stored under.
+func importWorkbookKey(id string) string {
+	return "imports/" + id + ".xlsx"
+}
+
+// readUpload reads the file of an import request.
+func (r Repository) readUpload(ctx context.Context, request ExcellRequest) (ImportFile, error) {
+	if _, err := r.CheckClaims(ctx, auth.RoleAdmin); err != nil {
+		return ImportFile{}, err
+	}
+	if err := r.ValidateStruct(&request, "Excell"); err != nil {
+		return ImportFile{}, err
+	}
+
+	file, err := upload.Read(upload.Import, request.Excell)
+	if err != nil {
+		return ImportFile{}, upload.RequestError(err)
+	}
+
+	return ImportFile{
+		Name:     file.OriginalName,
+		Format:   request.Format,
+		Encoding: request.Encoding,
+		Data:     file.Data,
+	}, nil
+}
+
+// GetImport returns a plan previewed by the current admin.
+func (r Repository) GetImport(ctx context.Context, id string) (ImportPlan, error) {
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return ImportPlan{}, err
+	}
+
+	plan, steps, err := selectImport(ctx, r.DB, id, claims.UserId, false)
+	if err != nil {
+		return ImportPlan{}, err
+	}
+	plan.setRows(steps)
+
+	return plan, nil
+}
+
+// Import previews the uploaded file and applies the plan right away. When
+// rows are rejected and request.Partial is not set nothing is applied and
+// the result is empty.
+func (r Repository) Import(ctx context.Context, request ExcellRequest) (ImportPlan, ImportResult, error) {
+	file, err := r.readUpload(ctx, request)
+	if err != nil {
+		return ImportPlan{}, ImportResult{}, err
+	}
+
+	plan, err := r.PreviewFile(ctx, request.Mode, file)
+	if err != nil {
+		return ImportPlan{}, ImportResult{}, err
+	}
+	if plan.Summary.Rejected > 0 && !request.Partial {
+		return plan, ImportResult{}, nil
+	}
+
+	result, err := r.ApplyImport(ctx, plan.ID, request.Partial)
+	if err != nil {
+		return ImportPlan{}, ImportResult{}, err
+	}
+
+	return plan, result, nil
+}
+
+// ApplyImport carries out a previewed plan in one transaction. A plan with
+// rejected rows is only applied, without them, when partial is set. It fails
+// with a conflict, changing nothing, when a user a row targets changed since
+// the preview, or an employee ID or email to be created got taken meanwhile.
+func (r Repository) ApplyImport(ctx context.Context, id string, partial bool) (ImportResult, error) {
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	var (
+		result      ImportResult
+		workbookKey sql.NullString
+	)
+	err = r.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		plan, steps, err := selectImport(ctx, tx, id, claims.UserId, true)
+		if err != nil {
+			return err
+		}
+		if err := plan.check(steps, partial); err != nil {
+			return err
+		}
+
+		var (
+			encoded []byte
+			hashes  map[int]string
+		)
+		err = tx.QueryRowContext(ctx, "SELECT password_hashes, workbook_key FROM import_plan WHERE id = ?", id).Scan(&encoded, &workbookKey)
+		if err != nil {
+			return web.NewRequestError(errors.Wrap(err, "selecting import passwords"), http.StatusInternalServerError)
+		}
+		if len(encoded) > 0 {
+			if err := json.Unmarshal(encoded, &hashes); err != nil {
+				return web.NewRequestError(errors.Wrap(err, "decoding import passwords"), http.StatusInternalServerError)
+			}
+		}
+
+		for _, step := range steps {
+			if err := applyImportStep(ctx, tx, step, hashes[step.Row], claims.UserId, &result); err != nil {
+				return err
+			}
+		}
+
+		// The passwords and the workbook are not needed any more.
+		_, err = tx.ExecContext(ctx, `
+			UPDATE import_plan
+			SET applied_at = now(), password_hashes = NULL, workbook = NULL, workbook_key = NULL
+			WHERE id = ?`, id)
+		if err != nil {
+			return web.NewRequestError(errors.Wrap(err, "marking import plan applied"), http.StatusInternalServerError)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	if workbookKey.Valid {
+		r.removeImportWorkbook(ctx, workbookKey.String)
+	}
+
+	return result, nil
+}
+
+// ClearImports deletes the workbooks and password hashes of the plans that
+// were applied or have expired, and returns how many plans it cleared.
+func (r Repository) ClearImports(ctx context.Context) (int, error) {
+	rows, err := r.QueryContext(ctx, `
+		SELECT id, workbook_key FROM import_plan
+		WHERE (applied_at IS NOT NULL OR expires_at < now())
+			AND (password_hashes IS NOT NULL OR workbook IS NOT NULL OR workbook_key IS NOT NULL)`)
+	if err != nil {
+		return 0, errors.Wrap(err, "selecting finished import plans")
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var (
+			id  string
+			key sql.NullString
+		)
+		if err := rows.Scan(&id, &key); err != nil {
+			return 0, errors.Wrap(err, "scanning finished import plans")
+		}
+		if key.Valid {
+			r.removeImportWorkbook(ctx, key.String)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, errors.Wrap(err, "reading finished import plans")
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	_, err = r.ExecContext(ctx, `
+		UPDATE import_plan
+		SET password_hashes = NULL, workbook = NULL, workbook_key = NULL
+		WHERE id IN (?)`, bun.In(ids))
+	if err != nil {
+		return 0, errors.Wrap(err, "clearing finished import plans")
+	}
+
+	return len(ids), nil
+}
+
+// removeImportWorkbook deletes a stored workbook. Failures are logged rather
+// than failing the request.
+func (r Repository) removeImportWorkbook(ctx context.Context, key string) {
+	if err := r.Files.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		slog.ErrorContext(ctx, "deleting import workbook", "key", key, "error", err)
+	}
+}
+
+// check returns why the plan cannot be applied, if it cannot.
+func (p *ImportPlan) check(steps []importStep, partial bool) error {
+	if p.AppliedAt != nil {
+		return web.NewCodeError(i18n.CodeImportPlanApplied, http.StatusConflict)
+	}
+	if p.Expired {
+		return web.NewCodeError(i18n.CodeImportPlanExpired, http.StatusGone)
+	}
+	p.setRows(steps)
+	if p.Summary.Rejected > 0 && !partial {
+		return web.NewCodeError(i18n.CodeImportRejected, http.StatusUnprocessableEntity, p.Summary.Rejected)
+	}
+
+	return nil
+}
+
+func applyImportStep(ctx context.Context, tx bun.Tx, step importStep, passwordHash string, userID int, result *ImportResult) error {
+	stale := web.NewCodeError(i18n.CodeImportStale, http.StatusConflict, step.Row)
+	now := time.Now()
+
+	switch step.Action {
+	case ImportDelete:
+		res, err := tx.ExecContext(ctx, `
+			UPDATE users SET deleted_at = ?, deleted_by = ?
+			WHERE id = ? AND deleted_at IS NULL AND COALESCE(updated_at, created_at)::text = ?`,
+			now, userID, step.UserID, step.Version)
+		if err != nil {
+			return web.NewRequestError(errors.Wrap(err, "deleting user"), http.StatusInternalServerError)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return stale
+		}
+		result.Deleted++
+
+	case ImportUpdate:
+		v := step.Values
+		res, err := tx.ExecContext(ctx, `
+			UPDATE users SET
+				last_name = ?, first_name = ?, nick_name = ?, role = ?,
+				department_id = ?, position_id = ?, phone = ?, email = ?,
+				updated_at = ?, updated_by = ?
+			WHERE id = ? AND deleted_at IS NULL AND COALESCE(updated_at, created_at)::text = ?`,
+			v.LastName, v.FirstName, v.NickName, v.Role, v.DepartmentID, v.PositionID, v.Phone, v.Email,
+			now, userID, step.UserID, step.Version)
+		if err != nil {
+			return web.NewRequestError(errors.Wrap(err, "updating user"), http.StatusInternalServerError)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return stale
+		}
+		result.Updated++
+
+	case ImportCreate:
+		if passwordHash == "" {
+			return stale
+		}
+		v := step.Values
+		var taken bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM users
+				WHERE deleted_at IS NULL AND (employee_id = ? OR (? <> '' AND email = ?))
+			)`, step.EmployeeID, v.Email, v.Email).Scan(&taken)
+		if err != nil {
+			return web.NewRequestError(errors.Wrap(err, "checking employee id"), http.StatusInternalServerError)
+		}
+		if taken {
+			return stale
+		}
+
+		user := CreateResponse{
+			EmployeeID:   &step.EmployeeID,
+			Password:     &passwordHash,
+			Role:         v.Role,
+			FirstName:    &v.FirstName,
+			LastName:     &v.LastName,
+			NickName:     v.NickName,
+			DepartmentID: &v.DepartmentID,
+			PositionID:   &v.PositionID,
+			Phone:        &v.Phone,
+			Email:        &v.Email,
+			CreatedAt:    now,
+			CreatedBy:    userID,
+		}
+		if _, err := tx.NewInsert().Model(&user).Exec(ctx); err != nil {
+			return web.NewRequestError(errors.Wrap(err, "inserting user"), http.StatusInternalServerError)
+		}
+		result.Created++
+	}
+
+	return nil
+}
+
+// selectImport loads a plan of the user, locking it when forUpdate is set.
+func selectImport(ctx context.Context, db bun.IDB, id string, userID int, forUpdate bool) (ImportPlan, []importStep, error) {
+	query := `
+		SELECT id, mode, COALESCE(file_name, ''), steps, expires_at, expires_at < now(), applied_at
+		FROM import_plan
+		WHERE id = ? AND created_by = ?`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	var (
+		plan    ImportPlan
+		encoded []byte
+		applied sql.NullTime
+	)
+	err := db.QueryRowContext(ctx, query, id, userID).Scan(&plan.ID, &plan.Mode, &plan.FileName, &encoded, &plan.ExpiresAt, &plan.Expired, &applied)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ImportPlan{}, nil, web.NewRequestError(postgres.ErrNotFound, http.StatusNotFound)
+		}
+		return ImportPlan{}, nil, web.NewRequestError(errors.Wrap(err, "selecting import plan"), http.StatusInternalServerError)
+	}
+	if applied.Valid {
+		plan.AppliedAt = &applied.Time
+	}
+
+	var steps []importStep
+	if err := json.Unmarshal(encoded, &steps); err != nil {
+		return ImportPlan{}, nil, web.NewRequestError(errors.Wrap(err, "decoding import plan"), http.StatusInternalServerError)
+	}
+
+	return plan, steps, nil
+}
+
+func (p *ImportPlan) setRows(steps []importStep) {
+	p.Rows = make([]ImportRow, 0, len(steps))
+	p.Summary = ImportSummary{}
+	for _, step := range steps {
+		p.Rows = append(p.Rows, step.ImportRow)
+		switch step.Action {
+		case ImportCreate:
+			p.Summary.Create++
+		case ImportUpdate:
+			p.Summary.Update++
+		case ImportDelete:
+			p.Summary.Delete++
+		default:
+			p.Summary.Skip++
+			if len(step.Errors) > 0 {
+				p.Summary.Rejected++
+			}
+		}
+	}
+}
+
+// importWorkbook returns the workbook plan id was previewed from.
+func (r Repository) importWorkbook(ctx context.Context, id string) ([]byte, error) {
+	// Plans previewed before the workbooks were kept in the storage still
+	// hold theirs in the table.
+	var (
+		workbook    []byte
+		workbookKey sql.NullString
+	)
+	err := r.QueryRowContext(ctx, `
+		SELECT workbook, workbook_key FROM import_plan
+		WHERE id = ? AND (workbook IS NOT NULL OR workbook_key IS NOT NULL)`, id).Scan(&workbook, &workbookKey)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, web.NewCodeError(i18n.CodeFileNotFound, http.StatusNotFound)
+		}
+		return nil, web.NewRequestError(errors.Wrap(err, "selecting import workbook"), http.StatusInternalServerError)
+	}
+	if workbookKey.Valid {
+		workbook, err = storage.ReadAll(ctx, r.Files, workbookKey.String)
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, web.NewCodeError(i18n.CodeFileNotFound, http.StatusNotFound)
+		}
+		if err != nil {
+			return nil, web.NewRequestError(errors.Wrap(err, "reading import workbook"), http.StatusInternalServerError)
+		}
+	}
+
+	return workbook, nil
+}
+
+// GetImportErrors returns the workbook of a plan, without the passwords, with
+// the errors of its rejected rows marked on the cells they were found in. It
+// is gone once the plan was applied or has expired.
+func (r Repository) GetImportErrors(ctx context.Context, id string) ([]byte, error) {
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	_, steps, err := selectImport(ctx, r.DB, id, claims.UserId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	workbook, err := r.importWorkbook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var cellErrors []service.CellError
+	for _, step := range steps {
+		for _, e := range step.Errors {
+			column := 1
+			for i, c := range importColumns {
+				if c == e.Field {
+					column = i + 1
+				}
+			}
+			cellErrors = append(cellErrors, service.CellError{Row: step.Row, Column: column, Message: e.Error})
+		}
+	}
+
+	annotated, err := service.AnnotateExcel(workbook, hashing.EmployeeSheet, cellErrors)
+	if err != nil {
+		return nil, web.NewRequestError(errors.Wrap(err, "annotating import workbook"), http.StatusInternalServerError)
+	}
+
+	return annotated, nil
+}
+
+func (r Repository) loadImportState(ctx context.Context) (*importState, error) {
+	departments, err := r.LoadDepartmentMap(ctx)
+	if err != nil {
+		return nil, web.NewRequestError(errors.Wrap(err, "loading department map"), http.StatusInternalServerError)
+	}
+	positions, err := r.LoadPositionMap(ctx)
+	if err != nil {
+		return nil, web.NewRequestError(errors.Wrap(err, "loading position map"), http.StatusInternalServerError)
+	}
+
+	s := importState{
+		lang:        web.GetLang(ctx),
+		departments: departments,
+		positions:   positions,
+		names: map[string]map[int]string{
+			columnDepartment: make(map[int]string, len(departments)),
+			columnPosition:   make(map[int]string, len(positions)),
+		},
+		users:      make(map[string]importUser),
+		emails:     make(map[string]string),
+		fileIDs:    make(map[string]int),
+		fileEmails: make(map[string]int),
+	}
+	for name, id := range departments {
+		s.names[columnDepartment][id] = name
+	}
+	for name, id := range positions {
+		s.names[columnPosition][id] = name
+	}
+
+	rows, err := r.QueryContext(ctx, `
+		SELECT
+			id, employee_id, COALESCE(last_name, ''), COALESCE(first_name, ''), COALESCE(nick_name, ''),
+			COALESCE(role::text, ''), department_id, position_id, COALESCE(phone, ''), COALESCE(email, ''),
+			COALESCE(updated_at, created_at)::text
+		FROM users
+		WHERE deleted_at IS NULL`)
+	if err != nil {
+		return nil, web.NewRequestError(errors.Wrap(err, "selecting users"), http.StatusInternalServerError)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			u       importUser
+			version sql.NullString
+		)
+		if err := rows.Scan(&u.ID, &u.EmployeeID, &u.LastName, &u.FirstName, &u.NickName, &u.Role, &u.DepartmentID, &u.PositionID, &u.Phone, &u.Email, &version); err != nil {
+			return nil, web.NewRequestError(errors.Wrap(err, "scanning user"), http.StatusInternalServerError)
+		}
+		u.Version = version.String
+		s.users[u.EmployeeID] = u
+		if u.Email != "" {
+			s.emails[u.Email] = u.EmployeeID
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, web.NewRequestError(errors.Wrap(err, "reading users"), http.StatusInternalServerError)
+	}
+
+	return &s, nil
+}
+
+func (s *importState) fail(step *importStep, column string, code string, args ...interface{}) {
+	step.Errors = append(step.Errors, web.FieldError{
+		Field: column,
+		Error: i18n.T(s.lang, code, args...),
+		Code:  code,
+	})
+}
+
+// readRow validates the cells shared by creates and updates.
+func (s *importState) readRow(step *importStep, row []string, required ...string) map[string]string {
+	cells := make(map[string]string, len(importColumns))
+	for i, column := range importColumns {
+		if i < len(row) {
+			cells[column] = strings.TrimSpace(row[i])
+		}
+	}
+	step.EmployeeID = cells[columnEmployeeID]
+
+	for _, column := range required {
+		if cells[column] == "" {
+			s.fail(step, column, i18n.CodeFieldRequired)
+		}
+	}
+	for _, column := range []string{columnEmployeeID, columnPassword, columnEmail} {
+		if cells[column] != "" && !hashing.IsHalfWidth(cells[column]) {
+			s.fail(step, column, i18n.CodeHalfWidthOnly)
+		}
+	}
+
+	if role := strings.ToUpper(cells[columnRole]); role != "" {
+		if role != auth.RoleEmployee && role != auth.RoleAdmin {
+			s.fail(step, columnRole, i18n.CodeRoleInvalid)
+		}
+		cells[columnRole] = role
+	}
+	if name := cells[columnDepartment]; name != "" {
+		if _, ok := s.departments[name]; !ok {
+			s.fail(step, columnDepartment, i18n.CodeDepartmentInvalid)
+		}
+	}
+	if name := cells[columnPosition]; name != "" {
+		if _, ok := s.positions[name]; !ok {
+			s.fail(step, columnPosition, i18n.CodePositionInvalid)
+		}
+	}
+	if email := cells[columnEmail]; email != "" && !importEmailRegex.MatchString(email) {
+		s.fail(step, columnEmail, i18n.CodeEmailInvalid)
+	}
+	if phone := cells[columnPhone]; phone != "" && !importPhoneRegex.MatchString(phone) {
+		s.fail(step, columnPhone, i18n.CodePhoneInvalid)
+	}
+
+	if id := cells[columnEmployeeID]; id != "" {
+		if prev, ok := s.fileIDs[id]; ok {
+			s.fail(step, columnEmployeeID, i18n.CodeImportDuplicate, prev)
+		} else {
+			s.fileIDs[id] = step.Row
+		}
+	}
+	if email := cells[columnEmail]; email != "" {
+		if prev, ok := s.fileEmails[email]; ok {
+			s.fail(step, columnEmail, i18n.CodeImportDuplicate, prev)
+		} else {
+			s.fileEmails[email] = step.Row
+		}
+		if owner, ok := s.emails[email]; ok && owner != cells[columnEmployeeID] {
+			s.fail(step, columnEmail, i18n.CodeEmailTaken)
+		}
+	}
+
+	return cells
+}
+
+func (s *importState) values(cells map[string]string) *importValues {
+	return &importValues{
+		LastName:     cells[columnLastName],
+		FirstName:    cells[columnFirstName],
+		NickName:     cells[columnNickName],
+		Role:         cells[columnRole],
+		DepartmentID: s.departments[cells[columnDepartment]],
+		PositionID:   s.positions[cells[columnPosition]],
+		Phone:        cells[columnPhone],
+		Email:        cells[columnEmail],
+	}
+}
+
+func (s *importState) planCreate(step *importStep, row []string) {
+	cells := s.readRow(step, row, columnEmployeeID, columnLastName, columnFirstName, columnRole, columnPassword, columnDepartment, columnPosition)
+	if _, ok := s.users[step.EmployeeID]; ok {
+		s.fail(step, columnEmployeeID, i18n.CodeEmployeeTaken)
+	}
+
+	step.Action = ImportCreate
+	step.Values = s.values(cells)
+	step.Changes = make(map[string]ImportChange)
+	for _, column := range importColumns {
+		if column == columnEmployeeID || column == columnPassword || cells[column] == "" {
+			continue
+		}
+		value := cells[column]
+		step.Changes[column] = ImportChange{New: &value}
+	}
+}
+
+// planUpdate leaves the password alone, like the update upload always did.
+func (s *importState) planUpdate(step *importStep, row []string) {
+	cells := s.readRow(step, row, columnEmployeeID, columnLastName, columnFirstName, columnRole, columnDepartment, columnPosition)
+	step.Action = ImportUpdate
+	current, ok := s.users[step.EmployeeID]
+	if !ok {
+		if step.EmployeeID != "" {
+			s.fail(step, columnEmployeeID, i18n.CodeEmployeeNotFound)
+		}
+		return
+	}
+
+	step.UserID, step.Version = current.ID, current.Version
+	step.Values = s.values(cells)
+
+	step.Changes = make(map[string]ImportChange)
+	compare := func(column, old, new string) {
+		if old != new {
+			step.Changes[column] = ImportChange{Old: &old, New: &new}
+		}
+	}
+	compare(columnLastName, current.LastName, cells[columnLastName])
+	compare(columnFirstName, current.FirstName, cells[columnFirstName])
+	compare(columnNickName, current.NickName, cells[columnNickName])
+	compare(columnRole, current.Role, cells[columnRole])
+	compare(columnDepartment, s.name(columnDepartment, current.DepartmentID), cells[columnDepartment])
+	compare(columnPosition, s.name(columnPosition, current.PositionID), cells[columnPosition])
+	compare(columnPhone, current.Phone, cells[columnPhone])
+	compare(columnEmail, current.Email, cells[columnEmail])
+
+	if len(step.Changes) == 0 && len(step.Errors) == 0 {
+		step.Action, step.Values, step.Changes = ImportSkip, nil, nil
+	}
+}
+
+func (s *importState) planDelete(step *importStep, row []string) {
+	if len(row) > 0 {
+		step.EmployeeID = strings.TrimSpace(row[0])
+	}
+	if step.EmployeeID == "" {
+		s.fail(step, columnEmployeeID, i18n.CodeFieldRequired)
+		return
+	}
+	if prev, ok := s.fileIDs[step.EmployeeID]; ok {
+		s.fail(step, columnEmployeeID, i18n.CodeImportDuplicate, prev)
+		return
+	}
+	s.fileIDs[step.EmployeeID] = step.Row
+
+	current, ok := s.users[step.EmployeeID]
+	if !ok {
+		s.fail(step, columnEmployeeID, i18n.CodeEmployeeNotFound)
+		return
+	}
+
+	step.Action = ImportDelete
+	step.UserID, step.Version = current.ID, current.Version
+}
+
+func (s *importState) name(column string, id *int) string {
+	if id == nil {
+		return ""
+	}
+
+	return s.names[column][*id]
+}
+
+func blankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newImportID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,178 @@
+package user
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/auth"
+	"attendance/backend/internal/repository/postgres"
+	"attendance/backend/internal/service"
+	"attendance/backend/internal/storage"
+	"attendance/backend/internal/upload"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// photoDir is the directory profile photos are stored in, by user.
+const photoDir = "photos"
+
+// photoLinkTTL is how long links to photos stay valid. Reception screens keep
+// the dashboard open all day, so links outlive the default; they are not
+// bound to a user for the same reason.
+const photoLinkTTL = 12 * time.Hour
+
+// photoURL returns the link to the photo stored under key, or nil for none.
+func (r Repository) photoURL(key *string) *string {
+	if key == nil || *key == "" || r.Signer == nil {
+		return nil
+	}
+
+	link, err := r.Signer.URL(*key, 0, photoLinkTTL)
+	if err != nil {
+		slog.Error("signing photo link", "key", *key, "error", err)
+		return nil
+	}
+
+	return &link
+}
+
+// photoUser returns the user whose photo the signed in user may change: id,
+// or themselves for zero. Only admins change the photos of others.
+func (r Repository) photoUser(ctx context.Context, id int) (auth.Claims, int, error) {
+	claims, err := r.CheckClaims(ctx)
+	if err != nil {
+		return auth.Claims{}, 0, err
+	}
+	if id == 0 {
+		id = claims.UserId
+	}
+	if id != claims.UserId && claims.Role != auth.RoleAdmin {
+		return auth.Claims{}, 0, web.NewCodeError(i18n.CodeForbidden, http.StatusForbidden)
+	}
+
+	return claims, id, nil
+}
+
+// photoKeys returns the keys of the photo and thumbnail of a user.
+func (r Repository) photoKeys(ctx context.Context, id int) (photo, thumbnail *string, err error) {
+	err = r.QueryRowContext(ctx, `
+		SELECT photo, photo_thumb
+		FROM users
+		WHERE id = ? AND deleted_at IS NULL
+	`, id).Scan(&photo, &thumbnail)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, web.NewRequestError(postgres.ErrNotFound, http.StatusNotFound)
+	}
+	if err != nil {
+		return nil, nil, web.NewRequestError(errors.Wrap(err, "selecting user photo"), http.StatusBadRequest)
+	}
+
+	return photo, thumbnail, nil
+}
+
+// SetPhoto crops the uploaded photo, stores it with its thumbnail and
+// replaces the photo the user had.
+func (r Repository) SetPhoto(ctx context.Context, request PhotoRequest) (PhotoResponse, error) {
+	claims, id, err := r.photoUser(ctx, request.ID)
+	if err != nil {
+		return PhotoResponse{}, err
+	}
+
+	oldPhoto, oldThumbnail, err := r.photoKeys(ctx, id)
+	if err != nil {
+		return PhotoResponse{}, err
+	}
+
+	file, err := upload.Read(upload.Photo, request.Photo)
+	if err != nil {
+		return PhotoResponse{}, upload.RequestError(err)
+	}
+
+	var crop *service.Crop
+	if request.CropX != nil || request.CropY != nil || request.CropSize != nil {
+		if request.CropX == nil || request.CropY == nil || request.CropSize == nil {
+			return PhotoResponse{}, web.NewCodeError(i18n.CodePhotoCrop, http.StatusBadRequest)
+		}
+		crop = &service.Crop{X: *request.CropX, Y: *request.CropY, Size: *request.CropSize}
+	}
+
+	photo, thumbnail, err := service.MakePhoto(file, crop)
+	if errors.Is(err, service.ErrCrop) {
+		return PhotoResponse{}, web.NewCodeError(i18n.CodePhotoCrop, http.StatusBadRequest)
+	}
+	if err != nil {
+		return PhotoResponse{}, upload.RequestError(&upload.ContentError{Type: file.Type.Name, Err: err})
+	}
+
+	name := strings.TrimSuffix(file.Name, path.Ext(file.Name))
+	photoKey := fmt.Sprintf("%s/%d/%s.jpg", photoDir, id, name)
+	thumbnailKey := fmt.Sprintf("%s/%d/%s_thumb.jpg", photoDir, id, name)
+
+	if err = storage.PutBytes(ctx, r.Files, photoKey, photo, "image/jpeg"); err != nil {
+		return PhotoResponse{}, web.NewRequestError(errors.Wrap(err, "storing photo"), http.StatusInternalServerError)
+	}
+	if err = storage.PutBytes(ctx, r.Files, thumbnailKey, thumbnail, "image/jpeg"); err != nil {
+		r.removePhoto(ctx, &photoKey, nil)
+		return PhotoResponse{}, web.NewRequestError(errors.Wrap(err, "storing photo thumbnail"), http.StatusInternalServerError)
+	}
+
+	_, err = r.ExecContext(ctx, `
+		UPDATE users
+		SET photo = ?, photo_thumb = ?, updated_at = ?, updated_by = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`, photoKey, thumbnailKey, time.Now(), claims.UserId, id)
+	if err != nil {
+		r.removePhoto(ctx, &photoKey, &thumbnailKey)
+		return PhotoResponse{}, web.NewRequestError(errors.Wrap(err, "updating user photo"), http.StatusBadRequest)
+	}
+
+	r.removePhoto(ctx, oldPhoto, oldThumbnail)
+
+	return PhotoResponse{PhotoURL: r.photoURL(&photoKey), ThumbnailURL: r.photoURL(&thumbnailKey)}, nil
+}
+
+// DeletePhoto removes the photo of user id, zero for the one signed in.
+func (r Repository) DeletePhoto(ctx context.Context, id int) error {
+	claims, id, err := r.photoUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	photo, thumbnail, err := r.photoKeys(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.ExecContext(ctx, `
+		UPDATE users
+		SET photo = NULL, photo_thumb = NULL, updated_at = ?, updated_by = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`, time.Now(), claims.UserId, id)
+	if err != nil {
+		return web.NewRequestError(errors.Wrap(err, "deleting user photo"), http.StatusBadRequest)
+	}
+
+	r.removePhoto(ctx, photo, thumbnail)
+
+	return nil
+}
+
+// removePhoto deletes stored photo files. Files left behind only take space,
+// so failures are logged rather than failing the request.
+func (r Repository) removePhoto(ctx context.Context, keys ...*string) {
+	for _, key := range keys {
+		if key == nil || *key == "" {
+			continue
+		}
+		if err := r.Files.Delete(ctx, *key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			slog.ErrorContext(ctx, "deleting photo", "key", *key, "error", err)
+		}
+	}
+}
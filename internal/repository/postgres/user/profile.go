@@ -0,0 +1,396 @@
+package user
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/auth"
+	"attendance/backend/internal/repository/postgres"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+// ProfileFields are the fields admins may let users change themselves.
+var ProfileFields = []string{"first_name", "last_name", "nick_name", "phone", "email"}
+
+// defaultProfileFields are editable while no company info is set.
+var defaultProfileFields = []string{"nick_name", "phone"}
+
+// Email changes are confirmed with a code of six digits, valid for
+// emailCodeTTL and emailCodeAttempts tries. A new code is sent at most once
+// every emailCodeInterval.
+const (
+	emailCodeTTL      = 15 * time.Minute
+	emailCodeAttempts = 5
+	emailCodeInterval = time.Minute
+)
+
+var (
+	profileEmailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+	profilePhoneRegex = regexp.MustCompile(`^\+?\d*$`)
+)
+
+// GetProfileFields returns the fields users may change themselves.
+func (r Repository) GetProfileFields(ctx context.Context) ([]string, error) {
+	if _, err := r.CheckClaims(ctx); err != nil {
+		return nil, err
+	}
+
+	return r.profileFields(ctx)
+}
+
+func (r Repository) profileFields(ctx context.Context) ([]string, error) {
+	var fields []string
+	err := r.QueryRowContext(ctx, `
+		SELECT profile_fields
+		FROM company_info
+		WHERE deleted_at IS NULL
+		ORDER BY created_at DESC
+		LIMIT 1
+	`).Scan(pgdialect.Array(&fields))
+	if errors.Is(err, sql.ErrNoRows) {
+		return defaultProfileFields, nil
+	}
+	if err != nil {
+		return nil, web.NewRequestError(errors.Wrap(err, "selecting profile fields"), http.StatusInternalServerError)
+	}
+
+	return fields, nil
+}
+
+// UpdateProfileFields sets the fields users may change themselves.
+func (r Repository) UpdateProfileFields(ctx context.Context, request ProfileFieldsRequest) error {
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	fields := make([]string, 0, len(request.Fields))
+	for _, field := range request.Fields {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !slices.Contains(ProfileFields, field) {
+			return web.NewCodeError(i18n.CodeProfileFieldUnknown, http.StatusBadRequest, field)
+		}
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+
+	result, err := r.ExecContext(ctx, `
+		UPDATE company_info
+		SET profile_fields = ?, updated_at = ?, updated_by = ?
+		WHERE id = (SELECT id FROM company_info WHERE deleted_at IS NULL ORDER BY created_at DESC LIMIT 1)
+	`, pgdialect.Array(fields), time.Now(), claims.UserId)
+	if err != nil {
+		return web.NewRequestError(errors.Wrap(err, "updating profile fields"), http.StatusBadRequest)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return web.NewCodeError(i18n.CodeCompanyInfoNotFound, http.StatusNotFound)
+	}
+
+	return nil
+}
+
+// GetProfile returns the profile of the signed in user.
+func (r Repository) GetProfile(ctx context.Context) (Profile, error) {
+	claims, err := r.CheckClaims(ctx)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	var (
+		profile          Profile
+		photo, thumbnail *string
+	)
+	err = r.QueryRowContext(ctx, `
+		SELECT
+			u.id,
+			u.employee_id,
+			u.first_name,
+			u.last_name,
+			u.nick_name,
+			u.phone,
+			u.email,
+			e.email,
+			u.department_id,
+			d.name,
+			u.position_id,
+			p.name,
+			u.photo,
+			u.photo_thumb
+		FROM users u
+		LEFT JOIN department d ON d.id = u.department_id AND d.deleted_at IS NULL
+		LEFT JOIN position p ON p.id = u.position_id AND p.deleted_at IS NULL
+		LEFT JOIN email_change e ON e.user_id = u.id AND e.expires_at > now() AND e.attempts < ?
+		WHERE u.id = ? AND u.deleted_at IS NULL
+	`, emailCodeAttempts, claims.UserId).Scan(
+		&profile.ID,
+		&profile.EmployeeID,
+		&profile.FirstName,
+		&profile.LastName,
+		&profile.NickName,
+		&profile.Phone,
+		&profile.Email,
+		&profile.PendingEmail,
+		&profile.DepartmentID,
+		&profile.Department,
+		&profile.PositionID,
+		&profile.Position,
+		&photo,
+		&thumbnail,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Profile{}, web.NewCodeError(i18n.CodeEmployeeNotFound, http.StatusNotFound)
+	}
+	if err != nil {
+		return Profile{}, web.NewRequestError(errors.Wrap(err, "selecting profile"), http.StatusBadRequest)
+	}
+	profile.PhotoURL = r.photoURL(photo)
+	profile.ThumbnailURL = r.photoURL(thumbnail)
+
+	if profile.EditableFields, err = r.profileFields(ctx); err != nil {
+		return Profile{}, err
+	}
+
+	return profile, nil
+}
+
+// UpdateProfile changes the profile of the signed in user. Only the fields
+// admins made editable may be given.
+func (r Repository) UpdateProfile(ctx context.Context, request ProfileRequest) error {
+	claims, err := r.CheckClaims(ctx)
+	if err != nil {
+		return err
+	}
+
+	editable, err := r.profileFields(ctx)
+	if err != nil {
+		return err
+	}
+
+	q := r.NewUpdate().Table("users").Where("deleted_at IS NULL AND id = ?", claims.UserId)
+	for _, field := range []struct {
+		name     string
+		value    *string
+		required bool
+	}{
+		{"first_name", request.FirstName, true},
+		{"last_name", request.LastName, true},
+		{"nick_name", request.NickName, false},
+		{"phone", request.Phone, false},
+	} {
+		if field.value == nil {
+			continue
+		}
+		if !slices.Contains(editable, field.name) {
+			return web.NewCodeError(i18n.CodeProfileFieldLocked, http.StatusForbidden, field.name)
+		}
+
+		value := strings.TrimSpace(*field.value)
+		if field.required && value == "" {
+			return web.NewCodeError(i18n.CodeRequiredBlank, http.StatusBadRequest)
+		}
+		if field.name == "phone" && !profilePhoneRegex.MatchString(value) {
+			return web.NewCodeError(i18n.CodePhoneInvalid, http.StatusBadRequest)
+		}
+		q.Set(field.name+" = ?", value)
+	}
+
+	q.Set("updated_at = ?", time.Now())
+	q.Set("updated_by = ?", claims.UserId)
+
+	if _, err = q.Exec(ctx); err != nil {
+		return web.NewRequestError(errors.Wrap(err, "updating profile"), http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+// RequestEmailChange starts changing the email of the signed in user to
+// request.Email. The change is made once the returned code, which the
+// caller sends to the new address, is confirmed.
+func (r Repository) RequestEmailChange(ctx context.Context, request EmailChangeRequest) (EmailChange, error) {
+	claims, err := r.CheckClaims(ctx)
+	if err != nil {
+		return EmailChange{}, err
+	}
+
+	editable, err := r.profileFields(ctx)
+	if err != nil {
+		return EmailChange{}, err
+	}
+	if !slices.Contains(editable, "email") {
+		return EmailChange{}, web.NewCodeError(i18n.CodeProfileFieldLocked, http.StatusForbidden, "email")
+	}
+
+	email := strings.TrimSpace(request.Email)
+	if email == "" {
+		return EmailChange{}, web.NewCodeError(i18n.CodeEmailRequired, http.StatusBadRequest)
+	}
+	if !profileEmailRegex.MatchString(email) {
+		return EmailChange{}, web.NewCodeError(i18n.CodeEmailInvalid, http.StatusBadRequest)
+	}
+
+	var current sql.NullString
+	err = r.QueryRowContext(ctx, "SELECT email FROM users WHERE id = ? AND deleted_at IS NULL", claims.UserId).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return EmailChange{}, web.NewCodeError(i18n.CodeEmployeeNotFound, http.StatusNotFound)
+	}
+	if err != nil {
+		return EmailChange{}, web.NewRequestError(errors.Wrap(err, "selecting email"), http.StatusBadRequest)
+	}
+	if current.Valid && strings.EqualFold(current.String, email) {
+		return EmailChange{}, web.NewCodeError(i18n.CodeEmailUnchanged, http.StatusBadRequest)
+	}
+	if err = r.checkEmailFree(ctx, r.DB, email, claims.UserId); err != nil {
+		return EmailChange{}, err
+	}
+
+	code, err := emailCode()
+	if err != nil {
+		return EmailChange{}, web.NewRequestError(errors.Wrap(err, "generating email code"), http.StatusInternalServerError)
+	}
+	change := EmailChange{Email: email, Code: code, ExpiresAt: time.Now().Add(emailCodeTTL)}
+
+	// A pending change is only replaced once it is older than the interval,
+	// so the code cannot be used to flood a mailbox.
+	var userID int
+	err = r.QueryRowContext(ctx, `
+		INSERT INTO email_change (user_id, email, code_hash, attempts, expires_at, created_at)
+		VALUES (?, ?, ?, 0, ?, now())
+		ON CONFLICT (user_id) DO UPDATE SET
+			email = excluded.email,
+			code_hash = excluded.code_hash,
+			attempts = 0,
+			expires_at = excluded.expires_at,
+			created_at = now()
+		WHERE email_change.created_at < ?
+		RETURNING user_id
+	`, claims.UserId, email, emailCodeHash(claims.UserId, email, code), change.ExpiresAt, time.Now().Add(-emailCodeInterval)).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return EmailChange{}, web.NewCodeError(i18n.CodeEmailCodeTooSoon, http.StatusTooManyRequests)
+	}
+	if err != nil {
+		return EmailChange{}, web.NewRequestError(errors.Wrap(err, "storing email change"), http.StatusBadRequest)
+	}
+
+	return change, nil
+}
+
+// ConfirmEmailChange confirms the pending email change of the signed in
+// user with code and returns the new email.
+func (r Repository) ConfirmEmailChange(ctx context.Context, request EmailConfirmRequest) (string, error) {
+	claims, err := r.CheckClaims(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// The attempt is counted before the code is compared, so guesses are
+	// limited even when made concurrently.
+	var email, hash string
+	err = r.QueryRowContext(ctx, `
+		UPDATE email_change
+		SET attempts = attempts + 1
+		WHERE user_id = ? AND expires_at > now() AND attempts < ?
+		RETURNING email, code_hash
+	`, claims.UserId, emailCodeAttempts).Scan(&email, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", web.NewCodeError(i18n.CodeEmailCodeExpired, http.StatusBadRequest)
+	}
+	if err != nil {
+		return "", web.NewRequestError(errors.Wrap(err, "selecting email change"), http.StatusBadRequest)
+	}
+
+	code := strings.TrimSpace(request.Code)
+	if subtle.ConstantTimeCompare([]byte(emailCodeHash(claims.UserId, email, code)), []byte(hash)) != 1 {
+		return "", web.NewCodeError(i18n.CodeEmailCodeInvalid, http.StatusBadRequest)
+	}
+
+	err = r.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := r.checkEmailFree(ctx, tx, email, claims.UserId); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE users
+			SET email = ?, updated_at = ?, updated_by = ?
+			WHERE id = ? AND deleted_at IS NULL
+		`, email, time.Now(), claims.UserId, claims.UserId); err != nil {
+			return web.NewRequestError(errors.Wrap(err, "updating email"), http.StatusBadRequest)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM email_change WHERE user_id = ?", claims.UserId); err != nil {
+			return web.NewRequestError(errors.Wrap(err, "deleting email change"), http.StatusBadRequest)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return email, nil
+}
+
+// checkEmailFree fails when another user has email.
+func (r Repository) checkEmailFree(ctx context.Context, db bun.IDB, email string, userID int) error {
+	var taken bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE lower(email) = lower(?) AND deleted_at IS NULL AND id != ?)", email, userID).Scan(&taken)
+	if err != nil {
+		return web.NewRequestError(errors.Wrap(err, "email check"), http.StatusInternalServerError)
+	}
+	if taken {
+		return web.NewCodeError(i18n.CodeEmailTaken, http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+// GetMyQrCode regenerates the QR code of the signed in user and returns the
+// key it is stored under.
+func (r *Repository) GetMyQrCode(ctx context.Context) (string, error) {
+	claims, err := r.CheckClaims(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var employeeID sql.NullString
+	err = r.QueryRowContext(ctx, "SELECT employee_id FROM users WHERE id = ? AND deleted_at IS NULL", claims.UserId).Scan(&employeeID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && employeeID.String == "") {
+		return "", web.NewRequestError(postgres.ErrNotFound, http.StatusNotFound)
+	}
+	if err != nil {
+		return "", web.NewRequestError(errors.Wrap(err, "selecting employee id"), http.StatusBadRequest)
+	}
+
+	return r.storeQrCode(ctx, employeeID.String)
+}
+
+// emailCode returns a random code of six digits.
+func emailCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// emailCodeHash binds a code to the user and the address it was sent to.
+func emailCodeHash(userID int, email, code string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d\n%s\n%s", userID, strings.ToLower(email), code)))
+
+	return hex.EncodeToString(sum[:])
+}
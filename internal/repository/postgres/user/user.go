@@ -1,6 +1,8 @@
 package user
 
 import (
+	"attendance/backend/foundation/i18n"
+	"bytes"
 	"context"
 	"database/sql"
 	"fmt"
@@ -8,26 +10,24 @@ import (
 	"image/color"
 	"image/draw"
 	"image/png"
-	"log"
-	"os"
-	"path/filepath"
+	"log/slog"
 	"sort"
 
 	"github.com/jung-kurt/gofpdf/v2"
 
-	"io"
-	"io/ioutil"
 	"net/http"
 
 	"attendance/backend/foundation/web"
 	"attendance/backend/internal/auth"
 	"attendance/backend/internal/entity"
+	"attendance/backend/internal/pkg/period"
 	"attendance/backend/internal/pkg/repository/postgresql"
 	"attendance/backend/internal/repository/postgres"
 	"attendance/backend/internal/repository/postgres/department"
 	"attendance/backend/internal/repository/postgres/position"
 	"attendance/backend/internal/service"
 	"attendance/backend/internal/service/hashing"
+	"attendance/backend/internal/storage"
 	"strings"
 	"time"
 
@@ -43,10 +43,20 @@ type Repository struct {
 	*postgresql.Database
 	PositionRepo   *position.Repository
 	DepartmentRepo *department.Repository
+	Files          storage.Storage
+	Signer         *storage.Signer
 }
 
-func NewRepository(database *postgresql.Database) *Repository {
-	return &Repository{Database: database}
+// Keys of the files the repository generates.
+const (
+	qrCodeDir       = "qr_codes"
+	qrCodeListKey   = "exports/qr_employees.pdf"
+	employeeListKey = "exports/employee_list.xlsx"
+	templateKey     = "exports/template.xlsx"
+)
+
+func NewRepository(database *postgresql.Database, files storage.Storage, signer *storage.Signer) *Repository {
+	return &Repository{Database: database, Files: files, Signer: signer}
 }
 
 func (r Repository) GetByEmployeeID(ctx context.Context, employee_id string) (*entity.User, error) {
@@ -54,10 +64,7 @@ func (r Repository) GetByEmployeeID(ctx context.Context, employee_id string) (*e
 	err := r.NewSelect().Model(&detail).Where("employee_id = ? AND deleted_at IS NULL", employee_id).Scan(ctx)
 
 	if err != nil {
-		return &entity.User{}, &web.Error{
-			Err:    errors.New("employee not found!"),
-			Status: http.StatusUnauthorized,
-		}
+		return &entity.User{}, web.NewCodeError(i18n.CodeEmployeeNotFound, http.StatusUnauthorized)
 	}
 	return &detail, err
 }
@@ -66,10 +73,7 @@ func (r Repository) GetByEmployeeEmail(ctx context.Context, email string) (*enti
 	err := r.NewSelect().Model(&detail).Where("email = ? AND deleted_at IS NULL", email).Scan(ctx)
 
 	if err != nil {
-		return &entity.User{}, &web.Error{
-			Err:    errors.New("employee not found!"),
-			Status: http.StatusUnauthorized,
-		}
+		return &entity.User{}, web.NewCodeError(i18n.CodeEmployeeNotFound, http.StatusUnauthorized)
 	}
 	return &detail, err
 }
@@ -158,7 +162,9 @@ func (r Repository) GetList(ctx context.Context, filter Filter) ([]GetListRespon
 			u.position_id,
 			p.name as position_name,
 			u.phone,
-			u.email
+			u.email,
+			u.photo,
+			u.photo_thumb
 		FROM users u
 		 JOIN department d ON d.id=u.department_id and d.deleted_at is null
 		 JOIN position p ON p.id=u.position_id and p.deleted_at is null
@@ -179,6 +185,7 @@ func (r Repository) GetList(ctx context.Context, filter Filter) ([]GetListRespon
 	for rows.Next() {
 		var detail GetListResponse
 		var nickName sql.NullString
+		var photo, thumbnail *string
 		if err = rows.Scan(
 			&detail.ID,
 			&detail.EmployeeID,
@@ -189,9 +196,13 @@ func (r Repository) GetList(ctx context.Context, filter Filter) ([]GetListRespon
 			&detail.PositionID,
 			&detail.Position,
 			&detail.Phone,
-			&detail.Email); err != nil {
+			&detail.Email,
+			&photo,
+			&thumbnail); err != nil {
 			return nil, 0, web.NewRequestError(errors.Wrap(err, "scanning user list"), http.StatusBadRequest)
 		}
+		detail.PhotoURL = r.photoURL(photo)
+		detail.ThumbnailURL = r.photoURL(thumbnail)
 		if nickName.Valid {
 			detail.NickName = nickName.String
 		} else {
@@ -245,7 +256,9 @@ func (r Repository) GetDetailById(ctx context.Context, id int) (GetDetailByIdRes
 			u.position_id,
 			p.name,
 			u.phone,
-			u.email
+			u.email,
+			u.photo,
+			u.photo_thumb
 		FROM
 		    users u 
 		RIGHT JOIN department d ON u.department_id = d.id and d.deleted_at is null
@@ -255,6 +268,7 @@ func (r Repository) GetDetailById(ctx context.Context, id int) (GetDetailByIdRes
 
 	var detail GetDetailByIdResponse
 	var nickName sql.NullString
+	var photo, thumbnail *string
 
 	err = r.QueryRowContext(ctx, query).Scan(
 		&detail.ID,
@@ -267,6 +281,8 @@ func (r Repository) GetDetailById(ctx context.Context, id int) (GetDetailByIdRes
 		&detail.Position,
 		&detail.Phone,
 		&detail.Email,
+		&photo,
+		&thumbnail,
 	)
 	if nickName.Valid {
 		detail.NickName = nickName.String
@@ -279,6 +295,8 @@ func (r Repository) GetDetailById(ctx context.Context, id int) (GetDetailByIdRes
 	if err != nil {
 		return GetDetailByIdResponse{}, web.NewRequestError(errors.Wrap(err, "selecting user detail"), http.StatusBadRequest)
 	}
+	detail.PhotoURL = r.photoURL(photo)
+	detail.ThumbnailURL = r.photoURL(thumbnail)
 
 	return detail, nil
 }
@@ -297,7 +315,7 @@ func (r Repository) Create(ctx context.Context, request CreateRequest) (CreateRe
 
 	// Check if any of the fields are empty
 	if *request.EmployeeID == "" || *request.FirstName == "" || *request.LastName == "" || *request.Password == "" || *request.Email == "" {
-		return CreateResponse{}, web.NewRequestError(errors.New("必須項目は空欄にできません、またはスペースのみを含むことはできません。"), http.StatusBadRequest)
+		return CreateResponse{}, web.NewCodeError(i18n.CodeRequiredBlank, http.StatusBadRequest)
 	}
 
 	// Validate struct for required fields
@@ -315,21 +333,21 @@ func (r Repository) Create(ctx context.Context, request CreateRequest) (CreateRe
 	}
 
 	if EmployeeID {
-		return CreateResponse{}, web.NewRequestError(errors.New("社員番号はすでに使用されています。"), http.StatusBadRequest)
+		return CreateResponse{}, web.NewCodeError(i18n.CodeEmployeeTaken, http.StatusBadRequest)
 	}
 
 	// Check if department exists
 	var deptExists bool
 	err = r.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM department WHERE id = ? AND deleted_at IS NULL)", request.DepartmentID).Scan(&deptExists)
 	if err != nil || !deptExists {
-		return CreateResponse{}, web.NewRequestError(errors.New("invalid department ID"), http.StatusBadRequest)
+		return CreateResponse{}, web.NewCodeError(i18n.CodeDepartmentInvalid, http.StatusBadRequest)
 	}
 
 	// Check if position exists
 	var posExists bool
 	err = r.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM position WHERE id = ? AND deleted_at IS NULL)", request.PositionID).Scan(&posExists)
 	if err != nil || !posExists {
-		return CreateResponse{}, web.NewRequestError(errors.New("invalid position ID"), http.StatusBadRequest)
+		return CreateResponse{}, web.NewCodeError(i18n.CodePositionInvalid, http.StatusBadRequest)
 	}
 
 	// Check if the email already exists
@@ -342,7 +360,7 @@ func (r Repository) Create(ctx context.Context, request CreateRequest) (CreateRe
 	}
 
 	if Email {
-		return CreateResponse{}, web.NewRequestError(errors.New("メールアドレス はすでに使用されています。"), http.StatusBadRequest)
+		return CreateResponse{}, web.NewCodeError(i18n.CodeEmailTaken, http.StatusBadRequest)
 	}
 
 	// Hash the password
@@ -356,7 +374,7 @@ func (r Repository) Create(ctx context.Context, request CreateRequest) (CreateRe
 	var response CreateResponse
 	role := strings.ToUpper(*request.Role)
 	if (role != "EMPLOYEE") && (role != "ADMIN") {
-		return CreateResponse{}, web.NewRequestError(errors.New("incorrect role. role should be EMPLOYEE or ADMIN"), http.StatusBadRequest)
+		return CreateResponse{}, web.NewCodeError(i18n.CodeRoleInvalid, http.StatusBadRequest)
 	}
 	response.Role = role
 	response.FirstName = request.FirstName
@@ -395,7 +413,7 @@ func (r Repository) UpdateColumns(ctx context.Context, request UpdateRequest) er
 
 	// Check if any of the fields are empty
 	if *request.EmployeeID == "" || *request.FirstName == "" || *request.LastName == "" || *request.Email == "" {
-		return web.NewRequestError(errors.New("必須項目は空欄にできません、またはスペースのみを含むことはできません。"), http.StatusBadRequest)
+		return web.NewCodeError(i18n.CodeRequiredBlank, http.StatusBadRequest)
 	}
 
 	q := r.NewUpdate().Table("users").Where("deleted_at IS NULL AND id = ? ", request.ID)
@@ -406,7 +424,7 @@ func (r Repository) UpdateColumns(ctx context.Context, request UpdateRequest) er
 			return web.NewRequestError(errors.Wrap(err, "employee_id check"), http.StatusInternalServerError)
 		}
 		if userIdStatus {
-			return web.NewRequestError(errors.Wrap(errors.New(""), "社員番号はすでに使用されています。"), http.StatusBadRequest)
+			return web.NewCodeError(i18n.CodeEmployeeTaken, http.StatusBadRequest)
 		}
 		q.Set("employee_id = ?", request.EmployeeID)
 	}
@@ -417,25 +435,25 @@ func (r Repository) UpdateColumns(ctx context.Context, request UpdateRequest) er
 			return web.NewRequestError(errors.Wrap(err, "email check"), http.StatusInternalServerError)
 		}
 		if emailStatus {
-			return web.NewRequestError(errors.Wrap(errors.New(""), "メールアドレス はすでに使用されています。"), http.StatusBadRequest)
+			return web.NewCodeError(i18n.CodeEmailTaken, http.StatusBadRequest)
 		}
 		q.Set("email = ?", request.Email)
 	}
 	var deptExists bool
 	err = r.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM department WHERE id = ? AND deleted_at IS NULL)", request.DepartmentID).Scan(&deptExists)
 	if err != nil || !deptExists {
-		return web.NewRequestError(errors.New("invalid department ID"), http.StatusBadRequest)
+		return web.NewCodeError(i18n.CodeDepartmentInvalid, http.StatusBadRequest)
 	}
 
 	var posExists bool
 	err = r.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM position WHERE id = ? AND deleted_at IS NULL)", request.PositionID).Scan(&posExists)
 	if err != nil || !posExists {
-		return web.NewRequestError(errors.New("invalid position ID"), http.StatusBadRequest)
+		return web.NewCodeError(i18n.CodePositionInvalid, http.StatusBadRequest)
 	}
 	if request.Role != nil {
 		role := strings.ToUpper(*request.Role)
 		if (role != "EMPLOYEE") && (role != "ADMIN") {
-			return web.NewRequestError(errors.New("incorrect role. role should be EMPLOYEE or ADMIN"), http.StatusBadRequest)
+			return web.NewCodeError(i18n.CodeRoleInvalid, http.StatusBadRequest)
 		}
 		q.Set("role = ?", role)
 	}
@@ -482,404 +500,13 @@ func (r Repository) Delete(ctx context.Context, id int) error {
 	return r.DeleteRow(ctx, "users", id)
 }
 
-func (r Repository) CreateByExcell(ctx context.Context, request ExcellRequest) (int, []int, error) {
-	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
-	if err != nil {
-		return 0, nil, err
-	}
-	if err := r.ValidateStruct(&request); err != nil {
-		return 0, nil, err
-	}
-	departmentMap, err := r.LoadDepartmentMap(ctx)
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "loading department map"), http.StatusInternalServerError)
-	}
-
-	positionMap, err := r.LoadPositionMap(ctx)
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "loading position map"), http.StatusInternalServerError)
-	}
-
-	employeeIDMap := make(map[string]struct{})
-	emailMap := make(map[string]struct{})
-
-	rows, err := r.QueryContext(ctx,
-		"SELECT employee_id, email FROM users WHERE role='EMPLOYEE' AND deleted_at IS NULL")
-	if err != nil {
-		return 0, nil, web.NewRequestError(
-			errors.Wrap(err, "getting employee data"),
-			http.StatusInternalServerError,
-		)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var employeeID, email string
-
-		if err := rows.Scan(&employeeID, &email); err != nil {
-			return 0, nil, web.NewRequestError(
-				errors.Wrap(err, "scanning employee data"),
-				http.StatusInternalServerError,
-			)
-		}
-
-		// Employee ID ni mapga qo'shish
-		employeeIDMap[employeeID] = struct{}{}
-
-		// Email bo'sh bo'lmaganda mapga qo'shish
-		if email != "" {
-			emailMap[email] = struct{}{}
-		}
-	}
-
-	// Iteratsiya xatolari uchun tekshirish
-	if err := rows.Err(); err != nil {
-		return 0, nil, web.NewRequestError(
-			errors.Wrap(err, "database iteration error"),
-			http.StatusInternalServerError,
-		)
-	}
-	file, err := request.Excell.Open()
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "opening excel file"), http.StatusBadRequest)
-	}
-	defer file.Close()
-
-	tempFile, err := ioutil.TempFile("", "excel-*.xlsx")
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "creating temporary file"), http.StatusInternalServerError)
-	}
-	defer tempFile.Close()
-	defer os.Remove(tempFile.Name())
-
-	_, err = io.Copy(tempFile, file)
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "copying excel file"), http.StatusInternalServerError)
-	}
-	fields := map[int]string{
-		0: "EmployeeID",
-		1: "LastName",
-		2: "Firstname",
-		3: "NickName",
-		4: "Role",
-		5: "Password",
-		6: "DepartmentName",
-		7: "PositionName",
-		8: "Phone",
-		9: "Email",
-	}
-	excelData, incompleteRows, err := hashing.ExcelReaderByCreate(tempFile.Name(), fields, departmentMap, positionMap, employeeIDMap, emailMap)
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "reading excel data"), http.StatusBadRequest)
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute) // Adjust as needed
-	defer cancel()
-
-	var users []CreateResponse
-	for _, data := range excelData {
-		hash, err := bcrypt.GenerateFromPassword([]byte(data.Password), bcrypt.DefaultCost)
-		if err != nil {
-			return 0, nil, web.NewRequestError(errors.Wrap(err, "hashing password"), http.StatusInternalServerError)
-		}
-		hashedPassword := string(hash)
-
-		user := CreateResponse{
-			EmployeeID:   &data.EmployeeID,
-			Password:     &hashedPassword,
-			Role:         data.Role,
-			FirstName:    &data.FirstName,
-			LastName:     &data.LastName,
-			NickName:     data.NickName,
-			DepartmentID: &data.DepartmentID,
-			PositionID:   &data.PositionID,
-			Phone:        &data.Phone,
-			Email:        &data.Email,
-			CreatedAt:    time.Now(),
-			CreatedBy:    claims.UserId,
-		}
-
-		if err := r.ValidateStruct(&user); err != nil {
-			return 0, nil, err
-		}
-
-		users = append(users, user)
-	}
-
-	const batchSize = 100
-
-	var insertedCount int
-	for i := 0; i < len(users); i += batchSize {
-		end := i + batchSize
-		if end > len(users) {
-			end = len(users)
-		}
-
-		batch := users[i:end]
-		_, err := r.NewInsert().Model(&batch).Exec(ctx)
-		if err != nil {
-			log.Printf("Failed to insert batch %d-%d: %v", i, end, err)
-			continue // Skip to the next batch
-		}
-		insertedCount += len(batch)
-	}
-
-	// Count the created users
-	return insertedCount, incompleteRows, nil
-}
-func (r Repository) UpdateByExcell(ctx context.Context, request ExcellRequest) (int, []int, error) {
-	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
-	if err != nil {
-		return 0, nil, err
-	}
-
-	departmentMap, err := r.LoadDepartmentMap(ctx)
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "loading department map"), http.StatusInternalServerError)
-	}
-	positionMap, err := r.LoadPositionMap(ctx)
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "loading position map"), http.StatusInternalServerError)
-	}
-
-	employeeIDMap := make(map[string]struct{})
-	emailMap := make(map[string]struct{})
-
-	rows, err := r.QueryContext(ctx,
-		"SELECT employee_id, email FROM users WHERE role='EMPLOYEE' AND deleted_at IS NULL")
-	if err != nil {
-		return 0, nil, web.NewRequestError(
-			errors.Wrap(err, "getting employee data"),
-			http.StatusInternalServerError,
-		)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var employeeID, email string
-
-		if err := rows.Scan(&employeeID, &email); err != nil {
-			return 0, nil, web.NewRequestError(
-				errors.Wrap(err, "scanning employee data"),
-				http.StatusInternalServerError,
-			)
-		}
-
-		// Employee ID ni mapga qo'shish
-		employeeIDMap[employeeID] = struct{}{}
-
-		// Email bo'sh bo'lmaganda mapga qo'shish
-		if email != "" {
-			emailMap[email] = struct{}{}
-		}
-	}
-
-	// Iteratsiya xatolari uchun tekshirish
-	if err := rows.Err(); err != nil {
-		return 0, nil, web.NewRequestError(
-			errors.Wrap(err, "database iteration error"),
-			http.StatusInternalServerError,
-		)
-	}
-	if err := r.ValidateStruct(request.Excell); err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "validating excel request"), http.StatusBadRequest)
-	}
-
-	file, err := request.Excell.Open()
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "opening excel file"), http.StatusBadRequest)
-	}
-	defer file.Close()
-
-	tempFile, err := ioutil.TempFile("", "excel-*.xlsx")
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "creating temporary file"), http.StatusInternalServerError)
-	}
-	defer tempFile.Close()
-	defer os.Remove(tempFile.Name())
-
-	_, err = io.Copy(tempFile, file)
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "copying excel file"), http.StatusInternalServerError)
-	}
-	fields := map[int]string{
-		0: "EmployeeID",
-		1: "LastName",
-		2: "Firstname",
-		3: "NickName",
-		4: "Role",
-		5: "Password",
-		6: "DepartmentName",
-		7: "PositionName",
-		8: "Phone",
-		9: "Email",
-	}
-	excelData, incompleteRows, err := hashing.ExcelReaderByEdit(tempFile.Name(), fields, departmentMap, positionMap, employeeIDMap, emailMap)
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "reading excel data"), http.StatusBadRequest)
-	}
-	// Start a transaction
-	tx, err := r.DB.BeginTx(ctx, nil)
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "starting transaction"), http.StatusInternalServerError)
-	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
-		} else {
-			_ = tx.Commit()
-		}
-	}()
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute) // Adjust as needed
-	defer cancel()
-
-	createdCount := 0
-	for _, data := range excelData {
-		user := UpdateResponse{
-			EmployeeID:   &data.EmployeeID,
-			FirstName:    &data.FirstName,
-			LastName:     &data.LastName,
-			NickName:     &data.NickName,
-			Role:         &data.Role,
-			DepartmentID: &data.DepartmentID,
-			PositionID:   &data.PositionID,
-			Phone:        &data.Phone,
-			Email:        &data.Email,
-			UpdatedAt:    time.Now(),
-			UpdatedBy:    claims.UserId,
-		}
-
-		if err := r.ValidateStruct(&user); err != nil {
-			return 0, nil, err
-		}
-
-		q := r.NewUpdate().Table("users").Where("deleted_at IS NULL AND employee_id = ?", data.EmployeeID)
-
-		if user.FirstName != nil {
-			q.Set("first_name=?", data.FirstName)
-		}
-		if user.LastName != nil {
-			q.Set("last_name=?", data.LastName)
-		}
-		if user.NickName != nil {
-			q.Set("nick_name=?", data.NickName)
-		}
-		if user.Role != nil {
-			q.Set("role=?", data.Role)
-		}
-		if user.DepartmentID != nil {
-			q.Set("department_id=?", data.DepartmentID)
-		}
-		if user.PositionID != nil {
-			q.Set("position_id=?", data.PositionID)
-		}
-		if user.Phone != nil {
-			q.Set("phone=?", data.Phone)
-		}
-		if user.Email != nil {
-			q.Set("email=?", data.Email)
-		}
-		q.Set("updated_at=?", time.Now())
-		q.Set("updated_by=?", claims.UserId)
-
-		// Execute the update query
-		_, err = q.Exec(ctx)
-		if err != nil {
-			return 0, nil, web.NewRequestError(errors.Wrap(err, "updating user"), http.StatusBadRequest)
-		}
-
-		createdCount++
-	}
-
-	return createdCount, incompleteRows, nil
-}
-
-func (r Repository) DeleteByExcell(ctx context.Context, request ExcellRequest) (int, []int, error) {
-	_, err := r.CheckClaims(ctx, auth.RoleAdmin)
-	if err != nil {
-		return 0, nil, err
-	}
-
-	if err := r.ValidateStruct(request.Excell); err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "validating excel request"), http.StatusBadRequest)
-	}
-
-	file, err := request.Excell.Open()
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "opening excel file"), http.StatusBadRequest)
-	}
-	defer file.Close()
-
-	tempFile, err := ioutil.TempFile("", "excel-*.xlsx")
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "creating temporary file"), http.StatusInternalServerError)
-	}
-	defer tempFile.Close()
-	defer os.Remove(tempFile.Name())
-
-	_, err = io.Copy(tempFile, file)
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "copying excel file"), http.StatusInternalServerError)
-	}
-
-	fields := map[int]string{
-		0: "EmployeeID",
-		1: "FirstName",
-		2: "LastName",
-		3: "DepartmentName",
-		4: "PositionName",
-		5: "Phone",
-		6: "Email",
-	}
-	rowLen := 7
-
-	employeeIDs, incompleteRows, err := hashing.ExcelReaderByDelete(tempFile.Name(), rowLen, fields)
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "reading excel data"), http.StatusBadRequest)
-	}
-
-	if len(employeeIDs) == 0 {
-		return 0, nil, nil // No IDs to process
-	}
-
-	// Start a transaction
-	tx, err := r.DB.BeginTx(ctx, nil)
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "starting transaction"), http.StatusInternalServerError)
-	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
-		} else {
-			_ = tx.Commit()
-		}
-	}()
-
-	// Format employee IDs for SQL query
-	employeeIDList := "'" + strings.Join(employeeIDs, "', '") + "'"
-
-	// Construct and execute the update query
-	q := r.NewUpdate().Table("users").
-		Set("deleted_at = NOW()").
-		Where(fmt.Sprintf("deleted_at IS NULL AND employee_id IN (%s)", employeeIDList))
-
-	result, err := q.Exec(ctx)
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "executing update query"), http.StatusInternalServerError)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return 0, nil, web.NewRequestError(errors.Wrap(err, "getting affected rows after update"), http.StatusInternalServerError)
-	}
-
-	return int(rowsAffected), incompleteRows, nil
-}
-
-func GenerateQRCode(employeeID string, filename string) error {
+// GenerateQRCode returns the PNG of the QR code of an employee, labelled
+// with the employee ID.
+func GenerateQRCode(employeeID string) ([]byte, error) {
 	// Generate the QR code
 	qrCode, err := qrcode.New(employeeID, qrcode.Medium)
 	if err != nil {
-		return fmt.Errorf("could not generate QR code for %s: %v", employeeID, err)
+		return nil, fmt.Errorf("could not generate QR code for %s: %v", employeeID, err)
 	}
 
 	// Create an image with space for the text
@@ -893,18 +520,16 @@ func GenerateQRCode(employeeID string, filename string) error {
 	// Draw the employee ID text
 	addLabel(finalImage, employeeID, qrImage.Bounds().Max.Y)
 
-	// Save the final image to file
-	file, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("could not create file %s: %v", filename, err)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, finalImage); err != nil {
+		return nil, fmt.Errorf("could not encode PNG: %v", err)
 	}
-	defer file.Close()
 
-	if err := png.Encode(file, finalImage); err != nil {
-		return fmt.Errorf("could not encode PNG: %v", err)
-	}
+	return buf.Bytes(), nil
+}
 
-	return nil
+func qrCodeKey(employeeID string) string {
+	return qrCodeDir + "/" + employeeID + ".png"
 }
 
 func addLabel(img *image.RGBA, text string, yOffset int) {
@@ -929,8 +554,9 @@ func addLabel(img *image.RGBA, text string, yOffset int) {
 	d.DrawString(text)
 }
 
-// CreatePDF creates a PDF from a list of QR codes and numbers.
-func CreatePDF(employeeIDs []string, pdfFilename string) error {
+// CreatePDF creates a PDF from a list of QR codes and numbers. images holds
+// the PNG of every employee.
+func CreatePDF(employeeIDs []string, images map[string][]byte) ([]byte, error) {
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.AddPage()
 	pdf.SetFont("Arial", "", 8)
@@ -940,44 +566,58 @@ func CreatePDF(employeeIDs []string, pdfFilename string) error {
 		pdf.CellFormat(0, 5, employeeID, "", 1, "L", false, 0, "")
 
 		// Add QR code image
-		imagePath := fmt.Sprintf("qr_codes/%s.png", employeeID)
-		pdf.ImageOptions(imagePath, 5, pdf.GetY(), 30, 30, false, gofpdf.ImageOptions{ImageType: "PNG", ReadDpi: true}, 0, "")
+		if image, ok := images[employeeID]; ok {
+			options := gofpdf.ImageOptions{ImageType: "PNG", ReadDpi: true}
+			pdf.RegisterImageOptionsReader(employeeID, options, bytes.NewReader(image))
+			pdf.ImageOptions(employeeID, 5, pdf.GetY(), 30, 30, false, options, 0, "")
+		}
 		pdf.Ln(45)
 	}
 
-	err := pdf.OutputFileAndClose(pdfFilename)
-	if err != nil {
-		return fmt.Errorf("could not create PDF: %v", err)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("could not create PDF: %v", err)
 	}
 
-	return nil
+	return buf.Bytes(), nil
 }
 
+// GetQrCodeByEmployeeID regenerates the QR code of an employee and returns
+// the key it is stored under.
 func (r *Repository) GetQrCodeByEmployeeID(ctx context.Context, employeeID string) (string, error) {
 	_, err := r.CheckClaims(ctx, auth.RoleAdmin)
 	if err != nil {
 		return "", err
 	}
-	// Define the directory and filename
-	dir := "qr_codes"
-	filename := filepath.Join(dir, fmt.Sprintf("%s.png", employeeID))
 
-	// Check if the directory exists, create if it doesn't
-	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-		return "", fmt.Errorf("could not create directory %s: %v", dir, err)
-	}
+	return r.storeQrCode(ctx, employeeID)
+}
 
-	// Generate the QR code
-	if err := GenerateQRCode(employeeID, filename); err != nil {
+// storeQrCode generates the QR code of an employee and returns the key it
+// is stored under.
+func (r *Repository) storeQrCode(ctx context.Context, employeeID string) (string, error) {
+	image, err := GenerateQRCode(employeeID)
+	if err != nil {
 		return "", err
 	}
+	key := qrCodeKey(employeeID)
+	if err := storage.PutBytes(ctx, r.Files, key, image, "image/png"); err != nil {
+		return "", web.NewRequestError(errors.Wrap(err, "storing qr code"), http.StatusInternalServerError)
+	}
 
-	fmt.Printf("QR code for employee ID %s saved to %s\n", employeeID, filename)
-	return filename, nil
+	slog.DebugContext(ctx, "qr code generated", "employee_id", employeeID, "key", key)
+	return key, nil
 }
 
 func (r *Repository) GetQrCodeList(ctx context.Context) (string, error) {
-	rows, err := r.Query("SELECT employee_id FROM users WHERE deleted_at IS NULL AND role='EMPLOYEE'")
+	return r.GenerateQrCodes(ctx, nil)
+}
+
+// GenerateQrCodes regenerates the QR code of every employee and the PDF
+// listing them, calling progress, when given, after every code. It returns
+// the key the PDF is stored under.
+func (r *Repository) GenerateQrCodes(ctx context.Context, progress func(done, total int)) (string, error) {
+	rows, err := r.QueryContext(ctx, "SELECT employee_id FROM users WHERE deleted_at IS NULL AND role='EMPLOYEE'")
 	if err != nil {
 		return "", fmt.Errorf("failed to query employee IDs: %v", err)
 	}
@@ -992,29 +632,40 @@ func (r *Repository) GetQrCodeList(ctx context.Context) (string, error) {
 		employeeIDs = append(employeeIDs, employeeID)
 	}
 
-	if err := os.MkdirAll("qr_codes", os.ModePerm); err != nil {
-		return "", fmt.Errorf("failed to create directory: %v", err)
-	}
-
-	for _, employeeID := range employeeIDs {
-		filename := fmt.Sprintf("qr_codes/%s.png", employeeID)
-		if err := GenerateQRCode(employeeID, filename); err != nil {
-			log.Printf("Error generating QR code for %s: %v", employeeID, err)
+	images := make(map[string][]byte, len(employeeIDs))
+	for i, employeeID := range employeeIDs {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		image, err := GenerateQRCode(employeeID)
+		if err == nil {
+			err = storage.PutBytes(ctx, r.Files, qrCodeKey(employeeID), image, "image/png")
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "generating qr code", "employee_id", employeeID, "error", err)
+		} else {
+			images[employeeID] = image
+		}
+		if progress != nil {
+			progress(i+1, len(employeeIDs))
 		}
 	}
 
-	pdfFilename := "qr_employees.pdf"
-	if err := CreatePDF(employeeIDs, pdfFilename); err != nil {
+	pdf, err := CreatePDF(employeeIDs, images)
+	if err != nil {
 		return "", fmt.Errorf("failed to create PDF: %v", err)
 	}
+	if err := storage.PutBytes(ctx, r.Files, qrCodeListKey, pdf, "application/pdf"); err != nil {
+		return "", fmt.Errorf("failed to store PDF: %v", err)
+	}
 
-	return pdfFilename, nil
+	return qrCodeListKey, nil
 }
 
 func (r Repository) GetMonthlyStatistics(ctx context.Context, request MonthlyStatisticRequest) (MonthlyStatisticResponse, error) {
 	claims, err := r.CheckClaims(ctx)
 	if err != nil {
-		return MonthlyStatisticResponse{}, web.NewRequestError(errors.New("error getting user by token"), http.StatusBadRequest)
+		return MonthlyStatisticResponse{}, web.NewCodeError(i18n.CodeUserNotFound, http.StatusBadRequest)
 	}
 
 	// Calculate the start and end dates of the month
@@ -1068,30 +719,7 @@ func (r Repository) GetMonthlyStatistics(ctx context.Context, request MonthlySta
 func (r Repository) GetStatistics(ctx context.Context, filter StatisticRequest) ([]StatisticResponse, error) {
 	claims, err := r.CheckClaims(ctx)
 	if err != nil {
-		return nil, web.NewRequestError(errors.New("error getting user by token"), http.StatusBadRequest)
-	}
-
-	// Determine the start and end days based on the interval
-	var startDay, endDay int
-	switch filter.Interval {
-	case 0:
-		startDay, endDay = 1, 10
-	case 1:
-		startDay, endDay = 11, 20
-	case 2:
-		startDay, endDay = 21, 31 // Adjust for months with fewer than 31 days later
-	default:
-		return nil, web.NewRequestError(errors.New("invalid interval"), http.StatusBadRequest)
-	}
-
-	// Calculate start and end dates for the interval
-	startDate := time.Date(filter.Month.Year(), filter.Month.Month(), startDay, 0, 0, 0, 0, time.UTC)
-	endDate := time.Date(filter.Month.Year(), filter.Month.Month(), endDay, 23, 59, 59, 999999999, time.UTC)
-
-	// Generate all dates within the interval
-	var allDates []time.Time
-	for d := startDate; !d.After(endDate); d = d.AddDate(0, 0, 1) {
-		allDates = append(allDates, d)
+		return nil, web.NewCodeError(i18n.CodeUserNotFound, http.StatusBadRequest)
 	}
 
 	// Query for interval data
@@ -1118,7 +746,7 @@ func (r Repository) GetStatistics(ctx context.Context, filter StatisticRequest)
 	}
 	defer intervalStmt.Close()
 
-	rows, err := intervalStmt.QueryContext(ctx, claims.UserId, startDate, endDate)
+	rows, err := intervalStmt.QueryContext(ctx, claims.UserId, filter.Range.From.Format(period.Layout), filter.Range.To.Format(period.Layout))
 	if err != nil {
 		return nil, web.NewRequestError(errors.Wrap(err, "executing interval query"), http.StatusInternalServerError)
 	}
@@ -1126,6 +754,7 @@ func (r Repository) GetStatistics(ctx context.Context, filter StatisticRequest)
 
 	// Map to store retrieved data by date
 	dataMap := make(map[string]StatisticResponse)
+	minutesMap := make(map[string]float64)
 	for rows.Next() {
 		var detail StatisticResponse
 		var totalMinutes float64
@@ -1139,33 +768,63 @@ func (r Repository) GetStatistics(ctx context.Context, filter StatisticRequest)
 			return nil, web.NewRequestError(errors.Wrap(err, "scanning interval statistics"), http.StatusInternalServerError)
 		}
 
-		// Convert total minutes to HH:MM
-		hours := int(totalMinutes) / 60
-		minutes := int(totalMinutes) % 60
-		detail.TotalHours = fmt.Sprintf("%02d:%02d", hours, minutes)
+		detail.TotalHours = formatMinutes(totalMinutes)
 		dataMap[*detail.WorkDay] = detail
+		minutesMap[*detail.WorkDay] = totalMinutes
+	}
+	if err = rows.Err(); err != nil {
+		return nil, web.NewRequestError(errors.Wrap(err, "scanning interval statistics"), http.StatusInternalServerError)
 	}
 
 	// Generate the final list of responses, filling in missing dates with default values
-	var list []StatisticResponse
-	for _, date := range allDates {
-		dateStr := date.Format("2006-01-02")
-		if data, found := dataMap[dateStr]; found {
-			list = append(list, data)
-		} else {
-			list = append(list, StatisticResponse{
-				WorkDay:    &dateStr,
-				ComeTime:   ptr("00:00"),
-				LeaveTime:  ptr("00:00"),
-				TotalHours: "00:00",
-			})
+	list := make([]StatisticResponse, 0)
+	if filter.Bucket == period.BucketDay {
+		for _, date := range filter.Range.Days() {
+			dateStr := date.Format(period.Layout)
+			if data, found := dataMap[dateStr]; found {
+				list = append(list, data)
+			} else {
+				list = append(list, StatisticResponse{
+					WorkDay:    &dateStr,
+					ComeTime:   ptr("00:00"),
+					LeaveTime:  ptr("00:00"),
+					TotalHours: "00:00",
+				})
+			}
 		}
+
+		return list, nil
+	}
+
+	// Week and month buckets sum the hours of the days worked in them
+	for _, bucket := range filter.Range.Split(filter.Bucket) {
+		var minutes float64
+		days := 0
+		for d := bucket.From; !d.After(bucket.To); d = d.AddDate(0, 0, 1) {
+			if m, found := minutesMap[d.Format(period.Layout)]; found {
+				minutes += m
+				days++
+			}
+		}
+
+		list = append(list, StatisticResponse{
+			WorkDay:    ptr(bucket.From.Format(period.Layout)),
+			EndDay:     ptr(bucket.To.Format(period.Layout)),
+			TotalHours: formatMinutes(minutes),
+			Days:       &days,
+		})
 	}
 
 	return list, nil
 }
 
-// Utility function to return a pointer to a string
+// formatMinutes formats minutes as HH:MM.
+func formatMinutes(totalMinutes float64) string {
+	hours := int(totalMinutes) / 60
+	minutes := int(totalMinutes) % 60
+	return fmt.Sprintf("%02d:%02d", hours, minutes)
+}
+
 func ptr(s string) *string {
 	return &s
 }
@@ -1209,11 +868,17 @@ func (r Repository) GetEmployeeDashboard(ctx context.Context) (DashboardResponse
 	totalHours := fmt.Sprintf("%02d:%02d", hours, minutes)
 	detail.TotalHours = totalHours
 	if errors.Is(err, sql.ErrNoRows) {
-		return DashboardResponse{}, nil
+		detail = DashboardResponse{}
+	} else if err != nil {
+		return DashboardResponse{}, web.NewRequestError(errors.Wrap(err, "selecting user detail on dashboard"), http.StatusBadRequest)
 	}
+
+	err = r.QueryRowContext(ctx, `
+		SELECT count(id) FROM notification WHERE user_id = ? AND read_at IS NULL`, claims.UserId).Scan(&detail.UnreadNotifications)
 	if err != nil {
-		return DashboardResponse{}, web.NewRequestError(errors.Wrap(err, "selecting user detail on dashboard"), http.StatusBadRequest)
+		return DashboardResponse{}, web.NewRequestError(errors.Wrap(err, "counting unread notifications"), http.StatusInternalServerError)
 	}
+
 	return detail, nil
 }
 
@@ -1233,7 +898,12 @@ func (r Repository) GetDashboardList(ctx context.Context, filter Filter) ([]Depa
 
 	loc, _ := time.LoadLocation("Asia/Tokyo")
 	currentTime := time.Now().In(loc) // Yapon vaqtini olamiz
+	if filter.Date != nil {
+		currentTime = *filter.Date
+	}
 	workDay := currentTime.Format("2006-01-02")
+	roster := postgres.Roster(currentTime)
+	departmentExisted := postgres.ExistedOn("d", currentTime)
 	query := fmt.Sprintf(`
 
                  SELECT
@@ -1241,6 +911,8 @@ func (r Repository) GetDashboardList(ctx context.Context, filter Filter) ([]Depa
                     u.employee_id,
                     u.last_name,
 					u.nick_name,
+                    u.photo,
+                    u.photo_thumb,
                     COALESCE(a.status, false) AS status,
                     d.id AS department_id,
                     d.name AS department_name,
@@ -1248,7 +920,7 @@ func (r Repository) GetDashboardList(ctx context.Context, filter Filter) ([]Depa
                     d.display_number
                 FROM
                        department AS d
-                   LEFT JOIN users AS u ON d.id = u.department_id AND u.deleted_at IS NULL
+                   LEFT JOIN %s AS u ON d.id = u.department_id
                    LEFT JOIN (
                        SELECT
                            a.employee_id,
@@ -1258,8 +930,8 @@ func (r Repository) GetDashboardList(ctx context.Context, filter Filter) ([]Depa
                        WHERE
                            a.work_day = '%s'  AND a.deleted_at IS NULL
                    ) AS a ON a.employee_id = u.employee_id
-                   WHERE    d.deleted_at IS NULL
-                   ORDER BY   d.display_number ASC %s %s`, workDay, limitQuery, offsetQuery)
+                   WHERE    %s
+                   ORDER BY   d.display_number ASC %s %s`, roster, workDay, departmentExisted, limitQuery, offsetQuery)
 
 	rows, err := r.QueryContext(ctx, query)
 	if err != nil {
@@ -1279,7 +951,8 @@ func (r Repository) GetDashboardList(ctx context.Context, filter Filter) ([]Depa
 			departmentName     sql.NullString
 			departmentNickName sql.NullString
 
-			nickName sql.NullString
+			nickName         sql.NullString
+			photo, thumbnail *string
 		)
 
 		// Scan the row with individual fields
@@ -1288,6 +961,8 @@ func (r Repository) GetDashboardList(ctx context.Context, filter Filter) ([]Depa
 			&detail.EmployeeID,
 			&detail.LastName,
 			&nickName,
+			&photo,
+			&thumbnail,
 			&detail.Status,
 			&departmentID,
 			&departmentName,
@@ -1305,6 +980,8 @@ func (r Repository) GetDashboardList(ctx context.Context, filter Filter) ([]Depa
 		} else {
 			detail.NickName = ""
 		}
+		detail.PhotoURL = r.photoURL(photo)
+		detail.ThumbnailURL = r.photoURL(thumbnail)
 		if departmentName.Valid {
 			detail.DepartmentName = &departmentName.String
 		}
@@ -1352,13 +1029,12 @@ func (r Repository) GetDashboardList(ctx context.Context, filter Filter) ([]Depa
         SELECT
             count(u.employee_id)
         FROM
-            users AS u
+            %s AS u
         LEFT JOIN
             attendance AS a ON a.employee_id = u.employee_id AND a.work_day = '%s'
-		RIGHT JOIN department as d on d.id=u.department_id AND d.deleted_at IS NULL	
+		RIGHT JOIN department as d on d.id=u.department_id AND %s
         WHERE
-            u.deleted_at IS NULL AND
-            u.role = 'EMPLOYEE';`, workDay)
+            u.role = 'EMPLOYEE';`, roster, workDay, departmentExisted)
 
 	countRows, err := r.QueryContext(ctx, countQuery)
 	if err != nil {
@@ -1377,7 +1053,8 @@ func (r Repository) GetDashboardList(ctx context.Context, filter Filter) ([]Depa
 	return results, count, nil
 }
 
-func (r *Repository) ExportEmployee(ctx context.Context) (string, error) {
+// employees returns the employees as they are exported.
+func (r *Repository) employees(ctx context.Context) ([]service.Employee, error) {
 	query := `
 	SELECT 
 		u.employee_id,
@@ -1398,7 +1075,7 @@ func (r *Repository) ExportEmployee(ctx context.Context) (string, error) {
 
 	rows, err := r.Query(query)
 	if err != nil {
-		return "", fmt.Errorf("failed to export employee list: %v", err)
+		return nil, fmt.Errorf("failed to export employee list: %v", err)
 	}
 	defer rows.Close()
 
@@ -1421,7 +1098,7 @@ func (r *Repository) ExportEmployee(ctx context.Context) (string, error) {
 			&detail.PositionName,
 			&phone,
 			&detail.Email); err != nil {
-			return "", web.NewRequestError(errors.Wrap(err, "scanning user list"), http.StatusBadRequest)
+			return nil, web.NewRequestError(errors.Wrap(err, "scanning user list"), http.StatusBadRequest)
 		}
 		if nickName.Valid {
 			detail.NickName = nickName.String
@@ -1446,10 +1123,20 @@ func (r *Repository) ExportEmployee(ctx context.Context) (string, error) {
 
 		list = append(list, detail)
 	}
+
+	return list, nil
+}
+
+func (r *Repository) ExportEmployee(ctx context.Context) (string, error) {
+	list, err := r.employees(ctx)
+	if err != nil {
+		return "", err
+	}
+
 	departments := []string{}
 	positions := []string{}
 
-	query = `SELECT name FROM department  where deleted_at is null  ORDER BY display_number ASC`
+	query := `SELECT name FROM department  where deleted_at is null  ORDER BY display_number ASC`
 	err = r.NewRaw(query).Scan(ctx, &departments)
 	if err != nil {
 		return "", web.NewRequestError(errors.Wrap(err, "fetching departments list"), http.StatusInternalServerError)
@@ -1461,12 +1148,15 @@ func (r *Repository) ExportEmployee(ctx context.Context) (string, error) {
 		return "", web.NewRequestError(errors.Wrap(err, "fetching position list"), http.StatusInternalServerError)
 	}
 
-	file, err := service.AddDataToExcel(list, departments, positions)
+	workbook, err := service.AddDataToExcel(list, departments, positions)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		return "", web.NewRequestError(errors.Wrap(err, "exporting employees to excel"), http.StatusInternalServerError)
+	}
+	if err := storage.PutBytes(ctx, r.Files, employeeListKey, workbook, ""); err != nil {
+		return "", web.NewRequestError(errors.Wrap(err, "storing employee list"), http.StatusInternalServerError)
 	}
-	return file, nil
 
+	return employeeListKey, nil
 }
 
 func (r Repository) ExportTemplate(ctx context.Context) (string, error) {
@@ -1484,11 +1174,15 @@ func (r Repository) ExportTemplate(ctx context.Context) (string, error) {
 	if err != nil {
 		return "", web.NewRequestError(errors.Wrap(err, "fetching position list"), http.StatusInternalServerError)
 	}
-	file, err := hashing.EditExcell(departments, positions)
+	workbook, err := hashing.EditExcell(departments, positions)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		return "", web.NewRequestError(errors.Wrap(err, "exporting excel template"), http.StatusInternalServerError)
+	}
+	if err := storage.PutBytes(ctx, r.Files, templateKey, workbook, ""); err != nil {
+		return "", web.NewRequestError(errors.Wrap(err, "storing excel template"), http.StatusInternalServerError)
 	}
-	return file, nil
+
+	return templateKey, nil
 }
 
 type GetDepartmentListResponse struct {
@@ -0,0 +1,118 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type Filter struct {
+	Limit  *int
+	Offset *int
+	Page   *int
+	Event  *string
+	Active *bool
+}
+
+type DeliveryFilter struct {
+	Limit          *int
+	Offset         *int
+	Page           *int
+	SubscriptionID *int
+	Event          *string
+	Status         *string
+}
+
+type GetListResponse struct {
+	ID          int       `json:"id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Description *string   `json:"description"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type GetDetailByIdResponse struct {
+	ID          int       `json:"id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Description *string   `json:"description"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	Pending     int       `json:"pending"`
+	Failed      int       `json:"failed"`
+}
+
+type CreateRequest struct {
+	URL         *string  `json:"url" form:"url"`
+	Secret      *string  `json:"secret" form:"secret"`
+	Events      []string `json:"events" form:"events"`
+	Description *string  `json:"description" form:"description"`
+	Active      *bool    `json:"active" form:"active"`
+}
+
+// CreateResponse is the only place the secret is returned, receivers need
+// it to verify signatures.
+type CreateResponse struct {
+	bun.BaseModel `bun:"table:webhook_subscription"`
+
+	ID          int       `json:"id" bun:"-"`
+	URL         string    `json:"url" bun:"url"`
+	Secret      string    `json:"secret" bun:"secret"`
+	Events      []string  `json:"events" bun:"events,array"`
+	Description *string   `json:"description" bun:"description"`
+	Active      bool      `json:"active" bun:"active"`
+	CreatedAt   time.Time `json:"-" bun:"created_at"`
+	CreatedBy   int       `json:"-" bun:"created_by"`
+}
+
+type UpdateRequest struct {
+	ID          int      `json:"id" form:"id"`
+	URL         *string  `json:"url" form:"url"`
+	Secret      *string  `json:"secret" form:"secret"`
+	Events      []string `json:"events" form:"events"`
+	Description *string  `json:"description" form:"description"`
+	Active      *bool    `json:"active" form:"active"`
+}
+
+type DeliveryResponse struct {
+	ID             int64           `json:"id"`
+	SubscriptionID int             `json:"subscription_id"`
+	URL            string          `json:"url"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+	ResponseStatus *int            `json:"response_status"`
+	ResponseBody   *string         `json:"response_body"`
+	Error          *string         `json:"error"`
+	DurationMs     *int            `json:"duration_ms"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+// Delivery is a delivery claimed by the dispatcher.
+type Delivery struct {
+	ID             int64
+	SubscriptionID int
+	URL            string
+	Secret         string
+	Event          string
+	Payload        json.RawMessage
+	Attempts       int
+	CreatedAt      time.Time
+}
+
+// DeliveryResult is the outcome of one attempt. NextAttemptAt is set when the
+// delivery is retried.
+type DeliveryResult struct {
+	Status         string
+	NextAttemptAt  *time.Time
+	ResponseStatus int
+	ResponseBody   string
+	Error          string
+	Duration       time.Duration
+}
@@ -0,0 +1,556 @@
+package webhook
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/auth"
+	"attendance/backend/internal/pkg/repository/postgresql"
+	"attendance/backend/internal/repository/postgres"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+// Events a subscription can listen to. check_in, check_out, user_created and
+// user_deleted are queued by database triggers, the others by the code that
+// causes them.
+const (
+	EventCheckIn            = "check_in"
+	EventCheckOut           = "check_out"
+	EventForgotLeaveAutofix = "forgot_leave_autofix"
+	EventUserCreated        = "user_created"
+	EventUserDeleted        = "user_deleted"
+	EventNotification       = "notification"
+)
+
+// Events lists every event a subscription can listen to.
+var Events = []string{
+	EventCheckIn,
+	EventCheckOut,
+	EventForgotLeaveAutofix,
+	EventUserCreated,
+	EventUserDeleted,
+	EventNotification,
+}
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+type Repository struct {
+	*postgresql.Database
+}
+
+func NewRepository(database *postgresql.Database) *Repository {
+	return &Repository{Database: database}
+}
+
+// Enqueue queues a delivery of event for every active subscription listening
+// to it. db may be a transaction, so the deliveries are only queued when the
+// change they describe is committed.
+func Enqueue(ctx context.Context, db bun.IDB, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return errors.Wrap(err, "encoding webhook payload")
+	}
+
+	if _, err = db.ExecContext(ctx, "SELECT webhook_enqueue(?, ?::jsonb)", event, string(payload)); err != nil {
+		return errors.Wrapf(err, "enqueueing webhook %s", event)
+	}
+
+	return nil
+}
+
+func (r Repository) GetList(ctx context.Context, filter Filter) ([]GetListResponse, int, error) {
+	_, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	whereQuery := `
+			WHERE
+				deleted_at IS NULL
+			`
+
+	if filter.Event != nil {
+		whereQuery += fmt.Sprintf(` AND '%s' = ANY(events)`, strings.Replace(*filter.Event, "'", "''", -1))
+	}
+	if filter.Active != nil {
+		whereQuery += fmt.Sprintf(` AND active = %t`, *filter.Active)
+	}
+
+	orderQuery := "ORDER BY created_at desc"
+
+	var limitQuery, offsetQuery string
+
+	if filter.Page != nil && filter.Limit != nil {
+		offset := (*filter.Page - 1) * (*filter.Limit)
+		filter.Offset = &offset
+	}
+
+	if filter.Limit != nil {
+		limitQuery += fmt.Sprintf(" LIMIT %d", *filter.Limit)
+	}
+
+	if filter.Offset != nil {
+		offsetQuery += fmt.Sprintf(" OFFSET %d", *filter.Offset)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			id,
+			url,
+			events,
+			description,
+			active,
+			created_at
+		FROM webhook_subscription
+		%s %s %s %s
+	`, whereQuery, orderQuery, limitQuery, offsetQuery)
+
+	rows, err := r.QueryContext(ctx, query)
+	if err != nil {
+		return nil, 0, web.NewRequestError(errors.Wrap(err, "selecting webhooks"), http.StatusInternalServerError)
+	}
+	defer rows.Close()
+
+	var list []GetListResponse
+
+	for rows.Next() {
+		var detail GetListResponse
+		if err = rows.Scan(
+			&detail.ID,
+			&detail.URL,
+			pgdialect.Array(&detail.Events),
+			&detail.Description,
+			&detail.Active,
+			&detail.CreatedAt); err != nil {
+			return nil, 0, web.NewRequestError(errors.Wrap(err, "scanning webhook list"), http.StatusInternalServerError)
+		}
+
+		list = append(list, detail)
+	}
+
+	var count int
+	err = r.QueryRowContext(ctx, fmt.Sprintf(`SELECT count(id) FROM webhook_subscription %s`, whereQuery)).Scan(&count)
+	if err != nil {
+		return nil, 0, web.NewRequestError(errors.Wrap(err, "counting webhooks"), http.StatusInternalServerError)
+	}
+
+	return list, count, nil
+}
+
+func (r Repository) GetDetailById(ctx context.Context, id int) (GetDetailByIdResponse, error) {
+	_, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return GetDetailByIdResponse{}, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			s.id,
+			s.url,
+			s.events,
+			s.description,
+			s.active,
+			s.created_at,
+			(SELECT count(*) FROM webhook_delivery WHERE subscription_id = s.id AND status = '%s'),
+			(SELECT count(*) FROM webhook_delivery WHERE subscription_id = s.id AND status = '%s')
+		FROM webhook_subscription s
+		WHERE s.deleted_at IS NULL AND s.id = %d
+	`, StatusPending, StatusFailed, id)
+
+	var detail GetDetailByIdResponse
+
+	err = r.QueryRowContext(ctx, query).Scan(
+		&detail.ID,
+		&detail.URL,
+		pgdialect.Array(&detail.Events),
+		&detail.Description,
+		&detail.Active,
+		&detail.CreatedAt,
+		&detail.Pending,
+		&detail.Failed,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return GetDetailByIdResponse{}, web.NewRequestError(postgres.ErrNotFound, http.StatusNotFound)
+	}
+	if err != nil {
+		return GetDetailByIdResponse{}, web.NewRequestError(errors.Wrap(err, "selecting webhook detail"), http.StatusInternalServerError)
+	}
+
+	return detail, nil
+}
+
+func (r Repository) Create(ctx context.Context, request CreateRequest) (CreateResponse, error) {
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return CreateResponse{}, err
+	}
+
+	if err := r.ValidateStruct(&request, "URL", "Events"); err != nil {
+		return CreateResponse{}, err
+	}
+
+	target, err := validateURL(*request.URL)
+	if err != nil {
+		return CreateResponse{}, err
+	}
+	events, err := validateEvents(request.Events)
+	if err != nil {
+		return CreateResponse{}, err
+	}
+
+	var secret string
+	if request.Secret != nil && strings.TrimSpace(*request.Secret) != "" {
+		secret = strings.TrimSpace(*request.Secret)
+	} else if secret, err = newSecret(); err != nil {
+		return CreateResponse{}, web.NewRequestError(errors.Wrap(err, "generating webhook secret"), http.StatusInternalServerError)
+	}
+
+	response := CreateResponse{
+		URL:         target,
+		Secret:      secret,
+		Events:      events,
+		Description: request.Description,
+		Active:      true,
+		CreatedAt:   time.Now(),
+		CreatedBy:   claims.UserId,
+	}
+	if request.Active != nil {
+		response.Active = *request.Active
+	}
+
+	_, err = r.NewInsert().Model(&response).Returning("id").Exec(ctx, &response.ID)
+	if err != nil {
+		return CreateResponse{}, web.NewRequestError(errors.Wrap(err, "creating webhook"), http.StatusInternalServerError)
+	}
+
+	return response, nil
+}
+
+func (r Repository) UpdateColumns(ctx context.Context, request UpdateRequest) error {
+	if err := r.ValidateStruct(&request, "ID"); err != nil {
+		return err
+	}
+
+	claims, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	q := r.NewUpdate().Table("webhook_subscription").Where("deleted_at IS NULL AND id = ?", request.ID)
+
+	if request.URL != nil {
+		target, err := validateURL(*request.URL)
+		if err != nil {
+			return err
+		}
+		q.Set("url = ?", target)
+	}
+	if request.Events != nil {
+		events, err := validateEvents(request.Events)
+		if err != nil {
+			return err
+		}
+		q.Set("events = ?", pgdialect.Array(events))
+	}
+	if request.Secret != nil {
+		secret := strings.TrimSpace(*request.Secret)
+		if secret == "" {
+			return web.NewCodeError(i18n.CodeRequiredBlank, http.StatusBadRequest)
+		}
+		q.Set("secret = ?", secret)
+	}
+	if request.Description != nil {
+		q.Set("description = ?", request.Description)
+	}
+	if request.Active != nil {
+		q.Set("active = ?", *request.Active)
+	}
+
+	q.Set("updated_at = ?", time.Now())
+	q.Set("updated_by = ?", claims.UserId)
+
+	result, err := q.Exec(ctx)
+	if err != nil {
+		return web.NewRequestError(errors.Wrap(err, "updating webhook"), http.StatusInternalServerError)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return web.NewRequestError(postgres.ErrNotFound, http.StatusNotFound)
+	}
+
+	return nil
+}
+
+func (r Repository) Delete(ctx context.Context, id int) error {
+	if _, err := r.CheckClaims(ctx, auth.RoleAdmin); err != nil {
+		return err
+	}
+
+	return r.DeleteRow(ctx, "webhook_subscription", id)
+}
+
+// GetDeliveries returns the delivery log, newest first.
+func (r Repository) GetDeliveries(ctx context.Context, filter DeliveryFilter) ([]DeliveryResponse, int, error) {
+	_, err := r.CheckClaims(ctx, auth.RoleAdmin)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	whereQuery := `
+			WHERE
+				TRUE
+			`
+
+	if filter.SubscriptionID != nil {
+		whereQuery += fmt.Sprintf(` AND d.subscription_id = %d`, *filter.SubscriptionID)
+	}
+	if filter.Event != nil {
+		whereQuery += fmt.Sprintf(` AND d.event = '%s'`, strings.Replace(*filter.Event, "'", "''", -1))
+	}
+	if filter.Status != nil {
+		whereQuery += fmt.Sprintf(` AND d.status = '%s'`, strings.Replace(*filter.Status, "'", "''", -1))
+	}
+
+	orderQuery := "ORDER BY d.created_at desc, d.id desc"
+
+	var limitQuery, offsetQuery string
+
+	if filter.Page != nil && filter.Limit != nil {
+		offset := (*filter.Page - 1) * (*filter.Limit)
+		filter.Offset = &offset
+	}
+
+	if filter.Limit != nil {
+		limitQuery += fmt.Sprintf(" LIMIT %d", *filter.Limit)
+	}
+
+	if filter.Offset != nil {
+		offsetQuery += fmt.Sprintf(" OFFSET %d", *filter.Offset)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			d.id,
+			d.subscription_id,
+			s.url,
+			d.event,
+			d.payload,
+			d.status,
+			d.attempts,
+			CASE WHEN d.status = '%s' THEN d.next_attempt_at END,
+			d.last_attempt_at,
+			d.response_status,
+			d.response_body,
+			d.error,
+			d.duration_ms,
+			d.created_at,
+			d.delivered_at
+		FROM webhook_delivery d
+		JOIN webhook_subscription s ON s.id = d.subscription_id
+		%s %s %s %s
+	`, StatusPending, whereQuery, orderQuery, limitQuery, offsetQuery)
+
+	rows, err := r.QueryContext(ctx, query)
+	if err != nil {
+		return nil, 0, web.NewRequestError(errors.Wrap(err, "selecting webhook deliveries"), http.StatusInternalServerError)
+	}
+	defer rows.Close()
+
+	var list []DeliveryResponse
+
+	for rows.Next() {
+		var detail DeliveryResponse
+		var payload []byte
+		if err = rows.Scan(
+			&detail.ID,
+			&detail.SubscriptionID,
+			&detail.URL,
+			&detail.Event,
+			&payload,
+			&detail.Status,
+			&detail.Attempts,
+			&detail.NextAttemptAt,
+			&detail.LastAttemptAt,
+			&detail.ResponseStatus,
+			&detail.ResponseBody,
+			&detail.Error,
+			&detail.DurationMs,
+			&detail.CreatedAt,
+			&detail.DeliveredAt); err != nil {
+			return nil, 0, web.NewRequestError(errors.Wrap(err, "scanning webhook deliveries"), http.StatusInternalServerError)
+		}
+		detail.Payload = payload
+
+		list = append(list, detail)
+	}
+
+	var count int
+	err = r.QueryRowContext(ctx, fmt.Sprintf(`SELECT count(d.id) FROM webhook_delivery d %s`, whereQuery)).Scan(&count)
+	if err != nil {
+		return nil, 0, web.NewRequestError(errors.Wrap(err, "counting webhook deliveries"), http.StatusInternalServerError)
+	}
+
+	return list, count, nil
+}
+
+// Redeliver queues a delivery again, whatever its status, with a fresh
+// attempt budget.
+func (r Repository) Redeliver(ctx context.Context, id int64) error {
+	if _, err := r.CheckClaims(ctx, auth.RoleAdmin); err != nil {
+		return err
+	}
+
+	result, err := r.ExecContext(ctx, `
+		UPDATE webhook_delivery
+		SET status = ?, attempts = 0, next_attempt_at = now(), delivered_at = NULL
+		WHERE id = ?`, StatusPending, id)
+	if err != nil {
+		return web.NewRequestError(errors.Wrap(err, "queueing webhook delivery"), http.StatusInternalServerError)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return web.NewRequestError(postgres.ErrNotFound, http.StatusNotFound)
+	}
+
+	return nil
+}
+
+// ClaimDue locks up to limit pending deliveries that are due and counts the
+// attempt. A claimed delivery becomes due again after lease, so deliveries of
+// a dispatcher that died are picked up by another one. Deliveries of inactive
+// subscriptions wait until the subscription is activated again.
+func (r Repository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	query := fmt.Sprintf(`
+		UPDATE webhook_delivery d
+		SET attempts = d.attempts + 1,
+			last_attempt_at = now(),
+			next_attempt_at = now() + interval '%d seconds'
+		FROM webhook_subscription s
+		WHERE s.id = d.subscription_id AND d.id IN (
+			SELECT pd.id
+			FROM webhook_delivery pd
+			JOIN webhook_subscription ps ON ps.id = pd.subscription_id
+			WHERE pd.status = '%s' AND pd.next_attempt_at <= now()
+				AND ps.active AND ps.deleted_at IS NULL
+			ORDER BY pd.next_attempt_at
+			LIMIT %d
+			FOR UPDATE OF pd SKIP LOCKED
+		)
+		RETURNING d.id, d.subscription_id, s.url, s.secret, d.event, d.payload, d.attempts, d.created_at
+	`, int(lease.Seconds()), StatusPending, limit)
+
+	rows, err := r.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "claiming webhook deliveries")
+	}
+	defer rows.Close()
+
+	var list []Delivery
+	for rows.Next() {
+		var d Delivery
+		var payload []byte
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.URL, &d.Secret, &d.Event, &payload, &d.Attempts, &d.CreatedAt); err != nil {
+			return nil, errors.Wrap(err, "scanning claimed webhook delivery")
+		}
+		d.Payload = payload
+		list = append(list, d)
+	}
+
+	return list, rows.Err()
+}
+
+// Complete records the outcome of an attempt.
+func (r Repository) Complete(ctx context.Context, id int64, result DeliveryResult) error {
+	q := r.NewUpdate().Table("webhook_delivery").Where("id = ?", id).
+		Set("status = ?", result.Status).
+		Set("response_status = ?", nullInt(result.ResponseStatus)).
+		Set("response_body = ?", nullString(result.ResponseBody)).
+		Set("error = ?", nullString(result.Error)).
+		Set("duration_ms = ?", result.Duration.Milliseconds())
+
+	switch {
+	case result.Status == StatusSucceeded:
+		q.Set("delivered_at = now()")
+	case result.NextAttemptAt != nil:
+		q.Set("next_attempt_at = ?", *result.NextAttemptAt)
+	}
+
+	if _, err := q.Exec(ctx); err != nil {
+		return errors.Wrap(err, "recording webhook delivery")
+	}
+
+	return nil
+}
+
+func validateURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", web.NewCodeError(i18n.CodeWebhookURLInvalid, http.StatusBadRequest)
+	}
+
+	return raw, nil
+}
+
+func validateEvents(events []string) ([]string, error) {
+	var list []string
+	seen := make(map[string]bool)
+	for _, e := range events {
+		e = strings.TrimSpace(e)
+		if seen[e] {
+			continue
+		}
+
+		valid := false
+		for _, known := range Events {
+			if e == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, web.NewCodeError(i18n.CodeWebhookEventInvalid, http.StatusBadRequest, e)
+		}
+
+		seen[e] = true
+		list = append(list, e)
+	}
+	if len(list) == 0 {
+		return nil, web.NewCodeError(i18n.CodeRequiredBlank, http.StatusBadRequest)
+	}
+
+	return list, nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func nullInt(v int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(v), Valid: v != 0}
+}
+
+func nullString(v string) sql.NullString {
+	return sql.NullString{String: v, Valid: v != ""}
+}
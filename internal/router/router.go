@@ -5,11 +5,19 @@ import (
 	"attendance/backend/foundation/web"
 	"attendance/backend/internal/auth"
 	"attendance/backend/internal/controller/http/v1/file"
+	"attendance/backend/internal/controller/http/v1/health"
+	"attendance/backend/internal/mailer"
+	"attendance/backend/internal/metrics"
+	"attendance/backend/internal/realtime"
 	"attendance/backend/internal/repository/postgres/attendance"
 	"attendance/backend/internal/repository/postgres/companyInfo"
 	"attendance/backend/internal/repository/postgres/department"
+	"attendance/backend/internal/repository/postgres/job"
+	"attendance/backend/internal/repository/postgres/notification"
 	"attendance/backend/internal/repository/postgres/position"
-	"log"
+	"attendance/backend/internal/repository/postgres/report"
+	"attendance/backend/internal/repository/postgres/webhook"
+	"attendance/backend/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
@@ -23,8 +31,13 @@ import (
 	auth_controller "attendance/backend/internal/controller/http/v1/auth"
 	companyInfo_controller "attendance/backend/internal/controller/http/v1/companyInfo"
 	department_controller "attendance/backend/internal/controller/http/v1/department"
+	job_controller "attendance/backend/internal/controller/http/v1/job"
+	notification_controller "attendance/backend/internal/controller/http/v1/notification"
 	position_controller "attendance/backend/internal/controller/http/v1/position"
+	report_controller "attendance/backend/internal/controller/http/v1/report"
 	user_controller "attendance/backend/internal/controller/http/v1/user"
+	webhook_controller "attendance/backend/internal/controller/http/v1/webhook"
+	ws_controller "attendance/backend/internal/controller/http/v1/ws"
 
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -34,29 +47,51 @@ type Router struct {
 	*web.App
 	postgresDB         *postgresql.Database
 	redisDB            *redis.Client
-	port               string
 	auth               *auth.Auth
 	fileServerBasePath string
+	metrics            *metrics.Metrics
+	hub                *realtime.Hub
+	inbox              *realtime.Inbox
+	wsOrigins          []string
+	channels           []string
+	files              storage.Storage
+	signer             *storage.Signer
+	mail               *mailer.Mailer
 }
 
 func NewRouter(
 	app *web.App,
 	postgresDB *postgresql.Database,
 	redisDB *redis.Client,
-	port string,
 	auth *auth.Auth,
 	fileServerBasePath string,
+	metrics *metrics.Metrics,
+	hub *realtime.Hub,
+	inbox *realtime.Inbox,
+	wsOrigins []string,
+	channels []string,
+	files storage.Storage,
+	signer *storage.Signer,
+	mail *mailer.Mailer,
 ) *Router {
 	return &Router{
 		app,
 		postgresDB,
 		redisDB,
-		port,
 		auth,
 		fileServerBasePath,
+		metrics,
+		hub,
+		inbox,
+		wsOrigins,
+		channels,
+		files,
+		signer,
+		mail,
 	}
 }
 
+// Init registers all routes of the API on the application.
 func (r Router) Init() error {
 
 	r.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler), func(ctx *gin.Context) {
@@ -67,22 +102,38 @@ func (r Router) Init() error {
 	})
 
 	// - postgresql
-	userPostgres := user.NewRepository(r.postgresDB)
+	userPostgres := user.NewRepository(r.postgresDB, r.files, r.signer)
 	departmentPostgres := department.NewRepository(r.postgresDB)
 	positionPostgres := position.NewRepository(r.postgresDB)
 	companyInfoPostgres := companyInfo.NewRepository(r.postgresDB)
 	attendancePostgres := attendance.NewRepository(r.postgresDB)
+	webhookPostgres := webhook.NewRepository(r.postgresDB)
+	notificationPostgres := notification.NewRepository(r.postgresDB)
+	reportPostgres := report.NewRepository(r.postgresDB)
+	jobPostgres := job.NewRepository(r.postgresDB)
 
 	// controller
-	userController := user_controller.NewController(userPostgres, companyInfoPostgres)
+	userController := user_controller.NewController(userPostgres, companyInfoPostgres, r.hub, r.files, r.mail)
 	authController := auth_controller.NewController(userPostgres)
 	departmentController := department_controller.NewController(departmentPostgres)
 	positionController := position_controller.NewController(positionPostgres)
-	companyInfoController := companyInfo_controller.NewController(companyInfoPostgres)
+	companyInfoController := companyInfo_controller.NewController(companyInfoPostgres, r.files)
 
 	attendanceController := attendance_controller.NewController(attendancePostgres, companyInfoPostgres)
+	webhookController := webhook_controller.NewController(webhookPostgres)
+	notificationController := notification_controller.NewController(notificationPostgres, r.inbox, r.channels)
+	reportController := report_controller.NewController(reportPostgres)
+	jobController := job_controller.NewController(jobPostgres)
+	wsController := ws_controller.NewController(r.hub, attendancePostgres, companyInfoPostgres, r.wsOrigins)
 
-	fileC := file.NewController(r.App, r.fileServerBasePath)
+	fileC := file.NewController(r.App, r.fileServerBasePath, r.files, r.signer, r.auth)
+	healthController := health.NewController(r.postgresDB.DB, r.redisDB)
+
+	r.metrics.Register(metrics.NewAttendanceCollector(attendancePostgres))
+
+	// #health
+	r.Get(health.LivenessPath, healthController.Liveness)
+	r.Get(health.ReadinessPath, healthController.Readiness)
 
 	// #auth
 	r.Post("/api/v1/sign-in", authController.SignIn)
@@ -97,22 +148,72 @@ func (r Router) Init() error {
 	r.Get("/api/v1/user/qrcode", userController.GetQrCodeByEmployeeId, middleware.Authenticate(r.auth, auth.RoleAdmin))
 	r.Get("/api/v1/user/qrcodelist", userController.GetQrCodeList, middleware.Authenticate(r.auth, auth.RoleAdmin))
 	r.Get("/api/v1/user/export_employee", userController.ExportEmployee, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Get("/api/v1/user/export", userController.Export, middleware.Authenticate(r.auth, auth.RoleAdmin))
 	r.Get("/api/v1/user/export_template", userController.ExportTemplate, middleware.Authenticate(r.auth, auth.RoleAdmin))
 
 	r.Post("/api/v1/user/create", userController.CreateUser, middleware.Authenticate(r.auth, auth.RoleAdmin), middleware.ValidateEmailAndPhoneInput(), middleware.ValidateHalfWidthInput())
 	r.Post("/api/v1/user/create_excell", userController.CreateUserByExcell, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Post("/api/v1/user/import/preview", userController.PreviewImport, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Get("/api/v1/user/import/:id", userController.GetImport, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Get("/api/v1/user/import/:id/errors", userController.GetImportErrors, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Post("/api/v1/user/import/:id/apply", userController.ApplyImport, middleware.Authenticate(r.auth, auth.RoleAdmin))
 
 	r.Patch("/api/v1/user/:id", userController.UpdateUserColumns, middleware.Authenticate(r.auth, auth.RoleAdmin), middleware.ValidateEmailAndPhoneInput(), middleware.ValidateHalfWidthInput())
 	r.Delete("/api/v1/user/:id", userController.DeleteUser, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Post("/api/v1/user/:id/photo", userController.SetPhoto, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Delete("/api/v1/user/:id/photo", userController.DeletePhoto, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Get("/api/v1/user/profile_fields", userController.GetProfileFields, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Put("/api/v1/user/profile_fields", userController.UpdateProfileFields, middleware.Authenticate(r.auth, auth.RoleAdmin))
 	r.Get("/api/v1/user/statistics", userController.GetStatistics, middleware.Authenticate(r.auth))
 	r.Get("/api/v1/user/monthly", userController.GetMonthlyStatistics, middleware.Authenticate(r.auth))
 	r.Get("/api/v1/user/dashboard", userController.GetEmployeeDashboard, middleware.Authenticate(r.auth))
-	r.GET("/api/v1/user/dashboardlist", func(c *gin.Context) {
-		w := c.Writer
-		r := c.Request
-		log.Println("WebSocket route hit")
-		userController.GetDashboardListSSE(w, r)
-	})
+	r.Stream("/api/v1/user/dashboardlist", userController.GetDashboardListSSE, middleware.AuthenticateStream(r.auth, auth.RoleAdmin, auth.RoleDashboard))
+
+	// #me
+	r.Get("/api/v1/me", userController.GetProfile, middleware.Authenticate(r.auth))
+	r.Patch("/api/v1/me", userController.UpdateProfile, middleware.Authenticate(r.auth), middleware.ValidateHalfWidthInput())
+	r.Get("/api/v1/me/qrcode", userController.GetMyQrCode, middleware.Authenticate(r.auth))
+	r.Post("/api/v1/me/photo", userController.SetPhoto, middleware.Authenticate(r.auth))
+	r.Delete("/api/v1/me/photo", userController.DeletePhoto, middleware.Authenticate(r.auth))
+	r.Post("/api/v1/me/email", userController.RequestEmailChange, middleware.Authenticate(r.auth))
+	r.Post("/api/v1/me/email/confirm", userController.ConfirmEmailChange, middleware.Authenticate(r.auth))
+
+	// #ws
+	r.Stream("/api/v1/ws", wsController.Connect, middleware.AuthenticateStream(r.auth, auth.RoleAdmin, auth.RoleDashboard, auth.RoleQrCode))
+	r.Post("/api/v1/ws/command", wsController.Command, middleware.Authenticate(r.auth, auth.RoleAdmin))
+
+	// #webhook
+	r.Get("/api/v1/webhook/list", webhookController.GetList, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Get("/api/v1/webhook/deliveries", webhookController.GetDeliveries, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Get("/api/v1/webhook/:id", webhookController.GetDetailById, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Post("/api/v1/webhook/create", webhookController.Create, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Post("/api/v1/webhook/deliveries/:id/retry", webhookController.Redeliver, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Patch("/api/v1/webhook/:id", webhookController.UpdateColumns, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Delete("/api/v1/webhook/:id", webhookController.Delete, middleware.Authenticate(r.auth, auth.RoleAdmin))
+
+	// #notification
+	r.Get("/api/v1/notification/list", notificationController.GetList, middleware.Authenticate(r.auth))
+	r.Post("/api/v1/notification/read_all", notificationController.MarkAllRead, middleware.Authenticate(r.auth))
+	r.Post("/api/v1/notification/:id/read", notificationController.MarkRead, middleware.Authenticate(r.auth))
+	r.Stream("/api/v1/notification/stream", notificationController.Stream, middleware.AuthenticateStream(r.auth))
+	r.Get("/api/v1/notification/preferences", notificationController.GetPreferences, middleware.Authenticate(r.auth))
+	r.Put("/api/v1/notification/preferences", notificationController.UpdatePreferences, middleware.Authenticate(r.auth))
+
+	// #report
+	r.Post("/api/v1/report/run", reportController.Run, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Get("/api/v1/report/list", reportController.GetList, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Get("/api/v1/report/:id", reportController.GetDetailById, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Get("/api/v1/report/:id/run", reportController.RunSaved, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Post("/api/v1/report/create", reportController.Create, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Patch("/api/v1/report/:id", reportController.UpdateColumns, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Delete("/api/v1/report/:id", reportController.Delete, middleware.Authenticate(r.auth, auth.RoleAdmin))
+
+	// #job
+	r.Get("/api/v1/job/list", jobController.GetList, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Get("/api/v1/job/:id", jobController.GetDetailById, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Get("/api/v1/job/:id/result", jobController.GetResult, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Post("/api/v1/job/create", jobController.Create, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Post("/api/v1/job/:id/cancel", jobController.Cancel, middleware.Authenticate(r.auth, auth.RoleAdmin))
 
 	// #department
 	r.Get("/api/v1/department/list", departmentController.GetList, middleware.Authenticate(r.auth, auth.RoleAdmin, auth.RoleDashboard))
@@ -136,6 +237,8 @@ func (r Router) Init() error {
 	r.Get("/api/v1/attendance/list", attendanceController.GetList, middleware.Authenticate(r.auth, auth.RoleAdmin, auth.RoleEmployee, auth.RoleDashboard))
 	r.Get("/api/v1/attendance/:id", attendanceController.GetDetailById, middleware.Authenticate(r.auth, auth.RoleAdmin))
 	r.Get("/api/v1/attendance/history", attendanceController.GetHistoryById, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Get("/api/v1/attendance/export", attendanceController.ExportHistory, middleware.Authenticate(r.auth, auth.RoleAdmin))
+	r.Post("/api/v1/attendance/import", attendanceController.ImportHistory, middleware.Authenticate(r.auth, auth.RoleAdmin))
 	r.Post("/api/v1/attendance/createbyphone", attendanceController.CreateByPhone, middleware.Authenticate(r.auth))
 	r.Post("/api/v1/attendance/createbyqrcode", attendanceController.CreateByQRCode, middleware.Authenticate(r.auth))
 	r.Patch("/api/v1/attendance/exitbyphone", attendanceController.ExitByPhone, middleware.Authenticate(r.auth))
@@ -147,5 +250,5 @@ func (r Router) Init() error {
 	r.Get("/api/v1/attendance/barchart", attendanceController.GetBarChartStatistics, middleware.Authenticate(r.auth, auth.RoleAdmin))
 	r.Get("/api/v1/attendance/graph", attendanceController.GetGraphStatistic, middleware.Authenticate(r.auth, auth.RoleAdmin))
 
-	return r.Run(r.port)
+	return nil
 }
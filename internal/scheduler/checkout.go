@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"attendance/backend/internal/repository/postgres"
+	"attendance/backend/internal/repository/postgres/attendance"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// CheckoutStore closes the attendances nobody checked out of.
+type CheckoutStore interface {
+	GetCompanySchedule(ctx context.Context) (attendance.CompanySchedule, error)
+	CloseForgottenCheckouts(ctx context.Context, through string, policy string) ([]attendance.ForgottenCheckout, error)
+}
+
+// CheckoutNotifier tells employees their attendance was closed for them.
+type CheckoutNotifier interface {
+	NotifyForgottenCheckouts(ctx context.Context, closed []attendance.ForgottenCheckout)
+}
+
+// CheckoutConfig configures the forgotten check-out job.
+type CheckoutConfig struct {
+	// Policy is one of the attendance.CheckoutPolicy values.
+	Policy string
+
+	// Delay after the company over_end_time at which a work day is closed.
+	Delay time.Duration
+
+	// Location the company times are given in.
+	Location *time.Location
+}
+
+// CheckoutJob closes the attendances of a work day that are still open Delay
+// after the company over_end_time. Days missed while no replica was running
+// are caught up on the next run.
+type CheckoutJob struct {
+	cfg      CheckoutConfig
+	store    CheckoutStore
+	notifier CheckoutNotifier
+
+	// closed is the last work day known to be closed.
+	closed string
+}
+
+// NewCheckoutJob constructs the job. notifier may be nil.
+func NewCheckoutJob(cfg CheckoutConfig, store CheckoutStore, notifier CheckoutNotifier) *CheckoutJob {
+	if cfg.Policy == "" {
+		cfg.Policy = attendance.CheckoutPolicyEndTime
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+
+	return &CheckoutJob{cfg: cfg, store: store, notifier: notifier}
+}
+
+// Name implements Job.
+func (j *CheckoutJob) Name() string {
+	return "forgotten_checkouts"
+}
+
+// Run implements Job.
+func (j *CheckoutJob) Run(ctx context.Context, now time.Time) error {
+	schedule, err := j.store.GetCompanySchedule(ctx)
+	if err != nil {
+		return err
+	}
+
+	through, err := j.closable(schedule, now.In(j.cfg.Location))
+	if err != nil {
+		return err
+	}
+	if through <= j.closed {
+		return nil
+	}
+
+	closed, err := j.store.CloseForgottenCheckouts(ctx, through, j.cfg.Policy)
+	switch {
+	case errors.Is(err, postgres.ErrLocked):
+		// Another replica is on it.
+		slog.Debug("scheduler: forgotten check-outs locked by another instance", "through", through)
+		return nil
+	case err != nil:
+		return err
+	}
+	j.closed = through
+
+	if len(closed) > 0 {
+		slog.Info("scheduler: closed forgotten check-outs", "through", through, "policy", j.cfg.Policy, "count", len(closed))
+		if j.notifier != nil {
+			j.notifier.NotifyForgottenCheckouts(ctx, closed)
+		}
+	}
+
+	return nil
+}
+
+// closable returns the last work day whose closing time has passed at now.
+func (j *CheckoutJob) closable(schedule attendance.CompanySchedule, now time.Time) (string, error) {
+	at := schedule.OverEndTime
+	if at == "" {
+		at = schedule.EndTime
+	}
+	if at == "" {
+		at = "23:59:59"
+	}
+
+	clock, err := time.Parse("15:04:05", at)
+	if err != nil {
+		return "", fmt.Errorf("parsing over_end_time %q: %w", at, err)
+	}
+	offset := time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute + time.Duration(clock.Second())*time.Second + j.cfg.Delay
+
+	// The closing time of a day can fall on the next one, so step back
+	// until a day is found whose closing time has passed.
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, j.cfg.Location)
+	for day.Add(offset).After(now) {
+		day = day.AddDate(0, 0, -1)
+	}
+
+	return day.Format("2006-01-02"), nil
+}
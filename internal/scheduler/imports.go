@@ -0,0 +1,41 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// ImportStore clears what previewed imports leave behind.
+type ImportStore interface {
+	ClearImports(ctx context.Context) (int, error)
+}
+
+// ImportCleanupJob deletes the workbooks and password hashes of the user
+// imports that were applied or have expired.
+type ImportCleanupJob struct {
+	store ImportStore
+}
+
+// NewImportCleanupJob constructs the job.
+func NewImportCleanupJob(store ImportStore) *ImportCleanupJob {
+	return &ImportCleanupJob{store: store}
+}
+
+// Name implements Job.
+func (j *ImportCleanupJob) Name() string {
+	return "import_cleanup"
+}
+
+// Run implements Job.
+func (j *ImportCleanupJob) Run(ctx context.Context, now time.Time) error {
+	cleared, err := j.store.ClearImports(ctx)
+	if err != nil {
+		return err
+	}
+	if cleared > 0 {
+		slog.Info("scheduler: cleared import plans", "count", cleared)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package scheduler
+
+import (
+	"attendance/backend/internal/repository/postgres/attendance"
+	"attendance/backend/internal/repository/postgres/notification"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// ReminderStore provides the company working hours.
+type ReminderStore interface {
+	GetCompanySchedule(ctx context.Context) (attendance.CompanySchedule, error)
+}
+
+// Reminder sends the reminders of a kind that are due on a work day.
+type Reminder interface {
+	Remind(ctx context.Context, kind string, workDay string) (int, error)
+}
+
+// ReminderConfig configures the reminder job.
+type ReminderConfig struct {
+	// Days are the weekdays reminders are sent on.
+	Days []time.Weekday
+
+	// Location the company times are given in.
+	Location *time.Location
+}
+
+// ReminderJob reminds employees who did not check in by the company
+// late_time, and those still checked in at over_end_time.
+type ReminderJob struct {
+	cfg      ReminderConfig
+	store    ReminderStore
+	reminder Reminder
+
+	// sent holds the last work day each kind was sent for.
+	sent map[string]string
+}
+
+// NewReminderJob constructs the job.
+func NewReminderJob(cfg ReminderConfig, store ReminderStore, reminder Reminder) *ReminderJob {
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+
+	return &ReminderJob{cfg: cfg, store: store, reminder: reminder, sent: make(map[string]string)}
+}
+
+// Name implements Job.
+func (j *ReminderJob) Name() string {
+	return "reminders"
+}
+
+// Run implements Job.
+func (j *ReminderJob) Run(ctx context.Context, now time.Time) error {
+	now = now.In(j.cfg.Location)
+	if !j.workday(now.Weekday()) {
+		return nil
+	}
+
+	schedule, err := j.store.GetCompanySchedule(ctx)
+	if err != nil {
+		return err
+	}
+
+	late := schedule.LateTime
+	if late == "" {
+		late = schedule.StartTime
+	}
+	over := schedule.OverEndTime
+	if over == "" {
+		over = schedule.EndTime
+	}
+
+	// HH:MM:SS compares correctly as a string.
+	clock := now.Format("15:04:05")
+	day := now.Format("2006-01-02")
+
+	if late != "" && clock >= late && (over == "" || clock < over) {
+		if err := j.remind(ctx, notification.KindCheckInReminder, day); err != nil {
+			return err
+		}
+	}
+	if over != "" && clock >= over {
+		if err := j.remind(ctx, notification.KindCheckOutReminder, day); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (j *ReminderJob) remind(ctx context.Context, kind string, day string) error {
+	if j.sent[kind] == day {
+		return nil
+	}
+
+	count, err := j.reminder.Remind(ctx, kind, day)
+	if err != nil {
+		return err
+	}
+	j.sent[kind] = day
+
+	if count > 0 {
+		slog.Info("scheduler: sent reminders", "kind", kind, "work_day", day, "count", count)
+	}
+
+	return nil
+}
+
+func (j *ReminderJob) workday(day time.Weekday) bool {
+	for _, d := range j.cfg.Days {
+		if d == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseWeekdays parses weekday names such as "Mon" or "monday".
+func ParseWeekdays(names []string) ([]time.Weekday, error) {
+	days := make([]time.Weekday, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		found := false
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			full := strings.ToLower(d.String())
+			if name == full || name == full[:3] {
+				days = append(days, d)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown weekday %q", name)
+		}
+	}
+
+	return days, nil
+}
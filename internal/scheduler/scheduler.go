@@ -0,0 +1,67 @@
+// Package scheduler runs the periodic background jobs of the API inside the
+// process. Every replica runs the scheduler; jobs that must run once take a
+// database lock themselves.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+// Job is run on every tick of the scheduler and decides itself whether there
+// is work due at now.
+type Job interface {
+	Name() string
+	Run(ctx context.Context, now time.Time) error
+}
+
+// Scheduler runs jobs one after another on a fixed interval.
+type Scheduler struct {
+	interval time.Duration
+	jobs     []Job
+}
+
+// New constructs a scheduler ticking every interval.
+func New(interval time.Duration, jobs ...Job) *Scheduler {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	return &Scheduler{interval: interval, jobs: jobs}
+}
+
+// Run runs the jobs right away and then on every tick until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		for _, job := range s.jobs {
+			if ctx.Err() != nil {
+				return
+			}
+			s.run(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			slog.Error("scheduler: job panicked", "job", job.Name(), "error", fmt.Sprint(rec), "stack", string(debug.Stack()))
+		}
+	}()
+
+	if err := job.Run(ctx, time.Now()); err != nil && ctx.Err() == nil {
+		slog.Error("scheduler: job failed", "job", job.Name(), "error", err)
+	}
+}
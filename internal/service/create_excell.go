@@ -1,8 +1,10 @@
 package service
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/xuri/excelize/v2"
 )
@@ -20,7 +22,10 @@ type Employee struct {
 	Email          string // メールアドレス
 }
 
-func AddDataToExcel(employees []Employee, departments, positions []string) (string, error) {
+// AddDataToExcel writes the employees, and the departments and positions
+// they can be given, into the bundled employee list template, or a new
+// workbook when there is none, and returns the workbook.
+func AddDataToExcel(employees []Employee, departments, positions []string) ([]byte, error) {
 	templateFileName := "employee_list.xlsx"
 
 	var f *excelize.File
@@ -36,7 +41,7 @@ func AddDataToExcel(employees []Employee, departments, positions []string) (stri
 		// Open the existing template file
 		f, err = excelize.OpenFile(templateFileName)
 		if err != nil {
-			return "", fmt.Errorf("failed to open template file: %w", err)
+			return nil, fmt.Errorf("failed to open template file: %w", err)
 		}
 	}
 	defer f.Close()
@@ -48,7 +53,7 @@ func AddDataToExcel(employees []Employee, departments, positions []string) (stri
 	for i, header := range headers {
 		cell := fmt.Sprintf("%c1", 'A'+i)
 		if err := f.SetCellValue(employeeSheet, cell, header); err != nil {
-			return "", fmt.Errorf("failed to write header in Employees sheet: %w", err)
+			return nil, fmt.Errorf("failed to write header in Employees sheet: %w", err)
 		}
 	}
 
@@ -58,7 +63,7 @@ func AddDataToExcel(employees []Employee, departments, positions []string) (stri
 		for j, value := range values {
 			cell := fmt.Sprintf("%c%d", 'A'+j, row)
 			if err := f.SetCellValue(employeeSheet, cell, value); err != nil {
-				return "", fmt.Errorf("failed to write employee data: %w", err)
+				return nil, fmt.Errorf("failed to write employee data: %w", err)
 			}
 		}
 	}
@@ -68,7 +73,7 @@ func AddDataToExcel(employees []Employee, departments, positions []string) (stri
 	for i, dept := range departments {
 		cell := fmt.Sprintf("A%d", i+2) // Start from the first row
 		if err := f.SetCellValue(departmentSheet, cell, dept); err != nil {
-			return "", fmt.Errorf("failed to write department data: %w", err)
+			return nil, fmt.Errorf("failed to write department data: %w", err)
 		}
 	}
 
@@ -77,85 +82,140 @@ func AddDataToExcel(employees []Employee, departments, positions []string) (stri
 	for i, pos := range positions {
 		cell := fmt.Sprintf("A%d", i+2) // Start from the first row
 		if err := f.SetCellValue(positionSheet, cell, pos); err != nil {
-			return "", fmt.Errorf("failed to write position data: %w", err)
+			return nil, fmt.Errorf("failed to write position data: %w", err)
 		}
 	}
 
-	// Save the file
-	if err := f.SaveAs(templateFileName); err != nil {
-		return "", fmt.Errorf("failed to save the Excel file: %w", err)
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to save the Excel file: %w", err)
 	}
 
-	return "employee_list.xlsx", nil
+	return buf.Bytes(), nil
 
 }
 
-func SaveInvalidUsersToExcel(employees []Employee, departments, positions []string) (string, error) {
-	templateFileName := "employee_list.xlsx"
+// CellError is an error found on a cell of a workbook. Row and Column are
+// 1-based.
+type CellError struct {
+	Row     int
+	Column  int
+	Message string
+}
 
-	var f *excelize.File
+// BlankExcelColumn empties column, counted from 1, of every row of sheet but
+// the header.
+func BlankExcelColumn(workbook []byte, sheet string, column int) ([]byte, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(workbook))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workbook: %w", err)
+	}
+	defer f.Close()
 
-	// Check if file exists
-	if _, err := os.Stat(templateFileName); os.IsNotExist(err) {
-		// Create a new file if the template doesn't exist
-		f = excelize.NewFile()
-		f.NewSheet("従業員")
-		f.NewSheet("部署") // Departments
-		f.NewSheet("役職") // Positions
-	} else {
-		// Open the existing template file
-		f, err = excelize.OpenFile(templateFileName)
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sheet: %w", err)
+	}
+	for i, row := range rows {
+		if i == 0 || column > len(row) {
+			continue
+		}
+		cell, err := excelize.CoordinatesToCellName(column, i+1)
 		if err != nil {
-			return "", fmt.Errorf("failed to open template file: %w", err)
+			return nil, err
 		}
+		if err := f.SetCellValue(sheet, cell, ""); err != nil {
+			return nil, fmt.Errorf("failed to blank cell: %w", err)
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write workbook: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// AnnotateExcel returns a copy of workbook in which every cell of errs is
+// highlighted and carries its messages as a comment. The messages of each
+// row are also listed in an error column added after the last one of the
+// header.
+func AnnotateExcel(workbook []byte, sheet string, errs []CellError) ([]byte, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(workbook))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workbook: %w", err)
 	}
 	defer f.Close()
 
-	// Write Employee Data to the "Employees" sheet
-	employeeSheet := "従業員"
-	f.SetSheetName("Sheet1", employeeSheet)
-	headers := []string{"社員番号", "姓", "名", "表示名", "権限", "パスワード", "部署", "役職", "電話番号", "メールアドレス"}
-	for i, header := range headers {
-		cell := fmt.Sprintf("%c1", 'A'+i)
-		if err := f.SetCellValue(employeeSheet, cell, header); err != nil {
-			return "", fmt.Errorf("failed to write header in Employees sheet: %w", err)
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sheet: %w", err)
+	}
+	errorColumn := 1
+	if len(rows) > 0 {
+		errorColumn = len(rows[0]) + 1
+	}
+	header, _ := excelize.CoordinatesToCellName(errorColumn, 1)
+	if err := f.SetCellValue(sheet, header, "エラー"); err != nil {
+		return nil, fmt.Errorf("failed to write error header: %w", err)
+	}
+
+	cells := make(map[string][]string)
+	byRow := make(map[int][]string)
+	var order []string
+	for _, e := range errs {
+		cell, err := excelize.CoordinatesToCellName(e.Column, e.Row)
+		if err != nil {
+			return nil, err
 		}
+		if _, ok := cells[cell]; !ok {
+			order = append(order, cell)
+		}
+		cells[cell] = append(cells[cell], e.Message)
+		byRow[e.Row] = append(byRow[e.Row], e.Message)
 	}
 
-	for i, emp := range employees {
-		row := i + 2 // Start from the second row
-		values := []interface{}{emp.EmployeeID, emp.LastName, emp.FirstName, emp.NickName, emp.Role, emp.Password, emp.DepartmentName, emp.PositionName, emp.Phone, emp.Email}
-		for j, value := range values {
-			cell := fmt.Sprintf("%c%d", 'A'+j, row)
-			if err := f.SetCellValue(employeeSheet, cell, value); err != nil {
-				return "", fmt.Errorf("failed to write employee data: %w", err)
+	// Highlighted cells keep the rest of their style.
+	highlighted := make(map[int]int)
+	for _, cell := range order {
+		styleID, err := f.GetCellStyle(sheet, cell)
+		if err != nil {
+			return nil, err
+		}
+		id, ok := highlighted[styleID]
+		if !ok {
+			style, err := f.GetStyle(styleID)
+			if err != nil || style == nil {
+				style = &excelize.Style{}
 			}
+			style.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FFC7CE"}}
+			if id, err = f.NewStyle(style); err != nil {
+				return nil, fmt.Errorf("failed to create error style: %w", err)
+			}
+			highlighted[styleID] = id
+		}
+		if err := f.SetCellStyle(sheet, cell, cell, id); err != nil {
+			return nil, err
 		}
-	}
 
-	// Write Department Data to the "部署" sheet
-	departmentSheet := "部署"
-	for i, dept := range departments {
-		cell := fmt.Sprintf("A%d", i+2) // Start from the first row
-		if err := f.SetCellValue(departmentSheet, cell, dept); err != nil {
-			return "", fmt.Errorf("failed to write department data: %w", err)
+		_ = f.DeleteComment(sheet, cell)
+		if err := f.AddComment(sheet, excelize.Comment{Cell: cell, Author: "import", Text: strings.Join(cells[cell], "\n")}); err != nil {
+			return nil, fmt.Errorf("failed to add comment: %w", err)
 		}
 	}
 
-	// Write Position Data to the "役職" sheet
-	positionSheet := "役職"
-	for i, pos := range positions {
-		cell := fmt.Sprintf("A%d", i+2) // Start from the first row
-		if err := f.SetCellValue(positionSheet, cell, pos); err != nil {
-			return "", fmt.Errorf("failed to write position data: %w", err)
+	for row, messages := range byRow {
+		cell, _ := excelize.CoordinatesToCellName(errorColumn, row)
+		if err := f.SetCellValue(sheet, cell, strings.Join(messages, "\n")); err != nil {
+			return nil, fmt.Errorf("failed to write errors: %w", err)
 		}
 	}
 
-	// Save the file
-	if err := f.SaveAs(templateFileName); err != nil {
-		return "", fmt.Errorf("failed to save the Excel file: %w", err)
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write workbook: %w", err)
 	}
 
-	return "employee_list.xlsx", nil
-
+	return buf.Bytes(), nil
 }
@@ -0,0 +1,374 @@
+package hashing
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"attendance/backend/internal/pkg/export"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/xuri/excelize/v2"
+	textencoding "golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+)
+
+// Formats of bulk import and export files.
+const (
+	FormatExcel = "xlsx"
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Formats are the known formats.
+var Formats = []string{FormatExcel, FormatCSV, FormatJSONL}
+
+// Encodings of CSV files. JSON Lines are always UTF-8.
+const (
+	EncodingUTF8     = "utf-8"
+	EncodingShiftJIS = "shift_jis"
+)
+
+// Layout describes the table of a bulk file.
+type Layout struct {
+	// Sheet is the sheet read from and written to Excel files.
+	Sheet string
+
+	// ExcelByHeader takes the columns of Excel files by the names in their
+	// header as well, reading the first sheet when the workbook has no
+	// Sheet.
+	ExcelByHeader bool
+
+	// Header is the header row of the sheet, one title per column.
+	Header []string
+
+	// Columns maps every name a column is accepted under in the header of
+	// a CSV or JSON Lines file, lower case, to its position.
+	Columns map[string]int
+}
+
+// Table is a bulk file read into rows whose cells are in the column order of
+// the layout. Rows[0] is the header. Lines holds the line of the file every
+// row starts on, or 0 for Excel files.
+type Table struct {
+	Rows  [][]string
+	Lines []int
+}
+
+// DetectFormat returns format, or when it is empty the format the extension
+// of fileName stands for, Excel by default.
+func DetectFormat(format, fileName string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(fileName)) {
+		case ".csv":
+			return FormatCSV, nil
+		case ".jsonl", ".ndjson":
+			return FormatJSONL, nil
+		}
+		return FormatExcel, nil
+	}
+
+	for _, f := range Formats {
+		if strings.EqualFold(f, format) {
+			return f, nil
+		}
+	}
+
+	return "", web.NewCodeError(i18n.CodeFileFormat, http.StatusBadRequest, format)
+}
+
+// ReadTable reads a bulk file. Excel columns are taken by position unless
+// the layout says otherwise, CSV and JSON Lines columns by the names in their
+// header; unknown ones are ignored.
+// A CSV file in no given encoding is read as UTF-8 if it is valid UTF-8 and
+// as Shift_JIS otherwise. The apostrophes WriteTable puts before formula
+// characters are removed.
+func ReadTable(data []byte, format, encoding string, layout Layout) (Table, error) {
+	switch format {
+	case FormatExcel:
+		if layout.ExcelByHeader {
+			return readExcel(data, layout)
+		}
+		rows, err := ExcelSheetRows(bytes.NewReader(data), layout.Sheet)
+		if err != nil {
+			return Table{}, web.NewRequestError(errors.Wrap(err, "reading excel data"), http.StatusBadRequest)
+		}
+		return Table{Rows: rows, Lines: make([]int, len(rows))}, nil
+
+	case FormatCSV:
+		text, err := decode(data, encoding)
+		if err != nil {
+			return Table{}, err
+		}
+		return readCSV(text, layout)
+
+	case FormatJSONL:
+		return readJSONL(bytes.TrimPrefix(data, utf8BOM), layout)
+	}
+
+	return Table{}, web.NewCodeError(i18n.CodeFileFormat, http.StatusBadRequest, format)
+}
+
+// WriteTable writes rows under the header names in a CSV or JSON Lines file.
+// CSV cells are written with export.CSVCell; characters Shift_JIS lacks are
+// replaced.
+func WriteTable(w io.Writer, format, encoding string, header []string, rows [][]string) error {
+	switch format {
+	case FormatCSV:
+		var sw io.WriteCloser
+		switch strings.ToLower(encoding) {
+		case "", EncodingUTF8:
+		case EncodingShiftJIS:
+			sw = transform.NewWriter(w, textencoding.ReplaceUnsupported(japanese.ShiftJIS.NewEncoder()))
+			w = sw
+		default:
+			return web.NewCodeError(i18n.CodeFileEncoding, http.StatusBadRequest, encoding)
+		}
+
+		cw := csv.NewWriter(w)
+		if err := cw.Write(export.CSVRecord(header)); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := cw.Write(export.CSVRecord(row)); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+		if sw != nil {
+			return sw.Close()
+		}
+		return nil
+
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		for _, row := range rows {
+			object := make(map[string]string, len(header))
+			for i, name := range header {
+				if i < len(row) {
+					object[name] = row[i]
+				}
+			}
+			if err := enc.Encode(object); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return web.NewCodeError(i18n.CodeFileFormat, http.StatusBadRequest, format)
+}
+
+// TableWorkbook writes the rows of a table, header included, to the sheet of
+// a new workbook.
+func TableWorkbook(sheet string, rows [][]string) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return nil, err
+	}
+	for i, row := range rows {
+		cells := make([]interface{}, len(row))
+		for j, v := range row {
+			cells[j] = v
+		}
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.SetSheetRow(sheet, cell, &cells); err != nil {
+			return nil, err
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+func decode(data []byte, encoding string) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "":
+		if utf8.Valid(data) {
+			return bytes.TrimPrefix(data, utf8BOM), nil
+		}
+	case EncodingUTF8:
+		return bytes.TrimPrefix(data, utf8BOM), nil
+	case EncodingShiftJIS:
+	default:
+		return nil, web.NewCodeError(i18n.CodeFileEncoding, http.StatusBadRequest, encoding)
+	}
+
+	text, _, err := transform.Bytes(japanese.ShiftJIS.NewDecoder(), data)
+	if err != nil {
+		return nil, web.NewRequestError(errors.Wrap(err, "decoding shift_jis"), http.StatusBadRequest)
+	}
+
+	return text, nil
+}
+
+func readCSV(text []byte, layout Layout) (Table, error) {
+	r := csv.NewReader(bytes.NewReader(text))
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err != nil {
+		if err == io.EOF {
+			return Table{Rows: [][]string{layout.Header}, Lines: []int{0}}, nil
+		}
+		return Table{}, web.NewRequestError(errors.Wrap(err, "reading csv header"), http.StatusBadRequest)
+	}
+	positions, err := positions(header, layout)
+	if err != nil {
+		return Table{}, err
+	}
+
+	table := Table{Rows: [][]string{layout.Header}, Lines: []int{1}}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Table{}, web.NewRequestError(errors.Wrap(err, "reading csv"), http.StatusBadRequest)
+		}
+		line, _ := r.FieldPos(0)
+		for i, cell := range record {
+			record[i] = export.CSVValue(cell)
+		}
+
+		table.Rows = append(table.Rows, arrange(record, positions, layout))
+		table.Lines = append(table.Lines, line)
+	}
+
+	return table, nil
+}
+
+// readExcel reads the sheet of a workbook by its header. Lines are the
+// numbers of the sheet rows.
+func readExcel(data []byte, layout Layout) (Table, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return Table{}, web.NewRequestError(errors.Wrap(err, "reading excel data"), http.StatusBadRequest)
+	}
+	defer f.Close()
+
+	sheet := layout.Sheet
+	if idx, err := f.GetSheetIndex(sheet); err != nil || idx < 0 {
+		sheet = f.GetSheetName(0)
+	}
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return Table{}, web.NewRequestError(errors.Wrap(err, "reading excel data"), http.StatusBadRequest)
+	}
+
+	table := Table{Rows: [][]string{layout.Header}, Lines: []int{1}}
+	if len(rows) == 0 {
+		return table, nil
+	}
+	positions, err := positions(rows[0], layout)
+	if err != nil {
+		return Table{}, err
+	}
+
+	for i, record := range rows[1:] {
+		table.Rows = append(table.Rows, arrange(record, positions, layout))
+		table.Lines = append(table.Lines, i+2)
+	}
+
+	return table, nil
+}
+
+func readJSONL(text []byte, layout Layout) (Table, error) {
+	table := Table{Rows: [][]string{layout.Header}, Lines: []int{0}}
+
+	for i, line := range bytes.Split(text, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var object map[string]interface{}
+		d := json.NewDecoder(bytes.NewReader(line))
+		d.UseNumber()
+		if err := d.Decode(&object); err != nil || object == nil {
+			return Table{}, web.NewCodeError(i18n.CodeFileLine, http.StatusBadRequest, i+1)
+		}
+
+		row := make([]string, len(layout.Header))
+		var known bool
+		for key, value := range object {
+			p, ok := layout.Columns[strings.ToLower(strings.TrimSpace(key))]
+			if !ok {
+				continue
+			}
+			known = true
+			switch v := value.(type) {
+			case nil:
+			case string:
+				row[p] = v
+			default:
+				row[p] = fmt.Sprint(v)
+			}
+		}
+		if !known {
+			return Table{}, web.NewCodeError(i18n.CodeFileLine, http.StatusBadRequest, i+1)
+		}
+
+		table.Rows = append(table.Rows, row)
+		table.Lines = append(table.Lines, i+1)
+	}
+
+	return table, nil
+}
+
+// arrange puts the cells of a record in the column order of the layout.
+func arrange(record []string, positions []int, layout Layout) []string {
+	row := make([]string, len(layout.Header))
+	for i, p := range positions {
+		if p >= 0 && i < len(record) {
+			row[p] = record[i]
+		}
+	}
+
+	return row
+}
+
+// positions maps every column of a header to its position in the layout, -1
+// for unknown columns.
+func positions(header []string, layout Layout) ([]int, error) {
+	positions := make([]int, len(header))
+	var known bool
+	for i, name := range header {
+		p, ok := layout.Columns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			positions[i] = -1
+			continue
+		}
+		positions[i] = p
+		known = true
+	}
+	if !known {
+		return nil, web.NewCodeError(i18n.CodeFileHeader, http.StatusBadRequest)
+	}
+
+	return positions, nil
+}
@@ -0,0 +1,215 @@
+package hashing
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+)
+
+var testLayout = Layout{
+	Header:  []string{"Name", "Email", "Phone"},
+	Columns: map[string]int{"name": 0, "email": 1, "mail": 1, "phone": 2},
+}
+
+func shiftJIS(t *testing.T, s string) []byte {
+	t.Helper()
+	b, _, err := transform.Bytes(japanese.ShiftJIS.NewEncoder(), []byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestReadTable(t *testing.T) {
+	header := []string{"Name", "Email", "Phone"}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		format   string
+		encoding string
+		rows     [][]string
+		lines    []int
+		code     string
+	}{
+		{
+			name:   "csv by header",
+			data:   []byte("Phone, MAIL ,unknown,name\n+998,a@b.c,x,Tanaka\n"),
+			format: FormatCSV,
+			rows:   [][]string{header, {"Tanaka", "a@b.c", "+998"}},
+			lines:  []int{1, 2},
+		},
+		{
+			name:   "csv with bom and quoted newline",
+			data:   []byte("\xef\xbb\xbfname,email\n\"a\nb\",x@y.z\nc,\n"),
+			format: FormatCSV,
+			rows:   [][]string{header, {"a\nb", "x@y.z", ""}, {"c", "", ""}},
+			lines:  []int{1, 2, 4},
+		},
+		{
+			name:   "csv escaped formula",
+			data:   []byte("name,phone\n'=1+2,'+998 90\n"),
+			format: FormatCSV,
+			rows:   [][]string{header, {"=1+2", "", "+998 90"}},
+			lines:  []int{1, 2},
+		},
+		{
+			name:   "csv shift_jis detected",
+			data:   shiftJIS(t, "name\n田中\n"),
+			format: FormatCSV,
+			rows:   [][]string{header, {"田中", "", ""}},
+			lines:  []int{1, 2},
+		},
+		{
+			name:     "csv shift_jis given",
+			data:     shiftJIS(t, "name\nｱｲｳ\n"),
+			format:   FormatCSV,
+			encoding: "Shift_JIS",
+			rows:     [][]string{header, {"ｱｲｳ", "", ""}},
+			lines:    []int{1, 2},
+		},
+		{
+			name:   "csv empty",
+			format: FormatCSV,
+			rows:   [][]string{header},
+			lines:  []int{0},
+		},
+		{
+			name:   "csv unknown header",
+			data:   []byte("a,b\n1,2\n"),
+			format: FormatCSV,
+			code:   i18n.CodeFileHeader,
+		},
+		{
+			name:     "unknown encoding",
+			data:     []byte("name\n"),
+			format:   FormatCSV,
+			encoding: "latin1",
+			code:     i18n.CodeFileEncoding,
+		},
+		{
+			name:   "jsonl",
+			data:   []byte("{\"name\":\"Tanaka\",\"phone\":998}\n\n{\"Email\":\"a@b.c\",\"extra\":true,\"name\":null}\n"),
+			format: FormatJSONL,
+			rows:   [][]string{header, {"Tanaka", "", "998"}, {"", "a@b.c", ""}},
+			lines:  []int{0, 1, 3},
+		},
+		{
+			name:   "jsonl bad line",
+			data:   []byte("{\"name\":\"a\"}\n[1]\n"),
+			format: FormatJSONL,
+			code:   i18n.CodeFileLine,
+		},
+		{
+			name:   "jsonl unknown keys",
+			data:   []byte("{\"other\":\"a\"}\n"),
+			format: FormatJSONL,
+			code:   i18n.CodeFileLine,
+		},
+		{
+			name:   "unknown format",
+			format: "xml",
+			code:   i18n.CodeFileFormat,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := ReadTable(tt.data, tt.format, tt.encoding, testLayout)
+			if tt.code != "" {
+				var webErr *web.Error
+				if !errors.As(err, &webErr) || webErr.Code != tt.code {
+					t.Fatalf("got error %v, want code %s", err, tt.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadTable: %v", err)
+			}
+			if !reflect.DeepEqual(table.Rows, tt.rows) {
+				t.Errorf("rows %q, want %q", table.Rows, tt.rows)
+			}
+			if !reflect.DeepEqual(table.Lines, tt.lines) {
+				t.Errorf("lines %v, want %v", table.Lines, tt.lines)
+			}
+		})
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	rows := [][]string{{"=cmd", "a@b.c", "+998 90"}, {"田中 🙂", "", "-1"}}
+
+	tests := []struct {
+		name     string
+		format   string
+		encoding string
+		want     string
+		back     [][]string
+	}{
+		{
+			name:   "csv",
+			format: FormatCSV,
+			want:   "Name,Email,Phone\n'=cmd,a@b.c,'+998 90\n田中 🙂,,-1\n",
+			back:   rows,
+		},
+		{
+			name:     "csv shift_jis",
+			format:   FormatCSV,
+			encoding: EncodingShiftJIS,
+			want:     string(shiftJIS(t, "Name,Email,Phone\n'=cmd,a@b.c,'+998 90\n田中 ")) + "\x1a,,-1\n",
+			back:     [][]string{rows[0], {"田中 \x1a", "", "-1"}},
+		},
+		{
+			name:   "jsonl",
+			format: FormatJSONL,
+			want:   "{\"Email\":\"a@b.c\",\"Name\":\"=cmd\",\"Phone\":\"+998 90\"}\n{\"Email\":\"\",\"Name\":\"田中 🙂\",\"Phone\":\"-1\"}\n",
+			back:   rows,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteTable(&buf, tt.format, tt.encoding, testLayout.Header, rows); err != nil {
+				t.Fatalf("WriteTable: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("wrote %q, want %q", got, tt.want)
+			}
+
+			table, err := ReadTable(buf.Bytes(), tt.format, tt.encoding, testLayout)
+			if err != nil {
+				t.Fatalf("ReadTable: %v", err)
+			}
+			if !reflect.DeepEqual(table.Rows[1:], tt.back) {
+				t.Errorf("read back %q, want %q", table.Rows[1:], tt.back)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		format, file, want string
+	}{
+		{"", "users.CSV", FormatCSV},
+		{"", "users.ndjson", FormatJSONL},
+		{"", "users.xlsx", FormatExcel},
+		{"", "users", FormatExcel},
+		{"JSONL", "users.csv", FormatJSONL},
+	}
+	for _, tt := range tests {
+		if got, err := DetectFormat(tt.format, tt.file); err != nil || got != tt.want {
+			t.Errorf("DetectFormat(%q, %q) = %q, %v, want %q", tt.format, tt.file, got, err, tt.want)
+		}
+	}
+	if _, err := DetectFormat("xml", "users.xml"); err == nil || !strings.Contains(err.Error(), "xml") {
+		t.Errorf("DetectFormat(xml) = %v, want an error naming the format", err)
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"attendance/backend/internal/upload"
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+
+	"golang.org/x/image/draw"
+)
+
+// Sizes of profile photos, which are square.
+const (
+	PhotoSize     = 512
+	ThumbnailSize = 160
+)
+
+// ErrCrop is returned for crops that do not lie within the photo.
+var ErrCrop = errors.New("crop outside the photo")
+
+// Crop is a square of a photo, in pixels of the photo as it is shown, that
+// is once turned upright.
+type Crop struct {
+	X    int
+	Y    int
+	Size int
+}
+
+// MakePhoto turns an uploaded photo upright, crops it to crop, or to the
+// largest centred square when crop is nil, and returns it as a JPEG photo
+// and thumbnail. Transparent parts are made white.
+func MakePhoto(file upload.File, crop *Crop) (photo, thumbnail []byte, err error) {
+	src, _, err := image.Decode(bytes.NewReader(file.Data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if file.Orientation >= 5 {
+		width, height = height, width
+	}
+
+	if crop == nil {
+		size := min(width, height)
+		crop = &Crop{X: (width - size) / 2, Y: (height - size) / 2, Size: size}
+	}
+	if crop.Size <= 0 || crop.X < 0 || crop.Y < 0 || crop.X+crop.Size > width || crop.Y+crop.Size > height {
+		return nil, nil, ErrCrop
+	}
+
+	// The crop is cut from the photo as it is stored and scaled down first,
+	// so only the small square is turned upright.
+	x0, y0 := upright(file.Orientation, crop.X, crop.Y, bounds.Dx(), bounds.Dy())
+	x1, y1 := upright(file.Orientation, crop.X+crop.Size-1, crop.Y+crop.Size-1, bounds.Dx(), bounds.Dy())
+	cut := image.Rect(min(x0, x1), min(y0, y1), max(x0, x1)+1, max(y0, y1)+1).Add(bounds.Min)
+
+	size := min(crop.Size, PhotoSize)
+	square := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(square, square.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(square, square.Bounds(), src, cut, draw.Over, nil)
+	square = turn(square, file.Orientation)
+
+	if photo, err = scaleJPEG(square, PhotoSize); err != nil {
+		return nil, nil, err
+	}
+	if thumbnail, err = scaleJPEG(square, ThumbnailSize); err != nil {
+		return nil, nil, err
+	}
+
+	return photo, thumbnail, nil
+}
+
+// upright returns the pixel of an image stored with the EXIF orientation o,
+// w by h pixels large, that is shown at x, y.
+func upright(o, x, y, w, h int) (int, int) {
+	switch o {
+	case 2:
+		return w - 1 - x, y
+	case 3:
+		return w - 1 - x, h - 1 - y
+	case 4:
+		return x, h - 1 - y
+	case 5:
+		return y, x
+	case 6:
+		return y, h - 1 - x
+	case 7:
+		return w - 1 - y, h - 1 - x
+	case 8:
+		return w - 1 - y, x
+	}
+
+	return x, y
+}
+
+// turn returns the square img, stored with the EXIF orientation o, upright.
+func turn(img *image.RGBA, o int) *image.RGBA {
+	if o < 2 || o > 8 {
+		return img
+	}
+
+	size := img.Bounds().Dx()
+	turned := image.NewRGBA(img.Bounds())
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			sx, sy := upright(o, x, y, size, size)
+			copy(turned.Pix[turned.PixOffset(x, y):][:4], img.Pix[img.PixOffset(sx, sy):][:4])
+		}
+	}
+
+	return turned
+}
+
+// scaleJPEG encodes img scaled down to size, or as it is when smaller.
+func scaleJPEG(img *image.RGBA, size int) ([]byte, error) {
+	if img.Bounds().Dx() > size {
+		scaled := image.NewRGBA(image.Rect(0, 0, size, size))
+		draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, img.Bounds(), draw.Src, nil)
+		img = scaled
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
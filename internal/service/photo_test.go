@@ -0,0 +1,114 @@
+package service
+
+import (
+	"attendance/backend/internal/upload"
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// testPhoto is 64 by 32 pixels of eight 16 pixel blocks, each of its own
+// colour; its top left block is transparent.
+func testPhoto(t *testing.T) (image.Image, []byte) {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 64; x++ {
+			block := y/16*4 + x/16
+			c := color.NRGBA{R: uint8(block & 1 * 255), G: uint8(block >> 1 & 1 * 255), B: uint8(block >> 2 * 255), A: 255}
+			if block == 0 {
+				c = color.NRGBA{}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return img, buf.Bytes()
+}
+
+func TestMakePhoto(t *testing.T) {
+	src, data := testPhoto(t)
+
+	tests := []struct {
+		name        string
+		orientation int
+		crop        *Crop
+	}{
+		{"centred", 1, nil},
+		{"crop", 1, &Crop{X: 0, Y: 0, Size: 32}},
+		{"mirrored", 2, &Crop{X: 32, Y: 0, Size: 32}},
+		{"upside down", 3, nil},
+		{"transposed", 5, &Crop{X: 0, Y: 32, Size: 32}},
+		{"turned right", 6, nil},
+		{"turned left", 8, &Crop{X: 0, Y: 0, Size: 32}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crop := tt.crop
+			if crop == nil {
+				crop = &Crop{X: 16, Y: 0, Size: 32}
+				if tt.orientation >= 5 {
+					crop = &Crop{X: 0, Y: 16, Size: 32}
+				}
+			}
+
+			photo, thumbnail, err := MakePhoto(upload.File{Data: data, Orientation: tt.orientation}, tt.crop)
+			if err != nil {
+				t.Fatalf("MakePhoto: %v", err)
+			}
+			if _, err := jpeg.Decode(bytes.NewReader(thumbnail)); err != nil {
+				t.Fatalf("decoding thumbnail: %v", err)
+			}
+			img, err := jpeg.Decode(bytes.NewReader(photo))
+			if err != nil {
+				t.Fatalf("decoding photo: %v", err)
+			}
+			if img.Bounds() != image.Rect(0, 0, 32, 32) {
+				t.Fatalf("photo is %v", img.Bounds())
+			}
+
+			for _, p := range []image.Point{{8, 8}, {24, 8}, {8, 24}, {24, 24}} {
+				sx, sy := upright(tt.orientation, crop.X+p.X, crop.Y+p.Y, 64, 32)
+				want := src.At(sx, sy).(color.NRGBA)
+				if want.A == 0 {
+					want = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+				}
+				if got := img.At(p.X, p.Y); !near(got, want) {
+					t.Errorf("pixel %v is %v, want %v", p, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMakePhotoCrop(t *testing.T) {
+	_, data := testPhoto(t)
+
+	for _, crop := range []Crop{{X: 40, Y: 0, Size: 32}, {X: -1, Y: 0, Size: 8}, {X: 0, Y: 0, Size: 0}} {
+		if _, _, err := MakePhoto(upload.File{Data: data, Orientation: 1}, &crop); err != ErrCrop {
+			t.Errorf("crop %+v: got %v, want ErrCrop", crop, err)
+		}
+	}
+	if _, _, err := MakePhoto(upload.File{Data: data, Orientation: 6}, &Crop{X: 0, Y: 32, Size: 32}); err != nil {
+		t.Errorf("crop of a turned photo: %v", err)
+	}
+}
+
+func near(c color.Color, want color.NRGBA) bool {
+	r, g, b, _ := c.RGBA()
+	for _, d := range []int{int(r>>8) - int(want.R), int(g>>8) - int(want.G), int(b>>8) - int(want.B)} {
+		if d < -48 || d > 48 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -1,18 +1,14 @@
 package service
 
 import (
-	"errors"
-	"fmt"
-	"io"
-	"log"
+	"attendance/backend/internal/storage"
+	"attendance/backend/internal/upload"
+	"bytes"
+	"context"
 	"mime/multipart"
-	"os"
-	"path/filepath"
 	"time"
 )
 
-const baseDir = "statics"
-
 func InArray[T comparable](val T, array []T) bool {
 	for _, v := range array {
 		if val == v {
@@ -22,59 +18,23 @@ func InArray[T comparable](val T, array []T) bool {
 	return false
 }
 
-// Upload - uploads file to the specified folder
-func Upload(file *multipart.FileHeader, folder string) (path string, err error) {
-	targetPath := filepath.Join(baseDir, folder)
+// Upload - checks file for purpose, uploads it to the specified folder of
+// files and returns the link the file is served under. Files that fail the
+// checks are upload errors, see upload.RequestError.
+func Upload(ctx context.Context, files storage.Storage, purpose upload.Purpose, file *multipart.FileHeader, folder string) (path string, err error) {
 	if file == nil {
 		return "", nil
 	}
 
-	// Updated to include Excel MIME types
-	expectedContentType := []string{
-		"image/jpeg",
-		"image/png",
-		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", // for .xlsx files
-		"application/vnd.ms-excel", // for .xls files
-	}
-
-	incomeContentType := file.Header.Get("Content-Type")
-	if !InArray(incomeContentType, expectedContentType) {
-		return "", fmt.Errorf("invalid file type, expected: %v, got: %s", expectedContentType, incomeContentType)
-	}
-
-	if _, err := os.Stat(targetPath); errors.Is(err, os.ErrNotExist) {
-		err = os.MkdirAll(targetPath, os.ModePerm)
-		if err != nil {
-			return "", err
-		}
-	}
-
-	filepath := filepath.Join(targetPath, time.Now().Format(time.RFC3339)+"-"+file.Filename)
-
-	src, err := file.Open()
-	if err != nil {
-		return "", err
-	}
-	defer func() {
-		if closeErr := src.Close(); closeErr != nil {
-			log.Println("file upload src.Close() error:", closeErr)
-		}
-	}()
-
-	out, err := os.Create(filepath)
+	checked, err := upload.Read(purpose, file)
 	if err != nil {
 		return "", err
 	}
-	defer func() {
-		if closeErr := out.Close(); closeErr != nil {
-			log.Println("file upload out.Close() error:", closeErr)
-		}
-	}()
 
-	_, err = io.Copy(out, src)
-	if err != nil {
+	key := folder + "/" + time.Now().Format("2006-01-02") + "/" + checked.Name
+	if err := files.Put(ctx, key, bytes.NewReader(checked.Data), checked.Type.ContentType); err != nil {
 		return "", err
 	}
 
-	return filepath, nil
+	return "/media/" + key, nil
 }
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Local stores files in a directory of the local file system.
+type Local struct {
+	dir string
+}
+
+// NewLocal constructs a Local storing files in dir, creating it if needed.
+func NewLocal(dir string) (*Local, error) {
+	if dir == "" {
+		dir = "./media"
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	return &Local{dir: dir}, nil
+}
+
+func (l *Local) path(key string) (string, string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	return key, filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+// Put implements Storage. The file is written aside and renamed into place,
+// so readers never see it half written.
+func (l *Local) Put(_ context.Context, key string, body io.Reader, _ string) error {
+	_, name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+// Get implements Storage. The body is an *os.File.
+func (l *Local) Get(_ context.Context, key string) (io.ReadCloser, Object, error) {
+	key, name, err := l.path(key)
+	if err != nil {
+		return nil, Object{}, err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, Object{}, localError(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, Object{}, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, Object{}, ErrNotFound
+	}
+
+	return f, localObject(key, info), nil
+}
+
+// Stat implements Storage.
+func (l *Local) Stat(_ context.Context, key string) (Object, error) {
+	key, name, err := l.path(key)
+	if err != nil {
+		return Object{}, err
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return Object{}, localError(err)
+	}
+	if info.IsDir() {
+		return Object{}, ErrNotFound
+	}
+
+	return localObject(key, info), nil
+}
+
+// Delete implements Storage. Deleting a missing file is not an error.
+func (l *Local) Delete(_ context.Context, key string) error {
+	_, name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func localObject(key string, info fs.FileInfo) Object {
+	return Object{
+		Key:         key,
+		Size:        info.Size(),
+		ContentType: ContentType(key),
+		ModTime:     info.ModTime(),
+	}
+}
+
+func localError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+
+	return err
+}
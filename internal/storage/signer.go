@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrLinkRequired is returned for private files requested without a
+	// signed link.
+	ErrLinkRequired = errors.New("storage: signed link required")
+
+	// ErrLinkInvalid is returned for links whose signature does not match.
+	ErrLinkInvalid = errors.New("storage: invalid link")
+
+	// ErrLinkExpired is returned for links past their expiry.
+	ErrLinkExpired = errors.New("storage: link expired")
+)
+
+// Query parameters of signed links.
+const (
+	paramExpires   = "expires"
+	paramUser      = "uid"
+	paramSignature = "signature"
+)
+
+// SignerConfig configures a Signer.
+type SignerConfig struct {
+	// Secret is the key of the HMAC. Every instance must share it.
+	Secret []byte
+
+	// TTL is how long links stay valid when no other lifetime is asked for.
+	TTL time.Duration
+
+	// Public lists the directories whose files are served without a link,
+	// like the company logo shown before signing in.
+	Public []string
+}
+
+// Link is a verified link.
+type Link struct {
+	Key string
+
+	// UserID is the user the link is bound to, or zero.
+	UserID int
+
+	Expires time.Time
+}
+
+// Signer signs and verifies expiring links to stored files. A link is
+// /media/<key>?expires=<unix time>&signature=<HMAC-SHA256>, with uid=<user>
+// added when it is bound to a user.
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+	public []string
+	now    func() time.Time
+}
+
+// NewSigner constructs a Signer. Links are valid for 15 minutes by default.
+func NewSigner(cfg SignerConfig) (*Signer, error) {
+	if len(cfg.Secret) < 16 {
+		return nil, errors.New("storage: signing secret shorter than 16 bytes")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 15 * time.Minute
+	}
+
+	public := make([]string, 0, len(cfg.Public))
+	for _, dir := range cfg.Public {
+		if dir, err := CleanKey(dir); err == nil {
+			public = append(public, dir+"/")
+		}
+	}
+
+	return &Signer{secret: cfg.Secret, ttl: cfg.TTL, public: public, now: time.Now}, nil
+}
+
+// Public reports whether key is served without a link.
+func (s *Signer) Public(key string) bool {
+	key, err := CleanKey(key)
+	if err != nil {
+		return false
+	}
+	for _, dir := range s.public {
+		if strings.HasPrefix(key, dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// URL returns the link to key, valid for at least ttl or the default
+// lifetime when ttl is not positive. A link bound to userID, when it is not
+// zero, only opens for requests authenticated as that user.
+// Links made within a quarter of ttl share their expiry, so clients can
+// cache what they point to.
+func (s *Signer) URL(key string, userID int, ttl time.Duration) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	if ttl <= 0 {
+		ttl = s.ttl
+	}
+	step := ttl / 4
+	if step < time.Second {
+		step = time.Second
+	}
+
+	expires := strconv.FormatInt(s.now().Truncate(step).Add(ttl+step).Unix(), 10)
+	user := ""
+	if userID != 0 {
+		user = strconv.Itoa(userID)
+	}
+
+	query := url.Values{}
+	query.Set(paramExpires, expires)
+	if user != "" {
+		query.Set(paramUser, user)
+	}
+	query.Set(paramSignature, base64.RawURLEncoding.EncodeToString(s.sign(key, expires, user)))
+
+	return "/media/" + uriEncode(key, false) + "?" + query.Encode(), nil
+}
+
+// Signed reports whether query carries a signature at all.
+func Signed(query url.Values) bool {
+	return query.Has(paramSignature)
+}
+
+// Verify checks the link to key given by query.
+func (s *Signer) Verify(key string, query url.Values) (Link, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return Link{}, err
+	}
+	if !Signed(query) {
+		return Link{}, ErrLinkRequired
+	}
+
+	expires := query.Get(paramExpires)
+	user := query.Get(paramUser)
+	signature, err := base64.RawURLEncoding.DecodeString(query.Get(paramSignature))
+	if err != nil {
+		return Link{}, ErrLinkInvalid
+	}
+	if !hmac.Equal(signature, s.sign(key, expires, user)) {
+		return Link{}, ErrLinkInvalid
+	}
+
+	// The values are only parsed once they are known to be ours.
+	deadline, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return Link{}, ErrLinkInvalid
+	}
+	if s.now().Unix() > deadline {
+		return Link{}, ErrLinkExpired
+	}
+
+	link := Link{Key: key, Expires: time.Unix(deadline, 0)}
+	if user != "" {
+		if link.UserID, err = strconv.Atoi(user); err != nil || link.UserID == 0 {
+			return Link{}, ErrLinkInvalid
+		}
+	}
+
+	return link, nil
+}
+
+func (s *Signer) sign(key, expires, user string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte("media\n" + key + "\n" + expires + "\n" + user))
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSigner(t *testing.T, now *time.Time) *Signer {
+	t.Helper()
+	s, err := NewSigner(SignerConfig{Secret: []byte("0123456789abcdef"), TTL: time.Hour, Public: []string{"/logo/", "../x"}})
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	s.now = func() time.Time { return *now }
+
+	return s
+}
+
+// parseLink splits a link into the key and query Verify takes.
+func parseLink(t *testing.T, link string) (string, url.Values) {
+	t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", link, err)
+	}
+
+	return strings.TrimPrefix(u.Path, "/media/"), u.Query()
+}
+
+func TestSignerVerify(t *testing.T) {
+	now := time.Date(2024, time.May, 15, 9, 7, 30, 0, time.UTC)
+	s := testSigner(t, &now)
+
+	bound, err := s.URL("/photos/7/a b.jpg", 7, 0)
+	if err != nil {
+		t.Fatalf("URL: %v", err)
+	}
+	unbound, err := s.URL("photos/7/a b.jpg", 0, 0)
+	if err != nil {
+		t.Fatalf("URL: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		link   string
+		change func(key string, q url.Values) string
+		after  time.Duration
+		user   int
+		err    error
+	}{
+		{name: "bound", link: bound, user: 7},
+		{name: "unbound", link: unbound},
+		{name: "valid for the ttl", link: bound, after: time.Hour, user: 7},
+		{name: "expired", link: bound, after: time.Hour + 16*time.Minute, err: ErrLinkExpired},
+		{name: "other key", link: bound, err: ErrLinkInvalid, change: func(key string, q url.Values) string {
+			return "photos/8/a b.jpg"
+		}},
+		{name: "later expiry", link: bound, err: ErrLinkInvalid, change: func(key string, q url.Values) string {
+			q.Set(paramExpires, "9999999999")
+			return key
+		}},
+		{name: "other user", link: bound, err: ErrLinkInvalid, change: func(key string, q url.Values) string {
+			q.Set(paramUser, "8")
+			return key
+		}},
+		{name: "user removed", link: bound, err: ErrLinkInvalid, change: func(key string, q url.Values) string {
+			q.Del(paramUser)
+			return key
+		}},
+		{name: "user added", link: unbound, err: ErrLinkInvalid, change: func(key string, q url.Values) string {
+			q.Set(paramUser, "7")
+			return key
+		}},
+		{name: "bad signature", link: bound, err: ErrLinkInvalid, change: func(key string, q url.Values) string {
+			q.Set(paramSignature, "!!")
+			return key
+		}},
+		{name: "no signature", link: bound, err: ErrLinkRequired, change: func(key string, q url.Values) string {
+			q.Del(paramSignature)
+			return key
+		}},
+		{name: "bad key", link: bound, err: ErrInvalidKey, change: func(key string, q url.Values) string {
+			return "photos/../secret"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, query := parseLink(t, tt.link)
+			if tt.change != nil {
+				key = tt.change(key, query)
+			}
+
+			verifier := testSigner(t, &now)
+			at := now.Add(tt.after)
+			verifier.now = func() time.Time { return at }
+
+			link, err := verifier.Verify(key, query)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Verify: got %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if link.Key != "photos/7/a b.jpg" || link.UserID != tt.user {
+				t.Errorf("got link %+v, want user %d", link, tt.user)
+			}
+			if link.Expires.Before(now.Add(time.Hour)) {
+				t.Errorf("link expires at %v, before the ttl", link.Expires)
+			}
+		})
+	}
+}
+
+func TestSignerSharesExpiry(t *testing.T) {
+	now := time.Date(2024, time.May, 15, 9, 0, 0, 0, time.UTC)
+	s := testSigner(t, &now)
+
+	first, _ := s.URL("photos/1.jpg", 0, 0)
+	now = now.Add(14 * time.Minute)
+	second, _ := s.URL("photos/1.jpg", 0, 0)
+	now = now.Add(2 * time.Minute)
+	third, _ := s.URL("photos/1.jpg", 0, 0)
+
+	if first != second {
+		t.Errorf("links within a quarter of the ttl differ: %q, %q", first, second)
+	}
+	if second == third {
+		t.Errorf("links a quarter of the ttl apart are the same: %q", third)
+	}
+}
+
+func TestSignerPublic(t *testing.T) {
+	now := time.Now()
+	s := testSigner(t, &now)
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"logo/company.png", true},
+		{"/logo/company.png", true},
+		{"logo", false},
+		{"logos/company.png", false},
+		{"logo/../photos/1.jpg", false},
+		{"x/1.png", false},
+		{"photos/1.jpg", false},
+	}
+	for _, tt := range tests {
+		if got := s.Public(tt.key); got != tt.want {
+			t.Errorf("Public(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestNewSignerShortSecret(t *testing.T) {
+	if _, err := NewSigner(SignerConfig{Secret: []byte("short")}); err == nil {
+		t.Error("NewSigner took a secret of 5 bytes")
+	}
+}
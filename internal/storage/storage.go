@@ -0,0 +1,159 @@
+// Package storage keeps the files of the API, uploads and generated files
+// alike, in a store shared by every instance. Files are addressed by keys,
+// slash separated relative paths such as "company_info/2024-05-01/logo.png".
+//
+// Local keeps them in a directory, which is enough for a single instance or
+// a shared volume; S3 keeps them in a bucket of any S3 compatible service.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Drivers.
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+var (
+	// ErrNotFound is returned for keys nothing is stored under.
+	ErrNotFound = errors.New("storage: object not found")
+
+	// ErrInvalidKey is returned for keys that are empty, absolute or leave
+	// the store.
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// Object describes a stored file.
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage stores files by key. Put replaces the file stored under the key.
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, Object, error)
+	Stat(ctx context.Context, key string) (Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Config selects and configures the storage.
+type Config struct {
+	// Driver is DriverLocal or DriverS3.
+	Driver string
+
+	// Dir is the directory of DriverLocal.
+	Dir string
+
+	// S3 configures DriverS3.
+	S3 S3Config
+}
+
+// New constructs the storage cfg selects.
+func New(cfg Config) (Storage, error) {
+	switch cfg.Driver {
+	case "", DriverLocal:
+		return NewLocal(cfg.Dir)
+	case DriverS3:
+		return NewS3(cfg.S3, nil)
+	}
+
+	return nil, fmt.Errorf("storage: unknown driver %q", cfg.Driver)
+}
+
+// CleanKey returns key in canonical form, or ErrInvalidKey.
+func CleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(key, "/")
+	if key == "" || strings.ContainsAny(key, "\\\x00") {
+		return "", ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." {
+			return "", ErrInvalidKey
+		}
+	}
+
+	key = path.Clean(key)
+	if key == "." {
+		return "", ErrInvalidKey
+	}
+
+	return key, nil
+}
+
+// ContentType returns the content type of a key by its extension.
+func ContentType(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+
+	return "application/octet-stream"
+}
+
+// PutBytes stores data under key.
+func PutBytes(ctx context.Context, s Storage, key string, data []byte, contentType string) error {
+	return s.Put(ctx, key, bytes.NewReader(data), contentType)
+}
+
+// ReadAll returns the file stored under key.
+func ReadAll(ctx context.Context, s Storage, key string) ([]byte, error) {
+	body, _, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return io.ReadAll(body)
+}
+
+// svgPolicy is the Content-Security-Policy of served drawings: opened on
+// their own they run nothing and load nothing but embedded images.
+const svgPolicy = "default-src 'none'; img-src data:; style-src 'unsafe-inline'; script-src 'none'; sandbox"
+
+// Serve answers a request with the file stored under key, honouring range
+// and conditional requests when the store allows seeking. Browsers are told
+// not to guess the type of the file.
+func Serve(ctx context.Context, s Storage, w http.ResponseWriter, r *http.Request, key string) error {
+	body, obj, err := s.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", obj.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if strings.HasPrefix(obj.ContentType, "image/svg+xml") {
+		w.Header().Set("Content-Security-Policy", svgPolicy)
+	}
+	if rs, ok := body.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, path.Base(obj.Key), obj.ModTime, rs)
+		return nil
+	}
+
+	if obj.Size >= 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(obj.Size))
+	}
+	if !obj.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", obj.ModTime.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return nil
+	}
+	_, err = io.Copy(w, body)
+
+	return err
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeHeaders(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+
+	tests := []struct {
+		key    string
+		policy bool
+	}{
+		{key: "company_info/logo.svg", policy: true},
+		{key: "company_info/logo.png", policy: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if err := PutBytes(ctx, s, tt.key, []byte("x"), ContentType(tt.key)); err != nil {
+				t.Fatalf("PutBytes: %v", err)
+			}
+			w := httptest.NewRecorder()
+			if err := Serve(ctx, s, w, httptest.NewRequest("GET", "/media/"+tt.key, nil), tt.key); err != nil {
+				t.Fatalf("Serve: %v", err)
+			}
+			if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+				t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+			}
+			if got := w.Header().Get("Content-Security-Policy"); (got == svgPolicy) != tt.policy {
+				t.Errorf("Content-Security-Policy = %q", got)
+			}
+		})
+	}
+}
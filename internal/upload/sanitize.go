@@ -0,0 +1,313 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"image"
+	_ "image/jpeg" // register the decoders DecodeConfig needs
+	_ "image/png"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// sanitize returns data with what must not be stored removed, and the
+// EXIF orientation of images, which may have at most maxPixels pixels.
+func sanitize(typ Type, data []byte, maxPixels int) ([]byte, int, error) {
+	switch typ.Name {
+	case PNG.Name, JPEG.Name:
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, 0, err
+		}
+		if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+			return nil, 0, errors.New("image dimensions out of range")
+		}
+		if typ.Name == PNG.Name {
+			data, err := stripPNG(data)
+			return data, 1, err
+		}
+		return stripJPEG(data)
+	case SVG.Name:
+		data, err := sanitizeSVG(data)
+		return data, 1, err
+	}
+
+	return data, 1, nil
+}
+
+// stripJPEG removes the EXIF, XMP and other application segments and the
+// comments of a JPEG image, keeping the JFIF header, the Adobe colour
+// transform and the ICC profile. The image data is copied untouched. The
+// orientation found in the EXIF segment is returned.
+func stripJPEG(data []byte) ([]byte, int, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	orientation := 1
+
+	for i := 2; ; {
+		if i+1 >= len(data) || data[i] != 0xFF {
+			return nil, 0, errors.New("malformed jpeg segment")
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte.
+			i++
+			continue
+		case marker == 0xDA:
+			// The scans follow the start of scan until the end.
+			return append(out, data[i:]...), orientation, nil
+		case marker == 0x01 || marker >= 0xD0 && marker <= 0xD7:
+			out = append(out, data[i:i+2]...)
+			i += 2
+			continue
+		}
+
+		if i+4 > len(data) {
+			return nil, 0, errors.New("truncated jpeg segment")
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end < i+4 || end > len(data) {
+			return nil, 0, errors.New("truncated jpeg segment")
+		}
+		segment := data[i:end]
+
+		keep := true
+		switch {
+		case marker == 0xFE:
+			keep = false
+		case marker == 0xE2:
+			keep = bytes.HasPrefix(segment[4:], []byte("ICC_PROFILE\x00"))
+		case marker >= 0xE1 && marker <= 0xEF:
+			keep = marker == 0xEE
+			if marker == 0xE1 && bytes.HasPrefix(segment[4:], exifHeader) {
+				orientation = exifOrientation(segment[4+len(exifHeader):])
+			}
+		}
+		if keep {
+			out = append(out, segment...)
+		}
+		i = end
+	}
+}
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// exifOrientation reads the orientation tag of the first image directory of
+// TIFF data, 1 when there is none.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	dir := int(order.Uint32(tiff[4:]))
+	if dir < 8 || dir+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[dir:]))
+	for k := 0; k < entries; k++ {
+		entry := dir + 2 + 12*k
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			break
+		}
+	}
+
+	return 1
+}
+
+// pngMetadata are the chunks stripPNG removes.
+var pngMetadata = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+// stripPNG removes the EXIF, text and time chunks of a PNG image.
+func stripPNG(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, pngMagic...)
+
+	for i := len(pngMagic); ; {
+		if i+12 > len(data) {
+			return nil, errors.New("truncated png chunk")
+		}
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if end < i || end > len(data) {
+			return nil, errors.New("truncated png chunk")
+		}
+		kind := string(data[i+4 : i+8])
+		if !pngMetadata[kind] {
+			out = append(out, data[i:end]...)
+		}
+		if kind == "IEND" {
+			return out, nil
+		}
+		i = end
+	}
+}
+
+// svgElements are the elements a drawing may hold, by lower-case name.
+// Anything else, animations included, is dropped with all it holds.
+var svgElements = setOf(
+	"svg", "g", "defs", "symbol", "use", "title", "desc", "style", "a", "switch",
+	"path", "rect", "circle", "ellipse", "line", "polyline", "polygon", "image",
+	"text", "tspan", "textpath",
+	"lineargradient", "radialgradient", "stop", "pattern", "clippath", "mask", "marker",
+	"filter", "feblend", "fecolormatrix", "fecomponenttransfer", "fecomposite",
+	"fedropshadow", "feflood", "fefunca", "fefuncb", "fefuncg", "fefuncr",
+	"fegaussianblur", "femerge", "femergenode", "femorphology", "feoffset", "fetile",
+)
+
+// svgAttributes are the attributes without a namespace prefix the elements
+// of a drawing may keep, by lower-case name.
+var svgAttributes = setOf(
+	"id", "class", "style", "lang", "version", "baseprofile",
+	"x", "y", "x1", "y1", "x2", "y2", "cx", "cy", "r", "rx", "ry", "fx", "fy", "fr",
+	"width", "height", "d", "points", "pathlength", "viewbox", "preserveaspectratio", "transform",
+	"fill", "fill-opacity", "fill-rule", "stroke", "stroke-width", "stroke-opacity",
+	"stroke-linecap", "stroke-linejoin", "stroke-miterlimit", "stroke-dasharray", "stroke-dashoffset",
+	"opacity", "color", "display", "visibility", "overflow", "clip-path", "clip-rule", "mask", "filter",
+	"marker-start", "marker-mid", "marker-end", "stop-color", "stop-opacity", "offset",
+	"paint-order", "vector-effect", "mix-blend-mode", "isolation",
+	"shape-rendering", "text-rendering", "image-rendering", "color-interpolation",
+	"font-family", "font-size", "font-weight", "font-style", "font-variant",
+	"text-anchor", "dominant-baseline", "alignment-baseline", "baseline-shift",
+	"letter-spacing", "word-spacing", "text-decoration", "writing-mode",
+	"dx", "dy", "rotate", "textlength", "lengthadjust", "startoffset",
+	"gradientunits", "gradienttransform", "spreadmethod",
+	"patternunits", "patterncontentunits", "patterntransform", "clippathunits",
+	"maskunits", "maskcontentunits", "markerunits", "markerwidth", "markerheight", "refx", "refy", "orient",
+	"filterunits", "primitiveunits", "in", "in2", "result", "stddeviation", "mode", "operator",
+	"k1", "k2", "k3", "k4", "type", "values", "tablevalues", "slope", "intercept", "amplitude", "exponent",
+	"flood-color", "flood-opacity", "color-interpolation-filters", "radius",
+)
+
+func setOf(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+
+	return set
+}
+
+// svgUnsafeValue matches attribute values and style sheets that run code or
+// load something from elsewhere.
+var svgUnsafeValue = regexp.MustCompile(`(?i)(java|vb)script:|data:text/html|expression\s*\(|@import|url\(\s*['"]?\s*[^#'"\s)]`)
+
+// svgLocalRef matches the links a drawing may keep: to its own elements and
+// to embedded raster images.
+var svgLocalRef = regexp.MustCompile(`^\s*(#|data:image/(png|jpeg|gif);base64,)`)
+
+// sanitizeSVG rewrites a drawing keeping only the elements and attributes it
+// knows to be inert: scripts, animations, event handlers, links to other
+// documents, comments, processing instructions and document types are gone.
+func sanitizeSVG(data []byte) ([]byte, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var out bytes.Buffer
+	out.WriteString(xml.Header)
+
+	skip := 0
+	var open []string
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 || !safeElement(t.Name) {
+				skip++
+				continue
+			}
+			out.WriteString("<" + qualified(t.Name))
+			for _, attr := range t.Attr {
+				if !safeAttr(attr) {
+					continue
+				}
+				out.WriteString(" " + qualified(attr.Name) + `="`)
+				if err := xml.EscapeText(&out, []byte(attr.Value)); err != nil {
+					return nil, err
+				}
+				out.WriteString(`"`)
+			}
+			out.WriteString(">")
+			open = append(open, strings.ToLower(t.Name.Local))
+
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if len(open) == 0 {
+				return nil, errors.New("unbalanced svg element")
+			}
+			open = open[:len(open)-1]
+			out.WriteString("</" + qualified(t.Name) + ">")
+
+		case xml.CharData:
+			if skip > 0 || len(open) == 0 {
+				continue
+			}
+			if open[len(open)-1] == "style" && svgUnsafeValue.Match(t) {
+				continue
+			}
+			if err := xml.EscapeText(&out, t); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(open) != 0 {
+		return nil, errors.New("unclosed svg element")
+	}
+
+	return out.Bytes(), nil
+}
+
+func safeElement(name xml.Name) bool {
+	return (name.Space == "" || name.Space == "svg") && svgElements[strings.ToLower(name.Local)]
+}
+
+func safeAttr(attr xml.Attr) bool {
+	space, name := strings.ToLower(attr.Name.Space), strings.ToLower(attr.Name.Local)
+	switch {
+	case space == "" && name == "xmlns", space == "xmlns":
+	case space == "xml":
+		if name != "space" && name != "lang" {
+			return false
+		}
+	case name == "href" && (space == "" || space == "xlink"):
+		return svgLocalRef.MatchString(attr.Value)
+	case space != "" || !svgAttributes[name]:
+		return false
+	}
+
+	return !svgUnsafeValue.MatchString(attr.Value)
+}
+
+func qualified(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}
@@ -0,0 +1,93 @@
+package upload
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"strings"
+)
+
+var (
+	pngMagic  = []byte("\x89PNG\r\n\x1a\n")
+	jpegMagic = []byte{0xFF, 0xD8, 0xFF}
+	zipMagic  = []byte("PK\x03\x04")
+	utf8BOM   = []byte("\xEF\xBB\xBF")
+)
+
+// sniff tells the type of a file from its content. Plain text is a JSON
+// Lines file when named so and made of objects, and a CSV file otherwise.
+func sniff(data []byte, ext string) (Type, bool) {
+	switch {
+	case bytes.HasPrefix(data, pngMagic):
+		return PNG, true
+	case bytes.HasPrefix(data, jpegMagic):
+		return JPEG, true
+	case bytes.HasPrefix(data, zipMagic):
+		if isWorkbook(data) {
+			return XLSX, true
+		}
+		return Type{Name: "zip"}, false
+	}
+
+	if !isText(data) {
+		return Type{}, false
+	}
+	text := bytes.TrimLeft(bytes.TrimPrefix(data, utf8BOM), " \t\r\n")
+	switch {
+	case bytes.HasPrefix(text, []byte("<")):
+		if isSVG(text) {
+			return SVG, true
+		}
+		return Type{Name: "xml"}, false
+	case JSONL.named(ext) && bytes.HasPrefix(text, []byte("{")):
+		return JSONL, true
+	}
+
+	return CSV, true
+}
+
+// isWorkbook reports whether a zip archive is an Office Open XML workbook.
+func isWorkbook(data []byte) bool {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return false
+	}
+
+	var types, workbook bool
+	for _, f := range archive.File {
+		switch f.Name {
+		case "[Content_Types].xml":
+			types = true
+		case "xl/workbook.xml":
+			workbook = true
+		}
+	}
+
+	return types && workbook
+}
+
+// isText reports whether data holds no control characters but tabs and
+// line breaks. It holds for UTF-8 and Shift_JIS text alike.
+func isText(data []byte) bool {
+	for _, b := range data {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' || b == 0x7F {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isSVG reports whether the root element of an XML document is svg.
+func isSVG(data []byte) bool {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.RawToken()
+		if err != nil {
+			return false
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return strings.EqualFold(start.Name.Local, "svg")
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Package upload checks the files users upload before they are read or
+// stored. The type of a file is told from its content, never from the
+// Content-Type the client sent; each purpose only takes the types and the
+// size it lists. Images lose their metadata, SVG drawings their scripts, and
+// files are stored under random names.
+package upload
+
+import (
+	"attendance/backend/foundation/i18n"
+	"attendance/backend/foundation/web"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+// Type is a kind of file the validator tells apart.
+type Type struct {
+	Name        string
+	ContentType string
+
+	// Extensions are those a file of the type may be named with, the first
+	// one is given to stored files.
+	Extensions []string
+}
+
+// Types.
+var (
+	PNG   = Type{Name: "png", ContentType: "image/png", Extensions: []string{".png"}}
+	JPEG  = Type{Name: "jpeg", ContentType: "image/jpeg", Extensions: []string{".jpg", ".jpeg"}}
+	SVG   = Type{Name: "svg", ContentType: "image/svg+xml", Extensions: []string{".svg"}}
+	XLSX  = Type{Name: "xlsx", ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Extensions: []string{".xlsx"}}
+	CSV   = Type{Name: "csv", ContentType: "text/csv", Extensions: []string{".csv"}}
+	JSONL = Type{Name: "jsonl", ContentType: "application/x-ndjson", Extensions: []string{".jsonl", ".ndjson"}}
+)
+
+// Purpose lists what may be uploaded for one use.
+type Purpose struct {
+	Name    string
+	Types   []Type
+	MaxSize int64
+
+	// MaxPixels bounds the size of images, so a small file cannot unpack
+	// into a huge one; maxPixels when zero.
+	MaxPixels int
+}
+
+// Purposes.
+var (
+	// Logo is the logo of the company.
+	Logo = Purpose{Name: "logo", Types: []Type{PNG, JPEG, SVG}, MaxSize: 2 << 20}
+
+	// Import is a file of rows to import. JSON Lines files are taken as
+	// well, as the imports read them.
+	Import = Purpose{Name: "import", Types: []Type{XLSX, CSV, JSONL}, MaxSize: 20 << 20}
+
+	// Photo is the profile photo of a user. Photos are decoded whole to be
+	// cropped, so they are held to what phone cameras take.
+	Photo = Purpose{Name: "photo", Types: []Type{PNG, JPEG}, MaxSize: 10 << 20, MaxPixels: 16_000_000}
+)
+
+// maxPixels is the default MaxPixels.
+const maxPixels = 40_000_000
+
+// File is an upload that passed the checks.
+type File struct {
+	// Name is a random name with the extension of the type.
+	Name string
+
+	// OriginalName is the base name the client gave.
+	OriginalName string
+
+	Type Type
+	Data []byte
+
+	// Orientation is the EXIF orientation, 1 to 8, the image had before
+	// its metadata was stripped; 1 when it had none.
+	Orientation int
+}
+
+// ErrMissing is returned when no file was uploaded.
+var ErrMissing = errors.New("upload: no file")
+
+// SizeError is returned for files larger than their purpose allows.
+type SizeError struct {
+	Purpose string
+	Size    int64
+	Limit   int64
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("upload: %s of %d bytes exceeds the limit of %d bytes", e.Purpose, e.Size, e.Limit)
+}
+
+// TypeError is returned for files of a type their purpose does not take,
+// or named with an extension that does not match their content. Type is
+// empty when the content was not recognised at all.
+type TypeError struct {
+	Purpose string
+	Type    string
+	Name    string
+	Allowed []string
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("upload: %s %q of type %q, allowed: %s", e.Purpose, e.Name, e.Type, strings.Join(e.Allowed, ", "))
+}
+
+// ContentError is returned for files of an allowed type that are damaged
+// or cannot be made safe.
+type ContentError struct {
+	Type string
+	Err  error
+}
+
+func (e *ContentError) Error() string {
+	return fmt.Sprintf("upload: invalid %s: %v", e.Type, e.Err)
+}
+
+func (e *ContentError) Unwrap() error {
+	return e.Err
+}
+
+// Read reads and checks an uploaded file.
+func Read(purpose Purpose, header *multipart.FileHeader) (File, error) {
+	if header == nil {
+		return File{}, ErrMissing
+	}
+	if header.Size > purpose.MaxSize {
+		return File{}, &SizeError{Purpose: purpose.Name, Size: header.Size, Limit: purpose.MaxSize}
+	}
+
+	f, err := header.Open()
+	if err != nil {
+		return File{}, err
+	}
+	defer f.Close()
+
+	// The header may lie about the size; never read more than the limit.
+	data, err := io.ReadAll(io.LimitReader(f, purpose.MaxSize+1))
+	if err != nil {
+		return File{}, err
+	}
+
+	return Check(purpose, header.Filename, data)
+}
+
+// Check checks a file that was read already.
+func Check(purpose Purpose, name string, data []byte) (File, error) {
+	if int64(len(data)) > purpose.MaxSize {
+		return File{}, &SizeError{Purpose: purpose.Name, Size: int64(len(data)), Limit: purpose.MaxSize}
+	}
+	if len(data) == 0 {
+		return File{}, ErrMissing
+	}
+
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	ext := strings.ToLower(filepath.Ext(name))
+
+	typ, ok := sniff(data, ext)
+	allowed := make([]string, 0, len(purpose.Types))
+	for _, t := range purpose.Types {
+		allowed = append(allowed, t.Name)
+	}
+	typeErr := &TypeError{Purpose: purpose.Name, Type: typ.Name, Name: name, Allowed: allowed}
+	if !ok || !purpose.takes(typ) {
+		return File{}, typeErr
+	}
+	if !typ.named(ext) {
+		return File{}, typeErr
+	}
+
+	data, orientation, err := sanitize(typ, data, purpose.maxPixels())
+	if err != nil {
+		return File{}, &ContentError{Type: typ.Name, Err: err}
+	}
+
+	random, err := randomName()
+	if err != nil {
+		return File{}, err
+	}
+
+	return File{
+		Name:         random + typ.Extensions[0],
+		OriginalName: name,
+		Type:         typ,
+		Data:         data,
+		Orientation:  orientation,
+	}, nil
+}
+
+func (p Purpose) maxPixels() int {
+	if p.MaxPixels > 0 {
+		return p.MaxPixels
+	}
+
+	return maxPixels
+}
+
+func (p Purpose) takes(typ Type) bool {
+	for _, t := range p.Types {
+		if t.Name == typ.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t Type) named(ext string) bool {
+	for _, e := range t.Extensions {
+		if e == ext {
+			return true
+		}
+	}
+
+	return false
+}
+
+// randomName returns 32 random hex digits.
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// RequestError turns an error of Read or Check into the error the request
+// fails with.
+func RequestError(err error) error {
+	var (
+		sizeErr    *SizeError
+		typeErr    *TypeError
+		contentErr *ContentError
+	)
+	switch {
+	case errors.Is(err, ErrMissing):
+		return web.NewCodeError(i18n.CodeUploadMissing, http.StatusBadRequest)
+	case errors.As(err, &sizeErr):
+		return web.NewCodeError(i18n.CodeUploadTooLarge, http.StatusRequestEntityTooLarge, formatSize(sizeErr.Limit))
+	case errors.As(err, &typeErr):
+		return web.NewCodeError(i18n.CodeUploadType, http.StatusUnsupportedMediaType, strings.Join(typeErr.Allowed, ", "))
+	case errors.As(err, &contentErr):
+		return web.NewCodeError(i18n.CodeUploadInvalid, http.StatusBadRequest)
+	}
+
+	return web.NewRequestError(pkgerrors.Wrap(err, "reading upload"), http.StatusInternalServerError)
+}
+
+// formatSize writes a size in whole megabytes or kilobytes.
+func formatSize(n int64) string {
+	if n >= 1<<20 && n%(1<<20) == 0 {
+		return fmt.Sprintf("%d MB", n>>20)
+	}
+
+	return fmt.Sprintf("%d KB", (n+1<<10-1)>>10)
+}
@@ -0,0 +1,320 @@
+package upload
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func testPNG(t *testing.T, chunks ...[]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// The chunks go right after IHDR, which is 25 bytes long.
+	at := len(pngMagic) + 25
+	out := append([]byte{}, data[:at]...)
+	for _, c := range chunks {
+		out = append(out, c...)
+	}
+
+	return append(out, data[at:]...)
+}
+
+func pngChunk(kind string, body []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(body)))
+	chunk = append(chunk, kind...)
+	chunk = append(chunk, body...)
+
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// hugePNG is a PNG image whose header claims width by height pixels.
+func hugePNG(t *testing.T, width, height uint32) []byte {
+	t.Helper()
+	data := testPNG(t)
+	ihdr := data[len(pngMagic) : len(pngMagic)+25]
+	binary.BigEndian.PutUint32(ihdr[8:], width)
+	binary.BigEndian.PutUint32(ihdr[12:], height)
+	binary.BigEndian.PutUint32(ihdr[21:], crc32.ChecksumIEEE(ihdr[4:21]))
+
+	return data
+}
+
+func testJPEG(t *testing.T, segments ...[]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	out := append([]byte{}, data[:2]...)
+	for _, s := range segments {
+		out = append(out, s...)
+	}
+
+	return append(out, data[2:]...)
+}
+
+func jpegSegment(marker byte, body []byte) []byte {
+	segment := []byte{0xFF, marker}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(body)+2))
+
+	return append(segment, body...)
+}
+
+// exif is an EXIF segment body holding the orientation o.
+func exif(order binary.AppendByteOrder, o uint16) []byte {
+	tiff := []byte("II*\x00")
+	if order == binary.BigEndian {
+		tiff = []byte("MM\x00*")
+	}
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, 1)
+	tiff = order.AppendUint16(tiff, 0x0112)
+	tiff = order.AppendUint16(tiff, 3)
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = order.AppendUint16(tiff, o)
+	tiff = order.AppendUint16(tiff, 0)
+	tiff = order.AppendUint32(tiff, 0)
+
+	return append(append([]byte{}, exifHeader...), tiff...)
+}
+
+func testZip(t *testing.T, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range names {
+		if _, err := w.Create(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestCheck(t *testing.T) {
+	pngData := testPNG(t)
+	workbook := testZip(t, "[Content_Types].xml", "xl/workbook.xml")
+
+	tests := []struct {
+		name     string
+		purpose  Purpose
+		file     string
+		data     []byte
+		typ      string
+		original string
+		err      interface{}
+	}{
+		{name: "png logo", purpose: Logo, file: "logo.PNG", data: pngData, typ: "png", original: "logo.PNG"},
+		{name: "path dropped", purpose: Logo, file: `C:\fakepath\..\logo.png`, data: pngData, typ: "png", original: "logo.png"},
+		{name: "jpeg photo", purpose: Photo, file: "me.jpeg", data: testJPEG(t), typ: "jpeg", original: "me.jpeg"},
+		{name: "svg logo", purpose: Logo, file: "logo.svg", data: []byte("<?xml version=\"1.0\"?>\n<svg></svg>"), typ: "svg", original: "logo.svg"},
+		{name: "workbook", purpose: Import, file: "users.xlsx", data: workbook, typ: "xlsx", original: "users.xlsx"},
+		{name: "csv", purpose: Import, file: "users.csv", data: []byte("\xef\xbb\xbfid,name\n1,a\n"), typ: "csv", original: "users.csv"},
+		{name: "jsonl", purpose: Import, file: "users.jsonl", data: []byte("{\"id\":1}\n"), typ: "jsonl", original: "users.jsonl"},
+		{name: "objects in a csv", purpose: Import, file: "users.csv", data: []byte("{\"id\":1}\n"), typ: "csv", original: "users.csv"},
+		{name: "png named jpeg", purpose: Logo, file: "logo.jpg", data: pngData, err: new(*TypeError)},
+		{name: "svg photo", purpose: Photo, file: "me.svg", data: []byte("<svg/>"), err: new(*TypeError)},
+		{name: "html named svg", purpose: Logo, file: "logo.svg", data: []byte("<html></html>"), err: new(*TypeError)},
+		{name: "plain zip", purpose: Import, file: "users.xlsx", data: testZip(t, "a.txt"), err: new(*TypeError)},
+		{name: "binary", purpose: Import, file: "users.csv", data: []byte("a\x00b"), err: new(*TypeError)},
+		{name: "too large", purpose: Purpose{Name: "small", Types: []Type{PNG}, MaxSize: 10}, file: "a.png", data: pngData, err: new(*SizeError)},
+		{name: "empty", purpose: Logo, file: "logo.png", err: ErrMissing},
+		{name: "damaged png", purpose: Logo, file: "logo.png", data: pngData[:20], err: new(*ContentError)},
+		{name: "damaged svg", purpose: Logo, file: "logo.svg", data: []byte("<svg><g></svg>"), err: new(*ContentError)},
+		{name: "huge photo", purpose: Photo, file: "me.png", data: hugePNG(t, 5000, 4000), err: new(*ContentError)},
+		{name: "large logo", purpose: Logo, file: "logo.png", data: hugePNG(t, 5000, 4000), typ: "png", original: "logo.png"},
+		{name: "huge logo", purpose: Logo, file: "logo.png", data: hugePNG(t, 10000, 5000), err: new(*ContentError)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Check(tt.purpose, tt.file, tt.data)
+			switch want := tt.err.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("Check: %v", err)
+				}
+			case error:
+				if !errors.Is(err, want) {
+					t.Fatalf("got %v, want %v", err, want)
+				}
+				return
+			default:
+				if !errors.As(err, tt.err) {
+					t.Fatalf("got %v, want a %T", err, tt.err)
+				}
+				return
+			}
+
+			if file.Type.Name != tt.typ || file.OriginalName != tt.original {
+				t.Errorf("got type %q named %q, want %q named %q", file.Type.Name, file.OriginalName, tt.typ, tt.original)
+			}
+			if !strings.HasSuffix(file.Name, file.Type.Extensions[0]) || strings.TrimSuffix(file.Name, file.Type.Extensions[0]) == "" {
+				t.Errorf("stored name %q", file.Name)
+			}
+		})
+	}
+}
+
+func TestCheckStripsMetadata(t *testing.T) {
+	tests := []struct {
+		name        string
+		purpose     Purpose
+		file        string
+		data        []byte
+		orientation int
+		gone        []string
+	}{
+		{
+			name:        "jpeg exif little endian",
+			purpose:     Photo,
+			file:        "me.jpg",
+			data:        testJPEG(t, jpegSegment(0xE1, exif(binary.LittleEndian, 6)), jpegSegment(0xFE, []byte("secret comment"))),
+			orientation: 6,
+			gone:        []string{"Exif", "secret comment"},
+		},
+		{
+			name:        "jpeg exif big endian",
+			purpose:     Photo,
+			file:        "me.jpg",
+			data:        testJPEG(t, jpegSegment(0xE1, exif(binary.BigEndian, 8))),
+			orientation: 8,
+			gone:        []string{"Exif"},
+		},
+		{
+			name:        "jpeg orientation out of range",
+			purpose:     Photo,
+			file:        "me.jpg",
+			data:        testJPEG(t, jpegSegment(0xE1, exif(binary.LittleEndian, 9))),
+			orientation: 1,
+			gone:        []string{"Exif"},
+		},
+		{
+			name:        "jpeg xmp",
+			purpose:     Photo,
+			file:        "me.jpg",
+			data:        testJPEG(t, jpegSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<gps/>"))),
+			orientation: 1,
+			gone:        []string{"<gps/>"},
+		},
+		{
+			name:        "png text",
+			purpose:     Logo,
+			file:        "logo.png",
+			data:        testPNG(t, pngChunk("tEXt", []byte("Author\x00someone")), pngChunk("eXIf", []byte("MM\x00*"))),
+			orientation: 1,
+			gone:        []string{"someone", "eXIf"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Check(tt.purpose, tt.file, tt.data)
+			if err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			if file.Orientation != tt.orientation {
+				t.Errorf("orientation %d, want %d", file.Orientation, tt.orientation)
+			}
+			for _, s := range tt.gone {
+				if bytes.Contains(file.Data, []byte(s)) {
+					t.Errorf("%q was kept", s)
+				}
+			}
+			if _, _, err := image.Decode(bytes.NewReader(file.Data)); err != nil {
+				t.Errorf("decoding the stripped image: %v", err)
+			}
+		})
+	}
+}
+
+func TestSanitizeSVG(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		kept []string
+		gone []string
+	}{
+		{
+			name: "script",
+			in:   `<svg><script>alert(1)</script><circle r="1"/></svg>`,
+			kept: []string{"<circle", `r="1"`},
+			gone: []string{"script", "alert"},
+		},
+		{
+			name: "handlers",
+			in:   `<svg onload="alert(1)"><rect OnClick="x()" width="2"/></svg>`,
+			kept: []string{`width="2"`},
+			gone: []string{"onload", "OnClick", "alert"},
+		},
+		{
+			name: "links",
+			in:   `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><a xlink:href="javascript:alert(1)"><use href="#a"/></a><image href="https://evil.example/x.png"/><image href="data:image/png;base64,AAAA"/></svg>`,
+			kept: []string{`href="#a"`, `href="data:image/png;base64,AAAA"`},
+			gone: []string{"javascript", "evil.example"},
+		},
+		{
+			name: "foreign content",
+			in:   `<svg><foreignObject><iframe src="x"></iframe><p>hi</p></foreignObject><g/></svg>`,
+			kept: []string{"<g>"},
+			gone: []string{"iframe", "foreignObject", "hi"},
+		},
+		{
+			name: "styles",
+			in:   `<svg><style>@import url(https://evil.example/a.css);</style><style>.a{fill:red}</style><rect style="fill:url(#g)"/><rect style="background:url(https://evil.example/b)"/></svg>`,
+			kept: []string{".a{fill:red}", `style="fill:url(#g)"`},
+			gone: []string{"evil.example"},
+		},
+		{
+			name: "animations",
+			in:   `<svg><a><animate attributeName="href" values="javascript:alert(1)"/><set attributeName="onclick" to="alert(1)"/><animateTransform attributeName="transform"/><text>go</text></a><rect width="1"><set attributeName="onmouseover" to="x()"/></rect></svg>`,
+			kept: []string{"<text>go</text>", `width="1"`},
+			gone: []string{"animate", "set", "attributeName", "javascript", "alert", "onmouseover"},
+		},
+		{
+			name: "unknown",
+			in:   `<svg xmlns="http://www.w3.org/2000/svg" xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape" inkscape:version="1" xml:space="preserve"><inkscape:grid/><video src="x.mp4"><circle r="2"/></video><metadata>rdf</metadata><rect width="3" formaction="x"/></svg>`,
+			kept: []string{`xmlns="http://www.w3.org/2000/svg"`, `xml:space="preserve"`, `width="3"`},
+			gone: []string{"inkscape:version", "grid", "video", "r=\"2\"", "rdf", "formaction"},
+		},
+		{
+			name: "doctype and comments",
+			in:   `<?xml version="1.0"?><!DOCTYPE svg [<!ENTITY x "y">]><!-- note --><svg><?php echo 1 ?><text>a &lt; b</text></svg>`,
+			kept: []string{"<text>a &lt; b</text>"},
+			gone: []string{"DOCTYPE", "ENTITY", "note", "php"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := sanitizeSVG([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("sanitizeSVG: %v", err)
+			}
+			for _, s := range tt.kept {
+				if !bytes.Contains(out, []byte(s)) {
+					t.Errorf("%q was dropped from %s", s, out)
+				}
+			}
+			for _, s := range tt.gone {
+				if bytes.Contains(out, []byte(s)) {
+					t.Errorf("%q was kept in %s", s, out)
+				}
+			}
+		})
+	}
+}
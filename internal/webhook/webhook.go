@@ -0,0 +1,287 @@
+// Package webhook delivers the queued webhook events to their subscribers.
+// Deliveries are queued in the webhook_delivery table, by database triggers
+// or by the code causing the event, and a Dispatcher on every instance claims
+// and sends the ones that are due. A failed attempt is retried with
+// exponential backoff until the attempt budget is spent.
+//
+// Every request is signed: X-Webhook-Signature is "sha256=" followed by the
+// hex encoded HMAC-SHA256, keyed with the subscription secret, of the
+// X-Webhook-Timestamp header value, a dot and the request body.
+package webhook
+
+import (
+	webhook_postgres "attendance/backend/internal/repository/postgres/webhook"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Request headers.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// maxResponseBody is the number of response bytes kept in the delivery log.
+const maxResponseBody = 2 << 10
+
+// Store is the delivery queue.
+type Store interface {
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]webhook_postgres.Delivery, error)
+	Complete(ctx context.Context, id int64, result webhook_postgres.DeliveryResult) error
+}
+
+// Config tunes the dispatcher.
+type Config struct {
+	// Workers is the number of deliveries sent concurrently.
+	Workers int
+
+	// PollInterval is how often the queue is checked for due deliveries.
+	PollInterval time.Duration
+
+	// Timeout bounds one request to a subscriber.
+	Timeout time.Duration
+
+	// MaxAttempts is the attempt budget of a delivery.
+	MaxAttempts int
+
+	// BaseBackoff is the delay after the first failed attempt. It doubles
+	// with every further failure up to MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// DefaultConfig returns the configuration used for zero values.
+func DefaultConfig() Config {
+	return Config{
+		Workers:      4,
+		PollInterval: 2 * time.Second,
+		Timeout:      10 * time.Second,
+		MaxAttempts:  8,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   6 * time.Hour,
+	}
+}
+
+// Envelope is the body of every delivery.
+type Envelope struct {
+	ID        string          `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Attempt   int             `json:"attempt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Dispatcher sends due deliveries.
+type Dispatcher struct {
+	cfg    Config
+	store  Store
+	client *http.Client
+}
+
+// NewDispatcher constructs a dispatcher. Zero values in cfg are taken from
+// DefaultConfig.
+func NewDispatcher(cfg Config, store Store) *Dispatcher {
+	def := DefaultConfig()
+	if cfg.Workers <= 0 {
+		cfg.Workers = def.Workers
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = def.PollInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = def.Timeout
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = def.MaxAttempts
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = def.BaseBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = def.MaxBackoff
+	}
+
+	return &Dispatcher{
+		cfg:   cfg,
+		store: store,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// Subscribers must answer themselves, a redirect is a failure.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Run sends deliveries until ctx is done. Deliveries in flight are finished
+// before it returns.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// A full batch means more may be due already.
+		if d.poll(ctx) == d.cfg.Workers {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll sends one batch and returns its size.
+func (d *Dispatcher) poll(ctx context.Context) int {
+	if ctx.Err() != nil {
+		return 0
+	}
+
+	// A claimed delivery is retried by anyone once the lease ran out, so it
+	// must outlast the request.
+	deliveries, err := d.store.ClaimDue(ctx, d.cfg.Workers, 2*d.cfg.Timeout+time.Minute)
+	if err != nil {
+		slog.Error("webhook: claiming deliveries", "error", err)
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery webhook_postgres.Delivery) {
+			defer wg.Done()
+
+			result := d.send(ctx, delivery)
+
+			// Record the outcome even when shutting down.
+			rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+			defer cancel()
+			if err := d.store.Complete(rctx, delivery.ID, result); err != nil {
+				slog.Error("webhook: recording delivery", "error", err, "delivery_id", delivery.ID)
+			}
+		}(delivery)
+	}
+	wg.Wait()
+
+	return len(deliveries)
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery webhook_postgres.Delivery) webhook_postgres.DeliveryResult {
+	start := time.Now()
+	result := d.attempt(ctx, delivery)
+	result.Duration = time.Since(start)
+
+	switch {
+	case result.Status == webhook_postgres.StatusSucceeded:
+		slog.Info("webhook: delivered", "delivery_id", delivery.ID, "event", delivery.Event, "url", delivery.URL, "status", result.ResponseStatus)
+
+	case delivery.Attempts >= d.cfg.MaxAttempts:
+		result.Status = webhook_postgres.StatusFailed
+		slog.Warn("webhook: delivery failed, giving up", "delivery_id", delivery.ID, "event", delivery.Event, "url", delivery.URL, "attempts", delivery.Attempts, "error", result.Error)
+
+	default:
+		result.Status = webhook_postgres.StatusPending
+		next := time.Now().Add(d.backoff(delivery.Attempts))
+		result.NextAttemptAt = &next
+		slog.Info("webhook: delivery failed, retrying", "delivery_id", delivery.ID, "event", delivery.Event, "url", delivery.URL, "attempts", delivery.Attempts, "next_attempt_at", next, "error", result.Error)
+	}
+
+	return result
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, delivery webhook_postgres.Delivery) webhook_postgres.DeliveryResult {
+	id := strconv.FormatInt(delivery.ID, 10)
+
+	body, err := json.Marshal(Envelope{
+		ID:        id,
+		Event:     delivery.Event,
+		CreatedAt: delivery.CreatedAt,
+		Attempt:   delivery.Attempts,
+		Data:      delivery.Payload,
+	})
+	if err != nil {
+		return webhook_postgres.DeliveryResult{Error: err.Error()}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return webhook_postgres.DeliveryResult{Error: err.Error()}
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "attendance-webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, id)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return webhook_postgres.DeliveryResult{Error: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	result := webhook_postgres.DeliveryResult{
+		ResponseStatus: resp.StatusCode,
+		ResponseBody:   string(b),
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		result.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+		return result
+	}
+
+	result.Status = webhook_postgres.StatusSucceeded
+
+	return result
+}
+
+// backoff returns the delay after the given number of failed attempts, with
+// up to 10% jitter so retries of a burst spread out.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.BaseBackoff
+	for i := 1; i < attempts && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.cfg.MaxBackoff {
+		delay = d.cfg.MaxBackoff
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(delay)/10+1))
+}
+
+// Sign returns the X-Webhook-Signature value of body.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of body. Receivers
+// written in Go can use it, and should also reject stale timestamps.
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}